@@ -0,0 +1,177 @@
+// Package backup seals and opens passphrase-encrypted archives
+// (scrypt key derivation + AES-256-GCM).
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// Format identifies gog backup archives.
+	Format  = "gog-backup"
+	Version = 1
+
+	kdfScrypt    = "scrypt"
+	cipherAESGCM = "aes-256-gcm"
+
+	defaultScryptN = 1 << 15
+	defaultScryptR = 8
+	defaultScryptP = 1
+	// maxScryptN, maxScryptR, maxScryptP, and maxScryptMemory bound the work
+	// factors accepted from an archive so a crafted file cannot make restore
+	// allocate unbounded memory (scrypt needs about 128*N*r bytes) or spin
+	// before the passphrase is even checked.
+	maxScryptN      = 1 << 20
+	maxScryptR      = 32
+	maxScryptP      = 16
+	maxScryptMemory = 1 << 30
+
+	keyLen  = 32
+	saltLen = 16
+)
+
+var (
+	ErrWrongPassphrase = errors.New("wrong passphrase or corrupted archive")
+	errNotBackup       = errors.New("not a gog backup archive")
+	errEmptyPassphrase = errors.New("empty passphrase")
+	errScryptParams    = errors.New("invalid scrypt parameters")
+)
+
+type kdfParams struct {
+	Name string `json:"name"`
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+type envelope struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	KDF        kdfParams `json:"kdf"`
+	Cipher     string    `json:"cipher"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// Seal encrypts plaintext with a key derived from passphrase.
+func Seal(plaintext []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errEmptyPassphrase
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("salt: %w", err)
+	}
+
+	params := kdfParams{Name: kdfScrypt, Salt: salt, N: defaultScryptN, R: defaultScryptR, P: defaultScryptP}
+
+	aead, err := newAEAD(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce: %w", err)
+	}
+
+	env := envelope{
+		Format:  Format,
+		Version: Version,
+		KDF:     params,
+		Cipher:  cipherAESGCM,
+		Nonce:   nonce,
+	}
+	env.Ciphertext = aead.Seal(nil, nonce, plaintext, associatedData(env))
+
+	data, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal archive: %w", err)
+	}
+
+	return append(data, '\n'), nil
+}
+
+// Open decrypts an archive produced by Seal.
+func Open(data []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errEmptyPassphrase
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != Format {
+		return nil, errNotBackup
+	}
+
+	if env.Version != Version {
+		return nil, fmt.Errorf("unsupported backup version %d", env.Version)
+	}
+
+	if env.KDF.Name != kdfScrypt || env.Cipher != cipherAESGCM {
+		return nil, fmt.Errorf("unsupported backup encryption %s/%s", env.KDF.Name, env.Cipher)
+	}
+
+	if !validScryptParams(env.KDF) {
+		return nil, fmt.Errorf("%w n=%d r=%d p=%d", errScryptParams, env.KDF.N, env.KDF.R, env.KDF.P)
+	}
+
+	aead, err := newAEAD(passphrase, env.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, errNotBackup
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, associatedData(env))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plaintext, nil
+}
+
+// validScryptParams reports whether an archive's scrypt parameters are
+// within the bounds restore is willing to spend.
+func validScryptParams(params kdfParams) bool {
+	if params.N <= 1 || params.N > maxScryptN || params.R <= 0 || params.R > maxScryptR || params.P <= 0 || params.P > maxScryptP {
+		return false
+	}
+
+	return 128*int64(params.N)*int64(params.R) <= maxScryptMemory
+}
+
+func newAEAD(passphrase string, params kdfParams) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), params.Salt, params.N, params.R, params.P, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("new cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("new gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// associatedData binds the header fields to the ciphertext so they cannot be
+// swapped without failing authentication.
+func associatedData(env envelope) []byte {
+	return []byte(fmt.Sprintf("%s/%d/%s/%d/%d/%d/%s", env.Format, env.Version, env.KDF.Name, env.KDF.N, env.KDF.R, env.KDF.P, env.Cipher))
+}
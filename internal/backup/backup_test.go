@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	sealed, err := Seal([]byte(`{"secret":"value"}`), "correct horse")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if bytes.Contains(sealed, []byte("value")) {
+		t.Fatalf("plaintext leaked into archive")
+	}
+
+	got, err := Open(sealed, "correct horse")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if string(got) != `{"secret":"value"}` {
+		t.Fatalf("unexpected plaintext: %q", got)
+	}
+}
+
+func TestOpenWrongPassphrase(t *testing.T) {
+	sealed, err := Seal([]byte("data"), "one")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if _, err := Open(sealed, "two"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestOpenRejectsTamperedHeader(t *testing.T) {
+	sealed, err := Seal([]byte("data"), "pw")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	env.KDF.P = 2
+	tampered, _ := json.Marshal(env)
+
+	if _, err := Open(tampered, "pw"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected auth failure, got %v", err)
+	}
+
+	env.KDF.N = 1 << 30
+	tampered, _ = json.Marshal(env)
+
+	if _, err := Open(tampered, "pw"); err == nil {
+		t.Fatalf("expected scrypt parameter rejection")
+	}
+}
+
+func TestOpenRejectsExpensiveScryptParams(t *testing.T) {
+	sealed, err := Seal([]byte("data"), "pw")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	for _, params := range []struct{ n, r, p int }{
+		{1 << 15, 1 << 20, 1}, // ~4 TiB of memory via r alone
+		{1 << 15, 64, 1},
+		{1 << 15, 8, 1 << 20},
+		{1 << 20, 32, 1}, // each bound on its own, 4 GiB together
+		{1 << 15, 0, 1},
+	} {
+		var env envelope
+		if err := json.Unmarshal(sealed, &env); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		env.KDF.N, env.KDF.R, env.KDF.P = params.n, params.r, params.p
+		tampered, _ := json.Marshal(env)
+
+		if _, err := Open(tampered, "pw"); !errors.Is(err, errScryptParams) {
+			t.Fatalf("n=%d r=%d p=%d: expected errScryptParams, got %v", params.n, params.r, params.p, err)
+		}
+	}
+}
+
+func TestOpenNotBackup(t *testing.T) {
+	if _, err := Open([]byte(`{"email":"a@b.com"}`), "pw"); !errors.Is(err, errNotBackup) {
+		t.Fatalf("expected errNotBackup, got %v", err)
+	}
+
+	if _, err := Seal([]byte("x"), ""); !errors.Is(err, errEmptyPassphrase) {
+		t.Fatalf("expected errEmptyPassphrase, got %v", err)
+	}
+}
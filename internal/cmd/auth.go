@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -11,8 +12,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/99designs/keyring"
+
 	"github.com/steipete/gogcli/internal/authclient"
 	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/googleapi"
 	"github.com/steipete/gogcli/internal/googleauth"
 	"github.com/steipete/gogcli/internal/outfmt"
 	"github.com/steipete/gogcli/internal/secrets"
@@ -27,6 +31,8 @@ var (
 	ensureKeychainAccess = secrets.EnsureKeychainAccess
 	fetchAuthorizedEmail = googleauth.EmailForRefreshToken
 	manualAuthURL        = googleauth.ManualAuthURL
+
+	findApplicationDefaultCredentials = googleapi.FindApplicationDefaultCredentials
 )
 
 func ensureKeychainAccessIfNeeded() error {
@@ -34,7 +40,7 @@ func ensureKeychainAccessIfNeeded() error {
 	if err != nil {
 		return fmt.Errorf("resolve keyring backend: %w", err)
 	}
-	if backendInfo.Value == strFile {
+	if backendInfo.Value == strFile || secrets.IsHelperBackend(backendInfo.Value) {
 		return nil
 	}
 	return ensureKeychainAccess()
@@ -63,6 +69,9 @@ type AuthCmd struct {
 	Manage      AuthManageCmd         `cmd:"" name:"manage" help:"Open accounts manager in browser" aliases:"login"`
 	ServiceAcct AuthServiceAccountCmd `cmd:"" name:"service-account" help:"Configure service account (Workspace only; domain-wide delegation)"`
 	Keep        AuthKeepCmd           `cmd:"" name:"keep" help:"Configure service account for Google Keep (Workspace only)"`
+	Doctor      AuthDoctorCmd         `cmd:"" name:"doctor" help:"Diagnose credentials, keyring, tokens, scopes, and API access"`
+	Backup      AuthBackupCmd         `cmd:"" name:"backup" help:"Export all tokens, clients, aliases, and service accounts to an encrypted archive"`
+	Restore     AuthRestoreCmd        `cmd:"" name:"restore" help:"Restore an encrypted archive created by auth backup"`
 }
 
 type AuthCredentialsCmd struct {
@@ -695,6 +704,8 @@ func (c *AuthStatusCmd) Run(ctx context.Context, flags *RootFlags) error {
 	client := ""
 	credentialsPath := ""
 	credentialsExists := false
+	source := googleapi.CredentialSource{}
+	adcError := ""
 
 	if flags != nil {
 		if a, err := requireAccount(flags); err == nil {
@@ -720,6 +731,7 @@ func (c *AuthStatusCmd) Run(ctx context.Context, flags *RootFlags) error {
 			} else {
 				authPreferred = authTypeOAuth
 			}
+			source, adcError = resolveCredentialSource(ctx, account, client, serviceAccountConfigured, credentialsExists)
 		}
 	}
 
@@ -741,6 +753,14 @@ func (c *AuthStatusCmd) Run(ctx context.Context, flags *RootFlags) error {
 				"auth_preferred":             authPreferred,
 				"service_account_configured": serviceAccountConfigured,
 				"service_account_path":       serviceAccountPath,
+				"credential_source":          source.Kind,
+				"adc": map[string]any{
+					"type":        source.Type,
+					"origin":      source.Origin,
+					"path":        source.Path,
+					"impersonate": source.Impersonate,
+					"error":       adcError,
+				},
 			},
 		})
 	}
@@ -760,10 +780,53 @@ func (c *AuthStatusCmd) Run(ctx context.Context, flags *RootFlags) error {
 		if serviceAccountPath != "" {
 			u.Out().Printf("service_account_path\t%s", serviceAccountPath)
 		}
+		u.Out().Printf("credential_source\t%s", source.Kind)
+		if source.Kind == googleapi.CredentialSourceADC {
+			u.Out().Printf("adc_type\t%s", source.Type)
+			u.Out().Printf("adc_origin\t%s", source.Origin)
+			if source.Path != "" {
+				u.Out().Printf("adc_path\t%s", source.Path)
+			}
+			if source.Impersonate != "" {
+				u.Out().Printf("impersonate_service_account\t%s", source.Impersonate)
+			}
+		}
+		if adcError != "" {
+			u.Out().Printf("adc_error\t%s", adcError)
+		}
 	}
 	return nil
 }
 
+// resolveCredentialSource mirrors the order googleapi uses to pick credentials:
+// a stored service account key, then the OAuth refresh token, then application
+// default credentials. It never exchanges tokens.
+func resolveCredentialSource(ctx context.Context, account string, client string, serviceAccountConfigured bool, credentialsExists bool) (googleapi.CredentialSource, string) {
+	if serviceAccountConfigured {
+		return googleapi.CredentialSource{Kind: googleapi.CredentialSourceServiceAccount}, ""
+	}
+
+	if credentialsExists {
+		store, err := openSecretsStore()
+		if err != nil {
+			// Can't tell without the keyring; the OAuth path surfaces the real error.
+			return googleapi.CredentialSource{Kind: googleapi.CredentialSourceOAuth}, ""
+		}
+		if _, err := store.GetToken(client, account); err == nil || !errors.Is(err, keyring.ErrKeyNotFound) {
+			return googleapi.CredentialSource{Kind: googleapi.CredentialSourceOAuth}, ""
+		}
+	}
+
+	source, ok, err := findApplicationDefaultCredentials(ctx)
+	if err != nil {
+		return googleapi.CredentialSource{Kind: googleapi.CredentialSourceNone}, err.Error()
+	}
+	if !ok {
+		return googleapi.CredentialSource{Kind: googleapi.CredentialSourceNone}, ""
+	}
+	return source, ""
+}
+
 func (c *AuthListCmd) Run(ctx context.Context, _ *RootFlags) error {
 	u := ui.FromContext(ctx)
 	store, err := openSecretsStore()
@@ -0,0 +1,621 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/99designs/keyring"
+	"golang.org/x/term"
+
+	"github.com/steipete/gogcli/internal/backup"
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/secrets"
+	"github.com/steipete/gogcli/internal/tracking"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	backupPassphraseEnv     = "GOG_BACKUP_PASSPHRASE" //nolint:gosec // env var name, not a credential
+	backupMinPassphraseLen  = 8
+	authBackupPayloadFormat = 1
+
+	backupKindServiceAccount = "service_account"
+	backupKindKeep           = "keep"
+
+	restoreConflictFail      = "fail"
+	restoreConflictSkip      = "skip"
+	restoreConflictOverwrite = "overwrite"
+)
+
+var (
+	backupPassphraseIsTTY = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+	readBackupPassphrase  = func(prompt string) (string, error) {
+		_, _ = fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		_, _ = fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+)
+
+type AuthBackupCmd struct {
+	Output         OutputPathRequiredFlag `embed:""`
+	Overwrite      bool                   `name:"overwrite" help:"Overwrite output file if it exists"`
+	PassphraseFile string                 `name:"passphrase-file" help:"Read the archive passphrase from a file (default: $GOG_BACKUP_PASSPHRASE, then prompt)"`
+}
+
+type AuthRestoreCmd struct {
+	InPath         string `arg:"" name:"inPath" help:"Backup archive path or '-' for stdin"`
+	PassphraseFile string `name:"passphrase-file" help:"Read the archive passphrase from a file (default: $GOG_BACKUP_PASSPHRASE, then prompt)"`
+	OnConflict     string `name:"on-conflict" help:"When an item already exists with different content: fail|skip|overwrite" enum:"fail,skip,overwrite" default:"fail"`
+}
+
+type authBackupPayload struct {
+	Version         int                                 `json:"version"`
+	CreatedAt       string                              `json:"created_at"`
+	Tokens          []authBackupToken                   `json:"tokens,omitempty"`
+	DefaultAccounts map[string]string                   `json:"default_accounts,omitempty"`
+	Clients         map[string]config.ClientCredentials `json:"clients,omitempty"`
+	AccountAliases  map[string]string                   `json:"account_aliases,omitempty"`
+	AccountClients  map[string]string                   `json:"account_clients,omitempty"`
+	ClientDomains   map[string]string                   `json:"client_domains,omitempty"`
+	ServiceAccounts []authBackupServiceAccount          `json:"service_accounts,omitempty"`
+	Tracking        map[string]tracking.Config          `json:"tracking,omitempty"`
+}
+
+type authBackupToken struct {
+	Email        string   `json:"email"`
+	Client       string   `json:"client"`
+	Services     []string `json:"services,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	CreatedAt    string   `json:"created_at,omitempty"`
+	RefreshToken string   `json:"refresh_token"`
+}
+
+type authBackupServiceAccount struct {
+	Email string          `json:"email"`
+	Kind  string          `json:"kind"`
+	Key   json.RawMessage `json:"key"`
+}
+
+func (c *AuthBackupCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	outPath := strings.TrimSpace(c.Output.Path)
+	if outPath == "" {
+		return usage("empty outPath")
+	}
+	outPath, err := config.ExpandPath(outPath)
+	if err != nil {
+		return err
+	}
+
+	payload, err := collectAuthBackup()
+	if err != nil {
+		return err
+	}
+
+	summary := map[string]any{
+		"path":             outPath,
+		"tokens":           len(payload.Tokens),
+		"clients":          len(payload.Clients),
+		"aliases":          len(payload.AccountAliases),
+		"service_accounts": len(payload.ServiceAccounts),
+		"tracking":         len(payload.Tracking),
+	}
+	if dryRunErr := dryRunExit(ctx, flags, "auth.backup", summary); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	passphrase, err := resolveBackupPassphrase(flags, c.PassphraseFile, true)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode backup: %w", err)
+	}
+	sealed, err := backup.Seal(plaintext, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if mkErr := os.MkdirAll(filepath.Dir(outPath), 0o700); mkErr != nil {
+		return mkErr
+	}
+	openFlags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !c.Overwrite {
+		openFlags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	}
+	f, err := os.OpenFile(outPath, openFlags, 0o600) //nolint:gosec // user-provided path
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(sealed); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if outfmt.IsJSON(ctx) {
+		summary["written"] = true
+		return outfmt.WriteJSON(ctx, os.Stdout, summary)
+	}
+	u.Out().Printf("path\t%s", outPath)
+	u.Out().Printf("tokens\t%d", len(payload.Tokens))
+	u.Out().Printf("clients\t%d", len(payload.Clients))
+	u.Out().Printf("aliases\t%d", len(payload.AccountAliases))
+	u.Out().Printf("service_accounts\t%d", len(payload.ServiceAccounts))
+	u.Out().Printf("tracking\t%d", len(payload.Tracking))
+	return nil
+}
+
+func collectAuthBackup() (authBackupPayload, error) {
+	payload := authBackupPayload{
+		Version:   authBackupPayloadFormat,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	store, err := openSecretsStore()
+	if err != nil {
+		return payload, err
+	}
+	toks, err := store.ListTokens()
+	if err != nil {
+		return payload, err
+	}
+	sort.Slice(toks, func(i, j int) bool {
+		if toks[i].Client != toks[j].Client {
+			return toks[i].Client < toks[j].Client
+		}
+		return toks[i].Email < toks[j].Email
+	})
+
+	clientNames := make(map[string]struct{})
+	for _, tok := range toks {
+		client := tok.Client
+		if client == "" {
+			client = config.DefaultClientName
+		}
+		clientNames[client] = struct{}{}
+		created := ""
+		if !tok.CreatedAt.IsZero() {
+			created = tok.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		payload.Tokens = append(payload.Tokens, authBackupToken{
+			Email:        tok.Email,
+			Client:       client,
+			Services:     tok.Services,
+			Scopes:       tok.Scopes,
+			CreatedAt:    created,
+			RefreshToken: tok.RefreshToken,
+		})
+	}
+
+	creds, err := config.ListClientCredentials()
+	if err != nil {
+		return payload, err
+	}
+	for _, info := range creds {
+		c, readErr := config.ReadClientCredentialsFor(info.Client)
+		if readErr != nil {
+			return payload, fmt.Errorf("read credentials for client %s: %w", info.Client, readErr)
+		}
+		if payload.Clients == nil {
+			payload.Clients = make(map[string]config.ClientCredentials)
+		}
+		payload.Clients[info.Client] = c
+		clientNames[info.Client] = struct{}{}
+	}
+
+	for client := range clientNames {
+		email, defErr := store.GetDefaultAccount(client)
+		if defErr != nil || strings.TrimSpace(email) == "" {
+			continue
+		}
+		if payload.DefaultAccounts == nil {
+			payload.DefaultAccounts = make(map[string]string)
+		}
+		payload.DefaultAccounts[client] = email
+	}
+
+	cfg, err := config.ReadConfig()
+	if err != nil {
+		return payload, err
+	}
+	payload.AccountAliases = cfg.AccountAliases
+	payload.AccountClients = cfg.AccountClients
+	payload.ClientDomains = cfg.ClientDomains
+
+	saEmails, err := config.ListServiceAccountEmails()
+	if err != nil {
+		return payload, err
+	}
+	for _, email := range saEmails {
+		entries, saErr := readServiceAccountsForBackup(email)
+		if saErr != nil {
+			return payload, saErr
+		}
+		payload.ServiceAccounts = append(payload.ServiceAccounts, entries...)
+	}
+
+	accounts, err := tracking.ListAccounts()
+	if err != nil {
+		return payload, err
+	}
+	for _, account := range accounts {
+		tcfg, loadErr := tracking.LoadConfig(account)
+		if loadErr != nil {
+			return payload, fmt.Errorf("tracking config for %s: %w", account, loadErr)
+		}
+		if payload.Tracking == nil {
+			payload.Tracking = make(map[string]tracking.Config)
+		}
+		payload.Tracking[account] = *tcfg
+	}
+
+	return payload, nil
+}
+
+func readServiceAccountsForBackup(email string) ([]authBackupServiceAccount, error) {
+	var out []authBackupServiceAccount
+
+	saPath, err := config.ServiceAccountPath(email)
+	if err != nil {
+		return nil, err
+	}
+	if data, readErr := os.ReadFile(saPath); readErr == nil { //nolint:gosec // stored in user config dir
+		out = append(out, authBackupServiceAccount{Email: email, Kind: backupKindServiceAccount, Key: data})
+	} else if !os.IsNotExist(readErr) {
+		return nil, fmt.Errorf("read service account key: %w", readErr)
+	}
+
+	keepPaths := make([]string, 0, 2)
+	if p, pathErr := config.KeepServiceAccountPath(email); pathErr == nil {
+		keepPaths = append(keepPaths, p)
+	}
+	if p, pathErr := config.KeepServiceAccountLegacyPath(email); pathErr == nil {
+		keepPaths = append(keepPaths, p)
+	}
+	for _, p := range keepPaths {
+		data, readErr := os.ReadFile(p) //nolint:gosec // stored in user config dir
+		if readErr == nil {
+			out = append(out, authBackupServiceAccount{Email: email, Kind: backupKindKeep, Key: data})
+			break
+		}
+		if !os.IsNotExist(readErr) {
+			return nil, fmt.Errorf("read service account key: %w", readErr)
+		}
+	}
+
+	for i := range out {
+		if !json.Valid(out[i].Key) {
+			return nil, fmt.Errorf("service account key for %s is not valid JSON", email)
+		}
+	}
+	return out, nil
+}
+
+func resolveBackupPassphrase(flags *RootFlags, passphraseFile string, confirm bool) (string, error) {
+	if p := strings.TrimSpace(passphraseFile); p != "" {
+		p, err := config.ExpandPath(p)
+		if err != nil {
+			return "", err
+		}
+		b, err := os.ReadFile(p) //nolint:gosec // user-provided path
+		if err != nil {
+			return "", fmt.Errorf("read passphrase file: %w", err)
+		}
+		return checkBackupPassphrase(strings.TrimRight(string(b), "\r\n"), confirm)
+	}
+	if v, ok := os.LookupEnv(backupPassphraseEnv); ok {
+		return checkBackupPassphrase(v, confirm)
+	}
+
+	if (flags != nil && flags.NoInput) || !backupPassphraseIsTTY() {
+		return "", usage("passphrase required: use --passphrase-file or set " + backupPassphraseEnv)
+	}
+
+	passphrase, err := readBackupPassphrase("Backup passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	if confirm {
+		again, err := readBackupPassphrase("Confirm passphrase: ")
+		if err != nil {
+			return "", fmt.Errorf("read passphrase: %w", err)
+		}
+		if again != passphrase {
+			return "", usage("passphrases do not match")
+		}
+	}
+	return checkBackupPassphrase(passphrase, confirm)
+}
+
+func checkBackupPassphrase(passphrase string, creating bool) (string, error) {
+	if passphrase == "" {
+		return "", usage("empty passphrase")
+	}
+	if creating && len(passphrase) < backupMinPassphraseLen {
+		return "", usagef("passphrase must be at least %d characters", backupMinPassphraseLen)
+	}
+	return passphrase, nil
+}
+
+type restoreItem struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key"`
+	Action   string `json:"action"`
+	conflict bool
+	// config items only update the in-memory config.File; the caller writes it.
+	config bool
+	apply  func() error
+}
+
+const (
+	restoreActionCreate    = "create"
+	restoreActionOverwrite = "overwrite"
+	restoreActionSkip      = "skip"
+	restoreActionUnchanged = "unchanged"
+)
+
+func (c *AuthRestoreCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	var data []byte
+	var err error
+	if c.InPath == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		inPath, expandErr := config.ExpandPath(c.InPath)
+		if expandErr != nil {
+			return expandErr
+		}
+		data, err = os.ReadFile(inPath) //nolint:gosec // user-provided path
+	}
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := resolveBackupPassphrase(flags, c.PassphraseFile, false)
+	if err != nil {
+		return err
+	}
+	plaintext, err := backup.Open(data, passphrase)
+	if err != nil {
+		return err
+	}
+	var payload authBackupPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return fmt.Errorf("decode backup: %w", err)
+	}
+	if payload.Version != authBackupPayloadFormat {
+		return fmt.Errorf("unsupported backup payload version %d", payload.Version)
+	}
+
+	if keychainErr := ensureKeychainAccessIfNeeded(); keychainErr != nil {
+		return fmt.Errorf("keychain access: %w", keychainErr)
+	}
+	store, err := openSecretsStore()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.ReadConfig()
+	if err != nil {
+		return err
+	}
+
+	items, err := planAuthRestore(store, &cfg, payload)
+	if err != nil {
+		return err
+	}
+
+	mode := c.OnConflict
+	if mode == "" {
+		mode = restoreConflictFail
+	}
+	conflicts := make([]string, 0)
+	for i := range items {
+		if !items[i].conflict {
+			continue
+		}
+		conflicts = append(conflicts, items[i].Kind+" "+items[i].Key)
+		if mode == restoreConflictOverwrite {
+			items[i].Action = restoreActionOverwrite
+		} else {
+			items[i].Action = restoreActionSkip
+		}
+	}
+
+	if dryRunErr := dryRunExit(ctx, flags, "auth.restore", map[string]any{
+		"on_conflict": mode,
+		"items":       items,
+	}); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	if len(conflicts) > 0 {
+		switch mode {
+		case restoreConflictFail:
+			return fmt.Errorf("restore would overwrite %d existing item(s): %s (use --on-conflict skip|overwrite)", len(conflicts), strings.Join(conflicts, ", "))
+		case restoreConflictOverwrite:
+			if confirmErr := confirmDestructive(ctx, flags, fmt.Sprintf("overwrite %d existing auth item(s)", len(conflicts))); confirmErr != nil {
+				return confirmErr
+			}
+		}
+	}
+
+	configChanged := false
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[item.Action]++
+		if item.Action != restoreActionCreate && item.Action != restoreActionOverwrite {
+			continue
+		}
+		configChanged = configChanged || item.config
+		if applyErr := item.apply(); applyErr != nil {
+			return fmt.Errorf("restore %s %s: %w", item.Kind, item.Key, applyErr)
+		}
+	}
+	if configChanged {
+		if err := config.WriteConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"restored":  counts[restoreActionCreate] + counts[restoreActionOverwrite],
+			"skipped":   counts[restoreActionSkip],
+			"unchanged": counts[restoreActionUnchanged],
+			"items":     items,
+		})
+	}
+	w, done := tableWriter(ctx)
+	_, _ = fmt.Fprintln(w, "KIND\tKEY\tACTION")
+	for _, item := range items {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", item.Kind, item.Key, item.Action)
+	}
+	done()
+	u.Err().Printf("restored %d, skipped %d, unchanged %d", counts[restoreActionCreate]+counts[restoreActionOverwrite], counts[restoreActionSkip], counts[restoreActionUnchanged])
+	return nil
+}
+
+// planAuthRestore compares the archive against the current setup. Config map
+// entries are applied to cfg in place; the caller writes cfg once if any of
+// them are restored.
+func planAuthRestore(store secrets.Store, cfg *config.File, payload authBackupPayload) ([]restoreItem, error) {
+	var items []restoreItem
+
+	add := func(kind, key string, exists, same bool, apply func() error) {
+		item := restoreItem{Kind: kind, Key: key, Action: restoreActionCreate, apply: apply}
+		switch {
+		case exists && same:
+			item.Action = restoreActionUnchanged
+		case exists:
+			item.conflict = true
+		}
+		items = append(items, item)
+	}
+
+	for _, client := range sortedKeys(payload.Clients) {
+		creds := payload.Clients[client]
+		existing, err := config.ReadClientCredentialsFor(client)
+		exists := err == nil
+		add("client", client, exists, existing == creds, func() error {
+			return config.WriteClientCredentialsFor(client, creds)
+		})
+	}
+
+	for _, t := range payload.Tokens {
+		existing, err := store.GetToken(t.Client, t.Email)
+		if err != nil && !errors.Is(err, keyring.ErrKeyNotFound) {
+			return nil, fmt.Errorf("read token for %s: %w", t.Email, err)
+		}
+		exists := err == nil
+		add("token", t.Client+":"+t.Email, exists, exists && existing.RefreshToken == t.RefreshToken, func() error {
+			var createdAt time.Time
+			if t.CreatedAt != "" {
+				if parsed, parseErr := time.Parse(time.RFC3339, t.CreatedAt); parseErr == nil {
+					createdAt = parsed
+				}
+			}
+			return store.SetToken(t.Client, t.Email, secrets.Token{
+				Client:       t.Client,
+				Email:        t.Email,
+				Services:     t.Services,
+				Scopes:       t.Scopes,
+				CreatedAt:    createdAt,
+				RefreshToken: t.RefreshToken,
+			})
+		})
+	}
+
+	for _, client := range sortedKeys(payload.DefaultAccounts) {
+		email := payload.DefaultAccounts[client]
+		existing, err := store.GetDefaultAccount(client)
+		exists := err == nil && strings.TrimSpace(existing) != ""
+		add("default_account", client, exists, normalizeEmail(existing) == normalizeEmail(email), func() error {
+			return store.SetDefaultAccount(client, email)
+		})
+	}
+
+	planConfigMap := func(kind string, src map[string]string, dst *map[string]string) {
+		for _, key := range sortedKeys(src) {
+			value := src[key]
+			existing, exists := (*dst)[key]
+			add(kind, key, exists, existing == value, func() error {
+				if *dst == nil {
+					*dst = make(map[string]string)
+				}
+				(*dst)[key] = value
+				return nil
+			})
+			items[len(items)-1].config = true
+		}
+	}
+	planConfigMap("alias", payload.AccountAliases, &cfg.AccountAliases)
+	planConfigMap("account_client", payload.AccountClients, &cfg.AccountClients)
+	planConfigMap("client_domain", payload.ClientDomains, &cfg.ClientDomains)
+
+	for _, sa := range payload.ServiceAccounts {
+		var path string
+		var err error
+		switch sa.Kind {
+		case backupKindServiceAccount:
+			path, err = config.ServiceAccountPath(sa.Email)
+		case backupKindKeep:
+			path, err = config.KeepServiceAccountPath(sa.Email)
+		default:
+			return nil, fmt.Errorf("unknown service account kind %q", sa.Kind)
+		}
+		if err != nil {
+			return nil, err
+		}
+		existing, readErr := os.ReadFile(path) //nolint:gosec // stored in user config dir
+		exists := readErr == nil
+		add(sa.Kind, sa.Email, exists, bytes.Equal(existing, sa.Key), func() error {
+			if _, err := config.EnsureDir(); err != nil {
+				return err
+			}
+			return os.WriteFile(path, sa.Key, 0o600)
+		})
+	}
+
+	for _, account := range sortedKeys(payload.Tracking) {
+		tcfg := payload.Tracking[account]
+		existing, err := tracking.LoadConfig(account)
+		exists := err == nil && (existing.Enabled || existing.WorkerURL != "")
+		add("tracking", account, exists, exists && reflect.DeepEqual(*existing, tcfg), func() error {
+			if tcfg.SecretsInKeyring {
+				if err := tracking.SaveSecrets(account, tcfg.TrackingKey, tcfg.AdminKey); err != nil {
+					return err
+				}
+			}
+			return tracking.SaveConfig(account, &tcfg)
+		})
+	}
+
+	return items, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
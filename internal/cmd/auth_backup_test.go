@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/secrets"
+	"github.com/steipete/gogcli/internal/tracking"
+)
+
+func setupBackupHome(t *testing.T) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+	t.Setenv("GOG_KEYRING_BACKEND", "file")
+}
+
+func TestAuthBackupRestore_RoundTrip(t *testing.T) {
+	origOpen := openSecretsStore
+	t.Cleanup(func() { openSecretsStore = origOpen })
+	t.Setenv(backupPassphraseEnv, "correct horse battery")
+
+	archive := filepath.Join(t.TempDir(), "gog-backup.json")
+
+	// Source machine.
+	setupBackupHome(t)
+	src := newMemSecretsStore()
+	_ = src.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "rt-a", Services: []string{"gmail"}})
+	_ = src.SetToken("work", "c@d.com", secrets.Token{RefreshToken: "rt-c"})
+	_ = src.SetDefaultAccount(config.DefaultClientName, "a@b.com")
+	openSecretsStore = func() (secrets.Store, error) { return src, nil }
+
+	if err := config.WriteClientCredentialsFor("work", config.ClientCredentials{ClientID: "wid", ClientSecret: "wsec"}); err != nil {
+		t.Fatalf("write creds: %v", err)
+	}
+	if err := config.SetAccountAlias("me", "a@b.com"); err != nil {
+		t.Fatalf("alias: %v", err)
+	}
+	saPath, _ := config.ServiceAccountPath("svc@corp.com")
+	if err := os.WriteFile(saPath, []byte(`{"type":"service_account","client_email":"x@y.iam.gserviceaccount.com"}`), 0o600); err != nil {
+		t.Fatalf("write sa: %v", err)
+	}
+	if err := tracking.SaveConfig("a@b.com", &tracking.Config{Enabled: true, WorkerURL: "https://w.example", TrackingKey: "tk", AdminKey: "ak"}); err != nil {
+		t.Fatalf("tracking: %v", err)
+	}
+
+	_ = captureStderr(t, func() {
+		_ = captureStdout(t, func() {
+			if err := Execute([]string{"auth", "backup", "--out", archive}); err != nil {
+				t.Fatalf("backup: %v", err)
+			}
+		})
+	})
+	raw, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("read archive: %v", err)
+	}
+	if strings.Contains(string(raw), "rt-a") || strings.Contains(string(raw), "wsec") {
+		t.Fatalf("archive contains plaintext secrets")
+	}
+
+	// Target machine.
+	setupBackupHome(t)
+	dst := newMemSecretsStore()
+	openSecretsStore = func() (secrets.Store, error) { return dst, nil }
+
+	out := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--json", "auth", "restore", archive}); err != nil {
+				t.Fatalf("restore: %v", err)
+			}
+		})
+	})
+	var resp struct {
+		Restored  int `json:"restored"`
+		Unchanged int `json:"unchanged"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("unmarshal: %v\nout=%q", err, out)
+	}
+	// client, 2 tokens, default account, alias, service account, tracking
+	if resp.Restored != 7 {
+		t.Fatalf("unexpected restore count: %s", out)
+	}
+
+	if tok, err := dst.GetToken("work", "c@d.com"); err != nil || tok.RefreshToken != "rt-c" {
+		t.Fatalf("token not restored: %#v err=%v", tok, err)
+	}
+	if email, _ := dst.GetDefaultAccount(config.DefaultClientName); email != "a@b.com" {
+		t.Fatalf("default account not restored: %q", email)
+	}
+	if creds, err := config.ReadClientCredentialsFor("work"); err != nil || creds.ClientSecret != "wsec" {
+		t.Fatalf("client creds not restored: %#v err=%v", creds, err)
+	}
+	if email, ok, _ := config.ResolveAccountAlias("me"); !ok || email != "a@b.com" {
+		t.Fatalf("alias not restored")
+	}
+	if emails, _ := config.ListServiceAccountEmails(); len(emails) != 1 || emails[0] != "svc@corp.com" {
+		t.Fatalf("service account not restored: %v", emails)
+	}
+	if tcfg, err := tracking.LoadConfig("a@b.com"); err != nil || tcfg.WorkerURL != "https://w.example" || tcfg.TrackingKey != "tk" {
+		t.Fatalf("tracking not restored: %#v err=%v", tcfg, err)
+	}
+
+	// Restoring again changes nothing.
+	out = captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--json", "auth", "restore", archive}); err != nil {
+				t.Fatalf("restore again: %v", err)
+			}
+		})
+	})
+	if err := json.Unmarshal([]byte(out), &resp); err != nil || resp.Restored != 0 || resp.Unchanged != 7 {
+		t.Fatalf("expected all unchanged, got %q", out)
+	}
+}
+
+func TestAuthRestore_Conflicts(t *testing.T) {
+	origOpen := openSecretsStore
+	t.Cleanup(func() { openSecretsStore = origOpen })
+	t.Setenv(backupPassphraseEnv, "correct horse battery")
+	setupBackupHome(t)
+
+	store := newMemSecretsStore()
+	_ = store.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "old"})
+	openSecretsStore = func() (secrets.Store, error) { return store, nil }
+
+	archive := filepath.Join(t.TempDir(), "backup.json")
+	_ = captureStderr(t, func() {
+		_ = captureStdout(t, func() {
+			if err := Execute([]string{"auth", "backup", "--out", archive}); err != nil {
+				t.Fatalf("backup: %v", err)
+			}
+		})
+	})
+
+	_ = store.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "new"})
+
+	run := func(args ...string) error {
+		var err error
+		_ = captureStderr(t, func() {
+			_ = captureStdout(t, func() {
+				err = Execute(append([]string{"--no-input", "auth", "restore", archive}, args...))
+			})
+		})
+		return err
+	}
+
+	if err := run(); err == nil {
+		t.Fatalf("expected conflict failure")
+	}
+	if tok, _ := store.GetToken(config.DefaultClientName, "a@b.com"); tok.RefreshToken != "new" {
+		t.Fatalf("token changed on failed restore")
+	}
+
+	if err := run("--on-conflict", "skip"); err != nil {
+		t.Fatalf("skip: %v", err)
+	}
+	if tok, _ := store.GetToken(config.DefaultClientName, "a@b.com"); tok.RefreshToken != "new" {
+		t.Fatalf("token changed with skip")
+	}
+
+	if err := run("--on-conflict", "overwrite"); err == nil {
+		t.Fatalf("expected overwrite to require --force in non-interactive mode")
+	}
+	if err := Execute([]string{"--force", "auth", "restore", archive, "--on-conflict", "overwrite"}); err != nil {
+		t.Fatalf("overwrite: %v", err)
+	}
+	if tok, _ := store.GetToken(config.DefaultClientName, "a@b.com"); tok.RefreshToken != "old" {
+		t.Fatalf("expected token overwritten, got %q", tok.RefreshToken)
+	}
+}
+
+func TestAuthRestore_WrongPassphrase(t *testing.T) {
+	origOpen := openSecretsStore
+	t.Cleanup(func() { openSecretsStore = origOpen })
+	setupBackupHome(t)
+	store := newMemSecretsStore()
+	openSecretsStore = func() (secrets.Store, error) { return store, nil }
+
+	archive := filepath.Join(t.TempDir(), "backup.json")
+	t.Setenv(backupPassphraseEnv, "correct horse battery")
+	_ = captureStderr(t, func() {
+		_ = captureStdout(t, func() {
+			if err := Execute([]string{"auth", "backup", "--out", archive}); err != nil {
+				t.Fatalf("backup: %v", err)
+			}
+		})
+	})
+
+	t.Setenv(backupPassphraseEnv, "wrong")
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"auth", "restore", archive}); err == nil {
+			t.Fatalf("expected wrong passphrase error")
+		}
+	})
+}
+
+func TestResolveBackupPassphrase(t *testing.T) {
+	origTTY := backupPassphraseIsTTY
+	t.Cleanup(func() { backupPassphraseIsTTY = origTTY })
+	backupPassphraseIsTTY = func() bool { return false }
+
+	if _, err := resolveBackupPassphrase(&RootFlags{}, "", true); err == nil {
+		t.Fatalf("expected error without passphrase source")
+	}
+
+	path := filepath.Join(t.TempDir(), "pw")
+	if err := os.WriteFile(path, []byte("short\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := resolveBackupPassphrase(&RootFlags{}, path, true); err == nil {
+		t.Fatalf("expected short passphrase to be rejected for backup")
+	}
+	if got, err := resolveBackupPassphrase(&RootFlags{}, path, false); err != nil || got != "short" {
+		t.Fatalf("unexpected restore passphrase: %q err=%v", got, err)
+	}
+}
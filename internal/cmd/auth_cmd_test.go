@@ -14,6 +14,7 @@ import (
 	"github.com/99designs/keyring"
 
 	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/googleapi"
 	"github.com/steipete/gogcli/internal/secrets"
 )
 
@@ -488,3 +489,74 @@ func TestAuthListRemoveTokensListDelete_JSON(t *testing.T) {
 		t.Fatalf("expected empty keys, got: %#v", emptyKeysResp.Keys)
 	}
 }
+
+func TestAuthStatus_CredentialSource(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+
+	origOpen := openSecretsStore
+	origADC := findApplicationDefaultCredentials
+	t.Cleanup(func() {
+		openSecretsStore = origOpen
+		findApplicationDefaultCredentials = origADC
+	})
+
+	store := newMemSecretsStore()
+	openSecretsStore = func() (secrets.Store, error) { return store, nil }
+	findApplicationDefaultCredentials = func(context.Context) (googleapi.CredentialSource, bool, error) {
+		return googleapi.CredentialSource{
+			Kind:        googleapi.CredentialSourceADC,
+			Type:        "external_account",
+			Origin:      "env",
+			Path:        "/etc/gog/wif.json",
+			Impersonate: "gog@proj.iam.gserviceaccount.com",
+		}, true, nil
+	}
+
+	status := func() string {
+		return captureStdout(t, func() {
+			_ = captureStderr(t, func() {
+				if err := Execute([]string{"--account", "a@b.com", "auth", "status"}); err != nil {
+					t.Fatalf("Execute: %v", err)
+				}
+			})
+		})
+	}
+
+	out := status()
+	for _, want := range []string{
+		"credential_source\tadc",
+		"adc_type\texternal_account",
+		"adc_path\t/etc/gog/wif.json",
+		"impersonate_service_account\tgog@proj.iam.gserviceaccount.com",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q, got: %q", want, out)
+		}
+	}
+
+	if err := config.WriteClientCredentialsFor(config.DefaultClientName, config.ClientCredentials{ClientID: "id", ClientSecret: "secret"}); err != nil {
+		t.Fatalf("write credentials: %v", err)
+	}
+	if err := store.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "rt"}); err != nil {
+		t.Fatalf("SetToken: %v", err)
+	}
+
+	out = status()
+	if !strings.Contains(out, "credential_source\toauth") || strings.Contains(out, "adc_type") {
+		t.Fatalf("expected oauth credential source, got: %q", out)
+	}
+
+	findApplicationDefaultCredentials = func(context.Context) (googleapi.CredentialSource, bool, error) {
+		return googleapi.CredentialSource{}, false, nil
+	}
+	if err := store.DeleteToken(config.DefaultClientName, "a@b.com"); err != nil {
+		t.Fatalf("DeleteToken: %v", err)
+	}
+
+	out = status()
+	if !strings.Contains(out, "credential_source\tnone") {
+		t.Fatalf("expected no credential source, got: %q", out)
+	}
+}
@@ -0,0 +1,769 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/googleapi"
+	"github.com/steipete/gogcli/internal/googleauth"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/secrets"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	doctorStatusOK   = "ok"
+	doctorStatusWarn = "warn"
+	doctorStatusFail = "fail"
+	doctorStatusSkip = "skip"
+)
+
+const (
+	doctorClockSkewWarn = time.Minute
+	doctorClockSkewFail = 5 * time.Minute
+)
+
+var (
+	doctorRefreshAccessToken    = googleauth.RefreshAccessToken
+	doctorFetchTokenInfo        = googleauth.FetchTokenInfo
+	doctorServiceAccountToken   = googleapi.ServiceAccountToken
+	doctorCheckKeychainLocked   = secrets.CheckKeychainLocked
+	doctorReachabilityEndpoints = []string{
+		"https://oauth2.googleapis.com/",
+		"https://www.googleapis.com/",
+	}
+	doctorNow = time.Now
+)
+
+// doctorProbe is a cheap read-only request used to confirm an API is enabled
+// for the OAuth client's project. allowMissing treats 400/404 as success for
+// probes that address a placeholder resource ID.
+type doctorProbe struct {
+	URL          string
+	AllowMissing bool
+}
+
+var doctorProbes = map[googleauth.Service]doctorProbe{
+	googleauth.ServiceGmail:     {URL: "https://gmail.googleapis.com/gmail/v1/users/me/profile"},
+	googleauth.ServiceCalendar:  {URL: "https://www.googleapis.com/calendar/v3/users/me/calendarList?maxResults=1"},
+	googleauth.ServiceChat:      {URL: "https://chat.googleapis.com/v1/spaces?pageSize=1"},
+	googleauth.ServiceClassroom: {URL: "https://classroom.googleapis.com/v1/courses?pageSize=1"},
+	googleauth.ServiceDrive:     {URL: "https://www.googleapis.com/drive/v3/about?fields=user"},
+	googleauth.ServiceDocs:      {URL: "https://docs.googleapis.com/v1/documents/gog-doctor-probe", AllowMissing: true},
+	googleauth.ServiceSlides:    {URL: "https://slides.googleapis.com/v1/presentations/gog-doctor-probe", AllowMissing: true},
+	googleauth.ServiceContacts:  {URL: "https://people.googleapis.com/v1/people/me/connections?pageSize=1&personFields=names"},
+	googleauth.ServiceTasks:     {URL: "https://tasks.googleapis.com/tasks/v1/users/@me/lists?maxResults=1"},
+	googleauth.ServiceSheets:    {URL: "https://sheets.googleapis.com/v4/spreadsheets/gog-doctor-probe", AllowMissing: true},
+	googleauth.ServicePeople:    {URL: "https://people.googleapis.com/v1/people/me?personFields=names"},
+	googleauth.ServiceForms:     {URL: "https://forms.googleapis.com/v1/forms/gog-doctor-probe", AllowMissing: true},
+	googleauth.ServiceAppScript: {URL: "https://script.googleapis.com/v1/processes?pageSize=1"},
+	googleauth.ServiceGroups:    {URL: "https://cloudidentity.googleapis.com/v1/groups/gog-doctor-probe", AllowMissing: true},
+	googleauth.ServiceKeep:      {URL: "https://keep.googleapis.com/v1/notes?pageSize=1"},
+}
+
+type AuthDoctorCmd struct {
+	Timeout time.Duration `name:"timeout" help:"Timeout for each network check" default:"15s"`
+	Offline bool          `name:"offline" help:"Skip network checks (token exchange, scopes, API probes, reachability)"`
+	NoProbe bool          `name:"no-probe" help:"Skip per-service API probes"`
+}
+
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+type doctorAccountReport struct {
+	Email    string        `json:"email"`
+	Client   string        `json:"client,omitempty"`
+	Auth     string        `json:"auth"`
+	Services []string      `json:"services,omitempty"`
+	Checks   []doctorCheck `json:"checks"`
+}
+
+type doctorReport struct {
+	OK       bool                  `json:"ok"`
+	Checks   []doctorCheck         `json:"checks"`
+	Accounts []doctorAccountReport `json:"accounts"`
+}
+
+func (c *AuthDoctorCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	report := doctorReport{}
+	store, keyringChecks := c.checkKeyring()
+	report.Checks = append(report.Checks, keyringChecks...)
+	if !c.Offline {
+		report.Checks = append(report.Checks, c.checkReachability(ctx)...)
+	}
+
+	targets, err := c.doctorTargets(flags, store)
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		report.Accounts = append(report.Accounts, c.checkAccount(ctx, store, target))
+	}
+
+	report.OK = !doctorHasFailure(report)
+
+	if outfmt.IsJSON(ctx) {
+		if err := outfmt.WriteJSON(ctx, os.Stdout, report); err != nil {
+			return err
+		}
+	} else {
+		writeDoctorText(ctx, u, report)
+	}
+
+	if !report.OK {
+		return &ExitError{Code: 1, Err: errors.New("auth doctor found problems")}
+	}
+	return nil
+}
+
+type doctorTarget struct {
+	Email  string
+	Token  *secrets.Token
+	SAPath string
+}
+
+func (c *AuthDoctorCmd) doctorTargets(flags *RootFlags, store secrets.Store) ([]doctorTarget, error) {
+	if flags == nil {
+		flags = &RootFlags{}
+	}
+	explicit := ""
+	if strings.TrimSpace(flags.Account) != "" || strings.TrimSpace(os.Getenv("GOG_ACCOUNT")) != "" {
+		account, err := requireAccount(flags)
+		if err != nil {
+			return nil, err
+		}
+		explicit = normalizeEmail(account)
+	}
+
+	// One target per stored token: an email authorized under several OAuth
+	// clients has a separate refresh token (and failure mode) for each.
+	var targets []*doctorTarget
+	byEmail := make(map[string][]*doctorTarget)
+	add := func(email string, tok *secrets.Token) {
+		email = normalizeEmail(email)
+		if email == "" || (explicit != "" && email != explicit) {
+			return
+		}
+		if tok == nil && len(byEmail[email]) > 0 {
+			return
+		}
+		t := &doctorTarget{Email: email, Token: tok}
+		targets = append(targets, t)
+		byEmail[email] = append(byEmail[email], t)
+	}
+
+	if store != nil {
+		if explicit != "" {
+			client, err := resolveClientForEmail(explicit, flags, "")
+			if err != nil {
+				return nil, err
+			}
+			if tok, err := store.GetToken(client, explicit); err == nil {
+				tok.Client = client
+				add(explicit, &tok)
+			}
+		} else if toks, err := store.ListTokens(); err == nil {
+			for i := range toks {
+				add(toks[i].Email, &toks[i])
+			}
+		}
+	}
+
+	saEmails, err := config.ListServiceAccountEmails()
+	if err != nil {
+		return nil, err
+	}
+	for _, email := range saEmails {
+		add(email, nil)
+	}
+	if explicit != "" {
+		add(explicit, nil)
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		if targets[i].Email != targets[j].Email {
+			return targets[i].Email < targets[j].Email
+		}
+		return targets[i].client() < targets[j].client()
+	})
+	out := make([]doctorTarget, 0, len(targets))
+	checkedSA := make(map[string]bool)
+	for _, t := range targets {
+		// The service account key is per email; check it once.
+		if !checkedSA[t.Email] {
+			checkedSA[t.Email] = true
+			if p, _, ok := bestServiceAccountPathAndMtime(t.Email); ok {
+				t.SAPath = p
+			}
+		}
+		out = append(out, *t)
+	}
+	return out, nil
+}
+
+func (t *doctorTarget) client() string {
+	if t.Token == nil {
+		return ""
+	}
+	return t.Token.Client
+}
+
+func (c *AuthDoctorCmd) checkKeyring() (secrets.Store, []doctorCheck) {
+	checks := make([]doctorCheck, 0, 2)
+
+	info, err := secrets.ResolveKeyringBackendInfo()
+	if err != nil {
+		return nil, append(checks, doctorCheck{
+			Name:   "keyring",
+			Status: doctorStatusFail,
+			Detail: err.Error(),
+			Hint:   "Fix keyring_backend in config.json or GOG_KEYRING_BACKEND (auto|keychain|file|helper:<cmd>)",
+		})
+	}
+	backend := fmt.Sprintf("backend=%s source=%s", info.Value, info.Source)
+
+	if doctorCheckKeychainLocked() {
+		checks = append(checks, doctorCheck{
+			Name:   "keyring_lock",
+			Status: doctorStatusWarn,
+			Detail: "login keychain is locked",
+			Hint:   "Unlock the login keychain, or switch to the file backend: gog auth keyring file",
+		})
+	}
+
+	store, err := openSecretsStore()
+	if err != nil {
+		return nil, append(checks, doctorCheck{
+			Name:   "keyring",
+			Status: doctorStatusFail,
+			Detail: backend + ": " + err.Error(),
+			Hint:   "Check keyring access; for headless systems use: gog auth keyring file (and set GOG_KEYRING_PASSWORD)",
+		})
+	}
+	keys, err := store.Keys()
+	if err != nil {
+		return nil, append(checks, doctorCheck{
+			Name:   "keyring",
+			Status: doctorStatusFail,
+			Detail: backend + ": " + err.Error(),
+			Hint:   "The keyring opened but could not be read; check that it is unlocked and the password is correct",
+		})
+	}
+	return store, append(checks, doctorCheck{
+		Name:   "keyring",
+		Status: doctorStatusOK,
+		Detail: fmt.Sprintf("%s keys=%d", backend, len(keys)),
+	})
+}
+
+func (c *AuthDoctorCmd) httpClient() *http.Client {
+	return &http.Client{Timeout: c.timeout()}
+}
+
+func (c *AuthDoctorCmd) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return 15 * time.Second
+	}
+	return c.Timeout
+}
+
+func (c *AuthDoctorCmd) checkReachability(ctx context.Context) []doctorCheck {
+	checks := make([]doctorCheck, 0, len(doctorReachabilityEndpoints)+1)
+	var serverTime time.Time
+	var localTime time.Time
+
+	for _, endpoint := range doctorReachabilityEndpoints {
+		check := doctorCheck{Name: "reachability " + endpoint}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			check.Status = doctorStatusFail
+			check.Detail = err.Error()
+			checks = append(checks, check)
+			continue
+		}
+
+		proxy := "direct"
+		if proxyURL, err := http.ProxyFromEnvironment(req); err == nil && proxyURL != nil {
+			proxy = "proxy=" + proxyURL.Redacted()
+		}
+
+		start := doctorNow()
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			check.Status = doctorStatusFail
+			check.Detail = proxy + ": " + err.Error()
+			check.Hint = doctorReachabilityHint(err)
+			checks = append(checks, check)
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		_ = resp.Body.Close()
+
+		parts := []string{proxy, fmt.Sprintf("http=%d", resp.StatusCode)}
+		if resp.TLS != nil {
+			parts = append(parts, "tls="+tls.VersionName(resp.TLS.Version))
+		}
+		check.Status = doctorStatusOK
+		check.Detail = strings.Join(parts, " ")
+		checks = append(checks, check)
+
+		if serverTime.IsZero() {
+			if t, err := http.ParseTime(resp.Header.Get("Date")); err == nil {
+				serverTime = t
+				localTime = start
+			}
+		}
+	}
+
+	checks = append(checks, doctorClockCheck(serverTime, localTime))
+	return checks
+}
+
+func doctorReachabilityHint(err error) string {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "x509") || strings.Contains(msg, "certificate"):
+		return "TLS verification failed; if a corporate proxy intercepts TLS, add its CA to the system trust store (or set SSL_CERT_FILE)"
+	case strings.Contains(msg, "proxyconnect"):
+		return "The proxy from HTTPS_PROXY/HTTP_PROXY refused the connection; check the proxy settings"
+	case strings.Contains(msg, "no such host"):
+		return "DNS lookup failed; check network connectivity and resolver settings"
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "deadline exceeded"):
+		return "Request timed out; check firewall/proxy rules (HTTPS_PROXY, NO_PROXY) or raise --timeout"
+	default:
+		return "Check network connectivity and proxy settings (HTTPS_PROXY, NO_PROXY)"
+	}
+}
+
+func doctorClockCheck(serverTime time.Time, localTime time.Time) doctorCheck {
+	check := doctorCheck{Name: "clock_skew"}
+	if serverTime.IsZero() {
+		check.Status = doctorStatusSkip
+		check.Detail = "no Date header from Google"
+		return check
+	}
+
+	// The Date header has second precision, so ignore sub-second drift.
+	skew := localTime.Sub(serverTime).Round(time.Second)
+	abs := skew
+	if abs < 0 {
+		abs = -abs
+	}
+	check.Detail = fmt.Sprintf("local clock offset %s", skew)
+	switch {
+	case abs >= doctorClockSkewFail:
+		check.Status = doctorStatusFail
+		check.Hint = "Sync the system clock (NTP); token exchange and service account assertions fail with large skew"
+	case abs >= doctorClockSkewWarn:
+		check.Status = doctorStatusWarn
+		check.Hint = "Sync the system clock (NTP)"
+	default:
+		check.Status = doctorStatusOK
+	}
+	return check
+}
+
+func (c *AuthDoctorCmd) checkAccount(ctx context.Context, store secrets.Store, target doctorTarget) doctorAccountReport {
+	rep := doctorAccountReport{Email: target.Email, Auth: authTypeOAuth}
+	switch {
+	case target.Token != nil && target.SAPath != "":
+		rep.Auth = authTypeOAuthServiceAccount
+	case target.SAPath != "":
+		rep.Auth = authTypeServiceAccount
+	}
+
+	if target.Token != nil {
+		rep.Client = target.Token.Client
+		rep.Services = target.Token.Services
+		rep.Checks = append(rep.Checks, c.checkOAuth(ctx, target)...)
+	} else if store != nil {
+		rep.Checks = append(rep.Checks, doctorCheck{
+			Name:   "refresh_token",
+			Status: doctorStatusSkip,
+			Detail: "no OAuth token stored",
+		})
+	}
+
+	if target.SAPath != "" {
+		rep.Checks = append(rep.Checks, c.checkServiceAccount(ctx, target))
+	}
+	return rep
+}
+
+func (c *AuthDoctorCmd) checkOAuth(ctx context.Context, target doctorTarget) []doctorCheck {
+	tok := target.Token
+	client := tok.Client
+	if strings.TrimSpace(client) == "" {
+		client = config.DefaultClientName
+	}
+	addHint := doctorAuthAddHint(target.Email, client, tok.Services)
+
+	checks := []doctorCheck{c.checkCredentials(client)}
+	if checks[0].Status == doctorStatusFail {
+		return checks
+	}
+
+	if c.Offline {
+		return append(checks, doctorCheck{Name: "refresh_token", Status: doctorStatusSkip, Detail: "offline"})
+	}
+
+	access, err := doctorRefreshAccessToken(ctx, client, tok.RefreshToken, tok.Scopes, c.timeout())
+	if err != nil {
+		hint := "Re-authorize: " + addHint
+		if strings.Contains(err.Error(), "invalid_grant") {
+			hint = "Refresh token was revoked or expired (apps in Testing mode expire tokens after 7 days). Re-authorize: " + addHint
+		} else if strings.Contains(err.Error(), "invalid_client") || strings.Contains(err.Error(), "unauthorized_client") {
+			hint = "The token was issued to a different OAuth client; re-run auth with the matching client: " + addHint
+		}
+		return append(checks, doctorCheck{
+			Name:   "refresh_token",
+			Status: doctorStatusFail,
+			Detail: err.Error(),
+			Hint:   hint,
+		})
+	}
+	checks = append(checks, doctorCheck{
+		Name:   "refresh_token",
+		Status: doctorStatusOK,
+		Detail: "access token expires " + access.Expiry.UTC().Format(time.RFC3339),
+	})
+
+	info, err := doctorFetchTokenInfo(ctx, access.AccessToken, c.timeout())
+	if err != nil {
+		checks = append(checks, doctorCheck{
+			Name:   "scopes",
+			Status: doctorStatusWarn,
+			Detail: err.Error(),
+		})
+	} else {
+		if info.Email != "" && normalizeEmail(info.Email) != target.Email {
+			checks = append(checks, doctorCheck{
+				Name:   "identity",
+				Status: doctorStatusFail,
+				Detail: fmt.Sprintf("token belongs to %s", info.Email),
+				Hint:   "Remove the mismatched token (gog auth remove " + target.Email + ") and re-authorize: " + addHint,
+			})
+		}
+		checks = append(checks, doctorScopeChecks(tok.Services, info.Scopes, addHint)...)
+	}
+
+	if !c.NoProbe {
+		checks = append(checks, c.probeServices(ctx, access, tok.Services, addHint)...)
+	}
+	return checks
+}
+
+func (c *AuthDoctorCmd) checkCredentials(client string) doctorCheck {
+	check := doctorCheck{Name: "credentials"}
+	path, _ := config.ClientCredentialsPathFor(client)
+	creds, err := config.ReadClientCredentialsFor(client)
+	if err != nil {
+		check.Status = doctorStatusFail
+		check.Detail = fmt.Sprintf("client %s: %s", client, err.Error())
+		check.Hint = "Download an OAuth client JSON (Desktop app) from Google Cloud Console and run: gog auth credentials <file>"
+		if client != config.DefaultClientName {
+			check.Hint += " --client " + client
+		}
+		return check
+	}
+	if strings.TrimSpace(creds.ClientID) == "" || strings.TrimSpace(creds.ClientSecret) == "" {
+		check.Status = doctorStatusFail
+		check.Detail = fmt.Sprintf("client %s: missing client_id or client_secret in %s", client, path)
+		check.Hint = "Re-download the OAuth client JSON and run: gog auth credentials <file>"
+		return check
+	}
+	check.Status = doctorStatusOK
+	check.Detail = fmt.Sprintf("client %s: %s", client, path)
+	return check
+}
+
+func doctorScopeChecks(services []string, granted []string, addHint string) []doctorCheck {
+	checks := make([]doctorCheck, 0, len(services))
+	for _, raw := range services {
+		svc, err := googleauth.ParseService(raw)
+		if err != nil {
+			checks = append(checks, doctorCheck{
+				Name:   "scopes " + raw,
+				Status: doctorStatusWarn,
+				Detail: err.Error(),
+			})
+			continue
+		}
+
+		check := doctorCheck{Name: "scopes " + string(svc)}
+		full, _ := googleauth.ScopesWithOptions(svc, googleauth.ScopeOptions{})
+		missing := googleauth.MissingScopes(full, granted)
+		if len(missing) == 0 {
+			check.Status = doctorStatusOK
+			checks = append(checks, check)
+			continue
+		}
+
+		variants := []struct {
+			label string
+			opts  googleauth.ScopeOptions
+		}{
+			{"readonly", googleauth.ScopeOptions{Readonly: true}},
+			{"drive-scope=file", googleauth.ScopeOptions{DriveScope: googleauth.DriveScopeFile}},
+			{"drive-scope=readonly", googleauth.ScopeOptions{DriveScope: googleauth.DriveScopeReadonly}},
+		}
+		matched := ""
+		for _, v := range variants {
+			scopes, err := googleauth.ScopesWithOptions(svc, v.opts)
+			if err == nil && len(googleauth.MissingScopes(scopes, granted)) == 0 {
+				matched = v.label
+				break
+			}
+		}
+		if matched != "" {
+			check.Status = doctorStatusOK
+			check.Detail = matched
+			checks = append(checks, check)
+			continue
+		}
+
+		check.Status = doctorStatusFail
+		check.Detail = "missing " + strings.Join(missing, " ")
+		check.Hint = "Grant the missing scopes: " + addHint
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func (c *AuthDoctorCmd) probeServices(ctx context.Context, access *oauth2.Token, services []string, addHint string) []doctorCheck {
+	apiNames := make(map[googleauth.Service][]string)
+	for _, info := range googleauth.ServicesInfo() {
+		apiNames[info.Service] = info.APIs
+	}
+
+	checks := make([]doctorCheck, 0, len(services))
+	for _, raw := range services {
+		svc, err := googleauth.ParseService(raw)
+		if err != nil {
+			continue
+		}
+		probe, ok := doctorProbes[svc]
+		if !ok {
+			continue
+		}
+		check := c.runProbe(ctx, access, probe)
+		check.Name = "api " + string(svc)
+		switch check.Hint {
+		case doctorHintAPIDisabled:
+			check.Hint = fmt.Sprintf("Enable %s in Google Cloud Console (APIs & Services > Library) for the OAuth client's project", strings.Join(apiNames[svc], ", "))
+		case doctorHintScopes:
+			check.Hint = "Grant the missing scopes: " + addHint
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+const (
+	doctorHintAPIDisabled = "api-disabled"
+	doctorHintScopes      = "scopes"
+)
+
+func (c *AuthDoctorCmd) runProbe(ctx context.Context, access *oauth2.Token, probe doctorProbe) doctorCheck {
+	check := doctorCheck{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.URL, nil)
+	if err != nil {
+		check.Status = doctorStatusFail
+		check.Detail = err.Error()
+		return check
+	}
+	access.SetAuthHeader(req)
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		check.Status = doctorStatusFail
+		check.Detail = err.Error()
+		check.Hint = doctorReachabilityHint(err)
+		return check
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		check.Status = doctorStatusOK
+		return check
+	case probe.AllowMissing && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusBadRequest):
+		check.Status = doctorStatusOK
+		return check
+	}
+
+	reason, message := parseDoctorAPIError(body)
+	check.Status = doctorStatusFail
+	check.Detail = fmt.Sprintf("http %d", resp.StatusCode)
+	if message != "" {
+		check.Detail += ": " + message
+	}
+
+	lower := strings.ToLower(reason + " " + message)
+	switch {
+	case strings.Contains(lower, "accessnotconfigured") || strings.Contains(lower, "service_disabled") || strings.Contains(lower, "has not been used in project"):
+		check.Hint = doctorHintAPIDisabled
+	case strings.Contains(lower, "insufficientpermissions") || strings.Contains(lower, "access_token_scope_insufficient"):
+		check.Hint = doctorHintScopes
+	case resp.StatusCode == http.StatusUnauthorized:
+		check.Hint = "The access token was rejected; re-authorize the account"
+	case resp.StatusCode == http.StatusForbidden:
+		check.Hint = "Permission denied; the account may lack access to this product (Workspace-only APIs need a Workspace account)"
+	default:
+		check.Status = doctorStatusWarn
+	}
+	return check
+}
+
+// parseDoctorAPIError extracts the first error reason and the message from a
+// Google JSON error body.
+func parseDoctorAPIError(body []byte) (string, string) {
+	var parsed struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+			Details []struct {
+				Reason string `json:"reason"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return "", strings.TrimSpace(string(body))
+	}
+	reasons := []string{parsed.Error.Status}
+	for _, e := range parsed.Error.Errors {
+		reasons = append(reasons, e.Reason)
+	}
+	for _, d := range parsed.Error.Details {
+		reasons = append(reasons, d.Reason)
+	}
+	return strings.Join(reasons, " "), parsed.Error.Message
+}
+
+func (c *AuthDoctorCmd) checkServiceAccount(ctx context.Context, target doctorTarget) doctorCheck {
+	check := doctorCheck{Name: "service_account"}
+
+	data, err := os.ReadFile(target.SAPath) //nolint:gosec // stored in user config dir
+	if err != nil {
+		check.Status = doctorStatusFail
+		check.Detail = err.Error()
+		check.Hint = "Re-run: gog auth service-account set " + target.Email + " --key <service-account.json>"
+		return check
+	}
+	info, err := parseServiceAccountJSON(data)
+	if err != nil {
+		check.Status = doctorStatusFail
+		check.Detail = err.Error()
+		check.Hint = "Re-run: gog auth service-account set " + target.Email + " --key <service-account.json>"
+		return check
+	}
+	check.Detail = fmt.Sprintf("%s client_email=%s", target.SAPath, info.ClientEmail)
+
+	if c.Offline {
+		check.Status = doctorStatusSkip
+		return check
+	}
+
+	var scopes []string
+	if target.Token != nil && len(target.Token.Scopes) > 0 {
+		scopes = target.Token.Scopes
+	} else if keepPath, err := config.KeepServiceAccountPath(target.Email); err == nil && keepPath == target.SAPath {
+		scopes, _ = googleauth.Scopes(googleauth.ServiceKeep)
+	} else {
+		scopes, _ = googleauth.Scopes(googleauth.ServiceGmail)
+	}
+
+	if _, _, _, err := doctorServiceAccountToken(ctx, target.Email, scopes); err != nil {
+		check.Status = doctorStatusFail
+		check.Detail += ": " + err.Error()
+		if strings.Contains(err.Error(), "unauthorized_client") {
+			check.Hint = fmt.Sprintf("Authorize client ID %s for these scopes in Admin console > Security > API controls > Domain-wide delegation: %s", info.ClientID, strings.Join(scopes, ","))
+		} else {
+			check.Hint = "Check the service account key is active and domain-wide delegation is enabled"
+		}
+		return check
+	}
+	check.Status = doctorStatusOK
+	return check
+}
+
+func doctorAuthAddHint(email string, client string, services []string) string {
+	parts := []string{"gog auth add", email}
+	if len(services) > 0 {
+		parts = append(parts, "--services", strings.Join(services, ","))
+	}
+	if client != "" && client != config.DefaultClientName {
+		parts = append(parts, "--client", client)
+	}
+	parts = append(parts, "--force-consent")
+	return strings.Join(parts, " ")
+}
+
+func doctorHasFailure(report doctorReport) bool {
+	for _, c := range report.Checks {
+		if c.Status == doctorStatusFail {
+			return true
+		}
+	}
+	for _, a := range report.Accounts {
+		for _, c := range a.Checks {
+			if c.Status == doctorStatusFail {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeDoctorText(ctx context.Context, u *ui.UI, report doctorReport) {
+	w, done := tableWriter(ctx)
+	writeChecks := func(checks []doctorCheck) {
+		for _, check := range checks {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, check.Status, check.Detail)
+			if check.Hint != "" {
+				_, _ = fmt.Fprintf(w, "\thint\t%s\n", check.Hint)
+			}
+		}
+	}
+
+	_, _ = fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	writeChecks(report.Checks)
+	for _, acct := range report.Accounts {
+		label := acct.Email + " (" + acct.Auth
+		if acct.Client != "" {
+			label += ", client " + acct.Client
+		}
+		label += ")"
+		_, _ = fmt.Fprintf(w, "\n%s\t\t\n", label)
+		writeChecks(acct.Checks)
+	}
+	done()
+
+	if len(report.Accounts) == 0 {
+		u.Err().Println("No accounts configured; run: gog auth add <email>")
+	}
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/googleauth"
+	"github.com/steipete/gogcli/internal/secrets"
+)
+
+func stubAuthDoctor(t *testing.T, store *memSecretsStore, granted []string, probe http.HandlerFunc) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+	t.Setenv("GOG_KEYRING_BACKEND", "file")
+
+	origOpen := openSecretsStore
+	origRefresh := doctorRefreshAccessToken
+	origInfo := doctorFetchTokenInfo
+	origLocked := doctorCheckKeychainLocked
+	origEndpoints := doctorReachabilityEndpoints
+	origProbes := doctorProbes
+	t.Cleanup(func() {
+		openSecretsStore = origOpen
+		doctorRefreshAccessToken = origRefresh
+		doctorFetchTokenInfo = origInfo
+		doctorCheckKeychainLocked = origLocked
+		doctorReachabilityEndpoints = origEndpoints
+		doctorProbes = origProbes
+	})
+
+	openSecretsStore = func() (secrets.Store, error) { return store, nil }
+	doctorCheckKeychainLocked = func() bool { return false }
+	doctorRefreshAccessToken = func(context.Context, string, string, []string, time.Duration) (*oauth2.Token, error) {
+		return &oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(time.Hour)}, nil
+	}
+	doctorFetchTokenInfo = func(context.Context, string, time.Duration) (googleauth.TokenInfo, error) {
+		return googleauth.TokenInfo{Email: "a@b.com", Scopes: granted}, nil
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		probe(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	doctorReachabilityEndpoints = []string{srv.URL + "/"}
+	doctorProbes = map[googleauth.Service]doctorProbe{
+		googleauth.ServiceGmail:    {URL: srv.URL + "/gmail"},
+		googleauth.ServiceCalendar: {URL: srv.URL + "/calendar"},
+	}
+
+	if err := config.WriteClientCredentialsFor(config.DefaultClientName, config.ClientCredentials{ClientID: "id", ClientSecret: "secret"}); err != nil {
+		t.Fatalf("write creds: %v", err)
+	}
+}
+
+type doctorTestReport struct {
+	OK       bool `json:"ok"`
+	Accounts []struct {
+		Email  string        `json:"email"`
+		Client string        `json:"client"`
+		Checks []doctorCheck `json:"checks"`
+	} `json:"accounts"`
+}
+
+func (r doctorTestReport) check(name string) (doctorCheck, bool) {
+	for _, a := range r.Accounts {
+		for _, c := range a.Checks {
+			if c.Name == name {
+				return c, true
+			}
+		}
+	}
+	return doctorCheck{}, false
+}
+
+func TestAuthDoctor_JSON_AllOK(t *testing.T) {
+	store := newMemSecretsStore()
+	_ = store.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "rt", Services: []string{"gmail"}})
+
+	gmailScopes, _ := googleauth.Scopes(googleauth.ServiceGmail)
+	stubAuthDoctor(t, store, gmailScopes, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	out := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--json", "auth", "doctor"}); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+		})
+	})
+
+	var report doctorTestReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\nout=%q", err, out)
+	}
+	if !report.OK || len(report.Accounts) != 1 || report.Accounts[0].Email != "a@b.com" {
+		t.Fatalf("unexpected report: %#v", report)
+	}
+	for _, name := range []string{"credentials", "refresh_token", "scopes gmail", "api gmail"} {
+		c, ok := report.check(name)
+		if !ok || c.Status != doctorStatusOK {
+			t.Fatalf("check %s: %#v ok=%v", name, c, ok)
+		}
+	}
+}
+
+func TestAuthDoctor_MissingScopesAndDisabledAPI(t *testing.T) {
+	store := newMemSecretsStore()
+	_ = store.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "rt", Services: []string{"gmail", "calendar"}})
+
+	gmailScopes, _ := googleauth.Scopes(googleauth.ServiceGmail)
+	stubAuthDoctor(t, store, gmailScopes, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/calendar" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":{"code":403,"message":"Calendar API has not been used in project 1 before or it is disabled.","errors":[{"reason":"accessNotConfigured"}]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	var execErr error
+	out := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			execErr = Execute([]string{"--json", "auth", "doctor"})
+		})
+	})
+	if execErr == nil {
+		t.Fatalf("expected failure exit")
+	}
+
+	var report doctorTestReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\nout=%q", err, out)
+	}
+	if report.OK {
+		t.Fatalf("expected ok=false")
+	}
+	scopes, _ := report.check("scopes calendar")
+	if scopes.Status != doctorStatusFail || !strings.Contains(scopes.Detail, "auth/calendar") || !strings.Contains(scopes.Hint, "gog auth add a@b.com --services gmail,calendar") {
+		t.Fatalf("unexpected scopes check: %#v", scopes)
+	}
+	api, _ := report.check("api calendar")
+	if api.Status != doctorStatusFail || !strings.Contains(api.Hint, "Enable Calendar API") {
+		t.Fatalf("unexpected api check: %#v", api)
+	}
+}
+
+func TestAuthDoctor_ReportsEveryClientForAnEmail(t *testing.T) {
+	store := newMemSecretsStore()
+	_ = store.SetToken(config.DefaultClientName, "a@b.com", secrets.Token{RefreshToken: "rt", Services: []string{"gmail"}})
+	_ = store.SetToken("work", "a@b.com", secrets.Token{RefreshToken: "rt2", Services: []string{"gmail"}})
+
+	gmailScopes, _ := googleauth.Scopes(googleauth.ServiceGmail)
+	stubAuthDoctor(t, store, gmailScopes, func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+
+	out := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--json", "auth", "doctor"}); err == nil {
+				t.Fatalf("expected failure for the client without credentials")
+			}
+		})
+	})
+
+	var report doctorTestReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\nout=%q", err, out)
+	}
+	if len(report.Accounts) != 2 {
+		t.Fatalf("expected one report per client, got %#v", report.Accounts)
+	}
+	for i, client := range []string{config.DefaultClientName, "work"} {
+		acct := report.Accounts[i]
+		if acct.Email != "a@b.com" || acct.Client != client || len(acct.Checks) == 0 {
+			t.Fatalf("unexpected account %d: %#v", i, acct)
+		}
+		want := doctorStatusOK
+		if client == "work" {
+			want = doctorStatusFail
+		}
+		if acct.Checks[0].Name != "credentials" || acct.Checks[0].Status != want {
+			t.Fatalf("client %s: unexpected credentials check %#v", client, acct.Checks[0])
+		}
+	}
+}
+
+func TestAuthDoctor_ReadonlyScopesOK(t *testing.T) {
+	checks := doctorScopeChecks([]string{"gmail"}, []string{"https://www.googleapis.com/auth/gmail.readonly"}, "gog auth add x")
+	if len(checks) != 1 || checks[0].Status != doctorStatusOK || checks[0].Detail != "readonly" {
+		t.Fatalf("unexpected checks: %#v", checks)
+	}
+}
+
+func TestDoctorClockCheck(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		offset time.Duration
+		want   string
+	}{
+		{2 * time.Second, doctorStatusOK},
+		{-2 * time.Minute, doctorStatusWarn},
+		{10 * time.Minute, doctorStatusFail},
+	}
+	for _, tc := range cases {
+		if got := doctorClockCheck(now, now.Add(tc.offset)); got.Status != tc.want {
+			t.Fatalf("offset %s: got %#v", tc.offset, got)
+		}
+	}
+	if got := doctorClockCheck(time.Time{}, now); got.Status != doctorStatusSkip {
+		t.Fatalf("expected skip, got %#v", got)
+	}
+}
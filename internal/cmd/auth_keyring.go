@@ -14,7 +14,7 @@ import (
 )
 
 type AuthKeyringCmd struct {
-	Backend  string `arg:"" optional:"" name:"backend" help:"Keyring backend: auto|keychain|file"`
+	Backend  string `arg:"" optional:"" name:"backend" help:"Keyring backend: auto|keychain|file|helper:<cmd>"`
 	Backend2 string `arg:"" optional:"" name:"backend2" help:"(compat) Use: gog auth keyring set <backend>"`
 }
 
@@ -23,8 +23,8 @@ func (c *AuthKeyringCmd) Run(ctx context.Context, flags *RootFlags) error {
 
 	const keyringPasswordEnv = "GOG_KEYRING_PASSWORD" //nolint:gosec // env var name, not a credential
 
-	backend := strings.ToLower(strings.TrimSpace(c.Backend))
-	backend2 := strings.ToLower(strings.TrimSpace(c.Backend2))
+	backend := secrets.NormalizeBackend(c.Backend)
+	backend2 := secrets.NormalizeBackend(c.Backend2)
 
 	// Backwards compat for earlier suggestion: `gog auth keyring set <backend>`.
 	if backend == "set" {
@@ -54,7 +54,7 @@ func (c *AuthKeyringCmd) Run(ctx context.Context, flags *RootFlags) error {
 		u.Out().Printf("path\t%s", path)
 		u.Out().Printf("keyring_backend\t%s", info.Value)
 		u.Out().Printf("source\t%s", info.Source)
-		u.Err().Println("Hint: gog auth keyring <auto|keychain|file>")
+		u.Err().Println("Hint: gog auth keyring <auto|keychain|file|helper:<cmd>>")
 		return nil
 	}
 
@@ -71,8 +71,12 @@ func (c *AuthKeyringCmd) Run(ctx context.Context, flags *RootFlags) error {
 		"keychain": {},
 		strFile:    {},
 	}
-	if _, ok := allowed[backend]; !ok {
-		return usagef("invalid backend: %q (expected auto, keychain, or file)", c.Backend)
+	if secrets.IsHelperBackend(backend) {
+		if _, err := secrets.HelperArgv(backend); err != nil {
+			return usagef("invalid backend: %q (%v)", c.Backend, err)
+		}
+	} else if _, ok := allowed[backend]; !ok {
+		return usagef("invalid backend: %q (expected auto, keychain, file, or helper:<cmd>)", c.Backend)
 	}
 
 	path, _ := config.ConfigPath()
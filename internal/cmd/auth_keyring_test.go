@@ -136,3 +136,41 @@ func TestAuthKeyringSet_InvalidBackend(t *testing.T) {
 		t.Fatalf("expected usage exit 2, got: %v", err)
 	}
 }
+
+func TestAuthKeyringSet_HelperBackend(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+	t.Setenv("GOG_KEYRING_BACKEND", "")
+
+	var stdout, stderr bytes.Buffer
+	u, err := ui.New(ui.Options{Stdout: &stdout, Stderr: &stderr, Color: "never"})
+	if err != nil {
+		t.Fatalf("ui new: %v", err)
+	}
+	ctx := ui.WithUI(context.Background(), u)
+	ctx = outfmt.WithMode(ctx, outfmt.Mode{})
+
+	if err = runKong(t, &AuthKeyringCmd{}, []string{"Helper:/opt/Vault/gog-helper --profile Work"}, ctx, nil); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	cfg, err := config.ReadConfig()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if cfg.KeyringBackend != "helper:/opt/Vault/gog-helper --profile Work" {
+		t.Fatalf("expected helper command preserved, got %q", cfg.KeyringBackend)
+	}
+
+	err = runKong(t, &AuthKeyringCmd{}, []string{"helper:"}, ctx, nil)
+	var ee *ExitError
+	if !errors.As(err, &ee) || ee.Code != 2 {
+		t.Fatalf("expected usage exit 2 for empty helper, got: %v", err)
+	}
+
+	err = runKong(t, &AuthKeyringCmd{}, []string{`helper:"/Applications/My Vault/helper`}, ctx, nil)
+	if !errors.As(err, &ee) || ee.Code != 2 {
+		t.Fatalf("expected usage exit 2 for unterminated quote, got: %v", err)
+	}
+}
@@ -190,7 +190,13 @@ func deleteDriveFileBestEffort(ctx context.Context, driveSvc *drive.Service, fil
 }
 
 func resolveMarkdownImagePath(markdownFilePath string, imageRef string) (string, error) {
-	mdDir, err := filepath.Abs(filepath.Dir(markdownFilePath))
+	return resolveImageWithinDir(filepath.Dir(markdownFilePath), imageRef)
+}
+
+// resolveImageWithinDir resolves imageRef against dir (following symlinks)
+// and rejects paths that end up outside dir.
+func resolveImageWithinDir(dir string, imageRef string) (string, error) {
+	mdDir, err := filepath.Abs(dir)
 	if err != nil {
 		return "", fmt.Errorf("resolve markdown directory: %w", err)
 	}
@@ -31,6 +31,14 @@ func TestStableExitCode_AuthRequired(t *testing.T) {
 	}
 }
 
+func TestStableExitCode_InsufficientScopes(t *testing.T) {
+	in := &gogapi.InsufficientScopesError{Service: "gmail", Email: "a@b.com", Cause: &ggoogleapi.Error{Code: 403}}
+	out := stableExitCode(in)
+	if got := ExitCode(out); got != exitCodeAuthRequired {
+		t.Fatalf("expected exit code %d, got %d", exitCodeAuthRequired, got)
+	}
+}
+
 func TestStableExitCode_CredentialsMissing(t *testing.T) {
 	in := &config.CredentialsMissingError{Path: "/tmp/credentials.json", Cause: errors.New("missing")}
 	out := stableExitCode(in)
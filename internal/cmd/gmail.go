@@ -19,20 +19,27 @@ import (
 var newGmailService = googleapi.NewGmail
 
 type GmailCmd struct {
-	Search     GmailSearchCmd     `cmd:"" name:"search" aliases:"find,query,ls,list" group:"Read" help:"Search threads using Gmail query syntax"`
-	Messages   GmailMessagesCmd   `cmd:"" name:"messages" aliases:"message,msg,msgs" group:"Read" help:"Message operations"`
-	Thread     GmailThreadCmd     `cmd:"" name:"thread" aliases:"threads,read" group:"Organize" help:"Thread operations (get, modify)"`
-	Get        GmailGetCmd        `cmd:"" name:"get" aliases:"info,show" group:"Read" help:"Get a message (full|metadata|raw)"`
-	Attachment GmailAttachmentCmd `cmd:"" name:"attachment" group:"Read" help:"Download a single attachment"`
-	URL        GmailURLCmd        `cmd:"" name:"url" group:"Read" help:"Print Gmail web URLs for threads"`
-	History    GmailHistoryCmd    `cmd:"" name:"history" group:"Read" help:"Gmail history"`
+	Search      GmailSearchCmd      `cmd:"" name:"search" aliases:"find,query,ls,list" group:"Read" help:"Search threads using Gmail query syntax"`
+	Export      GmailExportCmd      `cmd:"" name:"export" group:"Read" help:"Export matching messages to mbox, .eml files, or Maildir"`
+	Sync        GmailSyncCmd        `cmd:"" name:"sync" group:"Read" help:"Mirror the mailbox into a local Maildir (incremental via history)"`
+	Messages    GmailMessagesCmd    `cmd:"" name:"messages" aliases:"message,msg,msgs" group:"Read" help:"Message operations"`
+	Thread      GmailThreadCmd      `cmd:"" name:"thread" aliases:"threads,read" group:"Organize" help:"Thread operations (get, modify)"`
+	Get         GmailGetCmd         `cmd:"" name:"get" aliases:"info,show" group:"Read" help:"Get a message (full|metadata|raw)"`
+	Attachment  GmailAttachmentCmd  `cmd:"" name:"attachment" group:"Read" help:"Download a single attachment"`
+	Attachments GmailAttachmentsCmd `cmd:"" name:"attachments" group:"Read" help:"Bulk attachment download by query"`
+	URL         GmailURLCmd         `cmd:"" name:"url" group:"Read" help:"Print Gmail web URLs for threads"`
+	History     GmailHistoryCmd     `cmd:"" name:"history" group:"Read" help:"Gmail history"`
 
 	Labels GmailLabelsCmd `cmd:"" name:"labels" aliases:"label" group:"Organize" help:"Label operations"`
 	Batch  GmailBatchCmd  `cmd:"" name:"batch" group:"Organize" help:"Batch operations"`
 
-	Send   GmailSendCmd   `cmd:"" name:"send" group:"Write" help:"Send an email"`
-	Track  GmailTrackCmd  `cmd:"" name:"track" group:"Write" help:"Email open tracking"`
-	Drafts GmailDraftsCmd `cmd:"" name:"drafts" aliases:"draft" group:"Write" help:"Draft operations"`
+	Send    GmailSendCmd    `cmd:"" name:"send" group:"Write" help:"Send an email"`
+	Forward GmailForwardCmd `cmd:"" name:"forward" aliases:"fwd" group:"Write" help:"Forward a message (inline or as .eml attachment)"`
+	Merge   GmailMergeCmd   `cmd:"" name:"merge" group:"Write" help:"Send personalized messages from CSV/JSON rows and templates"`
+	Track   GmailTrackCmd   `cmd:"" name:"track" group:"Write" help:"Email open tracking"`
+	SMIME   GmailSMIMECmd   `cmd:"" name:"smime" group:"Write" help:"S/MIME keys and recipient certificates"`
+	Drafts  GmailDraftsCmd  `cmd:"" name:"drafts" aliases:"draft" group:"Write" help:"Draft operations"`
+	Import  GmailImportCmd  `cmd:"" name:"import" group:"Write" help:"Import mbox, .eml files, or Maildir into the mailbox"`
 
 	Settings GmailSettingsCmd `cmd:"" name:"settings" group:"Admin" help:"Settings and admin"`
 
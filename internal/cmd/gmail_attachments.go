@@ -15,6 +15,7 @@ type attachmentInfo struct {
 	Size         int64
 	MimeType     string
 	AttachmentID string
+	PartID       string
 }
 
 type attachmentOutput struct {
@@ -188,6 +189,7 @@ func collectAttachments(p *gmail.MessagePart) []attachmentInfo {
 			Size:         p.Body.Size,
 			MimeType:     p.MimeType,
 			AttachmentID: p.Body.AttachmentId,
+			PartID:       p.PartId,
 		})
 	}
 	for _, part := range p.Parts {
@@ -0,0 +1,506 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	defaultAttachmentsManifest = "manifest.json"
+	attachmentsPathSegmentMax  = 120
+)
+
+type GmailAttachmentsCmd struct {
+	Download GmailAttachmentsDownloadCmd `cmd:"" name:"download" help:"Download attachments of every message matching a query"`
+}
+
+type GmailAttachmentsDownloadCmd struct {
+	Query            []string `arg:"" name:"query" help:"Search query (Gmail query syntax; has:attachment is added)"`
+	Out              string   `name:"out" aliases:"output" help:"Output directory" required:""`
+	Path             string   `name:"path" help:"File path template under --out; placeholders: {date} {year} {month} {from} {messageId} {threadId} {subject} {filename} {name} {ext} {hash}" default:"{date}/{from}/{filename}"`
+	Types            []string `name:"type" aliases:"mime-type" help:"Only attachments with this MIME type (repeatable, comma-separated; image/* wildcards)"`
+	Exts             []string `name:"ext" help:"Only attachments with this file extension (repeatable, comma-separated, e.g. pdf,xlsx)"`
+	MinSize          string   `name:"min-size" help:"Skip attachments smaller than this (bytes, or 10KB, 2MB, ...)"`
+	MaxSize          string   `name:"max-size" help:"Skip attachments larger than this (bytes, or 10KB, 2MB, ...)"`
+	Max              int64    `name:"max" aliases:"limit" help:"Max messages to scan (0 = all)" default:"0"`
+	IncludeSpamTrash bool     `name:"include-spam-trash" help:"Include messages from Spam and Trash"`
+	Manifest         string   `name:"manifest" help:"Manifest path (default: <out>/manifest.json)"`
+	Timezone         string   `name:"timezone" short:"z" help:"Timezone for {date}/{year}/{month} (IANA name, e.g. America/New_York, UTC). Default: local"`
+	Local            bool     `name:"local" help:"Use local timezone (default behavior, useful to override --timezone)"`
+}
+
+// attachmentsManifest records every saved file by content hash, with the
+// messages it was found in. Paths are relative to the output directory.
+type attachmentsManifest struct {
+	Query       string                    `json:"query,omitempty"`
+	UpdatedAt   string                    `json:"updatedAt,omitempty"`
+	Files       []*attachmentManifestFile `json:"files"`
+	byHash      map[string]*attachmentManifestFile
+	seenSources map[string]bool
+}
+
+type attachmentManifestFile struct {
+	Path     string                     `json:"path"`
+	SHA256   string                     `json:"sha256"`
+	Size     int64                      `json:"size"`
+	MimeType string                     `json:"mimeType,omitempty"`
+	Sources  []attachmentManifestSource `json:"messages"`
+}
+
+type attachmentManifestSource struct {
+	MessageID string `json:"messageId"`
+	ThreadID  string `json:"threadId,omitempty"`
+	PartID    string `json:"partId,omitempty"`
+	Filename  string `json:"filename"`
+	From      string `json:"from,omitempty"`
+	Date      string `json:"date,omitempty"`
+}
+
+type attachmentFilter struct {
+	types   []string
+	exts    map[string]struct{}
+	minSize int64
+	maxSize int64
+}
+
+type savedAttachment struct {
+	Path      string `json:"path"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+	MessageID string `json:"messageId"`
+	Filename  string `json:"filename"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+func (c *GmailAttachmentsDownloadCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	query := strings.TrimSpace(strings.Join(c.Query, " "))
+	if query == "" {
+		return usage("missing query")
+	}
+	outDir, err := config.ExpandPath(strings.TrimSpace(c.Out))
+	if err != nil {
+		return err
+	}
+	if outDir == "" {
+		return usage("empty --out")
+	}
+	if err = validateAttachmentsPathTemplate(c.Path); err != nil {
+		return err
+	}
+	filter, err := c.filter()
+	if err != nil {
+		return err
+	}
+	loc, err := resolveOutputLocation(c.Timezone, c.Local)
+	if err != nil {
+		return err
+	}
+	manifestPath := strings.TrimSpace(c.Manifest)
+	if manifestPath == "" {
+		manifestPath = filepath.Join(outDir, defaultAttachmentsManifest)
+	} else if manifestPath, err = config.ExpandPath(manifestPath); err != nil {
+		return err
+	}
+
+	searchQuery := query
+	if !strings.Contains(strings.ToLower(query), "has:attachment") {
+		searchQuery += " has:attachment"
+	}
+
+	if dryRunErr := dryRunExit(ctx, flags, "gmail.attachments.download", map[string]any{
+		"query":    searchQuery,
+		"out":      outDir,
+		"path":     c.Path,
+		"manifest": manifestPath,
+		"types":    filter.types,
+		"exts":     sortedKeys(filter.exts),
+		"min_size": filter.minSize,
+		"max_size": filter.maxSize,
+	}); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+	manifest, err := readAttachmentsManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	manifest.Query = query
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+	ids, err := listGmailMessageIDs(ctx, svc, searchQuery, c.IncludeSpamTrash, c.Max)
+	if err != nil {
+		return err
+	}
+
+	var saved []savedAttachment
+	skipped, filtered := 0, 0
+	err = fetchGmailMessages(ctx, svc, ids, gmailFormatFull, func(id string, msg *gmail.Message, _ []byte, fetchErr error) error {
+		if fetchErr != nil {
+			return fetchErr
+		}
+		for _, a := range collectAttachments(msg.Payload) {
+			if !filter.matches(a) {
+				filtered++
+				continue
+			}
+			if manifest.seenSources[attachmentSourceKey(id, a.PartID, a.Filename)] {
+				skipped++
+				continue
+			}
+			data, getErr := fetchAttachmentBytes(ctx, svc, id, a.AttachmentID)
+			if getErr != nil {
+				return fmt.Errorf("message %s attachment %s: %w", id, a.Filename, getErr)
+			}
+			result, saveErr := manifest.save(outDir, c.Path, msg, a, data, loc)
+			if saveErr != nil {
+				return saveErr
+			}
+			saved = append(saved, result)
+			if !outfmt.IsJSON(ctx) && len(saved)%gmailExportProgressEvery == 0 {
+				u.Err().Printf("saved %d attachments", len(saved))
+			}
+		}
+		return nil
+	})
+	if len(saved) > 0 {
+		manifest.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		if writeErr := writeAttachmentsManifest(manifestPath, manifest); writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	downloaded, duplicates := 0, 0
+	for _, s := range saved {
+		if s.Duplicate {
+			duplicates++
+		} else {
+			downloaded++
+		}
+	}
+	if outfmt.IsJSON(ctx) {
+		if saved == nil {
+			saved = []savedAttachment{}
+		}
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"out":        outDir,
+			"manifest":   manifestPath,
+			"matched":    len(ids),
+			"downloaded": downloaded,
+			"duplicates": duplicates,
+			"skipped":    skipped,
+			"filtered":   filtered,
+			"files":      saved,
+		})
+	}
+
+	for _, s := range saved {
+		if s.Duplicate {
+			u.Out().Printf("duplicate\t%s\t%s\t%s", s.MessageID, s.Filename, s.Path)
+			continue
+		}
+		u.Out().Printf("saved\t%s\t%s\t%s", s.MessageID, formatBytes(s.Size), s.Path)
+	}
+	u.Out().Printf("matched\t%d", len(ids))
+	u.Out().Printf("downloaded\t%d", downloaded)
+	u.Out().Printf("duplicates\t%d", duplicates)
+	u.Out().Printf("skipped\t%d", skipped)
+	u.Out().Printf("filtered\t%d", filtered)
+	u.Out().Printf("manifest\t%s", manifestPath)
+	return nil
+}
+
+func (c *GmailAttachmentsDownloadCmd) filter() (attachmentFilter, error) {
+	f := attachmentFilter{exts: map[string]struct{}{}}
+	for _, t := range splitCommaList(strings.Join(c.Types, ",")) {
+		f.types = append(f.types, strings.ToLower(t))
+	}
+	for _, ext := range splitCommaList(strings.Join(c.Exts, ",")) {
+		f.exts[strings.ToLower(strings.TrimPrefix(ext, "."))] = struct{}{}
+	}
+	var err error
+	if f.minSize, err = parseByteSize(c.MinSize); err != nil {
+		return f, usagef("--min-size: %v", err)
+	}
+	if f.maxSize, err = parseByteSize(c.MaxSize); err != nil {
+		return f, usagef("--max-size: %v", err)
+	}
+	if f.maxSize > 0 && f.minSize > f.maxSize {
+		return f, usage("--min-size must be <= --max-size")
+	}
+	return f, nil
+}
+
+func (f attachmentFilter) matches(a attachmentInfo) bool {
+	if len(f.types) > 0 {
+		mimeType := strings.ToLower(a.MimeType)
+		ok := false
+		for _, t := range f.types {
+			if mimeType == t || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(t, "*"))) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.exts) > 0 {
+		if _, ok := f.exts[strings.ToLower(strings.TrimPrefix(filepath.Ext(a.Filename), "."))]; !ok {
+			return false
+		}
+	}
+	if f.minSize > 0 && a.Size < f.minSize {
+		return false
+	}
+	if f.maxSize > 0 && a.Size > f.maxSize {
+		return false
+	}
+	return true
+}
+
+var byteSizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgt]?)(i?b?)$`)
+
+// parseByteSize parses a size like "1048576", "500k", "10KB", or "1.5MiB"
+// (1024-based). An empty string is 0.
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, nil
+	}
+	m := byteSizePattern.FindStringSubmatch(s)
+	if m == nil || (m[2] == "" && m[3] == "i") {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	shift := strings.Index("kmgt", m[2]) + 1
+	if m[2] == "" {
+		shift = 0
+	}
+	return int64(n * float64(int64(1)<<(10*shift))), nil
+}
+
+var attachmentsPlaceholder = regexp.MustCompile(`\{([A-Za-z]+)\}`)
+
+var attachmentsPlaceholders = map[string]bool{
+	"date": true, "year": true, "month": true, "from": true, "messageId": true, "threadId": true,
+	"subject": true, "filename": true, "name": true, "ext": true, "hash": true,
+}
+
+func validateAttachmentsPathTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return usage("empty --path")
+	}
+	for _, m := range attachmentsPlaceholder.FindAllStringSubmatch(tmpl, -1) {
+		if !attachmentsPlaceholders[m[1]] {
+			return usagef("--path: unknown placeholder {%s}", m[1])
+		}
+	}
+	if !strings.Contains(tmpl, "{filename}") && !strings.Contains(tmpl, "{name}") && !strings.Contains(tmpl, "{hash}") {
+		return usage("--path must contain {filename}, {name}, or {hash}")
+	}
+	return nil
+}
+
+// renderAttachmentsPath expands the path template into a relative,
+// slash-separated path. Each segment is sanitized, so values can't add
+// directories or escape the output directory.
+func renderAttachmentsPath(tmpl string, msg *gmail.Message, a attachmentInfo, sum string, loc *time.Location) string {
+	filename := sanitizeAttachmentFilename(a.Filename, "attachment")
+	ext := filepath.Ext(filename)
+	date := gmailInternalDate(msg).In(loc)
+	from := ""
+	if addrs := parseEmailAddresses(headerValue(msg.Payload, "From")); len(addrs) > 0 {
+		from = addrs[0]
+	}
+	values := map[string]string{
+		"date":      date.Format("2006-01-02"),
+		"year":      date.Format("2006"),
+		"month":     date.Format("01"),
+		"from":      from,
+		"messageId": msg.Id,
+		"threadId":  msg.ThreadId,
+		"subject":   headerValue(msg.Payload, "Subject"),
+		"filename":  filename,
+		"name":      strings.TrimSuffix(filename, ext),
+		"ext":       strings.TrimPrefix(ext, "."),
+		"hash":      sum[:12],
+	}
+
+	segments := strings.Split(strings.ReplaceAll(tmpl, "\\", "/"), "/")
+	out := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		rendered := attachmentsPlaceholder.ReplaceAllStringFunc(seg, func(p string) string {
+			return values[p[1:len(p)-1]]
+		})
+		out = append(out, sanitizeAttachmentsPathSegment(rendered))
+	}
+	return path.Join(out...)
+}
+
+func sanitizeAttachmentsPathSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, s)
+	s = strings.TrimSpace(s)
+	if runes := []rune(s); len(runes) > attachmentsPathSegmentMax {
+		s = strings.TrimSpace(string(runes[:attachmentsPathSegmentMax]))
+	}
+	if strings.Trim(s, ".") == "" {
+		return "_"
+	}
+	return s
+}
+
+func attachmentSourceKey(messageID, partID, filename string) string {
+	return messageID + "/" + partID + "/" + filename
+}
+
+// save records one attachment: content already in the manifest only gains
+// a message reference; new content is written under outDir, with a numeric
+// suffix when the rendered path is taken by a different file.
+func (m *attachmentsManifest) save(outDir, tmpl string, msg *gmail.Message, a attachmentInfo, data []byte, loc *time.Location) (savedAttachment, error) {
+	digest := sha256.Sum256(data)
+	sum := hex.EncodeToString(digest[:])
+	source := attachmentManifestSource{
+		MessageID: msg.Id,
+		ThreadID:  msg.ThreadId,
+		PartID:    a.PartID,
+		Filename:  a.Filename,
+		From:      headerValue(msg.Payload, "From"),
+	}
+	if date := gmailInternalDate(msg); !date.IsZero() {
+		source.Date = date.UTC().Format(time.RFC3339)
+	}
+	result := savedAttachment{SHA256: sum, Size: int64(len(data)), MessageID: msg.Id, Filename: a.Filename}
+	m.seenSources[attachmentSourceKey(msg.Id, a.PartID, a.Filename)] = true
+
+	if file := m.byHash[sum]; file != nil {
+		file.Sources = append(file.Sources, source)
+		result.Path = file.Path
+		result.Duplicate = true
+		return result, nil
+	}
+
+	rel, err := m.freePath(outDir, renderAttachmentsPath(tmpl, msg, a, sum, loc), sum)
+	if err != nil {
+		return result, err
+	}
+	if err := writeFileAtomic(filepath.Join(outDir, filepath.FromSlash(rel)), data); err != nil {
+		return result, err
+	}
+	file := &attachmentManifestFile{Path: rel, SHA256: sum, Size: int64(len(data)), MimeType: a.MimeType, Sources: []attachmentManifestSource{source}}
+	m.Files = append(m.Files, file)
+	m.byHash[sum] = file
+	result.Path = rel
+	return result, nil
+}
+
+// freePath returns rel, or rel with a "-2", "-3", ... suffix before the
+// extension, whichever is not yet used by the manifest or a different file
+// on disk. A file on disk with the same content is reused.
+func (m *attachmentsManifest) freePath(outDir, rel, sum string) (string, error) {
+	used := make(map[string]bool, len(m.Files))
+	for _, f := range m.Files {
+		used[f.Path] = true
+	}
+	ext := path.Ext(rel)
+	base := strings.TrimSuffix(rel, ext)
+	for i := 1; ; i++ {
+		candidate := rel
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		if used[candidate] {
+			continue
+		}
+		existing, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(candidate)))
+		if errors.Is(err, os.ErrNotExist) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if digest := sha256.Sum256(existing); hex.EncodeToString(digest[:]) == sum {
+			return candidate, nil
+		}
+	}
+}
+
+func readAttachmentsManifest(manifestPath string) (*attachmentsManifest, error) {
+	m := &attachmentsManifest{byHash: map[string]*attachmentManifestFile{}, seenSources: map[string]bool{}}
+	data, err := os.ReadFile(manifestPath) //nolint:gosec // user-provided path
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return m, nil
+		}
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("read manifest %s: %w", manifestPath, err)
+	}
+	for _, f := range m.Files {
+		if f == nil {
+			continue
+		}
+		m.byHash[f.SHA256] = f
+		for _, s := range f.Sources {
+			m.seenSources[attachmentSourceKey(s.MessageID, s.PartID, s.Filename)] = true
+		}
+	}
+	return m, nil
+}
+
+func writeAttachmentsManifest(manifestPath string, m *attachmentsManifest) error {
+	sort.SliceStable(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(manifestPath, append(data, '\n')); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
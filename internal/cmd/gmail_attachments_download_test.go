@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+type fakeAttachmentPart struct {
+	partID, filename, mimeType, data string
+	size                             int64
+}
+
+type fakeGmailAttachmentsServer struct {
+	mu       sync.Mutex
+	order    []string
+	from     map[string]string
+	dates    map[string]string
+	parts    map[string][]fakeAttachmentPart
+	fetches  int
+	attachID int
+}
+
+func (f *fakeGmailAttachmentsServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		switch {
+		case strings.HasSuffix(path, "/users/me/messages"):
+			if q := r.URL.Query().Get("q"); q != "from:invoices has:attachment" {
+				t.Errorf("unexpected query %q", q)
+			}
+			msgs := make([]map[string]any, 0, len(f.order))
+			for _, id := range f.order {
+				msgs = append(msgs, map[string]any{"id": id})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"messages": msgs})
+		case strings.Contains(path, "/attachments/"):
+			// Attachment IDs change on every fetch, like Gmail's.
+			id := path[strings.LastIndex(path, "/")+1:]
+			f.fetches++
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": base64.RawURLEncoding.EncodeToString([]byte(strings.SplitN(id, "~", 2)[1]))})
+		case strings.Contains(path, "/users/me/messages/"):
+			id := path[strings.LastIndex(path, "/")+1:]
+			parts := []map[string]any{{"partId": "0", "mimeType": "text/plain", "body": map[string]any{"size": 2, "data": "aGk"}}}
+			for _, p := range f.parts[id] {
+				f.attachID++
+				size := p.size
+				if size == 0 {
+					size = int64(len(p.data))
+				}
+				parts = append(parts, map[string]any{
+					"partId": p.partID, "filename": p.filename, "mimeType": p.mimeType,
+					"body": map[string]any{"size": size, "attachmentId": strings.Repeat("x", f.attachID) + "~" + p.data},
+				})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id": id, "threadId": "t" + id, "internalDate": f.dates[id],
+				"payload": map[string]any{
+					"mimeType": "multipart/mixed",
+					"headers":  []map[string]any{{"name": "From", "value": f.from[id]}, {"name": "Subject", "value": "Invoice"}},
+					"parts":    parts,
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func TestGmailAttachmentsDownload_DedupeAndManifest(t *testing.T) {
+	jan := time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC).UnixMilli()
+	feb := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC).UnixMilli()
+	fake := &fakeGmailAttachmentsServer{
+		order: []string{"m1", "m2", "m3"},
+		from: map[string]string{
+			"m1": "Vendor Billing <Invoices@Vendor.com>",
+			"m2": "invoices@vendor.com",
+			"m3": "Other <other@example.com>",
+		},
+		dates: map[string]string{"m1": strconv.FormatInt(jan, 10), "m2": strconv.FormatInt(feb, 10), "m3": strconv.FormatInt(feb, 10)},
+		parts: map[string][]fakeAttachmentPart{
+			"m1": {{"1", "inv.pdf", "application/pdf", "invoice-one", 0}, {"2", "logo.png", "image/png", "png", 0}},
+			"m2": {{"1", "copy.PDF", "application/pdf", "invoice-one", 0}, {"2", "big.pdf", "application/pdf", "big", 5 << 20}},
+			"m3": {{"1", "report.pdf", "application/pdf", "report", 0}},
+		},
+	}
+	svc, closeSrv := newGmailServiceForTest(t, fake.handler(t))
+	t.Cleanup(closeSrv)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	out := t.TempDir()
+	// A different file already sits where report.pdf would go.
+	taken := filepath.Join(out, "2025-02-01", "other@example.com", "report.pdf")
+	if err := os.MkdirAll(filepath.Dir(taken), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(taken, []byte("unrelated"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	args := []string{"--json", "--account", "a@b.com", "gmail", "attachments", "download", "from:invoices",
+		"--out", out, "--ext", "pdf", "--max-size", "1MB", "--timezone", "UTC"}
+	run := func() map[string]any {
+		t.Helper()
+		stdout := captureStdout(t, func() {
+			if err := Execute(args); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+		})
+		var result map[string]any
+		if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+			t.Fatalf("unmarshal: %v (%q)", err, stdout)
+		}
+		return result
+	}
+
+	result := run()
+	if result["matched"] != 3.0 || result["downloaded"] != 2.0 || result["duplicates"] != 1.0 || result["filtered"] != 2.0 || result["skipped"] != 0.0 {
+		t.Fatalf("unexpected result: %v", result)
+	}
+	for rel, want := range map[string]string{
+		"2025-01-03/invoices@vendor.com/inv.pdf":    "invoice-one",
+		"2025-02-01/other@example.com/report-2.pdf": "report",
+		"2025-02-01/other@example.com/report.pdf":   "unrelated",
+	} {
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(rel)))
+		if err != nil || string(data) != want {
+			t.Fatalf("%s: got %q (%v), want %q", rel, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "2025-02-01", "invoices@vendor.com")); !os.IsNotExist(err) {
+		t.Fatalf("duplicate should not be written: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "manifest.json"))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var manifest attachmentsManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("manifest: %v", err)
+	}
+	if manifest.Query != "from:invoices" || len(manifest.Files) != 2 {
+		t.Fatalf("unexpected manifest: %s", data)
+	}
+	inv := manifest.Files[0]
+	if inv.Path != "2025-01-03/invoices@vendor.com/inv.pdf" || inv.MimeType != "application/pdf" || len(inv.SHA256) != 64 ||
+		len(inv.Sources) != 2 || inv.Sources[0].MessageID != "m1" || inv.Sources[1].MessageID != "m2" || inv.Sources[1].Filename != "copy.PDF" {
+		t.Fatalf("unexpected invoice entry: %+v", inv)
+	}
+
+	// A second run skips what the manifest already has, without downloading.
+	fetches := fake.fetches
+	result = run()
+	if result["downloaded"] != 0.0 || result["duplicates"] != 0.0 || result["skipped"] != 3.0 || fake.fetches != fetches {
+		t.Fatalf("unexpected rerun: %v (fetches %d -> %d)", result, fetches, fake.fetches)
+	}
+}
+
+func TestGmailAttachmentsDownload_Flags(t *testing.T) {
+	for _, args := range [][]string{
+		{"--path", "{date}/{from}"},
+		{"--path", "{date}/{sender}/{filename}"},
+		{"--min-size", "lots"},
+		{"--min-size", "2MB", "--max-size", "1MB"},
+	} {
+		full := append([]string{"--account", "a@b.com", "gmail", "attachments", "download", "q", "--out", t.TempDir()}, args...)
+		_ = captureStderr(t, func() {
+			if err := Execute(full); err == nil {
+				t.Fatalf("%v: expected error", args)
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"":       0,
+		"512":    512,
+		"10b":    10,
+		"2k":     2048,
+		"10KB":   10240,
+		"1.5MiB": 1572864,
+		"1 GB":   1 << 30,
+	} {
+		got, err := parseByteSize(in)
+		if err != nil || got != want {
+			t.Fatalf("%q: got %d (%v), want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"x", "-1", "10i", "5PB"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Fatalf("%q: expected error", in)
+		}
+	}
+}
+
+func TestRenderAttachmentsPath(t *testing.T) {
+	msg := &gmail.Message{
+		Id:           "m1",
+		ThreadId:     "t1",
+		InternalDate: time.Date(2025, 3, 9, 23, 30, 0, 0, time.UTC).UnixMilli(),
+		Payload: &gmail.MessagePart{Headers: []*gmail.MessagePartHeader{
+			{Name: "From", Value: "A <a@b.com>"},
+			{Name: "Subject", Value: "Re: Q1/Q2 <report>"},
+		}},
+	}
+	a := attachmentInfo{Filename: "../../etc/passwd.txt"}
+	ny, _ := time.LoadLocation("America/New_York")
+
+	got := renderAttachmentsPath("{year}/{month}/{subject}/{messageId}-{name}.{hash}.{ext}", msg, a, strings.Repeat("ab", 32), ny)
+	if want := "2025/03/Re_ Q1_Q2 _report_/m1-passwd.abababababab.txt"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if got := renderAttachmentsPath("{from}/../{filename}", msg, attachmentInfo{Filename: ".."}, strings.Repeat("0", 64), time.UTC); got != "a@b.com/_/attachment" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -3,7 +3,9 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"strings"
 
 	"google.golang.org/api/gmail/v1"
 
@@ -11,31 +13,100 @@ import (
 	"github.com/steipete/gogcli/internal/ui"
 )
 
+// gmailBatchChunkSize is the most IDs batchModify/batchDelete accept per call.
+const gmailBatchChunkSize = 1000
+
 type GmailBatchCmd struct {
 	Delete GmailBatchDeleteCmd `cmd:"" name:"delete" aliases:"rm,del,remove" help:"Permanently delete multiple messages"`
 	Modify GmailBatchModifyCmd `cmd:"" name:"modify" aliases:"update,edit,set" help:"Modify labels on multiple messages"`
+	Trash  GmailBatchTrashCmd  `cmd:"" name:"trash" help:"Move multiple messages to Trash"`
 }
 
-type GmailBatchDeleteCmd struct {
-	MessageIDs []string `arg:"" name:"messageId" help:"Message IDs"`
+// gmailBatchTarget selects messages either by explicit IDs or by a search
+// query that is paged through completely.
+type gmailBatchTarget struct {
+	MessageIDs       []string `arg:"" optional:"" name:"messageId" help:"Message IDs"`
+	Query            string   `name:"query" help:"Select all messages matching this Gmail search query instead of listing IDs"`
+	IncludeSpamTrash bool     `name:"include-spam-trash" help:"With --query, also match messages in Spam and Trash"`
+	Max              int64    `name:"max" help:"With --query, stop after this many matches (0 = all)"`
 }
 
-func (c *GmailBatchDeleteCmd) Run(ctx context.Context, flags *RootFlags) error {
-	u := ui.FromContext(ctx)
-	ids := make([]string, 0, len(c.MessageIDs))
-	for _, id := range c.MessageIDs {
+// explicitIDs validates the selection and returns the normalized IDs given
+// on the command line (nil when --query is used).
+func (t gmailBatchTarget) explicitIDs() ([]string, error) {
+	ids := make([]string, 0, len(t.MessageIDs))
+	for _, id := range t.MessageIDs {
 		id = normalizeGmailMessageID(id)
 		if id == "" {
 			continue
 		}
 		ids = append(ids, id)
 	}
-	if len(ids) == 0 {
-		return usage("missing messageId")
+	query := strings.TrimSpace(t.Query)
+	switch {
+	case query != "" && len(ids) > 0:
+		return nil, usage("use either message IDs or --query, not both")
+	case query == "" && len(ids) == 0:
+		return nil, usage("missing messageId (or --query)")
+	case query == "" && (t.IncludeSpamTrash || t.Max != 0):
+		return nil, usage("--include-spam-trash and --max require --query")
+	case t.Max < 0:
+		return nil, usage("--max must be >= 0")
+	}
+	if query != "" {
+		return nil, nil
+	}
+	return ids, nil
+}
+
+// matchingIDs pages through every message matching --query.
+func (t gmailBatchTarget) matchingIDs(ctx context.Context, u *ui.UI, svc *gmail.Service) ([]string, error) {
+	ids, err := listGmailMessageIDs(ctx, svc, strings.TrimSpace(t.Query), t.IncludeSpamTrash, t.Max)
+	if err != nil {
+		return nil, err
+	}
+	if !outfmt.IsJSON(ctx) {
+		u.Err().Printf("matched %d messages", len(ids))
 	}
+	return ids, nil
+}
 
-	if confirmErr := confirmDestructive(ctx, flags, "permanently delete gmail messages"); confirmErr != nil {
-		return confirmErr
+// runGmailBatchChunks calls fn for consecutive chunks of at most
+// gmailBatchChunkSize IDs, reporting progress when there is more than one.
+// It returns the number of chunks processed.
+func runGmailBatchChunks(ctx context.Context, u *ui.UI, verb string, ids []string, fn func(chunk []string) error) (int, error) {
+	chunks := 0
+	for start := 0; start < len(ids); start += gmailBatchChunkSize {
+		end := min(start+gmailBatchChunkSize, len(ids))
+		if err := fn(ids[start:end]); err != nil {
+			if start > 0 {
+				return chunks, fmt.Errorf("%s %d of %d messages, then failed: %w", verb, start, len(ids), err)
+			}
+			return chunks, err
+		}
+		chunks++
+		if len(ids) > gmailBatchChunkSize && !outfmt.IsJSON(ctx) {
+			u.Err().Printf("%s %d/%d", verb, end, len(ids))
+		}
+	}
+	return chunks, nil
+}
+
+type GmailBatchDeleteCmd struct {
+	Target gmailBatchTarget `embed:""`
+}
+
+func (c *GmailBatchDeleteCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	ids, err := c.Target.explicitIDs()
+	if err != nil {
+		return err
+	}
+
+	if ids != nil {
+		if confirmErr := confirmDestructive(ctx, flags, "permanently delete gmail messages"); confirmErr != nil {
+			return confirmErr
+		}
 	}
 
 	account, err := requireAccount(flags)
@@ -48,9 +119,29 @@ func (c *GmailBatchDeleteCmd) Run(ctx context.Context, flags *RootFlags) error {
 		return err
 	}
 
-	err = svc.Users.Messages.BatchDelete("me", &gmail.BatchDeleteMessagesRequest{
-		Ids: ids,
-	}).Do()
+	if ids == nil {
+		if ids, err = c.Target.matchingIDs(ctx, u, svc); err != nil {
+			return err
+		}
+		if dryRunErr := dryRunExit(ctx, flags, "gmail.batch.delete", map[string]any{
+			"query": strings.TrimSpace(c.Target.Query),
+			"count": len(ids),
+		}); dryRunErr != nil {
+			return dryRunErr
+		}
+		if len(ids) > 0 {
+			action := fmt.Sprintf("permanently delete %d gmail messages matching %q", len(ids), strings.TrimSpace(c.Target.Query))
+			if confirmErr := confirmDestructive(ctx, flags, action); confirmErr != nil {
+				return confirmErr
+			}
+		}
+	}
+
+	chunks, err := runGmailBatchChunks(ctx, u, "deleted", ids, func(chunk []string) error {
+		return svc.Users.Messages.BatchDelete("me", &gmail.BatchDeleteMessagesRequest{
+			Ids: chunk,
+		}).Context(ctx).Do()
+	})
 	if err != nil {
 		return err
 	}
@@ -59,6 +150,7 @@ func (c *GmailBatchDeleteCmd) Run(ctx context.Context, flags *RootFlags) error {
 		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
 			"deleted": ids,
 			"count":   len(ids),
+			"chunks":  chunks,
 		})
 	}
 
@@ -67,38 +159,48 @@ func (c *GmailBatchDeleteCmd) Run(ctx context.Context, flags *RootFlags) error {
 }
 
 type GmailBatchModifyCmd struct {
-	MessageIDs []string `arg:"" name:"messageId" help:"Message IDs"`
-	Add        string   `name:"add" help:"Labels to add (comma-separated, name or ID)"`
-	Remove     string   `name:"remove" help:"Labels to remove (comma-separated, name or ID)"`
+	Target gmailBatchTarget `embed:""`
+
+	Add    string `name:"add" help:"Labels to add (comma-separated, name or ID)"`
+	Remove string `name:"remove" help:"Labels to remove (comma-separated, name or ID)"`
 }
 
 func (c *GmailBatchModifyCmd) Run(ctx context.Context, flags *RootFlags) error {
-	u := ui.FromContext(ctx)
-	ids := make([]string, 0, len(c.MessageIDs))
-	for _, id := range c.MessageIDs {
-		id = normalizeGmailMessageID(id)
-		if id == "" {
-			continue
-		}
-		ids = append(ids, id)
-	}
-	if len(ids) == 0 {
-		return usage("missing messageId")
-	}
 	addLabels := splitCSV(c.Add)
 	removeLabels := splitCSV(c.Remove)
 	if len(addLabels) == 0 && len(removeLabels) == 0 {
 		return errors.New("must specify --add and/or --remove")
 	}
+	return runGmailBatchModify(ctx, flags, c.Target, "gmail.batch.modify", "modified", addLabels, removeLabels)
+}
 
-	if err := dryRunExit(ctx, flags, "gmail.batch.modify", map[string]any{
-		"message_ids": ids,
-		"add":         addLabels,
-		"remove":      removeLabels,
-	}); err != nil {
+type GmailBatchTrashCmd struct {
+	Target gmailBatchTarget `embed:""`
+}
+
+func (c *GmailBatchTrashCmd) Run(ctx context.Context, flags *RootFlags) error {
+	return runGmailBatchModify(ctx, flags, c.Target, "gmail.batch.trash", "trashed", []string{"TRASH"}, nil)
+}
+
+// runGmailBatchModify applies label changes to the selected messages; verb
+// ("modified", "trashed") is used for progress and the summary line.
+func runGmailBatchModify(ctx context.Context, flags *RootFlags, target gmailBatchTarget, op, verb string, addLabels, removeLabels []string) error {
+	u := ui.FromContext(ctx)
+	ids, err := target.explicitIDs()
+	if err != nil {
 		return err
 	}
 
+	if ids != nil {
+		if err := dryRunExit(ctx, flags, op, map[string]any{
+			"message_ids": ids,
+			"add":         addLabels,
+			"remove":      removeLabels,
+		}); err != nil {
+			return err
+		}
+	}
+
 	account, err := requireAccount(flags)
 	if err != nil {
 		return err
@@ -109,6 +211,20 @@ func (c *GmailBatchModifyCmd) Run(ctx context.Context, flags *RootFlags) error {
 		return err
 	}
 
+	if ids == nil {
+		if ids, err = target.matchingIDs(ctx, u, svc); err != nil {
+			return err
+		}
+		if err := dryRunExit(ctx, flags, op, map[string]any{
+			"query":  strings.TrimSpace(target.Query),
+			"count":  len(ids),
+			"add":    addLabels,
+			"remove": removeLabels,
+		}); err != nil {
+			return err
+		}
+	}
+
 	idMap, err := fetchLabelNameToID(svc)
 	if err != nil {
 		return err
@@ -117,11 +233,13 @@ func (c *GmailBatchModifyCmd) Run(ctx context.Context, flags *RootFlags) error {
 	addIDs := resolveLabelIDs(addLabels, idMap)
 	removeIDs := resolveLabelIDs(removeLabels, idMap)
 
-	err = svc.Users.Messages.BatchModify("me", &gmail.BatchModifyMessagesRequest{
-		Ids:            ids,
-		AddLabelIds:    addIDs,
-		RemoveLabelIds: removeIDs,
-	}).Do()
+	chunks, err := runGmailBatchChunks(ctx, u, verb, ids, func(chunk []string) error {
+		return svc.Users.Messages.BatchModify("me", &gmail.BatchModifyMessagesRequest{
+			Ids:            chunk,
+			AddLabelIds:    addIDs,
+			RemoveLabelIds: removeIDs,
+		}).Context(ctx).Do()
+	})
 	if err != nil {
 		return err
 	}
@@ -130,11 +248,12 @@ func (c *GmailBatchModifyCmd) Run(ctx context.Context, flags *RootFlags) error {
 		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
 			"modified":      ids,
 			"count":         len(ids),
+			"chunks":        chunks,
 			"addedLabels":   addIDs,
 			"removedLabels": removeIDs,
 		})
 	}
 
-	u.Out().Printf("Modified %d messages", len(ids))
+	u.Out().Printf("%s %d messages", strings.ToUpper(verb[:1])+verb[1:], len(ids))
 	return nil
 }
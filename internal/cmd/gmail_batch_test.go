@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// newGmailBatchTestService serves total messages matching any query in pages
+// of 500 and records batchModify/batchDelete chunk sizes.
+func newGmailBatchTestService(t *testing.T, total int) (modified, deleted *[]int, queries *[]string) {
+	t.Helper()
+
+	modified, deleted, queries = &[]int{}, &[]int{}, &[]string{}
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/labels"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": []map[string]any{
+				{"id": "Label_1", "name": "Newsletters", "type": "user"},
+				{"id": "INBOX", "name": "INBOX", "type": "system"},
+				{"id": "TRASH", "name": "TRASH", "type": "system"},
+			}})
+		case strings.HasSuffix(r.URL.Path, "/messages") && r.Method == http.MethodGet:
+			*queries = append(*queries, r.URL.Query().Get("q"))
+			start := 0
+			if tok := r.URL.Query().Get("pageToken"); tok != "" {
+				_, _ = fmt.Sscanf(tok, "p%d", &start)
+			}
+			end := min(start+500, total)
+			msgs := make([]map[string]any, 0, end-start)
+			for i := start; i < end; i++ {
+				msgs = append(msgs, map[string]any{"id": fmt.Sprintf("m%d", i)})
+			}
+			resp := map[string]any{"messages": msgs}
+			if end < total {
+				resp["nextPageToken"] = fmt.Sprintf("p%d", end)
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		case strings.HasSuffix(r.URL.Path, "/messages/batchModify"):
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			*modified = append(*modified, len(body.IDs))
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(r.URL.Path, "/messages/batchDelete"):
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			*deleted = append(*deleted, len(body.IDs))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+	return modified, deleted, queries
+}
+
+func TestGmailBatchModifyCmd_QueryChunks(t *testing.T) {
+	modified, _, queries := newGmailBatchTestService(t, 2500)
+
+	var stderr string
+	out := captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			if err := Execute([]string{"--account", "a@b.com", "gmail", "batch", "modify",
+				"--query", "label:Newsletters older_than:1y", "--remove", "INBOX"}); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+		})
+	})
+
+	if got := fmt.Sprint(*modified); got != "[1000 1000 500]" {
+		t.Fatalf("unexpected chunks: %s", got)
+	}
+	if len(*queries) != 5 || (*queries)[0] != "label:Newsletters older_than:1y" {
+		t.Fatalf("unexpected list calls: %v", *queries)
+	}
+	for _, want := range []string{"matched 2500 messages", "modified 1000/2500", "modified 2500/2500"} {
+		if !strings.Contains(stderr, want) {
+			t.Fatalf("stderr missing %q:\n%s", want, stderr)
+		}
+	}
+	if !strings.Contains(out, "Modified 2500 messages") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+}
+
+func TestGmailBatchCmd_QueryDryRun(t *testing.T) {
+	modified, deleted, _ := newGmailBatchTestService(t, 1200)
+
+	for _, sub := range []string{"trash", "delete"} {
+		out := captureStdout(t, func() {
+			_ = captureStderr(t, func() {
+				if err := Execute([]string{"--json", "--dry-run", "--account", "a@b.com", "gmail", "batch", sub,
+					"--query", "from:news@example.com"}); err != nil {
+					t.Fatalf("Execute %s: %v", sub, err)
+				}
+			})
+		})
+		var parsed struct {
+			DryRun  bool           `json:"dry_run"`
+			Request map[string]any `json:"request"`
+		}
+		if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+			t.Fatalf("json: %v\n%s", err, out)
+		}
+		if !parsed.DryRun || parsed.Request["count"] != float64(1200) {
+			t.Fatalf("unexpected %s dry run: %s", sub, out)
+		}
+	}
+	if len(*modified) != 0 || len(*deleted) != 0 {
+		t.Fatalf("dry run changed messages: modify=%v delete=%v", *modified, *deleted)
+	}
+}
+
+func TestGmailBatchTrashAndDeleteCmd_Query(t *testing.T) {
+	modified, deleted, _ := newGmailBatchTestService(t, 1001)
+
+	out := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--json", "--account", "a@b.com", "gmail", "batch", "trash", "--query", "is:old"}); err != nil {
+				t.Fatalf("trash: %v", err)
+			}
+		})
+	})
+	var parsed struct {
+		Count       int      `json:"count"`
+		Chunks      int      `json:"chunks"`
+		AddedLabels []string `json:"addedLabels"`
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if parsed.Count != 1001 || parsed.Chunks != 2 || fmt.Sprint(parsed.AddedLabels) != "[TRASH]" {
+		t.Fatalf("unexpected trash result: %+v", parsed)
+	}
+	if fmt.Sprint(*modified) != "[1000 1]" {
+		t.Fatalf("unexpected trash chunks: %v", *modified)
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--no-input", "--account", "a@b.com", "gmail", "batch", "delete", "--query", "is:old"}); err == nil {
+			t.Fatalf("expected delete without --force to be refused")
+		}
+	})
+	_ = captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--force", "--account", "a@b.com", "gmail", "batch", "delete", "--query", "is:old"}); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+		})
+	})
+	if fmt.Sprint(*deleted) != "[1000 1]" {
+		t.Fatalf("unexpected delete chunks: %v", *deleted)
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "batch", "trash", "m1", "--query", "is:old"}); err == nil {
+			t.Fatalf("expected IDs + --query to be rejected")
+		}
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "batch", "trash", "m1", "--max", "5"}); err == nil {
+			t.Fatalf("expected --max without --query to be rejected")
+		}
+	})
+}
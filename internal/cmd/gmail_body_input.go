@@ -3,6 +3,7 @@ package cmd
 import (
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/steipete/gogcli/internal/config"
@@ -35,3 +36,44 @@ func resolveBodyInput(body, bodyFile string) (string, error) {
 	}
 	return string(b), nil
 }
+
+// resolveMarkdownBodyInput renders --body-markdown/--body-markdown-file into
+// plain-text and HTML bodies. ok is false when neither flag is set. Relative
+// image paths resolve against the markdown file's directory.
+func resolveMarkdownBodyInput(markdown, markdownFile string, otherBodySet bool) (md markdownEmail, ok bool, err error) {
+	markdownFile = strings.TrimSpace(markdownFile)
+	if strings.TrimSpace(markdown) == "" && markdownFile == "" {
+		return markdownEmail{}, false, nil
+	}
+	if strings.TrimSpace(markdown) != "" && markdownFile != "" {
+		return markdownEmail{}, false, usage("use only one of --body-markdown or --body-markdown-file")
+	}
+	if otherBodySet {
+		return markdownEmail{}, false, usage("--body-markdown cannot be combined with --body, --body-file, or --body-html")
+	}
+
+	baseDir := ""
+	if markdownFile != "" {
+		var b []byte
+		if markdownFile == "-" {
+			b, err = io.ReadAll(os.Stdin)
+		} else {
+			markdownFile, err = config.ExpandPath(markdownFile)
+			if err != nil {
+				return markdownEmail{}, false, err
+			}
+			baseDir = filepath.Dir(markdownFile)
+			b, err = os.ReadFile(markdownFile) //nolint:gosec // user-provided path
+		}
+		if err != nil {
+			return markdownEmail{}, false, err
+		}
+		markdown = string(b)
+	}
+
+	rendered, err := renderMarkdownEmail(markdown, baseDir)
+	if err != nil {
+		return markdownEmail{}, false, err
+	}
+	return *rendered, true, nil
+}
@@ -291,6 +291,8 @@ type GmailDraftsCreateCmd struct {
 	Body             string   `name:"body" help:"Body (plain text; required unless --body-html is set)"`
 	BodyFile         string   `name:"body-file" help:"Body file path (plain text; '-' for stdin)"`
 	BodyHTML         string   `name:"body-html" help:"Body (HTML; optional)"`
+	BodyMarkdown     string   `name:"body-markdown" help:"Body (Markdown; rendered to HTML with a plain-text alternative, local images embedded inline)"`
+	BodyMarkdownFile string   `name:"body-markdown-file" help:"Markdown body file path ('-' for stdin; image paths relative to the file)"`
 	ReplyToMessageID string   `name:"reply-to-message-id" help:"Reply to Gmail message ID (sets In-Reply-To/References and thread)"`
 	ReplyTo          string   `name:"reply-to" help:"Reply-To header address"`
 	Attach           []string `name:"attach" help:"Attachment file path (repeatable)"`
@@ -304,6 +306,7 @@ type draftComposeInput struct {
 	Subject          string
 	Body             string
 	BodyHTML         string
+	InlineImages     []mailAttachment
 	ReplyToMessageID string
 	ReplyToThreadID  string
 	ReplyTo          string
@@ -316,7 +319,7 @@ func (c draftComposeInput) validate() error {
 		return usage("required: --subject")
 	}
 	if strings.TrimSpace(c.Body) == "" && strings.TrimSpace(c.BodyHTML) == "" {
-		return usage("required: --body, --body-file, or --body-html")
+		return usage("required: --body, --body-file, --body-html, or --body-markdown")
 	}
 	return nil
 }
@@ -355,17 +358,18 @@ func buildDraftMessage(ctx context.Context, svc *gmail.Service, account string,
 	}
 
 	raw, err := buildRFC822(mailOptions{
-		From:        fromAddr,
-		To:          splitCSV(input.To),
-		Cc:          splitCSV(input.Cc),
-		Bcc:         splitCSV(input.Bcc),
-		ReplyTo:     input.ReplyTo,
-		Subject:     input.Subject,
-		Body:        input.Body,
-		BodyHTML:    input.BodyHTML,
-		InReplyTo:   inReplyTo,
-		References:  references,
-		Attachments: atts,
+		From:         fromAddr,
+		To:           splitCSV(input.To),
+		Cc:           splitCSV(input.Cc),
+		Bcc:          splitCSV(input.Bcc),
+		ReplyTo:      input.ReplyTo,
+		Subject:      input.Subject,
+		Body:         input.Body,
+		BodyHTML:     input.BodyHTML,
+		InReplyTo:    inReplyTo,
+		References:   references,
+		Attachments:  atts,
+		InlineImages: input.InlineImages,
 	}, &rfc822Config{allowMissingTo: true})
 	if err != nil {
 		return nil, "", err
@@ -409,6 +413,15 @@ func (c *GmailDraftsCreateCmd) Run(ctx context.Context, flags *RootFlags) error
 	if err != nil {
 		return err
 	}
+	bodyHTML := c.BodyHTML
+	var inlineImages []mailAttachment
+	md, useMarkdown, err := resolveMarkdownBodyInput(c.BodyMarkdown, c.BodyMarkdownFile, strings.TrimSpace(body) != "" || strings.TrimSpace(bodyHTML) != "")
+	if err != nil {
+		return err
+	}
+	if useMarkdown {
+		body, bodyHTML, inlineImages = md.Text, md.HTML, md.Images
+	}
 	replyToMessageID := normalizeGmailMessageID(c.ReplyToMessageID)
 
 	attachPaths := make([]string, 0, len(c.Attach))
@@ -426,7 +439,8 @@ func (c *GmailDraftsCreateCmd) Run(ctx context.Context, flags *RootFlags) error
 		Bcc:              c.Bcc,
 		Subject:          c.Subject,
 		Body:             body,
-		BodyHTML:         c.BodyHTML,
+		BodyHTML:         bodyHTML,
+		InlineImages:     inlineImages,
 		ReplyToMessageID: replyToMessageID,
 		ReplyToThreadID:  "",
 		ReplyTo:          c.ReplyTo,
@@ -483,6 +497,8 @@ type GmailDraftsUpdateCmd struct {
 	Body             string   `name:"body" help:"Body (plain text; required unless --body-html is set)"`
 	BodyFile         string   `name:"body-file" help:"Body file path (plain text; '-' for stdin)"`
 	BodyHTML         string   `name:"body-html" help:"Body (HTML; optional)"`
+	BodyMarkdown     string   `name:"body-markdown" help:"Body (Markdown; rendered to HTML with a plain-text alternative, local images embedded inline)"`
+	BodyMarkdownFile string   `name:"body-markdown-file" help:"Markdown body file path ('-' for stdin; image paths relative to the file)"`
 	ReplyToMessageID string   `name:"reply-to-message-id" help:"Reply to Gmail message ID (sets In-Reply-To/References and thread)"`
 	ReplyTo          string   `name:"reply-to" help:"Reply-To header address"`
 	Attach           []string `name:"attach" help:"Attachment file path (repeatable)"`
@@ -507,6 +523,15 @@ func (c *GmailDraftsUpdateCmd) Run(ctx context.Context, flags *RootFlags) error
 	if err != nil {
 		return err
 	}
+	bodyHTML := c.BodyHTML
+	var inlineImages []mailAttachment
+	md, useMarkdown, err := resolveMarkdownBodyInput(c.BodyMarkdown, c.BodyMarkdownFile, strings.TrimSpace(body) != "" || strings.TrimSpace(bodyHTML) != "")
+	if err != nil {
+		return err
+	}
+	if useMarkdown {
+		body, bodyHTML, inlineImages = md.Text, md.HTML, md.Images
+	}
 	replyToMessageID := normalizeGmailMessageID(c.ReplyToMessageID)
 
 	attachPaths := make([]string, 0, len(c.Attach))
@@ -524,7 +549,8 @@ func (c *GmailDraftsUpdateCmd) Run(ctx context.Context, flags *RootFlags) error
 		Bcc:              c.Bcc,
 		Subject:          c.Subject,
 		Body:             body,
-		BodyHTML:         c.BodyHTML,
+		BodyHTML:         bodyHTML,
+		InlineImages:     inlineImages,
 		ReplyToMessageID: replyToMessageID,
 		ReplyToThreadID:  "",
 		ReplyTo:          c.ReplyTo,
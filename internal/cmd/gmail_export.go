@@ -0,0 +1,508 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/mailbox"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	gmailExportFormatMbox    = "mbox"
+	gmailExportFormatEML     = "eml"
+	gmailExportFormatMaildir = "maildir"
+
+	gmailExportStateSuffix   = ".gog-export"
+	gmailExportConcurrency   = 4
+	gmailExportProgressEvery = 100
+)
+
+type GmailExportCmd struct {
+	Query            []string `arg:"" name:"query" help:"Search query (Gmail query syntax)"`
+	Format           string   `name:"format" help:"Archive format: mbox|eml|maildir" enum:"mbox,eml,maildir" default:"mbox"`
+	Out              string   `name:"out" aliases:"output" help:"Output path (mbox file, or directory for eml/maildir)" required:""`
+	Max              int64    `name:"max" aliases:"limit" help:"Max messages to export (0 = all)" default:"0"`
+	IncludeSpamTrash bool     `name:"include-spam-trash" help:"Include messages from Spam and Trash"`
+	State            string   `name:"state" help:"Resume state file (default: <out>.gog-export, or <out>/.gog-export for directories)"`
+	Restart          bool     `name:"restart" help:"Ignore previous progress and start over (truncates an existing mbox)"`
+}
+
+func (c *GmailExportCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	query := strings.TrimSpace(strings.Join(c.Query, " "))
+	if query == "" {
+		return usage("missing query")
+	}
+
+	outPath, err := config.ExpandPath(strings.TrimSpace(c.Out))
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		return usage("empty --out")
+	}
+
+	statePath := strings.TrimSpace(c.State)
+	if statePath == "" {
+		statePath = defaultGmailExportStatePath(c.Format, outPath)
+	} else if statePath, err = config.ExpandPath(statePath); err != nil {
+		return err
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	done := map[string]bool{}
+	resumeAt := int64(-1)
+	if c.Restart {
+		if removeErr := os.Remove(statePath); removeErr != nil && !os.IsNotExist(removeErr) {
+			return fmt.Errorf("reset export state: %w", removeErr)
+		}
+	} else if done, resumeAt, err = readGmailExportState(statePath); err != nil {
+		return err
+	}
+
+	sink, err := openGmailExportSink(c.Format, outPath, len(done) > 0, c.Restart, resumeAt)
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	ids, err := listGmailMessageIDs(ctx, svc, query, c.IncludeSpamTrash, c.Max)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !done[id] {
+			pending = append(pending, id)
+		}
+	}
+
+	idToName, err := fetchLabelIDToName(svc)
+	if err != nil {
+		return err
+	}
+
+	state, err := os.OpenFile(statePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // user-provided path
+	if err != nil {
+		return fmt.Errorf("open export state: %w", err)
+	}
+	defer state.Close()
+
+	exported := 0
+	err = fetchGmailRawMessages(ctx, svc, pending, func(msg *gmail.Message, raw []byte) error {
+		raw = mailbox.SetHeader(raw, mailbox.LabelsHeader, mailbox.FormatLabels(gmailLabelNames(msg.LabelIds, idToName)))
+		if writeErr := sink.Write(msg, raw); writeErr != nil {
+			return fmt.Errorf("write message %s: %w", msg.Id, writeErr)
+		}
+		line := msg.Id
+		if mbox, ok := sink.(*mboxExportSink); ok {
+			offset, offsetErr := mbox.Offset()
+			if offsetErr != nil {
+				return fmt.Errorf("record export state: %w", offsetErr)
+			}
+			line += "\t" + strconv.FormatInt(offset, 10)
+		}
+		if _, writeErr := fmt.Fprintln(state, line); writeErr != nil {
+			return fmt.Errorf("record export state: %w", writeErr)
+		}
+		exported++
+		if exported%gmailExportProgressEvery == 0 && !outfmt.IsJSON(ctx) {
+			u.Err().Printf("exported %d/%d", exported, len(pending))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"format":   c.Format,
+			"out":      outPath,
+			"state":    statePath,
+			"matched":  len(ids),
+			"exported": exported,
+			"skipped":  len(ids) - len(pending),
+		})
+	}
+
+	u.Out().Printf("format\t%s", c.Format)
+	u.Out().Printf("out\t%s", outPath)
+	u.Out().Printf("matched\t%d", len(ids))
+	u.Out().Printf("exported\t%d", exported)
+	u.Out().Printf("skipped\t%d", len(ids)-len(pending))
+	return nil
+}
+
+func defaultGmailExportStatePath(format string, outPath string) string {
+	if format == gmailExportFormatMbox {
+		return outPath + gmailExportStateSuffix
+	}
+	return filepath.Join(outPath, gmailExportStateSuffix)
+}
+
+// readGmailExportState loads the message IDs recorded by a previous export
+// run. mbox exports record "<id>\t<offset>", the mbox size after the message
+// was written; the last offset is returned (-1 if none) so a resume can cut
+// off a message written after it but never recorded. A final line without a
+// newline was cut short by a crash and is ignored.
+func readGmailExportState(path string) (map[string]bool, int64, error) {
+	done := map[string]bool{}
+	offset := int64(-1)
+
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path
+	if err != nil {
+		if os.IsNotExist(err) {
+			return done, offset, nil
+		}
+		return nil, 0, fmt.Errorf("read state file: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		id, rest, hasOffset := strings.Cut(strings.TrimSpace(line), "\t")
+		if id == "" {
+			continue
+		}
+		done[id] = true
+		if hasOffset {
+			n, parseErr := strconv.ParseInt(rest, 10, 64)
+			if parseErr != nil || n < 0 {
+				return nil, 0, fmt.Errorf("read state file: invalid offset in %q", line)
+			}
+			offset = n
+		}
+	}
+	return done, offset, nil
+}
+
+// readGmailProgressState loads the keys recorded by a previous import run (one per line).
+func readGmailProgressState(path string) (map[string]bool, error) {
+	done := map[string]bool{}
+
+	f, err := os.Open(path) //nolint:gosec // user-provided path
+	if err != nil {
+		if os.IsNotExist(err) {
+			return done, nil
+		}
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if id := strings.TrimSpace(sc.Text()); id != "" {
+			done[id] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	return done, nil
+}
+
+func listGmailMessageIDs(ctx context.Context, svc *gmail.Service, query string, includeSpamTrash bool, limit int64) ([]string, error) {
+	pageSize := int64(500)
+	if limit > 0 && limit < pageSize {
+		pageSize = limit
+	}
+
+	seen := int64(0)
+	fetch := func(pageToken string) ([]string, string, error) {
+		call := svc.Users.Messages.List("me").
+			Q(query).
+			IncludeSpamTrash(includeSpamTrash).
+			MaxResults(pageSize).
+			Fields("messages(id),nextPageToken").
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		resp, err := call.Do()
+		if err != nil {
+			return nil, "", err
+		}
+		page := make([]string, 0, len(resp.Messages))
+		for _, m := range resp.Messages {
+			if m != nil && m.Id != "" {
+				page = append(page, m.Id)
+			}
+		}
+		seen += int64(len(page))
+		if limit > 0 && seen >= limit {
+			return page, "", nil
+		}
+		return page, resp.NextPageToken, nil
+	}
+
+	all, err := collectAllPages("", fetch)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 && int64(len(all)) > limit {
+		all = all[:limit]
+	}
+	return all, nil
+}
+
+// fetchGmailRawMessages downloads messages with format=raw, stopping at the
+// first failure. See fetchGmailMessages.
+func fetchGmailRawMessages(ctx context.Context, svc *gmail.Service, ids []string, fn func(msg *gmail.Message, raw []byte) error) error {
+	return fetchGmailMessages(ctx, svc, ids, gmailFormatRaw, func(_ string, msg *gmail.Message, raw []byte, err error) error {
+		if err != nil {
+			return err
+		}
+		return fn(msg, raw)
+	})
+}
+
+// fetchGmailMessages downloads messages in the given format using a few
+// concurrent requests, calling fn in the order of ids with the per-message
+// result (raw is only set for format=raw). At most gmailExportConcurrency
+// messages are held in memory at once. Returning an error from fn stops.
+func fetchGmailMessages(ctx context.Context, svc *gmail.Service, ids []string, format string, fn func(id string, msg *gmail.Message, raw []byte, err error) error) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type fetched struct {
+		msg *gmail.Message
+		raw []byte
+		err error
+	}
+
+	results := make([]chan fetched, len(ids))
+	for i := range results {
+		results[i] = make(chan fetched, 1)
+	}
+
+	sem := make(chan struct{}, gmailExportConcurrency)
+	go func() {
+		for i, id := range ids {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func(out chan<- fetched, id string) {
+				msg, err := svc.Users.Messages.Get("me", id).Format(format).Context(ctx).Do()
+				if err != nil {
+					out <- fetched{err: fmt.Errorf("get message %s: %w", id, err)}
+					return
+				}
+				if format != gmailFormatRaw {
+					out <- fetched{msg: msg}
+					return
+				}
+				raw, err := decodeGmailRaw(msg.Raw)
+				if err != nil {
+					err = fmt.Errorf("decode message %s: %w", id, err)
+				}
+				out <- fetched{msg: msg, raw: raw, err: err}
+			}(results[i], id)
+		}
+	}()
+
+	for i, id := range ids {
+		var r fetched
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		<-sem
+		if err := fn(id, r.msg, r.raw, r.err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeGmailRaw(raw string) ([]byte, error) {
+	if raw == "" {
+		return nil, errors.New("empty raw message")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		// Gmail can return padded base64url; accept both.
+		return base64.URLEncoding.DecodeString(raw)
+	}
+	return data, nil
+}
+
+func gmailLabelNames(labelIDs []string, idToName map[string]string) []string {
+	names := make([]string, 0, len(labelIDs))
+	for _, id := range labelIDs {
+		if name := idToName[id]; name != "" {
+			names = append(names, name)
+		} else {
+			names = append(names, id)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func gmailInternalDate(msg *gmail.Message) time.Time {
+	if msg == nil || msg.InternalDate <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(msg.InternalDate)
+}
+
+// gmailMaildirFlags maps Gmail system labels onto Maildir info flags.
+func gmailMaildirFlags(labelIDs []string) string {
+	seen := true
+	var flags []rune
+	for _, id := range labelIDs {
+		switch id {
+		case "UNREAD":
+			seen = false
+		case "STARRED":
+			flags = append(flags, mailbox.FlagFlagged)
+		case "DRAFT":
+			flags = append(flags, mailbox.FlagDraft)
+		case "TRASH":
+			flags = append(flags, mailbox.FlagTrashed)
+		}
+	}
+	if seen {
+		flags = append(flags, mailbox.FlagSeen)
+	}
+	return mailbox.NormalizeFlags(string(flags))
+}
+
+type gmailExportSink interface {
+	Write(msg *gmail.Message, raw []byte) error
+	Close() error
+}
+
+// openGmailExportSink opens the archive. An mbox being resumed is cut back
+// to resumeAt (the last offset recorded in the state file, or -1 for state
+// written without offsets) before new messages are appended.
+func openGmailExportSink(format string, outPath string, resume bool, restart bool, resumeAt int64) (gmailExportSink, error) {
+	switch format {
+	case gmailExportFormatMbox:
+		mode := os.O_CREATE | os.O_WRONLY
+		switch {
+		case resume && resumeAt >= 0:
+		case resume:
+			mode |= os.O_APPEND
+		case restart:
+			mode |= os.O_TRUNC
+		default:
+			if st, err := os.Stat(outPath); err == nil && st.Size() > 0 {
+				return nil, usagef("%s already exists; use --restart to overwrite it", outPath)
+			}
+			mode |= os.O_TRUNC
+		}
+		if err := os.MkdirAll(filepath.Dir(outPath), 0o700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(outPath, mode, 0o600) //nolint:gosec // user-provided path
+		if err != nil {
+			return nil, fmt.Errorf("open mbox: %w", err)
+		}
+		if resume && resumeAt >= 0 {
+			if err := truncateMboxForResume(f, outPath, resumeAt); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+		return &mboxExportSink{f: f, w: mailbox.NewMboxWriter(f)}, nil
+	case gmailExportFormatEML:
+		if err := os.MkdirAll(outPath, 0o700); err != nil {
+			return nil, err
+		}
+		return emlExportSink(outPath), nil
+	case gmailExportFormatMaildir:
+		dir := mailbox.Maildir(outPath)
+		if err := dir.Create(); err != nil {
+			return nil, err
+		}
+		return maildirExportSink{dir: dir}, nil
+	default:
+		return nil, usagef("invalid --format: %q (expected mbox|eml|maildir)", format)
+	}
+}
+
+type mboxExportSink struct {
+	f *os.File
+	w *mailbox.MboxWriter
+}
+
+func (s *mboxExportSink) Write(msg *gmail.Message, raw []byte) error {
+	return s.w.WriteMessage(raw, gmailInternalDate(msg))
+}
+
+// Offset is the mbox size after the messages written so far.
+func (s *mboxExportSink) Offset() (int64, error) { return s.f.Seek(0, io.SeekCurrent) }
+
+func (s *mboxExportSink) Close() error { return s.f.Close() }
+
+// truncateMboxForResume drops anything written after the last recorded
+// message, e.g. a message cut short by a crash, and positions f there.
+func truncateMboxForResume(f *os.File, outPath string, offset int64) error {
+	st, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("open mbox: %w", err)
+	}
+	if st.Size() < offset {
+		return usagef("%s is shorter than its export state records; use --restart to start over", outPath)
+	}
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("truncate mbox: %w", err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("truncate mbox: %w", err)
+	}
+	return nil
+}
+
+type emlExportSink string
+
+func (s emlExportSink) Write(msg *gmail.Message, raw []byte) error {
+	return writeFileAtomic(filepath.Join(string(s), msg.Id+".eml"), raw)
+}
+
+func (s emlExportSink) Close() error { return nil }
+
+type maildirExportSink struct {
+	dir mailbox.Maildir
+}
+
+func (s maildirExportSink) Write(msg *gmail.Message, raw []byte) error {
+	_, err := s.dir.Deliver(msg.Id, gmailMaildirFlags(msg.LabelIds), raw, gmailInternalDate(msg))
+	return err
+}
+
+func (s maildirExportSink) Close() error { return nil }
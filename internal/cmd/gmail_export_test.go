@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/mailbox"
+)
+
+type fakeGmailExportServer struct {
+	mu       sync.Mutex
+	messages map[string]string
+	labels   map[string][]string
+	order    []string
+	gets     []string
+	failOn   string
+}
+
+func (f *fakeGmailExportServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/users/me/labels"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": []map[string]any{
+				{"id": "INBOX", "name": "INBOX", "type": "system"},
+				{"id": "Label_1", "name": "Projects/Alpha", "type": "user"},
+			}})
+		case strings.HasSuffix(path, "/users/me/messages"):
+			if r.URL.Query().Get("q") != "label:alpha" {
+				t.Errorf("unexpected query %q", r.URL.Query().Get("q"))
+			}
+			msgs := make([]map[string]any, 0, len(f.order))
+			for _, id := range f.order {
+				msgs = append(msgs, map[string]any{"id": id})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"messages": msgs})
+		case strings.Contains(path, "/users/me/messages/"):
+			id := path[strings.LastIndex(path, "/")+1:]
+			if r.URL.Query().Get("format") != "raw" {
+				t.Errorf("expected format=raw, got %q", r.URL.Query().Get("format"))
+			}
+			f.mu.Lock()
+			f.gets = append(f.gets, id)
+			f.mu.Unlock()
+			if id == f.failOn {
+				http.Error(w, `{"error":{"code":500,"message":"boom"}}`, http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id":           id,
+				"labelIds":     f.labels[id],
+				"internalDate": "1700000000000",
+				"raw":          base64.URLEncoding.EncodeToString([]byte(f.messages[id])),
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func newFakeGmailExportServer() *fakeGmailExportServer {
+	return &fakeGmailExportServer{
+		order: []string{"m1", "m2"},
+		messages: map[string]string{
+			"m1": "From: Alice <alice@example.com>\r\nSubject: one\r\n\r\nFrom here on\r\n",
+			"m2": "From: bob@example.com\r\nX-Gmail-Labels: stale\r\nSubject: two\r\n\r\nbody\r\n",
+		},
+		labels: map[string][]string{
+			"m1": {"INBOX", "Label_1", "UNREAD"},
+			"m2": {"Label_1", "STARRED"},
+		},
+	}
+}
+
+func useFakeGmailExportServer(t *testing.T, f *fakeGmailExportServer) {
+	t.Helper()
+
+	svc, closeSrv := newGmailServiceForTest(t, f.handler(t))
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+}
+
+func TestGmailExport_MboxResume(t *testing.T) {
+	fake := newFakeGmailExportServer()
+	fake.failOn = "m2"
+	useFakeGmailExportServer(t, fake)
+
+	out := filepath.Join(t.TempDir(), "archive.mbox")
+	args := []string{"--json", "--account", "a@b.com", "gmail", "export", "label:alpha", "--out", out}
+
+	_ = captureStderr(t, func() {
+		if err := Execute(args); err == nil {
+			t.Fatalf("expected failure on m2")
+		}
+	})
+
+	state, err := os.ReadFile(out + ".gog-export")
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	st, err := os.Stat(out)
+	if err != nil || string(state) != fmt.Sprintf("m1\t%d\n", st.Size()) {
+		t.Fatalf("expected m1 recorded with the mbox offset, got %q err=%v", state, err)
+	}
+
+	// Simulate a crash after m2 was partly written to the mbox and while its
+	// state line was being appended: both must be discarded on resume.
+	appendTo := func(path, data string) {
+		f, openErr := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		if openErr != nil {
+			t.Fatalf("open %s: %v", path, openErr)
+		}
+		defer f.Close()
+		if _, writeErr := f.WriteString(data); writeErr != nil {
+			t.Fatalf("append %s: %v", path, writeErr)
+		}
+	}
+	appendTo(out, "From bob@example.com Tue Nov 14 22:13:20 2023\nFrom: bob@exa")
+	appendTo(out+".gog-export", "m2\t99")
+
+	fake.failOn = ""
+	fake.gets = nil
+
+	stdout := captureStdout(t, func() {
+		if err := Execute(args); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	var result struct {
+		Matched  int `json:"matched"`
+		Exported int `json:"exported"`
+		Skipped  int `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("unmarshal: %v (%q)", err, stdout)
+	}
+	if result.Matched != 2 || result.Exported != 1 || result.Skipped != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(fake.gets) != 1 || fake.gets[0] != "m2" {
+		t.Fatalf("expected only m2 fetched on resume, got %v", fake.gets)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("read mbox: %v", err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"From alice@example.com Tue Nov 14 22:13:20 2023\nX-Gmail-Labels: INBOX,Projects/Alpha,UNREAD\n",
+		"\n>From here on\n",
+		"From bob@example.com Tue Nov 14 22:13:20 2023\nX-Gmail-Labels: Projects/Alpha,STARRED\nFrom: bob@example.com\nSubject: two\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in mbox:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "stale") {
+		t.Fatalf("expected original X-Gmail-Labels replaced:\n%s", got)
+	}
+
+	if strings.Contains(got, "bob@exa\n") || strings.Count(got, "From bob@example.com ") != 1 {
+		t.Fatalf("expected the partial m2 to be replaced:\n%s", got)
+	}
+
+	r := mailbox.NewMboxReader(strings.NewReader(got))
+	for i := 0; i < 2; i++ {
+		if _, err := r.Next(); err != nil {
+			t.Fatalf("mbox message %d unreadable: %v", i, err)
+		}
+	}
+	if _, err := r.Next(); err == nil {
+		t.Fatalf("expected exactly two messages in mbox:\n%s", got)
+	}
+
+	// Without state, an existing mbox is never silently overwritten.
+	if err := os.Remove(out + ".gog-export"); err != nil {
+		t.Fatalf("remove state: %v", err)
+	}
+	if err := Execute(args); err == nil || !strings.Contains(err.Error(), "--restart") {
+		t.Fatalf("expected --restart hint, got %v", err)
+	}
+}
+
+func TestGmailExport_EMLAndMaildir(t *testing.T) {
+	fake := newFakeGmailExportServer()
+	useFakeGmailExportServer(t, fake)
+
+	dir := t.TempDir()
+	emlDir := filepath.Join(dir, "eml")
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "export", "label:alpha", "--format", "eml", "--out", emlDir}); err != nil {
+			t.Fatalf("Execute eml: %v", err)
+		}
+	})
+
+	eml, err := os.ReadFile(filepath.Join(emlDir, "m1.eml"))
+	if err != nil {
+		t.Fatalf("read eml: %v", err)
+	}
+	if !strings.HasPrefix(string(eml), "X-Gmail-Labels: INBOX,Projects/Alpha,UNREAD\r\nFrom: Alice") {
+		t.Fatalf("unexpected eml: %q", eml)
+	}
+	if _, err := os.Stat(filepath.Join(emlDir, ".gog-export")); err != nil {
+		t.Fatalf("expected state in eml dir: %v", err)
+	}
+
+	mdDir := filepath.Join(dir, "maildir")
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "export", "label:alpha", "--format", "maildir", "--out", mdDir}); err != nil {
+			t.Fatalf("Execute maildir: %v", err)
+		}
+	})
+
+	entries, err := os.ReadDir(filepath.Join(mdDir, "cur"))
+	if err != nil {
+		t.Fatalf("read maildir: %v", err)
+	}
+	flagsByID := map[string]string{}
+	for _, e := range entries {
+		parts := strings.SplitN(e.Name(), ".", 3)
+		info := strings.SplitN(e.Name(), mailbox.InfoSeparator+"2,", 2)
+		if len(parts) != 3 || len(info) != 2 {
+			t.Fatalf("unexpected maildir entry %q", e.Name())
+		}
+		flagsByID[parts[1]] = info[1]
+	}
+	if len(flagsByID) != 2 || flagsByID["m1"] != "" || flagsByID["m2"] != "FS" {
+		t.Fatalf("unexpected maildir flags: %v", flagsByID)
+	}
+}
+
+func TestGmailMaildirFlags(t *testing.T) {
+	cases := map[string][]string{
+		"S":   {"INBOX"},
+		"":    {"UNREAD"},
+		"FS":  {"STARRED"},
+		"DT":  {"UNREAD", "DRAFT", "TRASH"},
+		"FST": {"TRASH", "STARRED"},
+	}
+	for want, labels := range cases {
+		if got := gmailMaildirFlags(labels); got != want {
+			t.Fatalf("flags(%v) = %q, want %q", labels, got, want)
+		}
+	}
+}
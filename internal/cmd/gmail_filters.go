@@ -19,6 +19,8 @@ type GmailFiltersCmd struct {
 	Get    GmailFiltersGetCmd    `cmd:"" name:"get" aliases:"info,show" help:"Get a specific filter"`
 	Create GmailFiltersCreateCmd `cmd:"" name:"create" aliases:"add,new" help:"Create a new email filter"`
 	Delete GmailFiltersDeleteCmd `cmd:"" name:"delete" aliases:"rm,del,remove" help:"Delete a filter"`
+	Export GmailFiltersExportCmd `cmd:"" name:"export" help:"Export all filters as Gmail mailFilters.xml or JSON"`
+	Import GmailFiltersImportCmd `cmd:"" name:"import" help:"Create filters from a mailFilters.xml or JSON export, skipping existing ones"`
 }
 
 type GmailFiltersListCmd struct{}
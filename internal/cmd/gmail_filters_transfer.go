@@ -0,0 +1,688 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	gmailFiltersFormatXML  = "xml"
+	gmailFiltersFormatJSON = "json"
+
+	mailFiltersAtomNS = "http://www.w3.org/2005/Atom"
+	mailFiltersAppsNS = "http://schemas.google.com/apps/2006"
+)
+
+// gmailFilterSpec is the portable form of a filter used by export/import:
+// the API criteria as-is, with label IDs replaced by label names.
+type gmailFilterSpec struct {
+	Criteria gmail.FilterCriteria  `json:"criteria"`
+	Action   gmailFilterSpecAction `json:"action"`
+}
+
+type gmailFilterSpecAction struct {
+	AddLabels    []string `json:"addLabels,omitempty"`
+	RemoveLabels []string `json:"removeLabels,omitempty"`
+	Forward      string   `json:"forward,omitempty"`
+}
+
+type gmailFiltersDocument struct {
+	Filters []gmailFilterSpec `json:"filters"`
+}
+
+// Gmail's "smart labels" (inbox categories) as named in mailFilters.xml.
+var mailFiltersSmartLabels = map[string]string{
+	"^smartlabel_personal":     "CATEGORY_PERSONAL",
+	"^smartlabel_social":       "CATEGORY_SOCIAL",
+	"^smartlabel_promo":        "CATEGORY_PROMOTIONS",
+	"^smartlabel_notification": "CATEGORY_UPDATES",
+	"^smartlabel_group":        "CATEGORY_FORUMS",
+}
+
+type GmailFiltersExportCmd struct {
+	Format string         `name:"format" help:"Output format: xml (Gmail mailFilters.xml) or json" enum:"xml,json" default:"xml"`
+	Output OutputPathFlag `embed:""`
+}
+
+func (c *GmailFiltersExportCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	outPath, err := config.ExpandPath(strings.TrimSpace(c.Output.Path))
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	resp, err := svc.Users.Settings.Filters.List("me").Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	idToName, err := fetchLabelIDToName(svc)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if c.Format == gmailFiltersFormatJSON {
+		doc := gmailFiltersDocument{Filters: make([]gmailFilterSpec, 0, len(resp.Filter))}
+		for _, f := range resp.Filter {
+			doc.Filters = append(doc.Filters, gmailFilterSpecFromFilter(f, idToName))
+		}
+		data, err = json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		var warnings []string
+		data, warnings, err = marshalMailFiltersXML(resp.Filter, idToName, account, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, w := range warnings {
+			u.Err().Printf("warning: %s", w)
+		}
+	}
+
+	if outPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := writeFileAtomic(outPath, data); err != nil {
+		return err
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"path":   outPath,
+			"format": c.Format,
+			"count":  len(resp.Filter),
+		})
+	}
+	u.Out().Printf("path\t%s", outPath)
+	u.Out().Printf("filters\t%d", len(resp.Filter))
+	return nil
+}
+
+type GmailFiltersImportCmd struct {
+	File         string `arg:"" name:"file" help:"mailFilters.xml (Gmail export) or JSON from 'gmail filters export --format json'"`
+	Format       string `name:"format" help:"Input format (auto detects from content)" enum:"auto,xml,json" default:"auto"`
+	CreateLabels bool   `name:"create-labels" help:"Create labels that do not exist yet instead of failing"`
+}
+
+func (c *GmailFiltersImportCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	path, err := config.ExpandPath(strings.TrimSpace(c.File))
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return usage("missing file")
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path
+	if err != nil {
+		return fmt.Errorf("read filters: %w", err)
+	}
+
+	format := c.Format
+	if format == "" || format == "auto" {
+		format = gmailFiltersFormatJSON
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+			format = gmailFiltersFormatXML
+		}
+	}
+	var specs []gmailFilterSpec
+	if format == gmailFiltersFormatXML {
+		var warnings []string
+		specs, warnings, err = parseMailFiltersXML(data)
+		for _, w := range warnings {
+			u.Err().Printf("warning: %s", w)
+		}
+	} else {
+		var doc gmailFiltersDocument
+		err = json.Unmarshal(data, &doc)
+		specs = doc.Filters
+	}
+	if err != nil {
+		return fmt.Errorf("parse %s filters: %w", format, err)
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	existing, err := svc.Users.Settings.Filters.List("me").Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	nameToID, err := fetchLabelNameToID(svc)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]string, len(existing.Filter))
+	for _, f := range existing.Filter {
+		seen[gmailFilterKey(f)] = f.Id
+	}
+	missing := missingFilterLabels(specs, nameToID)
+
+	// Filters that need a missing label cannot match an existing one, so the
+	// duplicate check is only done for fully resolvable specs.
+	planned := 0
+	for _, spec := range specs {
+		if f, ok := spec.toFilter(nameToID); ok {
+			if _, dup := seen[gmailFilterKey(f)]; dup {
+				continue
+			}
+		}
+		planned++
+	}
+
+	if err := dryRunExit(ctx, flags, "gmail.filters.import", map[string]any{
+		"file":           path,
+		"format":         format,
+		"filters":        len(specs),
+		"create":         planned,
+		"skip":           len(specs) - planned,
+		"missing_labels": missing,
+	}); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 && !c.CreateLabels {
+		return usagef("labels not found: %s (use --create-labels to create them)", strings.Join(missing, ", "))
+	}
+
+	createdLabels := make([]string, 0, len(missing))
+	for _, name := range missing {
+		label, err := createLabel(ctx, svc, name)
+		if err != nil {
+			return fmt.Errorf("create label %q: %w", name, err)
+		}
+		nameToID[strings.ToLower(name)] = label.Id
+		createdLabels = append(createdLabels, name)
+		if !outfmt.IsJSON(ctx) {
+			u.Err().Printf("created label %s", name)
+		}
+	}
+
+	created := make([]string, 0, planned)
+	skipped := 0
+	for i, spec := range specs {
+		filter, _ := spec.toFilter(nameToID)
+		key := gmailFilterKey(filter)
+		if id, dup := seen[key]; dup {
+			skipped++
+			if !outfmt.IsJSON(ctx) {
+				u.Err().Printf("skip filter %d: identical to %s", i+1, id)
+			}
+			continue
+		}
+		out, err := svc.Users.Settings.Filters.Create("me", filter).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("create filter %d of %d (%d created): %w", i+1, len(specs), len(created), err)
+		}
+		seen[key] = out.Id
+		created = append(created, out.Id)
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"created":       created,
+			"skipped":       skipped,
+			"createdLabels": createdLabels,
+		})
+	}
+	u.Out().Printf("created\t%d", len(created))
+	u.Out().Printf("skipped\t%d", skipped)
+	if len(createdLabels) > 0 {
+		u.Out().Printf("labels_created\t%s", strings.Join(createdLabels, ", "))
+	}
+	return nil
+}
+
+func gmailFilterSpecFromFilter(f *gmail.Filter, idToName map[string]string) gmailFilterSpec {
+	var spec gmailFilterSpec
+	if f.Criteria != nil {
+		spec.Criteria = *f.Criteria
+	}
+	if f.Action != nil {
+		spec.Action.AddLabels = labelNamesForIDs(f.Action.AddLabelIds, idToName)
+		spec.Action.RemoveLabels = labelNamesForIDs(f.Action.RemoveLabelIds, idToName)
+		spec.Action.Forward = f.Action.Forward
+	}
+	return spec
+}
+
+// labelNamesForIDs maps user label IDs to names and keeps system label IDs
+// (INBOX, STARRED, CATEGORY_*, ...) as they are.
+func labelNamesForIDs(ids []string, idToName map[string]string) []string {
+	if len(ids) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name, ok := idToName[id]; ok && strings.HasPrefix(id, "Label_") {
+			out = append(out, name)
+			continue
+		}
+		out = append(out, id)
+	}
+	return out
+}
+
+// toFilter resolves label names to IDs; ok is false when a label is unknown.
+func (s gmailFilterSpec) toFilter(nameToID map[string]string) (*gmail.Filter, bool) {
+	criteria := s.Criteria
+	f := &gmail.Filter{
+		Criteria: &criteria,
+		Action:   &gmail.FilterAction{Forward: strings.TrimSpace(s.Action.Forward)},
+	}
+	ok := true
+	resolve := func(names []string) []string {
+		for _, name := range names {
+			if _, found := nameToID[strings.ToLower(strings.TrimSpace(name))]; !found {
+				ok = false
+			}
+		}
+		return resolveLabelIDs(names, nameToID)
+	}
+	f.Action.AddLabelIds = resolve(s.Action.AddLabels)
+	f.Action.RemoveLabelIds = resolve(s.Action.RemoveLabels)
+	return f, ok
+}
+
+// missingFilterLabels returns the label names used by specs that do not
+// exist yet, in first-seen order.
+func missingFilterLabels(specs []gmailFilterSpec, nameToID map[string]string) []string {
+	var missing []string
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		for _, name := range append(slices.Clone(spec.Action.AddLabels), spec.Action.RemoveLabels...) {
+			name = strings.TrimSpace(name)
+			key := strings.ToLower(name)
+			if name == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			if _, ok := nameToID[key]; !ok {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing
+}
+
+// gmailFilterKey identifies a filter by its criteria and actions, ignoring
+// the filter ID and the order of label IDs.
+func gmailFilterKey(f *gmail.Filter) string {
+	var key struct {
+		Criteria gmail.FilterCriteria
+		Add      []string
+		Remove   []string
+		Forward  string
+	}
+	if f.Criteria != nil {
+		key.Criteria = *f.Criteria
+		key.Criteria.ForceSendFields = nil
+		key.Criteria.NullFields = nil
+	}
+	if f.Action != nil {
+		key.Add = sortedUnique(f.Action.AddLabelIds)
+		key.Remove = sortedUnique(f.Action.RemoveLabelIds)
+		key.Forward = strings.ToLower(strings.TrimSpace(f.Action.Forward))
+	}
+	b, _ := json.Marshal(key)
+	return string(b)
+}
+
+func sortedUnique(in []string) []string {
+	out := slices.Clone(in)
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+type mailFiltersFeed struct {
+	XMLName   xml.Name           `xml:"feed"`
+	Xmlns     string             `xml:"xmlns,attr"`
+	XmlnsApps string             `xml:"xmlns:apps,attr"`
+	Title     string             `xml:"title"`
+	ID        string             `xml:"id"`
+	Updated   string             `xml:"updated"`
+	Author    *mailFiltersAuthor `xml:"author,omitempty"`
+	Entries   []mailFiltersEntry `xml:"entry"`
+}
+
+type mailFiltersAuthor struct {
+	Name  string `xml:"name,omitempty"`
+	Email string `xml:"email"`
+}
+
+type mailFiltersEntry struct {
+	Category struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Title      string                `xml:"title"`
+	ID         string                `xml:"id"`
+	Updated    string                `xml:"updated"`
+	Content    string                `xml:"content"`
+	Properties []mailFiltersProperty `xml:"apps:property"`
+}
+
+type mailFiltersProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// marshalMailFiltersXML renders filters in the Atom format used by Gmail's
+// Settings > Filters export. The format holds one label per entry, so
+// filters adding several user labels are split into one entry per label,
+// all with the filter's entry ID (parseMailFiltersXML merges them back).
+// Actions it cannot express are returned as warnings.
+func marshalMailFiltersXML(filters []*gmail.Filter, idToName map[string]string, account string, now time.Time) ([]byte, []string, error) {
+	updated := now.UTC().Format(time.RFC3339)
+	feed := mailFiltersFeed{
+		Xmlns:     mailFiltersAtomNS,
+		XmlnsApps: mailFiltersAppsNS,
+		Title:     "Mail Filters",
+		Updated:   updated,
+		Author:    &mailFiltersAuthor{Email: account},
+	}
+
+	var warnings []string
+	ids := make([]string, 0, len(filters))
+	for _, f := range filters {
+		ids = append(ids, f.Id)
+		criteria, actions, labels, warn := mailFiltersProperties(f, idToName)
+		for _, w := range warn {
+			warnings = append(warnings, fmt.Sprintf("filter %s: %s", f.Id, w))
+		}
+		if len(labels) == 0 {
+			labels = []string{""}
+		}
+		for i, label := range labels {
+			props := slices.Clone(criteria)
+			if label != "" {
+				props = append(props, mailFiltersProperty{Name: "label", Value: label})
+			}
+			if i == 0 {
+				props = append(props, actions...)
+			}
+			entry := mailFiltersEntry{
+				Title:      "Mail Filter",
+				ID:         "tag:mail.google.com,2008:filter:" + f.Id,
+				Updated:    updated,
+				Properties: props,
+			}
+			entry.Category.Term = "filter"
+			feed.Entries = append(feed.Entries, entry)
+		}
+	}
+	feed.ID = "tag:mail.google.com,2008:filters:" + strings.Join(ids, ",")
+
+	data, err := xml.MarshalIndent(feed, "", "\t")
+	if err != nil {
+		return nil, nil, err
+	}
+	out := append([]byte("<?xml version='1.0' encoding='UTF-8'?>\n"), data...)
+	return append(out, '\n'), warnings, nil
+}
+
+// mailFiltersProperties splits a filter into criteria properties, non-label
+// action properties, and the user label names it applies.
+func mailFiltersProperties(f *gmail.Filter, idToName map[string]string) (criteria, actions []mailFiltersProperty, labels, warnings []string) {
+	prop := func(list *[]mailFiltersProperty, name, value string) {
+		*list = append(*list, mailFiltersProperty{Name: name, Value: value})
+	}
+
+	if c := f.Criteria; c != nil {
+		for _, p := range []struct{ name, value string }{
+			{"from", c.From},
+			{"to", c.To},
+			{"subject", c.Subject},
+			{"hasTheWord", c.Query},
+			{"doesNotHaveTheWord", c.NegatedQuery},
+		} {
+			if p.value != "" {
+				prop(&criteria, p.name, p.value)
+			}
+		}
+		if c.HasAttachment {
+			prop(&criteria, "hasAttachment", "true")
+		}
+		if c.ExcludeChats {
+			prop(&criteria, "excludeChats", "true")
+		}
+		if c.Size > 0 {
+			size, unit := c.Size, "s_sb"
+			switch {
+			case size%(1<<20) == 0:
+				size, unit = size>>20, "s_smb"
+			case size%(1<<10) == 0:
+				size, unit = size>>10, "s_skb"
+			}
+			operator := "s_sl"
+			if c.SizeComparison == "smaller" {
+				operator = "s_ss"
+			}
+			prop(&criteria, "size", strconv.FormatInt(size, 10))
+			prop(&criteria, "sizeOperator", operator)
+			prop(&criteria, "sizeUnit", unit)
+		}
+	}
+
+	a := f.Action
+	if a == nil {
+		return criteria, actions, labels, warnings
+	}
+	for _, id := range a.AddLabelIds {
+		switch id {
+		case "STARRED":
+			prop(&actions, "shouldStar", "true")
+		case "TRASH":
+			prop(&actions, "shouldTrash", "true")
+		case "IMPORTANT":
+			prop(&actions, "shouldAlwaysMarkAsImportant", "true")
+		default:
+			if smart := mailFiltersSmartLabelFor(id); smart != "" {
+				prop(&actions, "smartLabelToApply", smart)
+			} else if name, ok := idToName[id]; ok && strings.HasPrefix(id, "Label_") {
+				labels = append(labels, name)
+			} else {
+				warnings = append(warnings, fmt.Sprintf("cannot export adding label %s", id))
+			}
+		}
+	}
+	for _, id := range a.RemoveLabelIds {
+		switch id {
+		case "INBOX":
+			prop(&actions, "shouldArchive", "true")
+		case "UNREAD":
+			prop(&actions, "shouldMarkAsRead", "true")
+		case "SPAM":
+			prop(&actions, "shouldNeverSpam", "true")
+		case "IMPORTANT":
+			prop(&actions, "shouldNeverMarkAsImportant", "true")
+		default:
+			warnings = append(warnings, fmt.Sprintf("cannot export removing label %s", idToNameOr(idToName, id)))
+		}
+	}
+	if a.Forward != "" {
+		prop(&actions, "forwardTo", a.Forward)
+	}
+	return criteria, actions, labels, warnings
+}
+
+func mailFiltersSmartLabelFor(id string) string {
+	for smart, category := range mailFiltersSmartLabels {
+		if category == id {
+			return smart
+		}
+	}
+	return ""
+}
+
+func idToNameOr(idToName map[string]string, id string) string {
+	if name, ok := idToName[id]; ok {
+		return name
+	}
+	return id
+}
+
+type mailFiltersFeedIn struct {
+	XMLName xml.Name `xml:"feed"`
+	Entries []struct {
+		ID         string                `xml:"id"`
+		Properties []mailFiltersProperty `xml:"property"`
+	} `xml:"entry"`
+}
+
+// parseMailFiltersXML reads a Gmail mailFilters.xml export. Entries sharing
+// an ID are one filter split per label and become a single filter again.
+// Properties the API cannot represent (e.g. canned responses) are skipped
+// with a warning.
+func parseMailFiltersXML(data []byte) ([]gmailFilterSpec, []string, error) {
+	var feed mailFiltersFeedIn
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	specs := make([]gmailFilterSpec, 0, len(feed.Entries))
+	byID := make(map[string]int, len(feed.Entries))
+	for i, entry := range feed.Entries {
+		var spec gmailFilterSpec
+		var size int64
+		var sizeUnit, sizeOperator string
+		c, a := &spec.Criteria, &spec.Action
+		for _, p := range entry.Properties {
+			switch p.Name {
+			case "from":
+				c.From = p.Value
+			case "to":
+				c.To = p.Value
+			case "subject":
+				c.Subject = p.Value
+			case "hasTheWord":
+				c.Query = p.Value
+			case "doesNotHaveTheWord":
+				c.NegatedQuery = p.Value
+			case "hasAttachment":
+				c.HasAttachment = p.Value == "true"
+			case "excludeChats":
+				c.ExcludeChats = p.Value == "true"
+			case "size":
+				n, err := strconv.ParseInt(strings.TrimSpace(p.Value), 10, 64)
+				if err != nil {
+					return nil, warnings, fmt.Errorf("entry %d: invalid size %q", i+1, p.Value)
+				}
+				size = n
+			case "sizeOperator":
+				sizeOperator = p.Value
+			case "sizeUnit":
+				sizeUnit = p.Value
+			case "label":
+				a.AddLabels = append(a.AddLabels, p.Value)
+			case "shouldStar":
+				a.AddLabels = appendIfTrue(a.AddLabels, p.Value, "STARRED")
+			case "shouldTrash":
+				a.AddLabels = appendIfTrue(a.AddLabels, p.Value, "TRASH")
+			case "shouldAlwaysMarkAsImportant":
+				a.AddLabels = appendIfTrue(a.AddLabels, p.Value, "IMPORTANT")
+			case "shouldArchive":
+				a.RemoveLabels = appendIfTrue(a.RemoveLabels, p.Value, "INBOX")
+			case "shouldMarkAsRead":
+				a.RemoveLabels = appendIfTrue(a.RemoveLabels, p.Value, "UNREAD")
+			case "shouldNeverSpam":
+				a.RemoveLabels = appendIfTrue(a.RemoveLabels, p.Value, "SPAM")
+			case "shouldNeverMarkAsImportant":
+				a.RemoveLabels = appendIfTrue(a.RemoveLabels, p.Value, "IMPORTANT")
+			case "smartLabelToApply":
+				if category, ok := mailFiltersSmartLabels[p.Value]; ok {
+					a.AddLabels = append(a.AddLabels, category)
+				} else {
+					warnings = append(warnings, fmt.Sprintf("entry %d: unknown smart label %q skipped", i+1, p.Value))
+				}
+			case "forwardTo":
+				a.Forward = p.Value
+			default:
+				warnings = append(warnings, fmt.Sprintf("entry %d: unsupported property %q skipped", i+1, p.Name))
+			}
+		}
+		if size > 0 {
+			switch sizeUnit {
+			case "s_smb":
+				size <<= 20
+			case "s_skb":
+				size <<= 10
+			}
+			c.Size = size
+			c.SizeComparison = "larger"
+			if sizeOperator == "s_ss" {
+				c.SizeComparison = "smaller"
+			}
+		}
+		id := strings.TrimSpace(entry.ID)
+		if j, ok := byID[id]; ok && id != "" {
+			mergeFilterSpecActions(&specs[j].Action, spec.Action)
+			continue
+		}
+		byID[id] = len(specs)
+		specs = append(specs, spec)
+	}
+	return specs, warnings, nil
+}
+
+// mergeFilterSpecActions adds the actions of another entry of the same
+// filter to a.
+func mergeFilterSpecActions(a *gmailFilterSpecAction, other gmailFilterSpecAction) {
+	for _, label := range other.AddLabels {
+		if !slices.Contains(a.AddLabels, label) {
+			a.AddLabels = append(a.AddLabels, label)
+		}
+	}
+	for _, label := range other.RemoveLabels {
+		if !slices.Contains(a.RemoveLabels, label) {
+			a.RemoveLabels = append(a.RemoveLabels, label)
+		}
+	}
+	if a.Forward == "" {
+		a.Forward = other.Forward
+	}
+}
+
+func appendIfTrue(labels []string, value, label string) []string {
+	if value != "true" {
+		return labels
+	}
+	return append(labels, label)
+}
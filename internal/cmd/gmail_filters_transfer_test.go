@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+const testMailFiltersXML = `<?xml version='1.0' encoding='UTF-8'?><feed xmlns='http://www.w3.org/2005/Atom' xmlns:apps='http://schemas.google.com/apps/2006'>
+	<title>Mail Filters</title>
+	<id>tag:mail.google.com,2008:filters:z1,z2</id>
+	<updated>2024-05-01T10:00:00Z</updated>
+	<author><name>Me</name><email>a@b.com</email></author>
+	<entry>
+		<category term='filter'></category>
+		<title>Mail Filter</title>
+		<id>tag:mail.google.com,2008:filter:z1</id>
+		<updated>2024-05-01T10:00:00Z</updated>
+		<content></content>
+		<apps:property name='from' value='news@example.com'/>
+		<apps:property name='label' value='Newsletters'/>
+		<apps:property name='shouldArchive' value='true'/>
+		<apps:property name='sizeOperator' value='s_sl'/>
+		<apps:property name='sizeUnit' value='s_smb'/>
+	</entry>
+	<entry>
+		<category term='filter'></category>
+		<title>Mail Filter</title>
+		<id>tag:mail.google.com,2008:filter:z2</id>
+		<updated>2024-05-01T10:00:00Z</updated>
+		<content></content>
+		<apps:property name='hasTheWord' value='subject:(receipt OR invoice)'/>
+		<apps:property name='size' value='2'/>
+		<apps:property name='sizeOperator' value='s_ss'/>
+		<apps:property name='sizeUnit' value='s_smb'/>
+		<apps:property name='label' value='Receipts'/>
+		<apps:property name='shouldMarkAsRead' value='true'/>
+		<apps:property name='smartLabelToApply' value='^smartlabel_notification'/>
+		<apps:property name='cannedResponse' value='tag:mail.google.com,2009:cannedResponse:1'/>
+	</entry>
+</feed>
+`
+
+type gmailFiltersTestServer struct {
+	filters       []*gmail.Filter
+	labels        []*gmail.Label
+	createdLabels []string
+}
+
+func newGmailFiltersTestServer(t *testing.T) *gmailFiltersTestServer {
+	t.Helper()
+
+	s := &gmailFiltersTestServer{
+		filters: []*gmail.Filter{{
+			Id:       "f1",
+			Criteria: &gmail.FilterCriteria{From: "news@example.com"},
+			Action:   &gmail.FilterAction{AddLabelIds: []string{"Label_1"}, RemoveLabelIds: []string{"INBOX"}},
+		}},
+		labels: []*gmail.Label{
+			{Id: "INBOX", Name: "INBOX", Type: "system"},
+			{Id: "UNREAD", Name: "UNREAD", Type: "system"},
+			{Id: "CATEGORY_UPDATES", Name: "CATEGORY_UPDATES", Type: "system"},
+			{Id: "Label_1", Name: "Newsletters", Type: "user"},
+		},
+	}
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/settings/filters") && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"filter": s.filters})
+		case strings.HasSuffix(r.URL.Path, "/settings/filters") && r.Method == http.MethodPost:
+			var f gmail.Filter
+			_ = json.NewDecoder(r.Body).Decode(&f)
+			f.Id = "f" + string(rune('1'+len(s.filters)))
+			s.filters = append(s.filters, &f)
+			_ = json.NewEncoder(w).Encode(f)
+		case strings.HasSuffix(r.URL.Path, "/labels") && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": s.labels})
+		case strings.HasSuffix(r.URL.Path, "/labels") && r.Method == http.MethodPost:
+			var l gmail.Label
+			_ = json.NewDecoder(r.Body).Decode(&l)
+			l.Id = "Label_" + string(rune('1'+len(s.labels)))
+			s.labels = append(s.labels, &l)
+			s.createdLabels = append(s.createdLabels, l.Name)
+			_ = json.NewEncoder(w).Encode(l)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+	return s
+}
+
+func TestParseMailFiltersXML(t *testing.T) {
+	specs, warnings, err := parseMailFiltersXML([]byte(testMailFiltersXML))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(specs) != 2 {
+		t.Fatalf("expected 2 filters, got %d", len(specs))
+	}
+	if got := specs[0]; got.Criteria.From != "news@example.com" || got.Criteria.Size != 0 ||
+		strings.Join(got.Action.AddLabels, ",") != "Newsletters" || strings.Join(got.Action.RemoveLabels, ",") != "INBOX" {
+		t.Fatalf("unexpected first filter: %+v", got)
+	}
+	got := specs[1]
+	if got.Criteria.Query != "subject:(receipt OR invoice)" || got.Criteria.Size != 2<<20 || got.Criteria.SizeComparison != "smaller" {
+		t.Fatalf("unexpected criteria: %+v", got.Criteria)
+	}
+	if strings.Join(got.Action.AddLabels, ",") != "Receipts,CATEGORY_UPDATES" || strings.Join(got.Action.RemoveLabels, ",") != "UNREAD" {
+		t.Fatalf("unexpected action: %+v", got.Action)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "cannedResponse") {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+}
+
+func TestMailFiltersXML_MultiLabelRoundTrip(t *testing.T) {
+	filters := []*gmail.Filter{
+		{
+			Id:       "f1",
+			Criteria: &gmail.FilterCriteria{From: "team@example.com"},
+			Action:   &gmail.FilterAction{AddLabelIds: []string{"Label_1", "Label_2"}, RemoveLabelIds: []string{"INBOX"}},
+		},
+		{
+			Id:       "f2",
+			Criteria: &gmail.FilterCriteria{From: "team@example.com"},
+			Action:   &gmail.FilterAction{AddLabelIds: []string{"Label_1"}},
+		},
+	}
+	idToName := map[string]string{"Label_1": "Team", "Label_2": "Work"}
+	data, _, err := marshalMailFiltersXML(filters, idToName, "a@b.com", time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if n := strings.Count(string(data), "<entry>"); n != 3 {
+		t.Fatalf("expected one entry per label, got %d:\n%s", n, data)
+	}
+
+	specs, warnings, err := parseMailFiltersXML(data)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("parse: %v %v", err, warnings)
+	}
+	if len(specs) != 2 {
+		t.Fatalf("expected 2 filters, got %d: %+v", len(specs), specs)
+	}
+	if got := specs[0].Action; strings.Join(got.AddLabels, ",") != "Team,Work" || strings.Join(got.RemoveLabels, ",") != "INBOX" {
+		t.Fatalf("unexpected merged action: %+v", got)
+	}
+	if got := specs[1].Action; strings.Join(got.AddLabels, ",") != "Team" || len(got.RemoveLabels) != 0 {
+		t.Fatalf("unexpected second action: %+v", got)
+	}
+}
+
+func TestGmailFiltersExportCmd(t *testing.T) {
+	s := newGmailFiltersTestServer(t)
+	s.filters = append(s.filters, &gmail.Filter{
+		Id:       "f2",
+		Criteria: &gmail.FilterCriteria{Query: "invoice", Size: 3 << 10, SizeComparison: "larger"},
+		Action:   &gmail.FilterAction{AddLabelIds: []string{"STARRED", "CATEGORY_UPDATES"}, RemoveLabelIds: []string{"Label_1"}},
+	})
+
+	var stderr string
+	out := captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			if err := Execute([]string{"--account", "a@b.com", "gmail", "filters", "export"}); err != nil {
+				t.Fatalf("export: %v", err)
+			}
+		})
+	})
+	for _, want := range []string{
+		`xmlns="http://www.w3.org/2005/Atom"`,
+		`xmlns:apps="http://schemas.google.com/apps/2006"`,
+		`<id>tag:mail.google.com,2008:filter:f1</id>`,
+		`<apps:property name="label" value="Newsletters"></apps:property>`,
+		`<apps:property name="shouldArchive" value="true"></apps:property>`,
+		`<apps:property name="size" value="3"></apps:property>`,
+		`<apps:property name="sizeUnit" value="s_skb"></apps:property>`,
+		`<apps:property name="smartLabelToApply" value="^smartlabel_notification"></apps:property>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("export missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(stderr, "cannot export removing label Newsletters") {
+		t.Fatalf("expected warning, got %q", stderr)
+	}
+
+	specs, _, err := parseMailFiltersXML([]byte(out))
+	if err != nil || len(specs) != 2 {
+		t.Fatalf("re-parse: %v (%d filters)", err, len(specs))
+	}
+	if specs[1].Criteria.Size != 3<<10 || strings.Join(specs[1].Action.AddLabels, ",") != "STARRED,CATEGORY_UPDATES" {
+		t.Fatalf("unexpected round trip: %+v", specs[1])
+	}
+
+	path := filepath.Join(t.TempDir(), "filters.json")
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "filters", "export", "--format", "json", "--out", path}); err != nil {
+			t.Fatalf("export json: %v", err)
+		}
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	var doc gmailFiltersDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("json: %v\n%s", err, data)
+	}
+	if len(doc.Filters) != 2 || strings.Join(doc.Filters[1].Action.RemoveLabels, ",") != "Newsletters" {
+		t.Fatalf("unexpected json export: %s", data)
+	}
+}
+
+func TestGmailFiltersImportCmd(t *testing.T) {
+	s := newGmailFiltersTestServer(t)
+	path := filepath.Join(t.TempDir(), "mailFilters.xml")
+	if err := os.WriteFile(path, []byte(testMailFiltersXML), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "filters", "import", path}); err == nil ||
+			!strings.Contains(err.Error(), "labels not found: Receipts") {
+			t.Fatalf("expected missing label error, got %v", err)
+		}
+	})
+	if len(s.filters) != 1 || len(s.createdLabels) != 0 {
+		t.Fatalf("import without --create-labels changed state")
+	}
+
+	out := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--json", "--account", "a@b.com", "gmail", "filters", "import", path, "--create-labels"}); err != nil {
+				t.Fatalf("import: %v", err)
+			}
+		})
+	})
+	var parsed struct {
+		Created       []string `json:"created"`
+		Skipped       int      `json:"skipped"`
+		CreatedLabels []string `json:"createdLabels"`
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if len(parsed.Created) != 1 || parsed.Skipped != 1 || strings.Join(parsed.CreatedLabels, ",") != "Receipts" {
+		t.Fatalf("unexpected import result: %s", out)
+	}
+	f := s.filters[1]
+	if f.Criteria.Size != 2<<20 || strings.Join(f.Action.AddLabelIds, ",") != "Label_5,CATEGORY_UPDATES" ||
+		strings.Join(f.Action.RemoveLabelIds, ",") != "UNREAD" {
+		t.Fatalf("unexpected created filter: %+v %+v", f.Criteria, f.Action)
+	}
+
+	// Importing again creates nothing.
+	out = captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--account", "a@b.com", "gmail", "filters", "import", path}); err != nil {
+				t.Fatalf("re-import: %v", err)
+			}
+		})
+	})
+	if !strings.Contains(out, "created\t0") || !strings.Contains(out, "skipped\t2") || len(s.filters) != 2 {
+		t.Fatalf("unexpected re-import: %q", out)
+	}
+}
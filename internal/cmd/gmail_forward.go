@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const gmailForwardSeparator = "---------- Forwarded message ---------"
+
+type GmailForwardCmd struct {
+	MessageID     string   `arg:"" name:"messageId" help:"Message ID to forward"`
+	To            string   `name:"to" help:"Recipients (comma-separated; required unless --draft)"`
+	Cc            string   `name:"cc" help:"CC recipients (comma-separated)"`
+	Bcc           string   `name:"bcc" help:"BCC recipients (comma-separated)"`
+	Subject       string   `name:"subject" help:"Subject (default: Fwd: <original subject>)"`
+	Body          string   `name:"body" help:"Note above the forwarded message (plain text)"`
+	BodyFile      string   `name:"body-file" help:"Note file path (plain text; '-' for stdin)"`
+	BodyHTML      string   `name:"body-html" help:"Note above the forwarded message (HTML)"`
+	AsAttachment  bool     `name:"as-attachment" help:"Attach the original as a message/rfc822 part instead of inlining it"`
+	NoAttachments bool     `name:"no-attachments" help:"Do not re-attach the original message's attachments"`
+	Attach        []string `name:"attach" help:"Additional attachment file path (repeatable)"`
+	From          string   `name:"from" help:"Send from this email address (must be a verified send-as alias)"`
+	ReplyTo       string   `name:"reply-to" help:"Reply-To header address"`
+	Draft         bool     `name:"draft" help:"Create a draft instead of sending"`
+}
+
+func (c *GmailForwardCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	messageID := normalizeGmailMessageID(c.MessageID)
+	if messageID == "" {
+		return usage("empty messageId")
+	}
+	if strings.TrimSpace(c.To) == "" && !c.Draft {
+		return usage("required: --to (or use --draft)")
+	}
+	if c.AsAttachment && c.NoAttachments {
+		return usage("--no-attachments has no effect with --as-attachment")
+	}
+
+	note, err := resolveBodyInput(c.Body, c.BodyFile)
+	if err != nil {
+		return err
+	}
+
+	attachPaths := make([]string, 0, len(c.Attach))
+	for _, p := range c.Attach {
+		expanded, expandErr := config.ExpandPath(p)
+		if expandErr != nil {
+			return expandErr
+		}
+		attachPaths = append(attachPaths, expanded)
+	}
+
+	if dryRunErr := dryRunExit(ctx, flags, "gmail.forward", map[string]any{
+		"message_id":     messageID,
+		"to":             splitCSV(c.To),
+		"cc":             splitCSV(c.Cc),
+		"bcc":            splitCSV(c.Bcc),
+		"subject":        strings.TrimSpace(c.Subject),
+		"body_len":       len(strings.TrimSpace(note)),
+		"body_html_len":  len(strings.TrimSpace(c.BodyHTML)),
+		"as_attachment":  c.AsAttachment,
+		"no_attachments": c.NoAttachments,
+		"attachments":    attachPaths,
+		"from":           strings.TrimSpace(c.From),
+		"reply_to":       strings.TrimSpace(c.ReplyTo),
+		"draft":          c.Draft,
+	}); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	fromAddr, _, err := resolveSendFromAddress(ctx, svc, account, c.From)
+	if err != nil {
+		return err
+	}
+
+	var fwd *gmailForward
+	if c.AsAttachment {
+		fwd, err = buildGmailForwardAsAttachment(ctx, svc, messageID, note, c.BodyHTML)
+	} else {
+		fwd, err = buildGmailForwardInline(ctx, svc, messageID, note, c.BodyHTML, !c.NoAttachments)
+	}
+	if err != nil {
+		return err
+	}
+	for _, p := range attachPaths {
+		fwd.Attachments = append(fwd.Attachments, mailAttachment{Path: p})
+	}
+
+	subject := strings.TrimSpace(c.Subject)
+	if subject == "" {
+		subject = forwardSubject(fwd.Subject)
+	}
+
+	raw, err := buildRFC822(mailOptions{
+		From:         fromAddr,
+		To:           splitCSV(c.To),
+		Cc:           splitCSV(c.Cc),
+		Bcc:          splitCSV(c.Bcc),
+		ReplyTo:      c.ReplyTo,
+		Subject:      subject,
+		Body:         fwd.Body,
+		BodyHTML:     fwd.BodyHTML,
+		Attachments:  fwd.Attachments,
+		InlineImages: fwd.InlineImages,
+	}, &rfc822Config{allowMissingTo: c.Draft})
+	if err != nil {
+		return fmt.Errorf("failed to build message: %w", err)
+	}
+	msg := &gmail.Message{Raw: base64.RawURLEncoding.EncodeToString(raw)}
+
+	if c.Draft {
+		draft, draftErr := svc.Users.Drafts.Create("me", &gmail.Draft{Message: msg}).Context(ctx).Do()
+		if draftErr != nil {
+			return draftErr
+		}
+		return writeDraftResult(ctx, u, draft, "")
+	}
+
+	sent, err := svc.Users.Messages.Send("me", msg).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	return writeSendResults(ctx, u, fromAddr, []sendResult{{MessageID: sent.Id, ThreadID: sent.ThreadId}})
+}
+
+// gmailForward is the content of a forward before addressing: bodies with
+// the original quoted below the note, plus parts carried over from it.
+type gmailForward struct {
+	Subject      string
+	Body         string
+	BodyHTML     string
+	Attachments  []mailAttachment
+	InlineImages []mailAttachment
+}
+
+func buildGmailForwardInline(ctx context.Context, svc *gmail.Service, messageID, note, noteHTML string, withAttachments bool) (*gmailForward, error) {
+	msg, err := svc.Users.Messages.Get("me", messageID).Format(gmailFormatFull).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if msg.Payload == nil {
+		return nil, errors.New("message has no payload")
+	}
+
+	header := forwardHeaderFields(msg.Payload)
+	origPlain := findPartBody(msg.Payload, "text/plain")
+	origHTML := findPartBody(msg.Payload, "text/html")
+	if origPlain == "" && origHTML != "" {
+		origPlain = stripHTMLTags(origHTML)
+	}
+	if origHTML == "" {
+		origHTML = escapeTextToHTML(origPlain)
+	}
+
+	fwd := &gmailForward{Subject: headerValue(msg.Payload, "Subject")}
+
+	var plain strings.Builder
+	if strings.TrimSpace(note) != "" {
+		plain.WriteString(strings.TrimRight(note, "\n"))
+		plain.WriteString("\n\n")
+	}
+	plain.WriteString(gmailForwardSeparator + "\n")
+	for _, f := range header {
+		plain.WriteString(f[0] + ": " + f[1] + "\n")
+	}
+	plain.WriteString("\n")
+	plain.WriteString(origPlain)
+	fwd.Body = plain.String()
+
+	var htmlBody strings.Builder
+	switch {
+	case strings.TrimSpace(noteHTML) != "":
+		htmlBody.WriteString(noteHTML)
+		htmlBody.WriteString("<br><br>")
+	case strings.TrimSpace(note) != "":
+		htmlBody.WriteString(escapeTextToHTML(strings.TrimRight(note, "\n")))
+		htmlBody.WriteString("<br><br>")
+	}
+	htmlBody.WriteString(`<div class="gmail_quote"><div dir="ltr" class="gmail_attr">` + gmailForwardSeparator + "<br>")
+	for _, f := range header {
+		htmlBody.WriteString(html.EscapeString(f[0]) + ": " + html.EscapeString(f[1]) + "<br>")
+	}
+	htmlBody.WriteString("</div><br><br>")
+	htmlBody.WriteString(origHTML)
+	htmlBody.WriteString("</div>")
+	fwd.BodyHTML = htmlBody.String()
+
+	if err := collectForwardParts(ctx, svc, messageID, msg.Payload, origHTML, withAttachments, fwd); err != nil {
+		return nil, err
+	}
+	return fwd, nil
+}
+
+func buildGmailForwardAsAttachment(ctx context.Context, svc *gmail.Service, messageID, note, noteHTML string) (*gmailForward, error) {
+	msg, err := svc.Users.Messages.Get("me", messageID).Format(gmailFormatRaw).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeGmailRaw(msg.Raw)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := ""
+	if parsed, parseErr := mail.ReadMessage(bytes.NewReader(raw)); parseErr == nil {
+		subject = decodeForwardHeader(parsed.Header.Get("Subject"))
+	}
+
+	filename := strings.TrimSpace(sanitizeForwardFilename(subject))
+	if filename == "" {
+		filename = "forwarded message"
+	}
+
+	return &gmailForward{
+		Subject:  subject,
+		Body:     note,
+		BodyHTML: noteHTML,
+		Attachments: []mailAttachment{{
+			Filename: filename + ".eml",
+			MIMEType: "message/rfc822",
+			Data:     raw,
+		}},
+	}, nil
+}
+
+// collectForwardParts downloads the original's attachments. Parts the HTML
+// references by cid: stay inline so embedded images keep rendering, even
+// when regular attachments are dropped.
+func collectForwardParts(ctx context.Context, svc *gmail.Service, messageID string, p *gmail.MessagePart, origHTML string, withAttachments bool, fwd *gmailForward) error {
+	if p == nil {
+		return nil
+	}
+	if strings.HasPrefix(strings.ToLower(p.MimeType), "multipart/") {
+		for _, part := range p.Parts {
+			if err := collectForwardParts(ctx, svc, messageID, part, origHTML, withAttachments, fwd); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	contentID := strings.Trim(strings.TrimSpace(headerValue(p, "Content-ID")), "<>")
+	inline := contentID != "" && strings.Contains(origHTML, "cid:"+contentID)
+	if !inline && (p.Filename == "" || !withAttachments) {
+		return nil
+	}
+	if p.Body == nil || (p.Body.AttachmentId == "" && p.Body.Data == "") {
+		return nil
+	}
+
+	var data []byte
+	var err error
+	if p.Body.AttachmentId != "" {
+		data, err = fetchAttachmentBytes(ctx, svc, messageID, p.Body.AttachmentId)
+	} else {
+		data, err = decodeBase64URLBytes(p.Body.Data)
+	}
+	if err != nil {
+		return fmt.Errorf("attachment %q: %w", p.Filename, err)
+	}
+
+	filename := p.Filename
+	if strings.TrimSpace(filename) == "" {
+		filename = "attachment"
+	}
+	a := mailAttachment{Filename: filename, MIMEType: normalizeMimeType(p.MimeType), Data: data}
+	if inline {
+		a.ContentID = contentID
+		fwd.InlineImages = append(fwd.InlineImages, a)
+		return nil
+	}
+	fwd.Attachments = append(fwd.Attachments, a)
+	return nil
+}
+
+// forwardHeaderFields returns the header lines shown in the forwarded block.
+func forwardHeaderFields(p *gmail.MessagePart) [][2]string {
+	var out [][2]string
+	for _, name := range []string{"From", "Date", "Subject", "To", "Cc"} {
+		if v := strings.TrimSpace(headerValue(p, name)); v != "" {
+			out = append(out, [2]string{name, v})
+		}
+	}
+	return out
+}
+
+// forwardSubject prefixes "Fwd: " unless the subject already is a forward.
+func forwardSubject(subject string) string {
+	subject = strings.TrimSpace(subject)
+	lower := strings.ToLower(subject)
+	if strings.HasPrefix(lower, "fwd:") || strings.HasPrefix(lower, "fw:") {
+		return subject
+	}
+	if subject == "" {
+		return "Fwd:"
+	}
+	return "Fwd: " + subject
+}
+
+func decodeForwardHeader(v string) string {
+	dec := mime.WordDecoder{CharsetReader: forwardCharsetReader}
+	if out, err := dec.DecodeHeader(v); err == nil {
+		return out
+	}
+	return v
+}
+
+func forwardCharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	if decoded, ok := decodeWithCharsetLabel(data, charset); ok {
+		return bytes.NewReader(decoded), nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+func sanitizeForwardFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		default:
+			return r
+		}
+	}, name)
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func b64url(s string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func newGmailForwardTestServer(t *testing.T, captured *string) {
+	t.Helper()
+
+	original := map[string]any{
+		"id": "m1",
+		"payload": map[string]any{
+			"mimeType": "multipart/mixed",
+			"headers": []map[string]any{
+				{"name": "From", "value": "Alice <alice@example.com>"},
+				{"name": "Date", "value": "Mon, 2 Mar 2026 10:00:00 +0000"},
+				{"name": "Subject", "value": "Q1 report"},
+				{"name": "To", "value": "me@example.com"},
+			},
+			"parts": []map[string]any{
+				{
+					"mimeType": "multipart/related",
+					"parts": []map[string]any{
+						{
+							"mimeType": "multipart/alternative",
+							"parts": []map[string]any{
+								{"mimeType": "text/plain", "body": map[string]any{"data": b64url("Numbers inside.")}},
+								{"mimeType": "text/html", "body": map[string]any{"data": b64url(`<p>Numbers <img src="cid:chart@x"></p>`)}},
+							},
+						},
+						{
+							"mimeType": "image/png",
+							"headers":  []map[string]any{{"name": "Content-ID", "value": "<chart@x>"}},
+							"body":     map[string]any{"data": b64url("PNG")},
+						},
+					},
+				},
+				{"mimeType": "application/pdf", "filename": "q1.pdf", "body": map[string]any{"attachmentId": "att1", "size": 3}},
+			},
+		},
+	}
+	raw := "From: Alice <alice@example.com>\r\nSubject: =?utf-8?q?Q1_r=C3=A9port?=\r\n\r\nNumbers inside.\r\n"
+
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/settings/sendAs"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"sendAs": []map[string]any{
+				{"sendAsEmail": "me@example.com", "displayName": "Me", "isPrimary": true, "verificationStatus": "accepted"},
+			}})
+		case strings.HasSuffix(path, "/messages/m1/attachments/att1"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": b64url("PDF")})
+		case strings.HasSuffix(path, "/messages/m1"):
+			if r.URL.Query().Get("format") == "raw" {
+				_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "raw": b64url(raw)})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(original)
+		case strings.HasSuffix(path, "/messages/send"), strings.HasSuffix(path, "/drafts"):
+			var body struct {
+				Raw     string `json:"raw"`
+				Message struct {
+					Raw string `json:"raw"`
+				} `json:"message"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			enc := body.Raw
+			if enc == "" {
+				enc = body.Message.Raw
+			}
+			data, _ := base64.RawURLEncoding.DecodeString(enc)
+			*captured = string(data)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "new1", "threadId": "t1", "message": map[string]any{"id": "new1"}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+}
+
+func TestGmailForward_Inline(t *testing.T) {
+	var sent string
+	newGmailForwardTestServer(t, &sent)
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "forward", "m1",
+			"--to", "bob@example.com", "--body", "FYI"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	for _, want := range []string{
+		"Subject: Fwd: Q1 report\r\n",
+		`From: "Me" <me@example.com>` + "\r\n",
+		"FYI\r\n\r\n" + gmailForwardSeparator + "\r\nFrom: Alice <alice@example.com>\r\nDate: Mon, 2 Mar 2026 10:00:00 +0000\r\nSubject: Q1 report\r\nTo: me@example.com\r\n\r\nNumbers inside.",
+		`From: Alice &lt;alice@example.com&gt;<br>`,
+		`<p>Numbers <img src="cid:chart@x"></p></div>`,
+		"multipart/related",
+		"Content-ID: <chart@x>",
+		"Content-Type: application/pdf\r\n",
+		`filename="q1.pdf"`,
+	} {
+		if !strings.Contains(sent, want) {
+			t.Fatalf("forward missing %q:\n%s", want, sent)
+		}
+	}
+
+	sent = ""
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "forward", "m1",
+			"--to", "bob@example.com", "--no-attachments", "--subject", "fw: custom"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	if strings.Contains(sent, "q1.pdf") || !strings.Contains(sent, "Content-ID: <chart@x>") || !strings.Contains(sent, "Subject: fw: custom\r\n") {
+		t.Fatalf("unexpected --no-attachments forward:\n%s", sent)
+	}
+}
+
+func TestGmailForward_AsAttachmentDraft(t *testing.T) {
+	var drafted string
+	newGmailForwardTestServer(t, &drafted)
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "forward", "m1", "--as-attachment", "--draft"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	for _, want := range []string{
+		"Subject: =?utf-8?q?Fwd:_Q1_r=C3=A9port?=\r\n",
+		"Content-Type: message/rfc822\r\nContent-Transfer-Encoding: 7bit\r\n",
+		"filename*=UTF-8''Q1%20r%C3%A9port.eml",
+		"\r\n\r\nFrom: Alice <alice@example.com>\r\nSubject: =?utf-8?q?Q1_r=C3=A9port?=\r\n\r\nNumbers inside.\r\n",
+	} {
+		if !strings.Contains(drafted, want) {
+			t.Fatalf("draft missing %q:\n%s", want, drafted)
+		}
+	}
+	if strings.Contains(drafted, "\r\nTo:") {
+		t.Fatalf("draft should have no recipients:\n%s", drafted)
+	}
+}
+
+func TestForwardSubject(t *testing.T) {
+	cases := map[string]string{
+		"Report":      "Fwd: Report",
+		"Fwd: Report": "Fwd: Report",
+		"FW: Report":  "FW: Report",
+		"Re: Report":  "Fwd: Re: Report",
+		"":            "Fwd:",
+	}
+	for in, want := range cases {
+		if got := forwardSubject(in); got != want {
+			t.Fatalf("forwardSubject(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "forward", "m1"}); err == nil {
+			t.Fatalf("expected --to to be required without --draft")
+		}
+	})
+}
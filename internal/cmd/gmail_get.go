@@ -7,14 +7,20 @@ import (
 	"os"
 	"strings"
 
+	"github.com/steipete/gogcli/internal/htmltext"
 	"github.com/steipete/gogcli/internal/outfmt"
 	"github.com/steipete/gogcli/internal/ui"
 )
 
 type GmailGetCmd struct {
-	MessageID string `arg:"" name:"messageId" help:"Message ID"`
-	Format    string `name:"format" help:"Message format: full|metadata|raw" default:"full"`
-	Headers   string `name:"headers" help:"Metadata headers (comma-separated; only for --format=metadata)"`
+	MessageID      string `arg:"" name:"messageId" help:"Message ID"`
+	Format         string `name:"format" help:"Message format: full|metadata|raw" default:"full"`
+	Headers        string `name:"headers" help:"Metadata headers (comma-separated; only for --format=metadata)"`
+	PGPVerify      bool   `name:"pgp-verify" help:"Verify PGP/MIME signatures against the local OpenPGP keyring"`
+	Decrypt        bool   `name:"decrypt" help:"Decrypt PGP/MIME messages with the local OpenPGP keyring (signatures inside are verified too)"`
+	PGPKeyring     string `name:"pgp-keyring" help:"OpenPGP keyring file (default: $GOG_PGP_KEYRING, then pgp/keyring.asc in the config dir)"`
+	Tree           bool   `name:"tree" help:"Show the MIME part tree (types, sizes, dispositions) instead of the message"`
+	CollapseQuotes bool   `name:"collapse-quotes" help:"Collapse quoted replies when rendering HTML bodies"`
 }
 
 const (
@@ -44,6 +50,9 @@ func (c *GmailGetCmd) Run(ctx context.Context, flags *RootFlags) error {
 	default:
 		return fmt.Errorf("invalid --format: %q (expected full|metadata|raw)", format)
 	}
+	if c.Tree && format != gmailFormatFull {
+		return usage("--tree requires --format full")
+	}
 
 	svc, err := newGmailService(ctx, account)
 	if err != nil {
@@ -66,8 +75,48 @@ func (c *GmailGetCmd) Run(ctx context.Context, flags *RootFlags) error {
 	if err != nil {
 		return err
 	}
+	if c.Tree {
+		return writeMessageTree(ctx, u, msg)
+	}
 
 	unsubscribe := bestUnsubscribeLink(msg.Payload)
+
+	// S/MIME and PGP/MIME: verify and decrypt from the raw message; the body
+	// shown is the innermost (decrypted) content when available.
+	fetchRaw := func() ([]byte, error) {
+		rawMsg, rawErr := svc.Users.Messages.Get("me", messageID).Format(gmailFormatRaw).Context(ctx).Do()
+		if rawErr != nil {
+			return nil, rawErr
+		}
+		return decodeGmailRaw(rawMsg.Raw)
+	}
+	var smimeInfo *smimeReport
+	var pgpInfo *pgpReport
+	bodyPart := msg.Payload
+	if format == gmailFormatFull && isSMIMEPayload(msg.Payload) {
+		raw, rawErr := fetchRaw()
+		if rawErr != nil {
+			return rawErr
+		}
+		smimeInfo = inspectSMIME(raw, account)
+		if smimeInfo.Content != nil {
+			bodyPart = smimeInfo.Content
+		}
+	}
+	if format == gmailFormatFull && (c.PGPVerify || c.Decrypt) && isPGPPayload(msg.Payload) {
+		keyring, keyringErr := loadPGPKeyring(c.PGPKeyring)
+		if keyringErr != nil {
+			return keyringErr
+		}
+		raw, rawErr := fetchRaw()
+		if rawErr != nil {
+			return rawErr
+		}
+		pgpInfo = inspectPGP(raw, keyring, c.Decrypt, pgpPassphrase(flags))
+		if pgpInfo.Content != nil {
+			bodyPart = pgpInfo.Content
+		}
+	}
 	if outfmt.IsJSON(ctx) {
 		// Include a flattened headers map for easier querying
 		// (e.g., jq '.headers.to' instead of complex nested queries)
@@ -87,9 +136,18 @@ func (c *GmailGetCmd) Run(ctx context.Context, flags *RootFlags) error {
 			payload["unsubscribe"] = unsubscribe
 		}
 		if format == gmailFormatFull {
-			if body := bestBodyText(msg.Payload); body != "" {
+			if body := bestBodyText(bodyPart); body != "" {
 				payload["body"] = body
 			}
+			if html, isHTML := bestBodyForDisplay(bodyPart); isHTML {
+				payload["bodyText"] = htmltext.Render(html, htmltext.Options{CollapseQuotes: c.CollapseQuotes})
+			}
+		}
+		if smimeInfo != nil {
+			payload["smime"] = smimeInfo.output()
+		}
+		if pgpInfo != nil {
+			payload["pgp"] = pgpInfo.output()
 		}
 		if format == gmailFormatFull || format == gmailFormatMetadata {
 			attachments := collectAttachments(msg.Payload)
@@ -127,13 +185,19 @@ func (c *GmailGetCmd) Run(ctx context.Context, flags *RootFlags) error {
 		if unsubscribe != "" {
 			u.Out().Printf("unsubscribe\t%s", unsubscribe)
 		}
+		if smimeInfo != nil {
+			smimeInfo.print(u)
+		}
+		if pgpInfo != nil {
+			pgpInfo.print(u)
+		}
 		attachments := attachmentOutputs(collectAttachments(msg.Payload))
 		if len(attachments) > 0 {
 			u.Out().Println("")
 			printAttachmentLines(u.Out(), attachments)
 		}
 		if format == gmailFormatFull {
-			body := bestBodyText(msg.Payload)
+			body := renderedBodyText(bodyPart, c.CollapseQuotes)
 			if body != "" {
 				u.Out().Println("")
 				u.Out().Println(body)
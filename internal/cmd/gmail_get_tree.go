@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+type mimeTreeNode struct {
+	PartID       string          `json:"partId"`
+	MimeType     string          `json:"mimeType"`
+	Filename     string          `json:"filename,omitempty"`
+	Disposition  string          `json:"disposition,omitempty"`
+	Size         int64           `json:"size"`
+	SizeHuman    string          `json:"sizeHuman"`
+	AttachmentID string          `json:"attachmentId,omitempty"`
+	ContentID    string          `json:"contentId,omitempty"`
+	Parts        []*mimeTreeNode `json:"parts,omitempty"`
+}
+
+// buildMimeTree mirrors the payload's part hierarchy. Multipart sizes are
+// the sum of their children since Gmail reports 0 for containers.
+func buildMimeTree(p *gmail.MessagePart) *mimeTreeNode {
+	if p == nil {
+		return nil
+	}
+	node := &mimeTreeNode{
+		PartID:      p.PartId,
+		MimeType:    p.MimeType,
+		Filename:    p.Filename,
+		Disposition: partDisposition(p),
+		ContentID:   strings.Trim(headerValue(p, "Content-ID"), "<> "),
+	}
+	if p.Body != nil {
+		node.Size = p.Body.Size
+		node.AttachmentID = p.Body.AttachmentId
+	}
+	for _, part := range p.Parts {
+		child := buildMimeTree(part)
+		if child == nil {
+			continue
+		}
+		node.Parts = append(node.Parts, child)
+		if p.Body == nil || p.Body.Size == 0 {
+			node.Size += child.Size
+		}
+	}
+	node.SizeHuman = formatBytes(node.Size)
+	return node
+}
+
+func partDisposition(p *gmail.MessagePart) string {
+	value := strings.TrimSpace(headerValue(p, "Content-Disposition"))
+	if value == "" {
+		return ""
+	}
+	if disposition, _, err := mime.ParseMediaType(value); err == nil {
+		return disposition
+	}
+	if idx := strings.Index(value, ";"); idx != -1 {
+		value = value[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func writeMessageTree(ctx context.Context, u *ui.UI, msg *gmail.Message) error {
+	tree := buildMimeTree(msg.Payload)
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"id":       msg.Id,
+			"threadId": msg.ThreadId,
+			"tree":     tree,
+		})
+	}
+	if tree == nil {
+		u.Err().Println("Empty payload")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PART\tTYPE\tSIZE\tDISPOSITION\tFILENAME")
+	var walk func(n *mimeTreeNode, depth int)
+	walk = func(n *mimeTreeNode, depth int) {
+		partID := n.PartID
+		if partID == "" {
+			partID = "-"
+		}
+		disposition := n.Disposition
+		if disposition == "" {
+			disposition = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s%s\t%s\t%s\t%s\n",
+			partID,
+			strings.Repeat("  ", depth),
+			n.MimeType,
+			n.SizeHuman,
+			disposition,
+			n.Filename)
+		for _, child := range n.Parts {
+			walk(child, depth+1)
+		}
+	}
+	walk(tree, 0)
+	return tw.Flush()
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func newsletterMessage() map[string]any {
+	html := `<html><body><div style="display:none">preheader</div><h1>Weekly</h1>` +
+		`<p>Read <a href="https://example.com/post">the post</a>.</p>` +
+		`<table><tr><td>Plan</td><td>Pro</td></tr></table>` +
+		`<blockquote><p>old reply</p></blockquote></body></html>`
+	return map[string]any{
+		"id":       "m1",
+		"threadId": "t1",
+		"payload": map[string]any{
+			"mimeType": "multipart/mixed",
+			"headers":  []map[string]any{{"name": "Subject", "value": "Weekly"}},
+			"parts": []map[string]any{
+				{
+					"partId":   "0",
+					"mimeType": "multipart/related",
+					"parts": []map[string]any{
+						{
+							"partId":   "0.0",
+							"mimeType": "text/html",
+							"body":     map[string]any{"size": len(html), "data": base64.RawURLEncoding.EncodeToString([]byte(html))},
+						},
+						{
+							"partId":   "0.1",
+							"mimeType": "image/png",
+							"filename": "logo.png",
+							"headers": []map[string]any{
+								{"name": "Content-Disposition", "value": `inline; filename="logo.png"`},
+								{"name": "Content-ID", "value": "<logo@x>"},
+							},
+							"body": map[string]any{"size": 2048, "attachmentId": "att-logo"},
+						},
+					},
+				},
+				{
+					"partId":   "1",
+					"mimeType": "application/pdf",
+					"filename": "issue.pdf",
+					"headers":  []map[string]any{{"name": "Content-Disposition", "value": `attachment; filename="issue.pdf"`}},
+					"body":     map[string]any{"size": 1 << 20, "attachmentId": "att-pdf"},
+				},
+			},
+		},
+	}
+}
+
+func stubNewsletterGmail(t *testing.T) {
+	t.Helper()
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/users/me/messages/m1"):
+			_ = json.NewEncoder(w).Encode(newsletterMessage())
+		case strings.HasSuffix(r.URL.Path, "/users/me/threads/t1"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "t1", "messages": []map[string]any{newsletterMessage()}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+}
+
+func TestGmailGetTree_JSON(t *testing.T) {
+	stubNewsletterGmail(t)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "a@b.com", "gmail", "get", "m1", "--tree"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	var parsed struct {
+		ID   string       `json:"id"`
+		Tree mimeTreeNode `json:"tree"`
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("unmarshal: %v (%q)", err, out)
+	}
+	root := parsed.Tree
+	if parsed.ID != "m1" || root.MimeType != "multipart/mixed" || len(root.Parts) != 2 {
+		t.Fatalf("unexpected tree: %s", out)
+	}
+	related := root.Parts[0]
+	logo := related.Parts[1]
+	if logo.PartID != "0.1" || logo.Disposition != "inline" || logo.ContentID != "logo@x" || logo.Size != 2048 {
+		t.Fatalf("unexpected logo part: %+v", logo)
+	}
+	pdf := root.Parts[1]
+	if pdf.Disposition != "attachment" || pdf.Filename != "issue.pdf" || pdf.SizeHuman != "1.0 MB" {
+		t.Fatalf("unexpected pdf part: %+v", pdf)
+	}
+	if root.Size != related.Size+pdf.Size || related.Size <= 2048 {
+		t.Fatalf("container sizes should sum children: root=%d related=%d", root.Size, related.Size)
+	}
+}
+
+func TestGmailGetTree_Text(t *testing.T) {
+	stubNewsletterGmail(t)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "get", "m1", "--tree"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "PART") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if fields := strings.Fields(lines[4]); len(fields) != 6 || fields[0] != "0.1" || fields[1] != "image/png" || fields[4] != "inline" || fields[5] != "logo.png" {
+		t.Fatalf("unexpected logo row: %q", lines[4])
+	}
+	if !strings.Contains(lines[3], "    text/html") {
+		t.Fatalf("nested parts should be indented: %q", lines[3])
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "get", "m1", "--tree", "--format", "raw"}); err == nil {
+			t.Fatalf("expected --tree/--format error")
+		}
+	})
+}
+
+func TestGmailGet_RendersHTMLBody(t *testing.T) {
+	stubNewsletterGmail(t)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "get", "m1", "--collapse-quotes"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	for _, want := range []string{"Weekly\n\nRead the post [1].\n\nPlan | Pro\n\n[quoted text hidden: 1 line]", "[1] https://example.com/post"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<") || strings.Contains(out, "preheader") {
+		t.Fatalf("html leaked into output:\n%s", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "a@b.com", "gmail", "get", "m1"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	var parsed struct {
+		Body     string `json:"body"`
+		BodyText string `json:"bodyText"`
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !strings.HasPrefix(parsed.Body, "<html>") || !strings.Contains(parsed.BodyText, "> old reply") {
+		t.Fatalf("unexpected body fields: %+v", parsed)
+	}
+}
+
+func TestGmailThreadGet_FullRendersHTMLBody(t *testing.T) {
+	stubNewsletterGmail(t)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "thread", "get", "t1", "--full"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	for _, want := range []string{"Read the post [1].", "Plan | Pro", "> old reply", "[1] https://example.com/post"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,505 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+	gapi "google.golang.org/api/googleapi"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/mailbox"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	gmailImportModeImport = "import"
+	gmailImportModeInsert = "insert"
+
+	gmailImportStateSuffix = ".gog-import"
+	gmailImportMaxParallel = 16
+)
+
+type GmailImportCmd struct {
+	Source             string   `arg:"" name:"source" help:"mbox file, .eml file, Maildir, or directory of .eml files"`
+	Mode               string   `name:"mode" help:"import (normal delivery scanning) or insert (store as-is, no scanning)" enum:"import,insert" default:"import"`
+	Label              []string `name:"label" help:"Label to add to every message (name or ID; repeatable)"`
+	LabelMap           []string `name:"label-map" help:"Rename a source label, e.g. 'Old/Name=New/Name' (empty target drops it; repeatable)"`
+	NoSourceLabels     bool     `name:"no-source-labels" help:"Ignore X-Gmail-Labels headers and Maildir flags in the source"`
+	CreateLabels       bool     `name:"create-labels" help:"Create missing user labels (default: true)" default:"true" negatable:"_"`
+	NeverMarkSpam      bool     `name:"never-mark-spam" help:"Never classify imported messages as spam (import mode)"`
+	ProcessForCalendar bool     `name:"process-for-calendar" help:"Add invitations in imported messages to Calendar (import mode)"`
+	Dedupe             bool     `name:"dedupe" help:"Skip messages whose Message-ID already exists in the mailbox (default: true)" default:"true" negatable:"_"`
+	Concurrency        int      `name:"concurrency" help:"Parallel uploads (1-16)" default:"4"`
+	State              string   `name:"state" help:"Resume checkpoint file (default: <source>.gog-import, or <source>/.gog-import for directories)"`
+	Restart            bool     `name:"restart" help:"Ignore the checkpoint and import everything again"`
+}
+
+// gmailImportItem is one message read from the source. key identifies it
+// in the checkpoint file and stays stable across runs.
+type gmailImportItem struct {
+	key     string
+	raw     []byte
+	flags   string
+	maildir bool
+}
+
+func (c *GmailImportCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	source, err := config.ExpandPath(strings.TrimSpace(c.Source))
+	if err != nil {
+		return err
+	}
+	if source == "" {
+		return usage("missing source")
+	}
+	st, err := os.Stat(source)
+	if err != nil {
+		return fmt.Errorf("import source: %w", err)
+	}
+
+	if c.Concurrency < 1 || c.Concurrency > gmailImportMaxParallel {
+		return usagef("--concurrency must be between 1 and %d", gmailImportMaxParallel)
+	}
+	if c.Mode == gmailImportModeInsert && (c.NeverMarkSpam || c.ProcessForCalendar) {
+		return usage("--never-mark-spam and --process-for-calendar require --mode import")
+	}
+
+	labelMap, err := parseGmailImportLabelMap(c.LabelMap)
+	if err != nil {
+		return err
+	}
+
+	statePath := strings.TrimSpace(c.State)
+	switch {
+	case statePath != "":
+		if statePath, err = config.ExpandPath(statePath); err != nil {
+			return err
+		}
+	case st.IsDir():
+		statePath = filepath.Join(source, gmailImportStateSuffix)
+	default:
+		statePath = source + gmailImportStateSuffix
+	}
+
+	if err = dryRunExit(ctx, flags, "gmail.import", map[string]any{
+		"source":               source,
+		"mode":                 c.Mode,
+		"labels":               c.Label,
+		"label_map":            labelMap,
+		"source_labels":        !c.NoSourceLabels,
+		"never_mark_spam":      c.NeverMarkSpam,
+		"process_for_calendar": c.ProcessForCalendar,
+		"dedupe":               c.Dedupe,
+		"state":                statePath,
+	}); err != nil {
+		return err
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	done := map[string]bool{}
+	if c.Restart {
+		if removeErr := os.Remove(statePath); removeErr != nil && !os.IsNotExist(removeErr) {
+			return fmt.Errorf("reset import state: %w", removeErr)
+		}
+	} else if done, err = readGmailProgressState(statePath); err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	nameToID, err := fetchLabelNameToID(svc)
+	if err != nil {
+		return err
+	}
+	labels := &gmailImportLabels{svc: svc, nameToID: nameToID, create: c.CreateLabels}
+
+	state, err := os.OpenFile(statePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // user-provided path
+	if err != nil {
+		return fmt.Errorf("open import state: %w", err)
+	}
+	defer state.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu         sync.Mutex
+		seenIDs    = map[string]bool{}
+		imported   int
+		duplicates int
+		skipped    int
+	)
+
+	record := func(key string, duplicate bool) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, writeErr := fmt.Fprintln(state, key); writeErr != nil {
+			return fmt.Errorf("record import state: %w", writeErr)
+		}
+		if duplicate {
+			duplicates++
+		} else {
+			imported++
+		}
+		if n := imported + duplicates; n%gmailExportProgressEvery == 0 && !outfmt.IsJSON(ctx) {
+			u.Err().Printf("imported %d, duplicates %d", imported, duplicates)
+		}
+		return nil
+	}
+
+	process := func(item gmailImportItem) error {
+		if c.Dedupe {
+			if msgID := mailbox.HeaderValue(item.raw, "Message-ID"); msgID != "" {
+				mu.Lock()
+				dup := seenIDs[msgID]
+				seenIDs[msgID] = true
+				mu.Unlock()
+
+				if !dup {
+					exists, existsErr := gmailMessageIDExists(ctx, svc, msgID)
+					if existsErr != nil {
+						return fmt.Errorf("check duplicate %s: %w", item.key, existsErr)
+					}
+					dup = exists
+				}
+				if dup {
+					return record(item.key, true)
+				}
+			}
+		}
+
+		names := append([]string(nil), c.Label...)
+		if !c.NoSourceLabels {
+			names = append(gmailImportSourceLabels(item, labelMap), names...)
+		}
+		labelIDs, labelErr := labels.resolve(ctx, names)
+		if labelErr != nil {
+			return labelErr
+		}
+
+		if uploadErr := uploadGmailImportMessage(ctx, svc, c, item.raw, labelIDs); uploadErr != nil {
+			return fmt.Errorf("%s %s: %w", c.Mode, item.key, uploadErr)
+		}
+		return record(item.key, false)
+	}
+
+	items := make(chan gmailImportItem)
+	errs := make(chan error, c.Concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range items {
+				if procErr := process(item); procErr != nil {
+					errs <- procErr
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+	readErr := readGmailImportSource(source, st.IsDir(), func(item gmailImportItem) error {
+		if done[item.key] {
+			skipped++
+			return nil
+		}
+		select {
+		case items <- item:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(items)
+	wg.Wait()
+	close(errs)
+
+	if procErr := <-errs; procErr != nil {
+		return procErr
+	}
+	if readErr != nil {
+		return readErr
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"source":     source,
+			"mode":       c.Mode,
+			"state":      statePath,
+			"imported":   imported,
+			"duplicates": duplicates,
+			"skipped":    skipped,
+		})
+	}
+
+	u.Out().Printf("source\t%s", source)
+	u.Out().Printf("mode\t%s", c.Mode)
+	u.Out().Printf("imported\t%d", imported)
+	u.Out().Printf("duplicates\t%d", duplicates)
+	u.Out().Printf("skipped\t%d", skipped)
+	return nil
+}
+
+func uploadGmailImportMessage(ctx context.Context, svc *gmail.Service, c *GmailImportCmd, raw []byte, labelIDs []string) error {
+	msg := &gmail.Message{LabelIds: labelIDs}
+	media := gapi.ContentType("message/rfc822")
+
+	if c.Mode == gmailImportModeInsert {
+		_, err := svc.Users.Messages.Insert("me", msg).
+			InternalDateSource("dateHeader").
+			Media(bytes.NewReader(raw), media).
+			Context(ctx).
+			Do()
+		return err
+	}
+
+	_, err := svc.Users.Messages.Import("me", msg).
+		InternalDateSource("dateHeader").
+		NeverMarkSpam(c.NeverMarkSpam).
+		ProcessForCalendar(c.ProcessForCalendar).
+		Media(bytes.NewReader(raw), media).
+		Context(ctx).
+		Do()
+	return err
+}
+
+func gmailMessageIDExists(ctx context.Context, svc *gmail.Service, messageID string) (bool, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(messageID), "<"), ">")
+	if id == "" {
+		return false, nil
+	}
+	resp, err := svc.Users.Messages.List("me").
+		Q("rfc822msgid:" + id).
+		IncludeSpamTrash(true).
+		MaxResults(1).
+		Fields("messages(id)").
+		Context(ctx).
+		Do()
+	if err != nil {
+		return false, err
+	}
+	return len(resp.Messages) > 0, nil
+}
+
+// readGmailImportSource calls fn for every message in source: an mbox file,
+// a single .eml file, a Maildir, or a directory of .eml files.
+func readGmailImportSource(source string, isDir bool, fn func(gmailImportItem) error) error {
+	if !isDir {
+		return readGmailImportFile(source, fn)
+	}
+
+	if mailbox.IsMaildir(source) {
+		names, err := mailbox.Maildir(source).Messages()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			raw, err := os.ReadFile(filepath.Join(source, name)) //nolint:gosec // enumerated from user-provided dir
+			if err != nil {
+				return err
+			}
+			// Flags are part of the file name, so key on the unique part only.
+			key, flags := mailbox.SplitFileName(name)
+			if err := fn(gmailImportItem{key: key, raw: raw, flags: flags, maildir: true}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return fmt.Errorf("read import source: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.EqualFold(filepath.Ext(e.Name()), ".eml") {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return usagef("no .eml files or Maildir found in %s", source)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		raw, err := os.ReadFile(filepath.Join(source, name)) //nolint:gosec // enumerated from user-provided dir
+		if err != nil {
+			return err
+		}
+		if err := fn(gmailImportItem{key: name, raw: raw}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readGmailImportFile(path string, fn func(gmailImportItem) error) error {
+	f, err := os.Open(path) //nolint:gosec // user-provided path
+	if err != nil {
+		return fmt.Errorf("read import source: %w", err)
+	}
+	defer f.Close()
+
+	head := make([]byte, 5)
+	n, _ := io.ReadFull(f, head)
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if string(head[:n]) != "From " {
+		raw, err := io.ReadAll(io.LimitReader(f, mailbox.MaxMessageSize+1))
+		if err != nil {
+			return err
+		}
+		if len(raw) > mailbox.MaxMessageSize {
+			return fmt.Errorf("%s: message exceeds %d bytes", path, mailbox.MaxMessageSize)
+		}
+		return fn(gmailImportItem{key: filepath.Base(path), raw: raw})
+	}
+
+	r := mailbox.NewMboxReader(f)
+	for i := 1; ; i++ {
+		raw, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read mbox message %d: %w", i, err)
+		}
+		if err := fn(gmailImportItem{key: strconv.Itoa(i), raw: raw}); err != nil {
+			return err
+		}
+	}
+}
+
+func parseGmailImportLabelMap(specs []string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, spec := range specs {
+		from, to, ok := strings.Cut(spec, "=")
+		from = strings.TrimSpace(from)
+		if !ok || from == "" {
+			return nil, usagef("invalid --label-map %q (expected Old=New)", spec)
+		}
+		out[strings.ToLower(from)] = strings.TrimSpace(to)
+	}
+	return out, nil
+}
+
+// gmailImportSourceLabels returns the label names carried by the message
+// itself (X-Gmail-Labels, Maildir flags), after applying labelMap.
+func gmailImportSourceLabels(item gmailImportItem, labelMap map[string]string) []string {
+	names := mailbox.ParseLabels(mailbox.HeaderValue(item.raw, mailbox.LabelsHeader))
+	if item.maildir {
+		if !strings.ContainsRune(item.flags, mailbox.FlagSeen) {
+			names = append(names, "UNREAD")
+		}
+		if strings.ContainsRune(item.flags, mailbox.FlagFlagged) {
+			names = append(names, "STARRED")
+		}
+	}
+
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		if mapped, ok := labelMap[strings.ToLower(name)]; ok {
+			name = mapped
+		}
+		if name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// gmailImportSystemLabels maps the system label names found in X-Gmail-Labels
+// (including Takeout spellings like "Inbox" or "Category Updates") to label IDs.
+// An empty ID means the label cannot be set on import and is dropped.
+var gmailImportSystemLabels = map[string]string{
+	"inbox":               "INBOX",
+	"unread":              "UNREAD",
+	"starred":             "STARRED",
+	"important":           "IMPORTANT",
+	"sent":                "SENT",
+	"spam":                "SPAM",
+	"trash":               "TRASH",
+	"category_personal":   "CATEGORY_PERSONAL",
+	"category_social":     "CATEGORY_SOCIAL",
+	"category_promotions": "CATEGORY_PROMOTIONS",
+	"category_updates":    "CATEGORY_UPDATES",
+	"category_forums":     "CATEGORY_FORUMS",
+	"category personal":   "CATEGORY_PERSONAL",
+	"category social":     "CATEGORY_SOCIAL",
+	"category promotions": "CATEGORY_PROMOTIONS",
+	"category updates":    "CATEGORY_UPDATES",
+	"category forums":     "CATEGORY_FORUMS",
+	"opened":              "",
+	"archived":            "",
+	"draft":               "",
+	"drafts":              "",
+	"chat":                "",
+}
+
+// gmailImportLabels resolves label names to IDs, creating missing user labels
+// once even when several uploads ask for them concurrently.
+type gmailImportLabels struct {
+	mu       sync.Mutex
+	svc      *gmail.Service
+	nameToID map[string]string
+	create   bool
+}
+
+func (l *gmailImportLabels) resolve(ctx context.Context, names []string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seen := map[string]bool{}
+	var ids []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+
+		id, ok := gmailImportSystemLabels[key]
+		if !ok {
+			id, ok = l.nameToID[key]
+		}
+		if !ok {
+			if !l.create {
+				return nil, usagef("label %q does not exist (drop --no-create-labels or map it with --label-map)", name)
+			}
+			label, err := createLabel(ctx, l.svc, name)
+			if err != nil {
+				return nil, mapLabelCreateError(err, name)
+			}
+			id = label.Id
+			l.nameToID[key] = id
+		}
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
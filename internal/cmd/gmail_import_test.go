@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/mailbox"
+)
+
+type fakeGmailImportUpload struct {
+	path     string
+	query    string
+	labelIDs []string
+	raw      string
+}
+
+type fakeGmailImportServer struct {
+	mu       sync.Mutex
+	existing map[string]bool
+	uploads  []fakeGmailImportUpload
+	created  []string
+	failOn   string
+}
+
+func (f *fakeGmailImportServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/users/me/labels") && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": []map[string]any{
+				{"id": "INBOX", "name": "INBOX", "type": "system"},
+				{"id": "Label_1", "name": "Projects/Alpha", "type": "user"},
+			}})
+		case strings.HasSuffix(path, "/users/me/labels") && r.Method == http.MethodPost:
+			var label gmail.Label
+			_ = json.NewDecoder(r.Body).Decode(&label)
+			f.mu.Lock()
+			f.created = append(f.created, label.Name)
+			f.mu.Unlock()
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "Label_new_" + label.Name, "name": label.Name})
+		case strings.HasSuffix(path, "/users/me/messages") && r.Method == http.MethodGet:
+			q := r.URL.Query().Get("q")
+			id := strings.TrimPrefix(q, "rfc822msgid:")
+			if id == q || r.URL.Query().Get("includeSpamTrash") != "true" {
+				t.Errorf("unexpected dedupe query %q", r.URL.RawQuery)
+			}
+			var msgs []map[string]any
+			if f.existing[id] {
+				msgs = append(msgs, map[string]any{"id": "existing"})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"messages": msgs})
+		case strings.HasSuffix(path, "/users/me/messages/import") || (strings.HasSuffix(path, "/users/me/messages") && r.Method == http.MethodPost):
+			upload := f.readUpload(t, r)
+			f.mu.Lock()
+			f.uploads = append(f.uploads, upload)
+			f.mu.Unlock()
+			if f.failOn != "" && strings.Contains(upload.raw, f.failOn) {
+				http.Error(w, `{"error":{"code":500,"message":"boom"}}`, http.StatusInternalServerError)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "new", "labelIds": upload.labelIDs})
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func (f *fakeGmailImportServer) readUpload(t *testing.T, r *http.Request) fakeGmailImportUpload {
+	t.Helper()
+
+	upload := fakeGmailImportUpload{path: r.URL.Path, query: r.URL.RawQuery}
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		t.Errorf("upload content type: %v", err)
+		return upload
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		data, _ := io.ReadAll(part)
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "application/json") {
+			var msg gmail.Message
+			_ = json.Unmarshal(data, &msg)
+			upload.labelIDs = msg.LabelIds
+			continue
+		}
+		upload.raw = string(data)
+	}
+	return upload
+}
+
+func (f *fakeGmailImportServer) sortedUploads() []fakeGmailImportUpload {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := append([]fakeGmailImportUpload(nil), f.uploads...)
+	sort.Slice(out, func(i, j int) bool { return out[i].raw < out[j].raw })
+	return out
+}
+
+func useFakeGmailImportServer(t *testing.T, f *fakeGmailImportServer) {
+	t.Helper()
+
+	svc, closeSrv := newGmailServiceForTest(t, f.handler(t))
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+}
+
+func writeTestMbox(t *testing.T, path string, msgs ...string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create mbox: %v", err)
+	}
+	defer f.Close()
+
+	w := mailbox.NewMboxWriter(f)
+	for _, m := range msgs {
+		if err := w.WriteMessage([]byte(m), time.Unix(1700000000, 0)); err != nil {
+			t.Fatalf("write mbox: %v", err)
+		}
+	}
+}
+
+func TestGmailImport_MboxLabelsDedupeResume(t *testing.T) {
+	fake := &fakeGmailImportServer{existing: map[string]bool{"old@example.com": true}, failOn: "Subject: three"}
+	useFakeGmailImportServer(t, fake)
+
+	src := filepath.Join(t.TempDir(), "old.mbox")
+	writeTestMbox(t, src,
+		"Message-ID: <one@example.com>\nX-Gmail-Labels: Inbox,Unread,\"Projects/Alpha\",Opened\nSubject: one\n\nFrom here\n",
+		"Message-ID: <old@example.com>\nSubject: already there\n\nbody\n",
+		"Message-ID: <one@example.com>\nSubject: same id again\n\nbody\n",
+		"X-Gmail-Labels: Old Stuff,Category Updates\nSubject: three\n\nbody\n",
+	)
+
+	args := []string{"--json", "--account", "a@b.com", "gmail", "import", src,
+		"--concurrency", "1", "--label", "Imported", "--label-map", "Old Stuff=Archive/Old", "--never-mark-spam"}
+
+	if err := Execute(args); err == nil || !strings.Contains(err.Error(), "import 4") {
+		t.Fatalf("expected failure on message 4, got %v", err)
+	}
+
+	state, err := os.ReadFile(src + ".gog-import")
+	if err != nil || string(state) != "1\n2\n3\n" {
+		t.Fatalf("unexpected checkpoint %q err=%v", state, err)
+	}
+
+	uploads := fake.sortedUploads()
+	if len(uploads) != 2 {
+		t.Fatalf("expected 2 uploads, got %d", len(uploads))
+	}
+	first := uploads[0]
+	if !strings.Contains(first.raw, "Subject: one\n\nFrom here\n") || strings.Contains(first.raw, ">From") {
+		t.Fatalf("unexpected raw upload %q", first.raw)
+	}
+	if strings.Join(first.labelIDs, ",") != "INBOX,UNREAD,Label_1,Label_new_Imported" {
+		t.Fatalf("unexpected labels %v", first.labelIDs)
+	}
+	if !strings.Contains(first.query, "neverMarkSpam=true") || !strings.Contains(first.query, "internalDateSource=dateHeader") {
+		t.Fatalf("unexpected import query %q", first.query)
+	}
+	if strings.Join(uploads[1].labelIDs, ",") != "Label_new_Archive/Old,CATEGORY_UPDATES,Label_new_Imported" {
+		t.Fatalf("unexpected mapped labels %v", uploads[1].labelIDs)
+	}
+
+	if strings.Join(fake.created, ",") != "Imported,Archive/Old" {
+		t.Fatalf("expected missing labels created once, got %v", fake.created)
+	}
+
+	fake.failOn = ""
+	fake.uploads = nil
+
+	stdout := captureStdout(t, func() {
+		if err := Execute(args); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	var result struct {
+		Imported   int `json:"imported"`
+		Duplicates int `json:"duplicates"`
+		Skipped    int `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("unmarshal: %v (%q)", err, stdout)
+	}
+	if result.Imported != 1 || result.Duplicates != 0 || result.Skipped != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(fake.uploads) != 1 || !strings.Contains(fake.uploads[0].raw, "Subject: three") {
+		t.Fatalf("expected only message 4 uploaded on resume, got %d", len(fake.uploads))
+	}
+}
+
+func TestGmailImport_MaildirInsert(t *testing.T) {
+	fake := &fakeGmailImportServer{}
+	useFakeGmailImportServer(t, fake)
+
+	dir := mailbox.Maildir(filepath.Join(t.TempDir(), "Mail"))
+	if err := dir.Create(); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := dir.Deliver("a", "S", []byte("Subject: read\n\nbody\n"), time.Unix(1, 0)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if _, err := dir.Deliver("b", "F", []byte("Subject: starred unread\n\nbody\n"), time.Unix(2, 0)); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "import", string(dir), "--mode", "insert", "--no-dedupe"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	uploads := fake.sortedUploads()
+	if len(uploads) != 2 {
+		t.Fatalf("expected 2 uploads, got %d", len(uploads))
+	}
+	if strings.HasSuffix(uploads[0].path, "/import") || strings.Contains(uploads[0].query, "neverMarkSpam") {
+		t.Fatalf("expected insert, got %s?%s", uploads[0].path, uploads[0].query)
+	}
+	if len(uploads[0].labelIDs) != 0 {
+		t.Fatalf("expected no labels for seen message, got %v", uploads[0].labelIDs)
+	}
+	if strings.Join(uploads[1].labelIDs, ",") != "UNREAD,STARRED" {
+		t.Fatalf("unexpected flag labels %v", uploads[1].labelIDs)
+	}
+
+	state, err := os.ReadFile(filepath.Join(string(dir), ".gog-import"))
+	if err != nil || strings.Contains(string(state), mailbox.InfoSeparator) || len(strings.Fields(string(state))) != 2 {
+		t.Fatalf("unexpected checkpoint %q err=%v", state, err)
+	}
+}
+
+func TestGmailImport_Validation(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "one.eml")
+	if err := os.WriteFile(src, []byte("Subject: x\n\nbody\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"gmail", "import", src, "--concurrency", "0"},
+		{"gmail", "import", src, "--mode", "insert", "--never-mark-spam"},
+		{"gmail", "import", src, "--label-map", "noequals"},
+	} {
+		if err := Execute(append([]string{"--account", "a@b.com"}, args...)); err == nil {
+			t.Fatalf("expected usage error for %v", args)
+		}
+	}
+}
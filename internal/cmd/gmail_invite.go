@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/steipete/gogcli/internal/timeparse"
+)
+
+const (
+	inviteMethodRequest = "request"
+	inviteMethodCancel  = "cancel"
+
+	icsDateTimeUTC = "20060102T150405Z"
+	icsDate        = "20060102"
+)
+
+// gmailInviteFlags are the `gmail send` options that turn a message into an
+// iMIP meeting invitation.
+type gmailInviteFlags struct {
+	InviteStart    string `name:"invite-start" help:"Attach a calendar invite starting at this time (RFC3339, 'YYYY-MM-DD HH:MM', or YYYY-MM-DD for all-day)"`
+	InviteEnd      string `name:"invite-end" help:"Invite end time (default: 1h after start, or 1 day for all-day)"`
+	InviteLocation string `name:"invite-location" help:"Invite location"`
+	InviteSummary  string `name:"invite-summary" help:"Invite title (default: --subject)"`
+	InviteUID      string `name:"invite-uid" help:"Invite UID; reuse the UID printed by an earlier send to update or cancel that invite"`
+	InviteSequence int    `name:"invite-sequence" help:"Invite revision; increase it for every update or cancellation of the same UID" default:"0"`
+	InviteMethod   string `name:"invite-method" help:"iMIP method: request|cancel" enum:"request,cancel" default:"request"`
+}
+
+func (f gmailInviteFlags) enabled() bool {
+	return strings.TrimSpace(f.InviteStart) != "" || strings.TrimSpace(f.InviteUID) != "" || f.InviteMethod == inviteMethodCancel
+}
+
+// gmailInvite is a single VEVENT wrapped in an iTIP REQUEST or CANCEL.
+type gmailInvite struct {
+	Method      string
+	UID         string
+	Sequence    int
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	Organizer   *mail.Address
+	Attendees   []*mail.Address
+	Optional    []*mail.Address
+	Stamp       time.Time
+}
+
+// schedule validates the invite flags and returns the event times. Start and
+// end are zero for a cancellation that only names the UID.
+func (f gmailInviteFlags) schedule() (start, end time.Time, allDay bool, err error) {
+	startRaw := strings.TrimSpace(f.InviteStart)
+	endRaw := strings.TrimSpace(f.InviteEnd)
+	method := strings.ToLower(strings.TrimSpace(f.InviteMethod))
+
+	if f.InviteSequence < 0 {
+		return start, end, false, usage("--invite-sequence must not be negative")
+	}
+	if method == inviteMethodCancel && strings.TrimSpace(f.InviteUID) == "" {
+		return start, end, false, usage("--invite-method cancel requires --invite-uid")
+	}
+	if strings.ContainsAny(f.InviteUID, "\r\n") {
+		return start, end, false, usage("invalid --invite-uid")
+	}
+	if startRaw == "" {
+		if endRaw != "" {
+			return start, end, false, usage("--invite-end requires --invite-start")
+		}
+		if method != inviteMethodCancel {
+			return start, end, false, usage("--invite-start is required for invites")
+		}
+		return start, end, false, nil
+	}
+
+	s, err := timeparse.ParseDateTimeOrDate(startRaw, time.Local)
+	if err != nil {
+		return start, end, false, usagef("invalid --invite-start: %v", err)
+	}
+	start, allDay = s.Time, !s.HasTime
+	end = start.Add(time.Hour)
+	if allDay {
+		end = start.AddDate(0, 0, 1)
+	}
+	if endRaw != "" {
+		e, err := timeparse.ParseDateTimeOrDate(endRaw, time.Local)
+		if err != nil {
+			return start, end, false, usagef("invalid --invite-end: %v", err)
+		}
+		if e.HasTime == allDay {
+			return start, end, false, usage("--invite-start and --invite-end must both be dates or both be date-times")
+		}
+		end = e.Time
+		if allDay {
+			// DTEND is exclusive for all-day events; the flag names the last day.
+			end = end.AddDate(0, 0, 1)
+		}
+	}
+	if !end.After(start) {
+		return start, end, false, usage("--invite-end must be after --invite-start")
+	}
+	return start, end, allDay, nil
+}
+
+// buildInvite returns the invite for the given organizer and recipients. To
+// recipients are required attendees and Cc recipients optional ones; Bcc
+// recipients are never listed.
+func (f gmailInviteFlags) buildInvite(summary, description, organizer string, to, cc []string, now time.Time) (*gmailInvite, error) {
+	start, end, allDay, err := f.schedule()
+	if err != nil {
+		return nil, err
+	}
+	inv := &gmailInvite{
+		Method:      strings.ToUpper(strings.TrimSpace(f.InviteMethod)),
+		UID:         strings.TrimSpace(f.InviteUID),
+		Sequence:    f.InviteSequence,
+		Summary:     strings.TrimSpace(f.InviteSummary),
+		Description: strings.TrimSpace(description),
+		Location:    strings.TrimSpace(f.InviteLocation),
+		Start:       start,
+		End:         end,
+		AllDay:      allDay,
+		Stamp:       now.UTC(),
+	}
+	if inv.Method == "" {
+		inv.Method = strings.ToUpper(inviteMethodRequest)
+	}
+	if inv.Summary == "" {
+		inv.Summary = strings.TrimSpace(summary)
+	}
+	if inv.UID == "" {
+		uid, err := randomInviteUID(organizer)
+		if err != nil {
+			return nil, err
+		}
+		inv.UID = uid
+	}
+
+	org, err := mail.ParseAddress(organizer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid organizer %q: %w", organizer, err)
+	}
+	inv.Organizer = org
+	inv.Attendees = parseInviteAddresses(to)
+	inv.Optional = parseInviteAddresses(cc)
+	if len(inv.Attendees)+len(inv.Optional) == 0 {
+		return nil, usage("invites need at least one --to or --cc attendee")
+	}
+	return inv, nil
+}
+
+func parseInviteAddresses(values []string) []*mail.Address {
+	var out []*mail.Address
+	for _, v := range values {
+		if addrs, err := mail.ParseAddressList(v); err == nil {
+			out = append(out, addrs...)
+			continue
+		}
+		if strings.Contains(v, "@") {
+			out = append(out, &mail.Address{Address: strings.TrimSpace(v)})
+		}
+	}
+	return out
+}
+
+func randomInviteUID(organizer string) (string, error) {
+	domain := "gogcli.local"
+	if addr, err := mail.ParseAddress(organizer); err == nil {
+		if at := strings.LastIndex(addr.Address, "@"); at >= 0 && at+1 < len(addr.Address) {
+			domain = addr.Address[at+1:]
+		}
+	}
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]) + "@" + domain, nil
+}
+
+// ICS renders the invite as an iCalendar object (RFC 5545) with CRLF line
+// endings and folded long lines.
+func (inv *gmailInvite) ICS() string {
+	var lines []string
+	add := func(line string) { lines = append(lines, line) }
+
+	add("BEGIN:VCALENDAR")
+	add("PRODID:-//gogcli//gog//EN")
+	add("VERSION:2.0")
+	add("CALSCALE:GREGORIAN")
+	add("METHOD:" + inv.Method)
+	add("BEGIN:VEVENT")
+	add("UID:" + inv.UID)
+	add(fmt.Sprintf("SEQUENCE:%d", inv.Sequence))
+	add("DTSTAMP:" + inv.Stamp.UTC().Format(icsDateTimeUTC))
+	if !inv.Start.IsZero() {
+		if inv.AllDay {
+			add("DTSTART;VALUE=DATE:" + inv.Start.Format(icsDate))
+			add("DTEND;VALUE=DATE:" + inv.End.Format(icsDate))
+		} else {
+			add("DTSTART:" + inv.Start.UTC().Format(icsDateTimeUTC))
+			add("DTEND:" + inv.End.UTC().Format(icsDateTimeUTC))
+		}
+	}
+	if inv.Summary != "" {
+		add("SUMMARY:" + icsEscapeText(inv.Summary))
+	}
+	if inv.Location != "" {
+		add("LOCATION:" + icsEscapeText(inv.Location))
+	}
+	if inv.Description != "" {
+		add("DESCRIPTION:" + icsEscapeText(inv.Description))
+	}
+	add("ORGANIZER" + icsCommonName(inv.Organizer) + ":mailto:" + inv.Organizer.Address)
+	for _, a := range inv.Attendees {
+		add("ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE" + icsCommonName(a) + ":mailto:" + a.Address)
+	}
+	for _, a := range inv.Optional {
+		add("ATTENDEE;ROLE=OPT-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE" + icsCommonName(a) + ":mailto:" + a.Address)
+	}
+	if inv.Method == "CANCEL" {
+		add("STATUS:CANCELLED")
+	} else {
+		add("STATUS:CONFIRMED")
+	}
+	add("TRANSP:OPAQUE")
+	add("END:VEVENT")
+	add("END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(icsFold(line))
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
+
+func icsCommonName(a *mail.Address) string {
+	name := strings.TrimSpace(a.Name)
+	if name == "" {
+		return ""
+	}
+	name = strings.NewReplacer(`"`, "'", "\r", "", "\n", " ").Replace(name)
+	return `;CN="` + name + `"`
+}
+
+func icsEscapeText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", "").Replace(s)
+}
+
+// icsFold splits lines longer than 75 octets without breaking UTF-8
+// sequences; continuation lines start with a single space.
+func icsFold(line string) string {
+	const limit = 75
+	if len(line) <= limit {
+		return line
+	}
+	var b strings.Builder
+	width := limit
+	for len(line) > width {
+		cut := width
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		width = limit - 1
+	}
+	b.WriteString(line)
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestGmailInviteICS(t *testing.T) {
+	f := gmailInviteFlags{
+		InviteStart:    "2026-03-10T15:00:00Z",
+		InviteLocation: "Room 1; 2nd floor, east",
+		InviteUID:      "abc@example.com",
+		InviteSequence: 2,
+		InviteMethod:   inviteMethodRequest,
+	}
+	stamp := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	inv, err := f.buildInvite("Planning", "Agenda:\nitems", "me@example.com",
+		[]string{"Bob <bob@example.com>"}, []string{"carol@example.com"}, stamp)
+	if err != nil {
+		t.Fatalf("buildInvite: %v", err)
+	}
+	ics := inv.ICS()
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nPRODID:-//gogcli//gog//EN\r\nVERSION:2.0\r\n",
+		"METHOD:REQUEST\r\n",
+		"UID:abc@example.com\r\nSEQUENCE:2\r\nDTSTAMP:20260301T080000Z\r\n",
+		"DTSTART:20260310T150000Z\r\nDTEND:20260310T160000Z\r\n",
+		"SUMMARY:Planning\r\n",
+		`LOCATION:Room 1\; 2nd floor\, east` + "\r\n",
+		`DESCRIPTION:Agenda:\nitems` + "\r\n",
+		"ORGANIZER:mailto:me@example.com\r\n",
+		`ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE;CN="Bob":mail` + "\r\n to:bob@example.com\r\n",
+		"ATTENDEE;ROLE=OPT-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:carol@\r\n example.com\r\n",
+		"STATUS:CONFIRMED\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Fatalf("ics missing %q:\n%s", want, ics)
+		}
+	}
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line not folded (%d octets): %q", len(line), line)
+		}
+	}
+}
+
+func TestGmailInviteAllDayAndCancel(t *testing.T) {
+	f := gmailInviteFlags{InviteStart: "2026-03-10", InviteEnd: "2026-03-11", InviteMethod: inviteMethodRequest}
+	inv, err := f.buildInvite("Offsite", "", "me@example.com", []string{"bob@example.com"}, nil, time.Now())
+	if err != nil {
+		t.Fatalf("buildInvite: %v", err)
+	}
+	ics := inv.ICS()
+	if !strings.Contains(ics, "DTSTART;VALUE=DATE:20260310\r\nDTEND;VALUE=DATE:20260312\r\n") {
+		t.Fatalf("unexpected all-day dates:\n%s", ics)
+	}
+	if !strings.HasSuffix(inv.UID, "@example.com") {
+		t.Fatalf("unexpected generated UID %q", inv.UID)
+	}
+
+	cancel := gmailInviteFlags{InviteUID: inv.UID, InviteSequence: 1, InviteMethod: inviteMethodCancel}
+	inv, err = cancel.buildInvite("Offsite", "", "me@example.com", []string{"bob@example.com"}, nil, time.Now())
+	if err != nil {
+		t.Fatalf("buildInvite cancel: %v", err)
+	}
+	ics = inv.ICS()
+	if !strings.Contains(ics, "METHOD:CANCEL\r\n") || !strings.Contains(ics, "STATUS:CANCELLED\r\n") || strings.Contains(ics, "DTSTART") {
+		t.Fatalf("unexpected cancel:\n%s", ics)
+	}
+
+	for _, bad := range []gmailInviteFlags{
+		{InviteMethod: inviteMethodCancel},
+		{InviteUID: "x@y", InviteMethod: inviteMethodRequest},
+		{InviteStart: "2026-03-10", InviteEnd: "2026-03-10T10:00:00Z", InviteMethod: inviteMethodRequest},
+		{InviteStart: "2026-03-10T10:00:00Z", InviteEnd: "2026-03-10T09:00:00Z", InviteMethod: inviteMethodRequest},
+		{InviteStart: "2026-03-10T10:00:00Z", InviteSequence: -1, InviteMethod: inviteMethodRequest},
+	} {
+		if _, _, _, err := bad.schedule(); err == nil {
+			t.Fatalf("expected error for %+v", bad)
+		}
+	}
+}
+
+func TestIcsFold_UTF8(t *testing.T) {
+	line := "SUMMARY:" + strings.Repeat("ä", 60)
+	folded := icsFold(line)
+	for _, part := range strings.Split(folded, "\r\n") {
+		if len(part) > 75 {
+			t.Fatalf("part too long: %d", len(part))
+		}
+	}
+	if strings.ReplaceAll(folded, "\r\n ", "") != line {
+		t.Fatalf("unfolding does not round-trip")
+	}
+}
+
+func TestGmailSendCmd_Invite(t *testing.T) {
+	var sent string
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/settings/sendAs"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"sendAs": []map[string]any{}})
+		case strings.HasSuffix(r.URL.Path, "/messages/send"):
+			var body struct {
+				Raw string `json:"raw"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			data, _ := base64.RawURLEncoding.DecodeString(body.Raw)
+			sent = string(data)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "threadId": "t1"})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "me@example.com", "gmail", "send",
+			"--to", "bob@example.com", "--bcc", "hidden@example.com", "--subject", "Sync", "--body", "See you",
+			"--invite-start", "2026-03-10T15:00:00Z", "--invite-location", "Room 1"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	var resp map[string]any
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	uid, _ := resp["invite_uid"].(string)
+	if !strings.HasSuffix(uid, "@example.com") {
+		t.Fatalf("unexpected invite_uid: %v", resp)
+	}
+	for _, want := range []string{
+		"Content-Type: multipart/alternative;",
+		"Content-Type: text/plain; charset=\"utf-8\"\r\n",
+		"Content-Type: text/calendar; charset=\"utf-8\"; method=REQUEST\r\n",
+		"UID:" + uid + "\r\n",
+		"ATTENDEE;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:bob@e",
+		"Content-Type: application/ics",
+		`filename="invite.ics"`,
+	} {
+		if !strings.Contains(sent, want) {
+			t.Fatalf("message missing %q:\n%s", want, sent)
+		}
+	}
+	if strings.Contains(sent, "mailto:hidden@example.com") {
+		t.Fatalf("bcc recipient listed as attendee:\n%s", sent)
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "send",
+			"--to", "bob@example.com", "--subject", "Sync", "--body", "Cancelled", "--invite-method", "cancel"}); err == nil {
+			t.Fatalf("expected cancel without --invite-uid to fail")
+		}
+	})
+}
@@ -18,6 +18,10 @@ type GmailLabelsCmd struct {
 	Create GmailLabelsCreateCmd `cmd:"" name:"create" aliases:"add,new" help:"Create a new label"`
 	Modify GmailLabelsModifyCmd `cmd:"" name:"modify" aliases:"update,edit,set" help:"Modify labels on threads"`
 	Delete GmailLabelsDeleteCmd `cmd:"" name:"delete" aliases:"rm,del" help:"Delete a label"`
+	Rename GmailLabelsRenameCmd `cmd:"" name:"rename" aliases:"mv" help:"Rename a label and all labels nested below it"`
+	Merge  GmailLabelsMergeCmd  `cmd:"" name:"merge" help:"Move all messages from one label to another and delete the source"`
+	Color  GmailLabelsColorCmd  `cmd:"" name:"color" aliases:"colour" help:"Set or clear a label's color"`
+	Tree   GmailLabelsTreeCmd   `cmd:"" name:"tree" help:"Show nested user labels with message/unread counts"`
 }
 
 type GmailLabelsGetCmd struct {
@@ -67,7 +71,8 @@ func (c *GmailLabelsGetCmd) Run(ctx context.Context, flags *RootFlags) error {
 }
 
 type GmailLabelsCreateCmd struct {
-	Name string `arg:"" help:"Label name"`
+	Name  string          `arg:"" help:"Label name"`
+	Color labelColorFlags `embed:""`
 }
 
 func (c *GmailLabelsCreateCmd) Run(ctx context.Context, flags *RootFlags) error {
@@ -81,6 +86,10 @@ func (c *GmailLabelsCreateCmd) Run(ctx context.Context, flags *RootFlags) error
 	if name == "" {
 		return usage("label name is required")
 	}
+	color, err := c.Color.color()
+	if err != nil {
+		return err
+	}
 
 	svc, err := newGmailService(ctx, account)
 	if err != nil {
@@ -92,7 +101,7 @@ func (c *GmailLabelsCreateCmd) Run(ctx context.Context, flags *RootFlags) error
 		return err
 	}
 
-	label, err := createLabel(ctx, svc, name)
+	label, err := createLabelWithColor(ctx, svc, name, color)
 	if err != nil {
 		return mapLabelCreateError(err, name)
 	}
@@ -105,10 +114,15 @@ func (c *GmailLabelsCreateCmd) Run(ctx context.Context, flags *RootFlags) error
 }
 
 func createLabel(ctx context.Context, svc *gmail.Service, name string) (*gmail.Label, error) {
+	return createLabelWithColor(ctx, svc, name, nil)
+}
+
+func createLabelWithColor(ctx context.Context, svc *gmail.Service, name string, color *gmail.LabelColor) (*gmail.Label, error) {
 	return svc.Users.Labels.Create("me", &gmail.Label{
 		Name:                  name,
 		LabelListVisibility:   "labelShow",
 		MessageListVisibility: "show",
+		Color:                 color,
 	}).Context(ctx).Do()
 }
 
@@ -0,0 +1,537 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+var labelColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// labelColorFlags sets a label's color. Gmail only accepts colors from its
+// fixed palette (e.g. #4a86e8 on #ffffff); other values are rejected by the API.
+type labelColorFlags struct {
+	Background string `name:"background-color" aliases:"bg" help:"Background color (#rrggbb from Gmail's label palette)"`
+	Text       string `name:"text-color" help:"Text color (#rrggbb from Gmail's label palette)"`
+}
+
+// color validates the flags and returns nil when no color was given.
+func (f labelColorFlags) color() (*gmail.LabelColor, error) {
+	bg := strings.ToLower(strings.TrimSpace(f.Background))
+	text := strings.ToLower(strings.TrimSpace(f.Text))
+	if bg == "" && text == "" {
+		return nil, nil //nolint:nilnil // no color requested
+	}
+	if bg == "" || text == "" {
+		return nil, usage("--background-color and --text-color must be set together")
+	}
+	for _, v := range []string{bg, text} {
+		if !labelColorPattern.MatchString(v) {
+			return nil, usagef("invalid color %q (expected #rrggbb)", v)
+		}
+	}
+	return &gmail.LabelColor{BackgroundColor: bg, TextColor: text}, nil
+}
+
+// findLabel looks a label up by exact ID first, then by name (case-insensitive).
+func findLabel(labels []*gmail.Label, raw string) *gmail.Label {
+	for _, l := range labels {
+		if l.Id == raw {
+			return l
+		}
+	}
+	for _, l := range labels {
+		if strings.EqualFold(l.Name, raw) {
+			return l
+		}
+	}
+	return nil
+}
+
+func listLabels(ctx context.Context, svc *gmail.Service) ([]*gmail.Label, error) {
+	resp, err := svc.Users.Labels.List("me").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return resp.Labels, nil
+}
+
+// isSubLabelName reports whether name is nested below parent ("Clients/Acme"
+// below "Clients"). Gmail compares label names case-insensitively.
+func isSubLabelName(name, parent string) bool {
+	return len(name) > len(parent)+1 && name[len(parent)] == '/' && strings.EqualFold(name[:len(parent)], parent)
+}
+
+type GmailLabelsColorCmd struct {
+	Label string          `arg:"" name:"labelIdOrName" help:"Label ID or name"`
+	Color labelColorFlags `embed:""`
+	Clear bool            `name:"clear" help:"Remove the label color"`
+}
+
+func (c *GmailLabelsColorCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	raw := strings.TrimSpace(c.Label)
+	if raw == "" {
+		return usage("empty label")
+	}
+	color, err := c.Color.color()
+	if err != nil {
+		return err
+	}
+	if (color == nil) == !c.Clear {
+		return usage("specify --background-color and --text-color, or --clear")
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	labels, err := listLabels(ctx, svc)
+	if err != nil {
+		return err
+	}
+	label := findLabel(labels, raw)
+	if label == nil {
+		return fmt.Errorf("label not found: %s", raw)
+	}
+	if label.Type == "system" {
+		return fmt.Errorf("cannot color system label %q", label.Name)
+	}
+
+	patch := &gmail.Label{Color: color}
+	if c.Clear {
+		patch.NullFields = []string{"Color"}
+	}
+	if err := dryRunExit(ctx, flags, "gmail.labels.color", map[string]any{
+		"id":    label.Id,
+		"name":  label.Name,
+		"color": color,
+	}); err != nil {
+		return err
+	}
+
+	updated, err := svc.Users.Labels.Patch("me", label.Id, patch).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"label": updated})
+	}
+	u.Out().Printf("id\t%s", updated.Id)
+	u.Out().Printf("name\t%s", updated.Name)
+	if updated.Color != nil {
+		u.Out().Printf("background_color\t%s", updated.Color.BackgroundColor)
+		u.Out().Printf("text_color\t%s", updated.Color.TextColor)
+	}
+	return nil
+}
+
+type GmailLabelsRenameCmd struct {
+	Label   string `arg:"" name:"labelIdOrName" help:"Label ID or name"`
+	NewName string `arg:"" name:"newName" help:"New label name (use '/' to nest)"`
+}
+
+type labelRename struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (c *GmailLabelsRenameCmd) Run(ctx context.Context, flags *RootFlags) error {
+	raw := strings.TrimSpace(c.Label)
+	newName := strings.Trim(strings.TrimSpace(c.NewName), "/")
+	if raw == "" || newName == "" {
+		return usage("label and new name are required")
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	labels, err := listLabels(ctx, svc)
+	if err != nil {
+		return err
+	}
+	label := findLabel(labels, raw)
+	if label == nil {
+		return fmt.Errorf("label not found: %s", raw)
+	}
+	if label.Type == "system" {
+		return fmt.Errorf("cannot rename system label %q", label.Name)
+	}
+
+	renames, err := planLabelRename(labels, label.Name, newName)
+	if err != nil {
+		return err
+	}
+
+	if err := dryRunExit(ctx, flags, "gmail.labels.rename", map[string]any{
+		"renames": renames,
+	}); err != nil {
+		return err
+	}
+
+	done, err := applyLabelRenames(ctx, svc, renames)
+	if err != nil {
+		if rollbackErr := rollbackLabelRenames(ctx, svc, done); rollbackErr != nil {
+			return fmt.Errorf("rename %q failed after %d of %d labels: %w (rollback failed: %w)", label.Name, len(done), len(renames), err, rollbackErr)
+		}
+		return fmt.Errorf("rename %q failed, rolled back %d labels: %w", label.Name, len(done), err)
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"renamed": renames})
+	}
+	w, flush := tableWriter(ctx)
+	defer flush()
+	fmt.Fprintln(w, "ID\tFROM\tTO")
+	for _, r := range renames {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.ID, r.From, r.To)
+	}
+	return nil
+}
+
+// planLabelRename renames oldName and every label nested below it, parents
+// first, and refuses to collide with labels that are not being renamed.
+func planLabelRename(labels []*gmail.Label, oldName, newName string) ([]labelRename, error) {
+	if newName == oldName {
+		return nil, usage("new name is the same as the current name")
+	}
+	if isSubLabelName(newName, oldName) {
+		return nil, usagef("cannot move %q below itself", oldName)
+	}
+
+	var renames []labelRename
+	renaming := map[string]bool{}
+	for _, l := range labels {
+		if l.Type == "system" {
+			continue
+		}
+		switch {
+		case strings.EqualFold(l.Name, oldName):
+			renames = append(renames, labelRename{ID: l.Id, From: l.Name, To: newName})
+		case isSubLabelName(l.Name, oldName):
+			renames = append(renames, labelRename{ID: l.Id, From: l.Name, To: newName + l.Name[len(oldName):]})
+		default:
+			continue
+		}
+		renaming[l.Id] = true
+	}
+
+	existing := make(map[string]string, len(labels))
+	for _, l := range labels {
+		if !renaming[l.Id] {
+			existing[strings.ToLower(l.Name)] = l.Name
+		}
+	}
+	for _, r := range renames {
+		if name, ok := existing[strings.ToLower(r.To)]; ok {
+			return nil, usagef("label already exists: %s", name)
+		}
+	}
+
+	sort.SliceStable(renames, func(i, j int) bool {
+		return strings.Count(renames[i].From, "/") < strings.Count(renames[j].From, "/")
+	})
+	return renames, nil
+}
+
+// applyLabelRenames returns the renames that succeeded, in order.
+func applyLabelRenames(ctx context.Context, svc *gmail.Service, renames []labelRename) ([]labelRename, error) {
+	done := make([]labelRename, 0, len(renames))
+	for _, r := range renames {
+		if _, err := svc.Users.Labels.Patch("me", r.ID, &gmail.Label{Name: r.To}).Context(ctx).Do(); err != nil {
+			return done, mapLabelCreateError(err, r.To)
+		}
+		done = append(done, r)
+	}
+	return done, nil
+}
+
+func rollbackLabelRenames(ctx context.Context, svc *gmail.Service, done []labelRename) error {
+	var firstErr error
+	for i := len(done) - 1; i >= 0; i-- {
+		r := done[i]
+		if _, err := svc.Users.Labels.Patch("me", r.ID, &gmail.Label{Name: r.From}).Context(ctx).Do(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("restore %q: %w", r.From, err)
+		}
+	}
+	return firstErr
+}
+
+type GmailLabelsMergeCmd struct {
+	Source      string `arg:"" name:"source" help:"Label to merge and delete (ID or name)"`
+	Destination string `arg:"" name:"destination" help:"Label that receives the messages (ID or name)"`
+}
+
+func (c *GmailLabelsMergeCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	srcRaw := strings.TrimSpace(c.Source)
+	dstRaw := strings.TrimSpace(c.Destination)
+	if srcRaw == "" || dstRaw == "" {
+		return usage("source and destination labels are required")
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	labels, err := listLabels(ctx, svc)
+	if err != nil {
+		return err
+	}
+	src := findLabel(labels, srcRaw)
+	if src == nil {
+		return fmt.Errorf("label not found: %s", srcRaw)
+	}
+	dst := findLabel(labels, dstRaw)
+	if dst == nil {
+		return fmt.Errorf("label not found: %s", dstRaw)
+	}
+	if src.Type == "system" {
+		return fmt.Errorf("cannot merge system label %q", src.Name)
+	}
+	if src.Id == dst.Id {
+		return usage("source and destination are the same label")
+	}
+
+	ids, err := listGmailLabelMessageIDs(ctx, svc, src.Id)
+	if err != nil {
+		return err
+	}
+	if err := dryRunExit(ctx, flags, "gmail.labels.merge", map[string]any{
+		"source":      src.Name,
+		"destination": dst.Name,
+		"count":       len(ids),
+	}); err != nil {
+		return err
+	}
+	action := fmt.Sprintf("move %d messages from label %q to %q and delete %q", len(ids), src.Name, dst.Name, src.Name)
+	if err := confirmDestructive(ctx, flags, action); err != nil {
+		return err
+	}
+
+	chunks, err := runGmailBatchChunks(ctx, u, "relabeled", ids, func(chunk []string) error {
+		return svc.Users.Messages.BatchModify("me", &gmail.BatchModifyMessagesRequest{
+			Ids:            chunk,
+			AddLabelIds:    []string{dst.Id},
+			RemoveLabelIds: []string{src.Id},
+		}).Context(ctx).Do()
+	})
+	if err != nil {
+		return err
+	}
+	if err := svc.Users.Labels.Delete("me", src.Id).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("messages relabeled, but deleting %q failed: %w", src.Name, err)
+	}
+
+	if !outfmt.IsJSON(ctx) {
+		for _, l := range labels {
+			if isSubLabelName(l.Name, src.Name) {
+				u.Err().Printf("note: sublabel %q was not merged", l.Name)
+			}
+		}
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"source":      src.Name,
+			"destination": dst.Name,
+			"count":       len(ids),
+			"chunks":      chunks,
+			"deleted":     true,
+		})
+	}
+	u.Out().Printf("Merged %d messages from %s into %s", len(ids), src.Name, dst.Name)
+	return nil
+}
+
+// listGmailLabelMessageIDs lists every message carrying labelID, including
+// Spam and Trash.
+func listGmailLabelMessageIDs(ctx context.Context, svc *gmail.Service, labelID string) ([]string, error) {
+	return collectAllPages("", func(pageToken string) ([]string, string, error) {
+		call := svc.Users.Messages.List("me").
+			LabelIds(labelID).
+			IncludeSpamTrash(true).
+			MaxResults(500).
+			Fields("messages(id),nextPageToken").
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		resp, err := call.Do()
+		if err != nil {
+			return nil, "", err
+		}
+		page := make([]string, 0, len(resp.Messages))
+		for _, m := range resp.Messages {
+			if m != nil && m.Id != "" {
+				page = append(page, m.Id)
+			}
+		}
+		return page, resp.NextPageToken, nil
+	})
+}
+
+type GmailLabelsTreeCmd struct {
+	NoCounts bool `name:"no-counts" help:"Skip fetching message counts (one request per label)"`
+}
+
+// labelTreeNode is one path segment of the user label hierarchy. ID is empty
+// for parents that only exist implicitly through a nested label's name.
+type labelTreeNode struct {
+	Name           string            `json:"name"`
+	Path           string            `json:"path"`
+	ID             string            `json:"id,omitempty"`
+	MessagesTotal  int64             `json:"messagesTotal"`
+	MessagesUnread int64             `json:"messagesUnread"`
+	Color          *gmail.LabelColor `json:"color,omitempty"`
+	Children       []*labelTreeNode  `json:"children,omitempty"`
+}
+
+func (c *GmailLabelsTreeCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	labels, err := listLabels(ctx, svc)
+	if err != nil {
+		return err
+	}
+	user := make([]*gmail.Label, 0, len(labels))
+	for _, l := range labels {
+		if l.Type == "system" {
+			continue
+		}
+		if !c.NoCounts {
+			full, err := svc.Users.Labels.Get("me", l.Id).Context(ctx).Do()
+			if err != nil {
+				return err
+			}
+			l = full
+		}
+		user = append(user, l)
+	}
+
+	tree := buildLabelTree(user)
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"labels": tree})
+	}
+	if len(tree) == 0 {
+		u.Err().Println("No user labels")
+		return nil
+	}
+
+	w, flush := tableWriter(ctx)
+	defer flush()
+	if c.NoCounts {
+		fmt.Fprintln(w, "LABEL\tID")
+	} else {
+		fmt.Fprintln(w, "LABEL\tMESSAGES\tUNREAD\tID")
+	}
+	var walk func(nodes []*labelTreeNode, depth int)
+	walk = func(nodes []*labelTreeNode, depth int) {
+		for _, n := range nodes {
+			name := strings.Repeat("  ", depth) + n.Name
+			id := n.ID
+			if id == "" {
+				id = "-"
+			}
+			if c.NoCounts {
+				fmt.Fprintf(w, "%s\t%s\n", name, id)
+			} else {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", name, n.MessagesTotal, n.MessagesUnread, id)
+			}
+			walk(n.Children, depth+1)
+		}
+	}
+	walk(tree, 0)
+	return nil
+}
+
+// buildLabelTree nests labels by their slash-separated names, sorted by name
+// at each level.
+func buildLabelTree(labels []*gmail.Label) []*labelTreeNode {
+	var roots []*labelTreeNode
+	nodes := map[string]*labelTreeNode{}
+	node := func(path string) *labelTreeNode {
+		key := strings.ToLower(path)
+		if n, ok := nodes[key]; ok {
+			return n
+		}
+		n := &labelTreeNode{Name: path[strings.LastIndex(path, "/")+1:], Path: path}
+		nodes[key] = n
+		return n
+	}
+
+	sorted := append([]*gmail.Label(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for _, l := range sorted {
+		n := node(l.Name)
+		n.ID = l.Id
+		n.MessagesTotal = l.MessagesTotal
+		n.MessagesUnread = l.MessagesUnread
+		n.Color = l.Color
+	}
+
+	seen := map[*labelTreeNode]bool{}
+	for _, l := range sorted {
+		child := node(l.Name)
+		for path := l.Name; !seen[child]; {
+			seen[child] = true
+			i := strings.LastIndex(path, "/")
+			if i <= 0 {
+				roots = append(roots, child)
+				break
+			}
+			path = path[:i]
+			parent := node(path)
+			parent.Children = append(parent.Children, child)
+			child = parent
+		}
+	}
+
+	var sortNodes func([]*labelTreeNode)
+	sortNodes = func(list []*labelTreeNode) {
+		sort.Slice(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+		for _, n := range list {
+			sortNodes(n.Children)
+		}
+	}
+	sortNodes(roots)
+	return roots
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestBuildLabelTree(t *testing.T) {
+	tree := buildLabelTree([]*gmail.Label{
+		{Id: "Label_3", Name: "Clients/Acme/Invoices", MessagesTotal: 4},
+		{Id: "Label_1", Name: "Clients", MessagesTotal: 10, MessagesUnread: 2},
+		{Id: "Label_4", Name: "archive"},
+		{Id: "Label_5", Name: "Projects/Q3"},
+		{Id: "Label_2", Name: "Clients/Beta"},
+	})
+
+	var lines []string
+	var walk func([]*labelTreeNode, int)
+	walk = func(nodes []*labelTreeNode, depth int) {
+		for _, n := range nodes {
+			lines = append(lines, fmt.Sprintf("%s%s[%s]", strings.Repeat(" ", depth), n.Name, n.ID))
+			walk(n.Children, depth+1)
+		}
+	}
+	walk(tree, 0)
+	want := "archive[Label_4] Clients[Label_1]  Acme[]   Invoices[Label_3]  Beta[Label_2] Projects[]  Q3[Label_5]"
+	if got := strings.Join(lines, " "); got != want {
+		t.Fatalf("unexpected tree:\n got %q\nwant %q", got, want)
+	}
+	if tree[1].MessagesUnread != 2 || tree[1].Children[0].Path != "Clients/Acme" {
+		t.Fatalf("unexpected node: %+v", tree[1])
+	}
+}
+
+func TestPlanLabelRename(t *testing.T) {
+	labels := []*gmail.Label{
+		{Id: "INBOX", Name: "INBOX", Type: "system"},
+		{Id: "Label_2", Name: "Clients/Acme/2024"},
+		{Id: "Label_1", Name: "Clients"},
+		{Id: "Label_3", Name: "Clients/Acme"},
+		{Id: "Label_4", Name: "ClientsOld"},
+		{Id: "Label_5", Name: "Customers/Beta"},
+	}
+
+	renames, err := planLabelRename(labels, "Clients", "Customers")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	var got []string
+	for _, r := range renames {
+		got = append(got, r.From+"->"+r.To)
+	}
+	if strings.Join(got, ",") != "Clients->Customers,Clients/Acme->Customers/Acme,Clients/Acme/2024->Customers/Acme/2024" {
+		t.Fatalf("unexpected plan: %v", got)
+	}
+
+	if _, err := planLabelRename(labels, "Clients/Acme", "customers/beta"); err == nil || !strings.Contains(err.Error(), "Customers/Beta") {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if _, err := planLabelRename(labels, "Clients", "Clients/Sub"); err == nil {
+		t.Fatalf("expected error moving a label below itself")
+	}
+	if renames, err := planLabelRename(labels, "Clients", "clients"); err != nil || len(renames) != 3 {
+		t.Fatalf("case-only rename: %v, %v", renames, err)
+	}
+}
+
+func TestLabelColorFlags(t *testing.T) {
+	if c, err := (labelColorFlags{}).color(); c != nil || err != nil {
+		t.Fatalf("expected no color, got %v, %v", c, err)
+	}
+	c, err := labelColorFlags{Background: "#4A86E8", Text: "#ffffff"}.color()
+	if err != nil || c.BackgroundColor != "#4a86e8" || c.TextColor != "#ffffff" {
+		t.Fatalf("unexpected color: %+v, %v", c, err)
+	}
+	if _, err := (labelColorFlags{Background: "#4a86e8"}).color(); err == nil {
+		t.Fatalf("expected error for background without text color")
+	}
+	if _, err := (labelColorFlags{Background: "blue", Text: "#ffffff"}).color(); err == nil {
+		t.Fatalf("expected error for invalid color")
+	}
+}
+
+type gmailLabelsTestServer struct {
+	labels   []*gmail.Label
+	patches  []string
+	failName string
+	modified []string
+	deleted  []string
+}
+
+func newGmailLabelsTestServer(t *testing.T, labels []*gmail.Label, messages int) *gmailLabelsTestServer {
+	t.Helper()
+
+	s := &gmailLabelsTestServer{labels: labels}
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/labels") && r.Method == http.MethodGet:
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": s.labels})
+		case strings.Contains(path, "/labels/") && r.Method == http.MethodPatch:
+			id := path[strings.LastIndex(path, "/")+1:]
+			var patch gmail.Label
+			_ = json.NewDecoder(r.Body).Decode(&patch)
+			s.patches = append(s.patches, id+"="+patch.Name)
+			if patch.Name != "" && patch.Name == s.failName {
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": 409, "message": "Label name exists or conflicts"}})
+				return
+			}
+			patch.Id = id
+			_ = json.NewEncoder(w).Encode(patch)
+		case strings.Contains(path, "/labels/") && r.Method == http.MethodDelete:
+			s.deleted = append(s.deleted, path[strings.LastIndex(path, "/")+1:])
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(path, "/messages") && r.Method == http.MethodGet:
+			msgs := make([]map[string]any, 0, messages)
+			for i := 0; i < messages; i++ {
+				msgs = append(msgs, map[string]any{"id": fmt.Sprintf("m%d", i)})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"messages": msgs})
+		case strings.HasSuffix(path, "/messages/batchModify"):
+			var body gmail.BatchModifyMessagesRequest
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			s.modified = append(s.modified, fmt.Sprintf("%d:+%s-%s", len(body.Ids), strings.Join(body.AddLabelIds, ","), strings.Join(body.RemoveLabelIds, ",")))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+	return s
+}
+
+func TestGmailLabelsRenameCmd(t *testing.T) {
+	labels := []*gmail.Label{
+		{Id: "Label_1", Name: "Clients", Type: "user"},
+		{Id: "Label_2", Name: "Clients/Acme", Type: "user"},
+	}
+	s := newGmailLabelsTestServer(t, labels, 0)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "labels", "rename", "clients", "Customers"}); err != nil {
+			t.Fatalf("rename: %v", err)
+		}
+	})
+	if strings.Join(s.patches, ",") != "Label_1=Customers,Label_2=Customers/Acme" {
+		t.Fatalf("unexpected patches: %v", s.patches)
+	}
+	if !strings.Contains(out, "Clients/Acme") || !strings.Contains(out, "Customers/Acme") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	// A failing child rename restores the labels already renamed.
+	s.patches, s.failName = nil, "Customers/Acme"
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "labels", "rename", "Clients", "Customers"}); err == nil ||
+			!strings.Contains(err.Error(), "rolled back 1 labels") {
+			t.Fatalf("expected rolled back error, got %v", err)
+		}
+	})
+	if strings.Join(s.patches, ",") != "Label_1=Customers,Label_2=Customers/Acme,Label_1=Clients" {
+		t.Fatalf("unexpected patches: %v", s.patches)
+	}
+}
+
+func TestGmailLabelsMergeCmd(t *testing.T) {
+	labels := []*gmail.Label{
+		{Id: "INBOX", Name: "INBOX", Type: "system"},
+		{Id: "Label_1", Name: "Old", Type: "user"},
+		{Id: "Label_2", Name: "New", Type: "user"},
+	}
+	s := newGmailLabelsTestServer(t, labels, 3)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--dry-run", "--account", "a@b.com", "gmail", "labels", "merge", "Old", "New"}); err != nil {
+			t.Fatalf("dry run: %v", err)
+		}
+	})
+	if !strings.Contains(out, `"count": 3`) || len(s.modified) != 0 || len(s.deleted) != 0 {
+		t.Fatalf("unexpected dry run: %q modified=%v deleted=%v", out, s.modified, s.deleted)
+	}
+
+	out = captureStdout(t, func() {
+		if err := Execute([]string{"--force", "--account", "a@b.com", "gmail", "labels", "merge", "Old", "New"}); err != nil {
+			t.Fatalf("merge: %v", err)
+		}
+	})
+	if strings.Join(s.modified, ";") != "3:+Label_2-Label_1" || strings.Join(s.deleted, ",") != "Label_1" {
+		t.Fatalf("unexpected merge: modified=%v deleted=%v", s.modified, s.deleted)
+	}
+	if !strings.Contains(out, "Merged 3 messages from Old into New") {
+		t.Fatalf("unexpected output: %q", out)
+	}
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--force", "--account", "a@b.com", "gmail", "labels", "merge", "INBOX", "New"}); err == nil {
+			t.Fatalf("expected system label merge to be refused")
+		}
+	})
+}
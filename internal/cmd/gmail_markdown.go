@@ -0,0 +1,528 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// markdownEmail is a markdown body rendered for sending: an HTML part, a
+// plain-text alternative, and the local images the HTML references by cid:.
+type markdownEmail struct {
+	Text   string
+	HTML   string
+	Images []mailAttachment
+}
+
+var (
+	markdownListItemRe = regexp.MustCompile(`^\s{0,3}([-*+]|\d{1,9}[.)])\s+(.*)$`)
+	markdownOrderedRe  = regexp.MustCompile(`^\d`)
+)
+
+// renderMarkdownEmail renders a CommonMark-ish subset (headings, paragraphs,
+// lists, blockquotes, fenced code, tables, rules, emphasis, code spans,
+// links, images). Relative image paths resolve against baseDir.
+func renderMarkdownEmail(src, baseDir string) (*markdownEmail, error) {
+	r := &markdownRenderer{baseDir: baseDir, cids: map[string]string{}}
+	htmlOut, textOut, err := r.blocks(strings.Split(normalizeMarkdownNewlines(src), "\n"))
+	if err != nil {
+		return nil, err
+	}
+	return &markdownEmail{
+		Text:   strings.TrimSpace(textOut) + "\n",
+		HTML:   htmlOut,
+		Images: r.images,
+	}, nil
+}
+
+func normalizeMarkdownNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\r", "\n")
+}
+
+type markdownRenderer struct {
+	baseDir string
+	cids    map[string]string // resolved image path -> Content-ID
+	images  []mailAttachment
+}
+
+func (r *markdownRenderer) blocks(lines []string) (string, string, error) {
+	var (
+		h     strings.Builder
+		t     strings.Builder
+		para  []string
+		block = func(htmlPart, textPart string) {
+			h.WriteString(htmlPart)
+			h.WriteString("\n")
+			if t.Len() > 0 {
+				t.WriteString("\n")
+			}
+			t.WriteString(strings.TrimRight(textPart, "\n"))
+			t.WriteString("\n")
+		}
+	)
+
+	flush := func() error {
+		if len(para) == 0 {
+			return nil
+		}
+		inner, text, err := r.inlineLines(para)
+		para = nil
+		if err != nil {
+			return err
+		}
+		block("<p>"+inner+"</p>", text)
+		return nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+			fence := trimmed[:3]
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), fence); i++ {
+				code = append(code, lines[i])
+			}
+			content := strings.Join(code, "\n")
+			block("<pre><code>"+html.EscapeString(content)+"</code></pre>", content)
+
+		case isHorizontalRule(line):
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+			block("<hr>", "----")
+
+		case strings.HasPrefix(trimmed, "#"):
+			level, content := parseHeading(trimmed)
+			if level == 0 {
+				para = append(para, line)
+				continue
+			}
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+			inner, text, err := r.inline(strings.TrimRight(content, " #"))
+			if err != nil {
+				return "", "", err
+			}
+			block(fmt.Sprintf("<h%d>%s</h%d>", level, inner, level), text)
+
+		case strings.HasPrefix(trimmed, ">"):
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(q, " "))
+			}
+			i--
+			inner, text, err := r.blocks(quoted)
+			if err != nil {
+				return "", "", err
+			}
+			block("<blockquote>\n"+inner+"</blockquote>", prefixLines(strings.TrimRight(text, "\n"), "> "))
+
+		case strings.HasPrefix(trimmed, "|") && i+1 < len(lines) && isTableSeparator(lines[i+1]):
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+			rows := parseMarkdownTable(lines[i:])
+			i += len(rows)
+			htmlTable, text, err := r.table(rows)
+			if err != nil {
+				return "", "", err
+			}
+			block(htmlTable, text)
+
+		case markdownListItemRe.MatchString(line):
+			if err := flush(); err != nil {
+				return "", "", err
+			}
+			end, htmlList, text, err := r.list(lines, i)
+			if err != nil {
+				return "", "", err
+			}
+			i = end - 1
+			block(htmlList, text)
+
+		default:
+			para = append(para, line)
+		}
+	}
+	if err := flush(); err != nil {
+		return "", "", err
+	}
+	return h.String(), t.String(), nil
+}
+
+// list renders the list starting at lines[start] and returns the index of
+// the first line after it. Indented lines continue the previous item.
+func (r *markdownRenderer) list(lines []string, start int) (int, string, string, error) {
+	ordered := markdownOrderedRe.MatchString(markdownListItemRe.FindStringSubmatch(lines[start])[1])
+
+	var items [][]string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+		if m := markdownListItemRe.FindStringSubmatch(line); m != nil && !strings.HasPrefix(line, "    ") {
+			if markdownOrderedRe.MatchString(m[1]) != ordered {
+				break
+			}
+			items = append(items, []string{m[2]})
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			break
+		}
+		items[len(items)-1] = append(items[len(items)-1], strings.TrimSpace(line))
+	}
+
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+	var h, t strings.Builder
+	h.WriteString("<" + tag + ">\n")
+	for n, item := range items {
+		inner, text, err := r.inlineLines(item)
+		if err != nil {
+			return 0, "", "", err
+		}
+		h.WriteString("<li>" + inner + "</li>\n")
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", n+1)
+		}
+		t.WriteString(marker + strings.ReplaceAll(text, "\n", "\n"+strings.Repeat(" ", len(marker))) + "\n")
+	}
+	h.WriteString("</" + tag + ">")
+	return i, h.String(), t.String(), nil
+}
+
+func (r *markdownRenderer) table(rows [][]string) (string, string, error) {
+	var h, t strings.Builder
+	h.WriteString(`<table border="1" cellpadding="4" cellspacing="0">` + "\n")
+	for n, row := range rows {
+		cellTag := "td"
+		if n == 0 {
+			cellTag = "th"
+		}
+		h.WriteString("<tr>")
+		texts := make([]string, 0, len(row))
+		for _, cell := range row {
+			inner, text, err := r.inline(cell)
+			if err != nil {
+				return "", "", err
+			}
+			h.WriteString("<" + cellTag + ">" + inner + "</" + cellTag + ">")
+			texts = append(texts, text)
+		}
+		h.WriteString("</tr>\n")
+		t.WriteString(strings.Join(texts, " | ") + "\n")
+	}
+	h.WriteString("</table>")
+	return h.String(), t.String(), nil
+}
+
+// inlineLines renders paragraph lines; a trailing double space or backslash
+// is a hard line break, other line ends are soft breaks.
+func (r *markdownRenderer) inlineLines(lines []string) (string, string, error) {
+	var h, t strings.Builder
+	for n, line := range lines {
+		hard := strings.HasSuffix(line, "  ") || strings.HasSuffix(line, "\\")
+		line = strings.TrimSpace(line)
+		if hard && strings.HasSuffix(line, "\\") {
+			line = strings.TrimSuffix(line, "\\")
+		}
+		inner, text, err := r.inline(line)
+		if err != nil {
+			return "", "", err
+		}
+		h.WriteString(inner)
+		t.WriteString(text)
+		if n < len(lines)-1 {
+			if hard {
+				h.WriteString("<br>")
+			}
+			h.WriteString("\n")
+			t.WriteString("\n")
+		}
+	}
+	return h.String(), t.String(), nil
+}
+
+func (r *markdownRenderer) inline(s string) (string, string, error) {
+	var h, t strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!|>~<", s[i+1]) >= 0:
+			h.WriteString(html.EscapeString(s[i+1 : i+2]))
+			t.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				code := s[i+1 : i+1+end]
+				h.WriteString("<code>" + html.EscapeString(code) + "</code>")
+				t.WriteString(code)
+				i += end + 2
+				continue
+			}
+
+		case c == '!' && strings.HasPrefix(s[i+1:], "["):
+			if alt, dest, n, ok := parseMarkdownLink(s[i+1:]); ok {
+				src, ok, err := r.imageSource(dest)
+				if err != nil {
+					return "", "", err
+				}
+				if !ok {
+					h.WriteString(html.EscapeString(alt))
+					t.WriteString(alt)
+					i += n + 1
+					continue
+				}
+				fmt.Fprintf(&h, `<img src="%s" alt="%s">`, html.EscapeString(src), html.EscapeString(alt))
+				if alt != "" {
+					t.WriteString("[" + alt + "]")
+				}
+				i += n + 1
+				continue
+			}
+
+		case c == '[':
+			if label, dest, n, ok := parseMarkdownLink(s[i:]); ok {
+				inner, text, err := r.inline(label)
+				if err != nil {
+					return "", "", err
+				}
+				if markdownLinkAllowed(dest) {
+					fmt.Fprintf(&h, `<a href="%s">%s</a>`, html.EscapeString(dest), inner)
+				} else {
+					fmt.Fprintf(&h, "%s (%s)", inner, html.EscapeString(dest))
+				}
+				t.WriteString(text)
+				if text != dest && "mailto:"+text != dest {
+					t.WriteString(" (" + dest + ")")
+				}
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				target := s[i+1 : i+end]
+				if markdownLinkAllowed(target) && !strings.ContainsAny(target, " <") {
+					fmt.Fprintf(&h, `<a href="%s">%s</a>`, html.EscapeString(target), html.EscapeString(strings.TrimPrefix(target, "mailto:")))
+					t.WriteString(target)
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if inner, text, n, ok, err := r.emphasis(s, i); err != nil {
+				return "", "", err
+			} else if ok {
+				h.WriteString(inner)
+				t.WriteString(text)
+				i += n
+				continue
+			}
+		}
+
+		h.WriteString(html.EscapeString(s[i : i+1]))
+		t.WriteByte(c)
+		i++
+	}
+	return h.String(), t.String(), nil
+}
+
+// emphasis handles **strong**, *em*, ___/__/_ equivalents and ~~strike~~ at
+// s[i]. Underscores only count at word boundaries so snake_case stays intact.
+func (r *markdownRenderer) emphasis(s string, i int) (string, string, int, bool, error) {
+	c := s[i]
+	if c == '_' && i > 0 && isMarkdownWordByte(s[i-1]) {
+		return "", "", 0, false, nil
+	}
+
+	for _, d := range []struct {
+		delim string
+		open  string
+		close string
+	}{
+		{strings.Repeat(string(c), 3), "<strong><em>", "</em></strong>"},
+		{strings.Repeat(string(c), 2), "<strong>", "</strong>"},
+		{string(c), "<em>", "</em>"},
+	} {
+		if c == '~' {
+			if len(d.delim) != 2 {
+				continue
+			}
+			d.open, d.close = "<del>", "</del>"
+		}
+		if !strings.HasPrefix(s[i:], d.delim) {
+			continue
+		}
+		start := i + len(d.delim)
+		if start >= len(s) || s[start] == ' ' {
+			continue
+		}
+		end := strings.Index(s[start:], d.delim)
+		if end <= 0 || s[start+end-1] == ' ' {
+			continue
+		}
+		after := start + end + len(d.delim)
+		if c == '_' && after < len(s) && isMarkdownWordByte(s[after]) {
+			continue
+		}
+		inner, text, err := r.inline(s[start : start+end])
+		if err != nil {
+			return "", "", 0, false, err
+		}
+		return d.open + inner + d.close, text, after - i, true, nil
+	}
+	return "", "", 0, false, nil
+}
+
+func isMarkdownWordByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// parseMarkdownLink parses "[label](dest)" or "[label](dest "title")" at
+// the start of s and returns the number of bytes consumed.
+func parseMarkdownLink(s string) (string, string, int, bool) {
+	depth := 0
+	closeLabel := -1
+	for i := 0; i < len(s) && closeLabel < 0; i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closeLabel = i
+			}
+		}
+	}
+	if closeLabel < 0 || closeLabel+1 >= len(s) || s[closeLabel+1] != '(' {
+		return "", "", 0, false
+	}
+	rest := s[closeLabel+2:]
+
+	var dest string
+	var consumed int
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			return "", "", 0, false
+		}
+		dest = rest[1:end]
+		closeParen := strings.IndexByte(rest[end:], ')')
+		if closeParen < 0 {
+			return "", "", 0, false
+		}
+		consumed = end + closeParen + 1
+	} else {
+		closeParen := strings.IndexByte(rest, ')')
+		if closeParen < 0 {
+			return "", "", 0, false
+		}
+		dest = strings.TrimSpace(rest[:closeParen])
+		if sp := strings.IndexAny(dest, " \t"); sp >= 0 {
+			dest = dest[:sp] // drop an optional "title"
+		}
+		consumed = closeParen + 1
+	}
+	if strings.TrimSpace(dest) == "" {
+		return "", "", 0, false
+	}
+	return s[1:closeLabel], dest, closeLabel + 2 + consumed, true
+}
+
+// markdownLinkSchemes and markdownImageSchemes are the URL schemes that
+// become live links and image sources in the HTML part; anything else
+// (javascript:, data:, ...) is rendered as plain text.
+var (
+	markdownLinkSchemes  = map[string]bool{"http": true, "https": true, "mailto": true}
+	markdownImageSchemes = map[string]bool{"http": true, "https": true, "cid": true}
+)
+
+func markdownLinkAllowed(dest string) bool {
+	u, err := url.Parse(dest)
+	return err == nil && markdownLinkSchemes[u.Scheme]
+}
+
+// imageSource returns the src for an image destination: remote URLs pass
+// through, local files become cid: references to inline parts. It reports
+// false for URLs with other schemes, which are rendered as alt text. Local
+// files must stay inside the Markdown file's directory, so Markdown passed
+// inline (no base directory) can only reference remote images.
+func (r *markdownRenderer) imageSource(dest string) (string, bool, error) {
+	if u, err := url.Parse(dest); err == nil && len(u.Scheme) > 1 {
+		return dest, markdownImageSchemes[u.Scheme], nil
+	}
+	if r.baseDir == "" {
+		return "", false, fmt.Errorf("markdown image %q: local images need --body-markdown-file", dest)
+	}
+
+	ref := dest
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	path, err := resolveImageWithinDir(r.baseDir, ref)
+	if err != nil {
+		return "", false, fmt.Errorf("markdown image %q: %w", dest, err)
+	}
+	if cid, ok := r.cids[path]; ok {
+		return "cid:" + cid, true, nil
+	}
+
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", false, fmt.Errorf("markdown image %q: %w", dest, err)
+	}
+	if st.IsDir() {
+		return "", false, fmt.Errorf("markdown image %q: is a directory", dest)
+	}
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", false, err
+	}
+	cid := fmt.Sprintf("img%d.%s@gogcli", len(r.images)+1, hex.EncodeToString(b[:]))
+	r.cids[path] = cid
+	r.images = append(r.images, mailAttachment{Path: path, ContentID: cid})
+	return "cid:" + cid, true, nil
+}
+
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestRenderMarkdownEmail(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "chart.png"), []byte("png"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	src := strings.Join([]string{
+		"# Weekly *update*",
+		"",
+		"Shipped **three** fixes for snake_case_names and <b>tags</b>.",
+		"Second line of the same paragraph.",
+		"",
+		"- one with `code`",
+		"- two with [docs](https://example.com/docs)",
+		"",
+		"1. first",
+		"2. second",
+		"",
+		"> quoted",
+		"",
+		"![Chart](chart.png) and again ![Chart](./chart.png) plus ![remote](https://example.com/x.png)",
+		"",
+		"```",
+		"a < b",
+		"```",
+	}, "\n")
+
+	md, err := renderMarkdownEmail(src, dir)
+	if err != nil {
+		t.Fatalf("renderMarkdownEmail: %v", err)
+	}
+
+	for _, want := range []string{
+		"<h1>Weekly <em>update</em></h1>",
+		"<p>Shipped <strong>three</strong> fixes for snake_case_names and &lt;b&gt;tags&lt;/b&gt;.\nSecond line of the same paragraph.</p>",
+		"<ul>\n<li>one with <code>code</code></li>\n<li>two with <a href=\"https://example.com/docs\">docs</a></li>\n</ul>",
+		"<ol>\n<li>first</li>\n<li>second</li>\n</ol>",
+		"<blockquote>\n<p>quoted</p>\n</blockquote>",
+		`<img src="https://example.com/x.png" alt="remote">`,
+		"<pre><code>a &lt; b</code></pre>",
+	} {
+		if !strings.Contains(md.HTML, want) {
+			t.Fatalf("html missing %q:\n%s", want, md.HTML)
+		}
+	}
+
+	if len(md.Images) != 1 || md.Images[0].Path != filepath.Join(dir, "chart.png") {
+		t.Fatalf("expected one deduplicated inline image, got %+v", md.Images)
+	}
+	cid := md.Images[0].ContentID
+	if strings.Count(md.HTML, `src="cid:`+cid+`"`) != 2 {
+		t.Fatalf("expected both references to use cid %q:\n%s", cid, md.HTML)
+	}
+
+	for _, want := range []string{
+		"Weekly update\n\nShipped three fixes",
+		"- one with code\n- two with docs (https://example.com/docs)",
+		"1. first\n2. second",
+		"> quoted",
+		"[Chart] and again [Chart] plus [remote]",
+		"a < b",
+	} {
+		if !strings.Contains(md.Text, want) {
+			t.Fatalf("text missing %q:\n%s", want, md.Text)
+		}
+	}
+
+	if _, err := renderMarkdownEmail("![x](missing.png)", dir); err == nil {
+		t.Fatalf("expected error for missing image")
+	}
+}
+
+func TestRenderMarkdownEmail_UnsafeURLsArePlainText(t *testing.T) {
+	src := strings.Join([]string{
+		"[click](javascript:alert(1)) [doc](data:text/html;base64,PHNjcmlwdD4=) [up](JaVaScRiPt:alert(2))",
+		"[rel](notes.html) [mail](mailto:a@b.com) <javascript:alert(3)>",
+		"![pixel](data:image/png;base64,AAAA) ![x](javascript:alert(4)) ![logo](cid:logo@x)",
+	}, "\n")
+
+	md, err := renderMarkdownEmail(src, t.TempDir())
+	if err != nil {
+		t.Fatalf("renderMarkdownEmail: %v", err)
+	}
+
+	if got := strings.Count(md.HTML, "<a "); got != 1 || !strings.Contains(md.HTML, `<a href="mailto:a@b.com">mail</a>`) {
+		t.Fatalf("expected only the mailto link to be live:\n%s", md.HTML)
+	}
+	for _, want := range []string{
+		"click (javascript:alert(1))",
+		"rel (notes.html)",
+		"pixel x",
+		`<img src="cid:logo@x" alt="logo">`,
+	} {
+		if !strings.Contains(md.HTML, want) {
+			t.Fatalf("html missing %q:\n%s", want, md.HTML)
+		}
+	}
+	if strings.Contains(md.HTML, `src="data:`) || strings.Contains(md.HTML, `src="javascript:`) || strings.Contains(md.HTML, `href="javascript:`) {
+		t.Fatalf("unsafe url left live:\n%s", md.HTML)
+	}
+}
+
+func TestRenderMarkdownEmail_LocalImagesStayInMarkdownDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "mail")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	secret := filepath.Join(root, "secrets.env")
+	if err := os.WriteFile(secret, []byte("TOKEN=x"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink(secret, filepath.Join(dir, "link.png")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	for _, src := range []string{
+		"![x](../secrets.env)",
+		"![x](" + secret + ")",
+		"![x](~/.ssh/id_rsa)",
+		"![x](link.png)",
+	} {
+		if md, err := renderMarkdownEmail(src, dir); err == nil {
+			t.Fatalf("%s: expected rejection, got images %+v", src, md.Images)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "chart.png"), []byte("png"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := renderMarkdownEmail("![x](chart.png)", ""); err == nil || !strings.Contains(err.Error(), "--body-markdown-file") {
+		t.Fatalf("expected inline markdown to reject local images, got %v", err)
+	}
+	if md, err := renderMarkdownEmail("![x](https://example.com/x.png)", ""); err != nil || len(md.Images) != 0 {
+		t.Fatalf("remote image without base dir = %+v, %v", md, err)
+	}
+}
+
+func TestGmailDraftsCreate_BodyMarkdownFile(t *testing.T) {
+	dir := t.TempDir()
+	mdPath := filepath.Join(dir, "note.md")
+	if err := os.WriteFile(mdPath, []byte("Hello **team**\n\n![logo](img/logo.png)\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "img"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "img", "logo.png"), []byte("\x89PNG"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	var raw []byte
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.HasSuffix(r.URL.Path, "/users/me/drafts") || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		var draft gmail.Draft
+		if err := json.NewDecoder(r.Body).Decode(&draft); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		raw, _ = base64.RawURLEncoding.DecodeString(draft.Message.Raw)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "d1", "message": map[string]any{"id": "m1"}})
+	})
+	t.Cleanup(closeSrv)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "a@b.com", "gmail", "drafts", "create",
+			"--to", "x@y.com", "--subject", "S", "--body-markdown-file", mdPath}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("top-level type = %q", mediaType)
+	}
+
+	var types []string
+	var walk func(r io.Reader, boundary string)
+	walk = func(r io.Reader, boundary string) {
+		mr := multipart.NewReader(r, boundary)
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return
+			}
+			ct, p, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			types = append(types, ct)
+			if strings.HasPrefix(ct, "multipart/") {
+				walk(part, p["boundary"])
+				continue
+			}
+			body, _ := io.ReadAll(part)
+			switch ct {
+			case "text/plain":
+				if strings.TrimSpace(string(body)) != "Hello team\r\n\r\n[logo]" {
+					t.Fatalf("plain = %q", body)
+				}
+			case "text/html":
+				if !strings.Contains(string(body), "<strong>team</strong>") || !strings.Contains(string(body), `src="cid:`) {
+					t.Fatalf("html = %q", body)
+				}
+			case "image/png":
+				if !strings.HasPrefix(part.Header.Get("Content-Id"), "<img1.") {
+					t.Fatalf("content-id = %q", part.Header.Get("Content-Id"))
+				}
+			}
+		}
+	}
+	walk(msg.Body, params["boundary"])
+
+	if strings.Join(types, ",") != "text/plain,multipart/related,text/html,image/png" {
+		t.Fatalf("unexpected structure %v", types)
+	}
+
+	if err := Execute([]string{"--account", "a@b.com", "gmail", "drafts", "create",
+		"--subject", "S", "--body", "x", "--body-markdown", "y"}); err == nil {
+		t.Fatalf("expected error combining --body and --body-markdown")
+	}
+}
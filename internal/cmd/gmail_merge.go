@@ -0,0 +1,578 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/tracking"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	gmailMergeStateSuffix = ".gog-merge"
+
+	gmailMergeStatusSending = "sending"
+	gmailMergeStatusSent    = "sent"
+	gmailMergeStatusFailed  = "failed"
+)
+
+type GmailMergeCmd struct {
+	Data            string        `name:"data" help:"Recipient rows: CSV with a header row, or a JSON array of objects" required:""`
+	SubjectTemplate string        `name:"subject-template" help:"Subject template (Go text/template), e.g. 'Hi {{.name}}'" required:""`
+	BodyTemplate    string        `name:"body-template" help:"Plain-text body template file"`
+	HTMLTemplate    string        `name:"html-template" help:"HTML body template file (html/template escaping)"`
+	ToColumn        string        `name:"to-column" help:"Column with To addresses (comma-separated)" default:"email"`
+	CcColumn        string        `name:"cc-column" help:"Column with CC addresses (optional)" default:"cc"`
+	BccColumn       string        `name:"bcc-column" help:"Column with BCC addresses (optional)" default:"bcc"`
+	AttachColumn    string        `name:"attach-column" help:"Column with attachment paths separated by ';' (relative to the data file)" default:"attachments"`
+	Attach          []string      `name:"attach" help:"Attachment for every message (repeatable)"`
+	From            string        `name:"from" help:"Send from this email address (must be a verified send-as alias)"`
+	ReplyTo         string        `name:"reply-to" help:"Reply-To header address"`
+	Track           bool          `name:"track" help:"Enable open tracking per row (requires --html-template and tracking setup)"`
+	Delay           time.Duration `name:"delay" help:"Pause between messages" default:"1s"`
+	State           string        `name:"state" help:"Per-row status file (default: <data>.gog-merge)"`
+	PreviewDir      string        `name:"preview-dir" help:"Write rendered .eml files to this directory instead of sending"`
+}
+
+type gmailMergeRow struct {
+	Number      int
+	Fields      map[string]any
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	Body        string
+	BodyHTML    string
+	Attachments []mailAttachment
+}
+
+func (r gmailMergeRow) key() string {
+	return fmt.Sprintf("%d:%s", r.Number, strings.ToLower(strings.Join(r.To, ",")))
+}
+
+// gmailMergeRecord is one line of the merge state file. The last record for
+// a row wins; "sending" without a later "sent" means the outcome is unknown.
+type gmailMergeRecord struct {
+	Row       int    `json:"row"`
+	Key       string `json:"key"`
+	Status    string `json:"status"`
+	MessageID string `json:"messageId,omitempty"`
+	GmailID   string `json:"gmailId,omitempty"`
+	ThreadID  string `json:"threadId,omitempty"`
+	Error     string `json:"error,omitempty"`
+	At        string `json:"at"`
+}
+
+func (c *GmailMergeCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	dataPath, err := config.ExpandPath(strings.TrimSpace(c.Data))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(c.BodyTemplate) == "" && strings.TrimSpace(c.HTMLTemplate) == "" {
+		return usage("required: --body-template or --html-template")
+	}
+	if c.Track && strings.TrimSpace(c.HTMLTemplate) == "" {
+		return usage("--track requires --html-template (pixel must be in HTML)")
+	}
+	if c.Delay < 0 {
+		return usage("--delay must not be negative")
+	}
+
+	tmpl, err := parseGmailMergeTemplates(c.SubjectTemplate, c.BodyTemplate, c.HTMLTemplate)
+	if err != nil {
+		return err
+	}
+
+	fields, err := readGmailMergeData(dataPath)
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return usagef("no rows in %s", dataPath)
+	}
+
+	commonAtts := make([]mailAttachment, 0, len(c.Attach))
+	for _, p := range c.Attach {
+		expanded, expandErr := config.ExpandPath(p)
+		if expandErr != nil {
+			return expandErr
+		}
+		commonAtts = append(commonAtts, mailAttachment{Path: expanded})
+	}
+
+	// Render everything up front so a template error never stops a run halfway.
+	rows := make([]gmailMergeRow, 0, len(fields))
+	for i, f := range fields {
+		row, rowErr := c.renderRow(tmpl, i+1, f, filepath.Dir(dataPath), commonAtts)
+		if rowErr != nil {
+			return rowErr
+		}
+		rows = append(rows, row)
+	}
+
+	if dryRunErr := dryRunExit(ctx, flags, "gmail.merge", map[string]any{
+		"data":     dataPath,
+		"rows":     len(rows),
+		"to":       rows[0].To,
+		"subject":  rows[0].Subject,
+		"reply_to": strings.TrimSpace(c.ReplyTo),
+		"from":     strings.TrimSpace(c.From),
+		"html":     rows[0].BodyHTML != "",
+		"track":    c.Track,
+		"delay":    c.Delay.String(),
+		"preview":  strings.TrimSpace(c.PreviewDir),
+	}); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(c.PreviewDir) != "" {
+		return c.writePreview(ctx, u, account, rows)
+	}
+
+	statePath := strings.TrimSpace(c.State)
+	if statePath == "" {
+		statePath = dataPath + gmailMergeStateSuffix
+	} else if statePath, err = config.ExpandPath(statePath); err != nil {
+		return err
+	}
+	previous, err := readGmailMergeState(statePath)
+	if err != nil {
+		return err
+	}
+
+	var trackingCfg *tracking.Config
+	if c.Track {
+		trackingCfg, err = tracking.LoadConfig(account)
+		if err != nil {
+			return fmt.Errorf("load tracking config: %w", err)
+		}
+		if !trackingCfg.IsConfigured() {
+			return fmt.Errorf("tracking not configured; run 'gog gmail track setup' first")
+		}
+	}
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	fromAddr, _, err := resolveSendFromAddress(ctx, svc, account, c.From)
+	if err != nil {
+		return err
+	}
+
+	stateFile, err := os.OpenFile(statePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600) //nolint:gosec // user-provided path
+	if err != nil {
+		return fmt.Errorf("open merge state: %w", err)
+	}
+	defer stateFile.Close()
+
+	record := func(rec gmailMergeRecord) error {
+		rec.At = time.Now().UTC().Format(time.RFC3339)
+		line, marshalErr := json.Marshal(rec)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		if _, writeErr := stateFile.Write(append(line, '\n')); writeErr != nil {
+			return fmt.Errorf("record merge state: %w", writeErr)
+		}
+		return stateFile.Sync()
+	}
+
+	results := make([]gmailMergeRecord, 0, len(rows))
+	sent, skipped := 0, 0
+	first := true
+	for _, row := range rows {
+		prev, seen := previous[row.key()]
+		if seen && prev.Status == gmailMergeStatusSent {
+			skipped++
+			continue
+		}
+
+		messageID := ""
+		if seen && (prev.Status == gmailMergeStatusSending || prev.Status == gmailMergeStatusFailed) && prev.MessageID != "" {
+			// A previous run crashed mid-send or saw an error (e.g. a timeout)
+			// after Gmail may have accepted the message; only resend if Gmail
+			// has no copy.
+			exists, existsErr := gmailMessageIDExists(ctx, svc, prev.MessageID)
+			if existsErr != nil {
+				return fmt.Errorf("row %d: check previous send: %w", row.Number, existsErr)
+			}
+			if exists {
+				prev.Status = gmailMergeStatusSent
+				if err := record(prev); err != nil {
+					return err
+				}
+				skipped++
+				continue
+			}
+			messageID = prev.MessageID
+		}
+		if messageID == "" {
+			if messageID, err = randomMessageID(fromAddr); err != nil {
+				return err
+			}
+		}
+
+		if !first && c.Delay > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.Delay):
+			}
+		}
+		first = false
+
+		rec := gmailMergeRecord{Row: row.Number, Key: row.key(), Status: gmailMergeStatusSending, MessageID: messageID}
+		if err := record(rec); err != nil {
+			return err
+		}
+
+		out, sendErr := sendGmailBatches(ctx, svc, sendMessageOptions{
+			FromAddr:          fromAddr,
+			ReplyTo:           c.ReplyTo,
+			Subject:           row.Subject,
+			Body:              row.Body,
+			BodyHTML:          row.BodyHTML,
+			Attachments:       row.Attachments,
+			AdditionalHeaders: map[string]string{"Message-ID": messageID},
+			Track:             c.Track,
+			TrackingCfg:       trackingCfg,
+		}, []sendBatch{{To: row.To, Cc: row.Cc, Bcc: row.Bcc, TrackingRecipient: row.To[0]}})
+		if sendErr != nil {
+			rec.Status = gmailMergeStatusFailed
+			rec.Error = sendErr.Error()
+			if err := record(rec); err != nil {
+				return fmt.Errorf("row %d (%s): %w (after send error: %v)", row.Number, strings.Join(row.To, ","), err, sendErr)
+			}
+			return fmt.Errorf("row %d (%s): %w", row.Number, strings.Join(row.To, ","), sendErr)
+		}
+
+		rec.Status = gmailMergeStatusSent
+		rec.GmailID = out[0].MessageID
+		rec.ThreadID = out[0].ThreadID
+		if err := record(rec); err != nil {
+			return err
+		}
+		results = append(results, rec)
+		sent++
+		if !outfmt.IsJSON(ctx) {
+			u.Err().Printf("sent %d/%d\t%s", row.Number, len(rows), strings.Join(row.To, ","))
+		}
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"rows":     len(rows),
+			"sent":     sent,
+			"skipped":  skipped,
+			"state":    statePath,
+			"messages": results,
+		})
+	}
+
+	u.Out().Printf("rows\t%d", len(rows))
+	u.Out().Printf("sent\t%d", sent)
+	u.Out().Printf("skipped\t%d", skipped)
+	u.Out().Printf("state\t%s", statePath)
+	return nil
+}
+
+func (c *GmailMergeCmd) renderRow(tmpl *gmailMergeTemplates, number int, fields map[string]any, baseDir string, commonAtts []mailAttachment) (gmailMergeRow, error) {
+	row := gmailMergeRow{
+		Number: number,
+		Fields: fields,
+		To:     splitCSV(gmailMergeField(fields, c.ToColumn)),
+		Cc:     splitCSV(gmailMergeField(fields, c.CcColumn)),
+		Bcc:    splitCSV(gmailMergeField(fields, c.BccColumn)),
+	}
+	if len(row.To) == 0 {
+		return row, usagef("row %d: empty %q column", number, c.ToColumn)
+	}
+
+	var err error
+	if row.Subject, err = tmpl.renderSubject(fields); err != nil {
+		return row, fmt.Errorf("row %d: subject: %w", number, err)
+	}
+	if row.Body, err = tmpl.renderBody(fields); err != nil {
+		return row, fmt.Errorf("row %d: body: %w", number, err)
+	}
+	if row.BodyHTML, err = tmpl.renderHTML(fields); err != nil {
+		return row, fmt.Errorf("row %d: html: %w", number, err)
+	}
+
+	row.Attachments = append(row.Attachments, commonAtts...)
+	for _, p := range strings.Split(gmailMergeField(fields, c.AttachColumn), ";") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		expanded, expandErr := config.ExpandPath(p)
+		if expandErr != nil {
+			return row, expandErr
+		}
+		if !filepath.IsAbs(expanded) {
+			expanded = filepath.Join(baseDir, expanded)
+		}
+		if _, statErr := os.Stat(expanded); statErr != nil {
+			return row, fmt.Errorf("row %d: attachment: %w", number, statErr)
+		}
+		row.Attachments = append(row.Attachments, mailAttachment{Path: expanded})
+	}
+	return row, nil
+}
+
+func (c *GmailMergeCmd) writePreview(ctx context.Context, u *ui.UI, account string, rows []gmailMergeRow) error {
+	dir, err := config.ExpandPath(strings.TrimSpace(c.PreviewDir))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	from := account
+	if strings.TrimSpace(c.From) != "" {
+		from = strings.TrimSpace(c.From)
+	}
+
+	files := make([]string, 0, len(rows))
+	for _, row := range rows {
+		raw, buildErr := buildRFC822(mailOptions{
+			From:        from,
+			To:          row.To,
+			Cc:          row.Cc,
+			Bcc:         row.Bcc,
+			ReplyTo:     c.ReplyTo,
+			Subject:     row.Subject,
+			Body:        row.Body,
+			BodyHTML:    row.BodyHTML,
+			Attachments: row.Attachments,
+		}, nil)
+		if buildErr != nil {
+			return fmt.Errorf("row %d: %w", row.Number, buildErr)
+		}
+		name := fmt.Sprintf("%04d-%s.eml", row.Number, sanitizeAccountForPath(row.To[0]))
+		path := filepath.Join(dir, name)
+		if err := writeFileAtomic(path, raw); err != nil {
+			return err
+		}
+		files = append(files, path)
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"preview": dir, "files": files})
+	}
+	for _, f := range files {
+		u.Out().Println(f)
+	}
+	return nil
+}
+
+type gmailMergeTemplates struct {
+	subject *texttemplate.Template
+	body    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+func parseGmailMergeTemplates(subject, bodyPath, htmlPath string) (*gmailMergeTemplates, error) {
+	t := &gmailMergeTemplates{}
+
+	var err error
+	if t.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(subject); err != nil {
+		return nil, usagef("invalid --subject-template: %v", err)
+	}
+
+	if strings.TrimSpace(bodyPath) != "" {
+		src, readErr := readGmailMergeTemplateFile(bodyPath)
+		if readErr != nil {
+			return nil, readErr
+		}
+		if t.body, err = texttemplate.New("body").Option("missingkey=error").Parse(src); err != nil {
+			return nil, usagef("invalid --body-template: %v", err)
+		}
+	}
+
+	if strings.TrimSpace(htmlPath) != "" {
+		src, readErr := readGmailMergeTemplateFile(htmlPath)
+		if readErr != nil {
+			return nil, readErr
+		}
+		if t.html, err = htmltemplate.New("html").Option("missingkey=error").Parse(src); err != nil {
+			return nil, usagef("invalid --html-template: %v", err)
+		}
+	}
+	return t, nil
+}
+
+func readGmailMergeTemplateFile(path string) (string, error) {
+	expanded, err := config.ExpandPath(strings.TrimSpace(path))
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(expanded) //nolint:gosec // user-provided path
+	if err != nil {
+		return "", fmt.Errorf("read template: %w", err)
+	}
+	return string(data), nil
+}
+
+func (t *gmailMergeTemplates) renderSubject(data map[string]any) (string, error) {
+	var b bytes.Buffer
+	if err := t.subject.Execute(&b, data); err != nil {
+		return "", err
+	}
+	subject := strings.TrimSpace(b.String())
+	if subject == "" {
+		return "", errors.New("renders empty")
+	}
+	return subject, nil
+}
+
+func (t *gmailMergeTemplates) renderBody(data map[string]any) (string, error) {
+	if t.body == nil {
+		return "", nil
+	}
+	var b bytes.Buffer
+	if err := t.body.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (t *gmailMergeTemplates) renderHTML(data map[string]any) (string, error) {
+	if t.html == nil {
+		return "", nil
+	}
+	var b bytes.Buffer
+	if err := t.html.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func gmailMergeField(fields map[string]any, column string) string {
+	column = strings.TrimSpace(column)
+	if column == "" {
+		return ""
+	}
+	v, ok := fields[column]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(fmt.Sprint(v))
+}
+
+// readGmailMergeData loads rows from a .json file (array of objects) or a
+// CSV file with a header row.
+func readGmailMergeData(path string) ([]map[string]any, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path
+	if err != nil {
+		return nil, fmt.Errorf("read merge data: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []map[string]any
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("parse %s: expected a JSON array of objects: %w", path, err)
+		}
+		return rows, nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []map[string]any
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue
+		}
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			if name == "" {
+				continue
+			}
+			value := ""
+			if i < len(rec) {
+				value = rec[i]
+			}
+			row[name] = value
+		}
+		rows = append(rows, row)
+	}
+}
+
+// readGmailMergeState returns the latest record per row key.
+func readGmailMergeState(path string) (map[string]gmailMergeRecord, error) {
+	out := map[string]gmailMergeRecord{}
+
+	f, err := os.Open(path) //nolint:gosec // user-provided path
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return nil, fmt.Errorf("read merge state: %w", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var rec gmailMergeRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			// A torn last line from a crash is expected; ignore it.
+			continue
+		}
+		if rec.Key != "" {
+			out[rec.Key] = rec
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read merge state: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+type fakeGmailMergeServer struct {
+	mu       sync.Mutex
+	existing map[string]bool
+	sent     []string
+	failOn   string
+	// landOn makes matching sends reach the mailbox but still return an
+	// error, like a timeout after Gmail accepted the message.
+	landOn string
+}
+
+func (f *fakeGmailMergeServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/settings/sendAs"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"sendAs": []map[string]any{}})
+		case strings.HasSuffix(path, "/users/me/messages") && r.Method == http.MethodGet:
+			id := strings.TrimPrefix(r.URL.Query().Get("q"), "rfc822msgid:")
+			var msgs []map[string]any
+			if f.existing[id] {
+				msgs = append(msgs, map[string]any{"id": "existing"})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"messages": msgs})
+		case strings.HasSuffix(path, "/users/me/messages/send"):
+			var msg gmail.Message
+			if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+				t.Errorf("decode send: %v", err)
+			}
+			raw, err := base64.RawURLEncoding.DecodeString(msg.Raw)
+			if err != nil {
+				t.Errorf("decode raw: %v", err)
+			}
+			if f.landOn != "" && strings.Contains(string(raw), f.landOn) {
+				for _, line := range strings.Split(string(raw), "\r\n") {
+					if id, ok := strings.CutPrefix(line, "Message-ID: "); ok {
+						f.existing[strings.Trim(id, "<>")] = true
+					}
+				}
+				http.Error(w, `{"error":{"code":503,"message":"timeout"}}`, http.StatusServiceUnavailable)
+				return
+			}
+			if f.failOn != "" && strings.Contains(string(raw), f.failOn) {
+				http.Error(w, `{"error":{"code":500,"message":"boom"}}`, http.StatusInternalServerError)
+				return
+			}
+			f.sent = append(f.sent, string(raw))
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": fmt.Sprintf("g%d", len(f.sent)), "threadId": "t"})
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func useFakeGmailMergeServer(t *testing.T, f *fakeGmailMergeServer) {
+	t.Helper()
+
+	svc, closeSrv := newGmailServiceForTest(t, f.handler(t))
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+}
+
+func writeMergeFixture(t *testing.T) (dir, data, body string) {
+	t.Helper()
+
+	dir = t.TempDir()
+	data = filepath.Join(dir, "people.csv")
+	body = filepath.Join(dir, "body.tmpl")
+	files := map[string]string{
+		data: "email,name,cc,attachments\n" +
+			"ann@example.com,Ann,,notes.txt\n" +
+			"bob@example.com,Bob,boss@example.com,\n" +
+			"cat@example.com,Cat,,\n",
+		body:                            "Hello {{.name}},\nsee you.\n",
+		filepath.Join(dir, "notes.txt"): "for ann",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	return dir, data, body
+}
+
+func TestGmailMerge_SendFailureAndResume(t *testing.T) {
+	fake := &fakeGmailMergeServer{failOn: "Hello Bob"}
+	useFakeGmailMergeServer(t, fake)
+
+	_, data, body := writeMergeFixture(t)
+	args := []string{"--json", "--account", "me@example.com", "gmail", "merge",
+		"--data", data, "--subject-template", "Hi {{.name}}", "--body-template", body, "--delay", "0s"}
+
+	_ = captureStderr(t, func() {
+		if err := Execute(args); err == nil || !strings.Contains(err.Error(), "row 2") {
+			t.Fatalf("expected failure on row 2, got %v", err)
+		}
+	})
+	if len(fake.sent) != 1 {
+		t.Fatalf("expected 1 sent, got %d", len(fake.sent))
+	}
+	first := fake.sent[0]
+	if !strings.Contains(first, "Subject: Hi Ann") || !strings.Contains(first, "To: ann@example.com") ||
+		!strings.Contains(first, `filename="notes.txt"`) {
+		t.Fatalf("unexpected first message:\n%s", first)
+	}
+
+	state, err := readGmailMergeState(data + gmailMergeStateSuffix)
+	if err != nil {
+		t.Fatalf("readGmailMergeState: %v", err)
+	}
+	if state["1:ann@example.com"].Status != gmailMergeStatusSent || state["2:bob@example.com"].Status != gmailMergeStatusFailed {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	fake.failOn = ""
+	fake.sent = nil
+	stdout := captureStdout(t, func() {
+		if err := Execute(args); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+
+	var result struct {
+		Rows    int `json:"rows"`
+		Sent    int `json:"sent"`
+		Skipped int `json:"skipped"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("unmarshal: %v (%q)", err, stdout)
+	}
+	if result.Rows != 3 || result.Sent != 2 || result.Skipped != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(fake.sent) != 2 || !strings.Contains(fake.sent[0], "Cc: boss@example.com") || !strings.Contains(fake.sent[1], "Hello Cat") {
+		t.Fatalf("unexpected resumed sends: %d", len(fake.sent))
+	}
+}
+
+func TestGmailMerge_ResumeUnknownSends(t *testing.T) {
+	fake := &fakeGmailMergeServer{existing: map[string]bool{"landed@example.com": true}}
+	useFakeGmailMergeServer(t, fake)
+
+	_, data, body := writeMergeFixture(t)
+	state := `{"row":1,"key":"1:ann@example.com","status":"sending","messageId":"<landed@example.com>"}` + "\n" +
+		`{"row":2,"key":"2:bob@example.com","status":"sending","messageId":"<lost@example.com>"}` + "\n" +
+		`{"row":3,"key":"3:cat@exam`
+	if err := os.WriteFile(data+gmailMergeStateSuffix, []byte(state), 0o600); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	_ = captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute([]string{"--account", "me@example.com", "gmail", "merge",
+				"--data", data, "--subject-template", "Hi {{.name}}", "--body-template", body, "--delay", "0s"}); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+		})
+	})
+
+	if len(fake.sent) != 2 {
+		t.Fatalf("expected rows 2 and 3 sent, got %d", len(fake.sent))
+	}
+	if !strings.Contains(fake.sent[0], "Message-ID: <lost@example.com>") {
+		t.Fatalf("expected resend to reuse the recorded Message-ID:\n%s", fake.sent[0])
+	}
+}
+
+func TestGmailMerge_FailedSendThatLandedIsNotResent(t *testing.T) {
+	fake := &fakeGmailMergeServer{existing: map[string]bool{}, landOn: "Hello Bob"}
+	useFakeGmailMergeServer(t, fake)
+
+	_, data, body := writeMergeFixture(t)
+	args := []string{"--account", "me@example.com", "gmail", "merge",
+		"--data", data, "--subject-template", "Hi {{.name}}", "--body-template", body, "--delay", "0s"}
+
+	_ = captureStderr(t, func() {
+		if err := Execute(args); err == nil || !strings.Contains(err.Error(), "row 2") {
+			t.Fatalf("expected failure on row 2, got %v", err)
+		}
+	})
+	state, err := readGmailMergeState(data + gmailMergeStateSuffix)
+	if err != nil {
+		t.Fatalf("readGmailMergeState: %v", err)
+	}
+	if bob := state["2:bob@example.com"]; bob.Status != gmailMergeStatusFailed || bob.MessageID == "" {
+		t.Fatalf("unexpected state %+v", bob)
+	}
+
+	fake.landOn = ""
+	fake.sent = nil
+	_ = captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute(args); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+		})
+	})
+	if len(fake.sent) != 1 || !strings.Contains(fake.sent[0], "Hello Cat") {
+		t.Fatalf("expected only row 3 to be sent, got %d sends", len(fake.sent))
+	}
+	state, err = readGmailMergeState(data + gmailMergeStateSuffix)
+	if err != nil {
+		t.Fatalf("readGmailMergeState: %v", err)
+	}
+	if state["2:bob@example.com"].Status != gmailMergeStatusSent {
+		t.Fatalf("expected row 2 to be marked sent, got %+v", state["2:bob@example.com"])
+	}
+}
+
+func TestGmailMerge_PreviewAndValidation(t *testing.T) {
+	dir, data, body := writeMergeFixture(t)
+	html := filepath.Join(dir, "body.html")
+	if err := os.WriteFile(html, []byte("<p>Hi {{.name}}</p>"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) {
+		t.Fatal("preview must not call Gmail")
+		return nil, nil
+	}
+
+	out := filepath.Join(dir, "preview")
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "merge", "--data", data,
+			"--subject-template", "Hi {{.name}}", "--body-template", body, "--html-template", html, "--preview-dir", out}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+	})
+	raw, err := os.ReadFile(filepath.Join(out, "0002-bob_example_com.eml"))
+	if err != nil {
+		t.Fatalf("read preview: %v", err)
+	}
+	if !strings.Contains(string(raw), "Subject: Hi Bob") || !strings.Contains(string(raw), "<p>Hi Bob</p>") {
+		t.Fatalf("unexpected preview:\n%s", raw)
+	}
+
+	for _, extra := range [][]string{
+		{"--subject-template", "Hi {{.nickname}}", "--body-template", body},
+		{"--subject-template", "Hi"},
+		{"--subject-template", "Hi", "--body-template", body, "--track"},
+	} {
+		args := append([]string{"--account", "me@example.com", "gmail", "merge", "--data", data, "--preview-dir", out}, extra...)
+		if err := Execute(args); err == nil {
+			t.Fatalf("expected error for %v", extra)
+		}
+	}
+}
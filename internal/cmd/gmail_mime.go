@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"mime"
+	"mime/quotedprintable"
 	"net/mail"
 	"net/url"
 	"os"
@@ -16,10 +17,18 @@ import (
 )
 
 type mailAttachment struct {
-	Path     string
-	Filename string
-	MIMEType string
-	Data     []byte
+	Path      string
+	Filename  string
+	MIMEType  string
+	Data      []byte
+	ContentID string // inline parts only; referenced from HTML as cid:<ContentID>
+}
+
+// mailCalendarPart is an iMIP (RFC 6047) calendar object sent as a
+// text/calendar alternative so mail clients render accept/decline buttons.
+type mailCalendarPart struct {
+	Method string
+	Data   string
 }
 
 type rfc822Config struct {
@@ -39,6 +48,10 @@ type mailOptions struct {
 	References        string
 	AdditionalHeaders map[string]string
 	Attachments       []mailAttachment
+	InlineImages      []mailAttachment
+	Calendar          *mailCalendarPart
+	SMIME             *mailSMIME
+	PGP               *mailPGP
 }
 
 func buildRFC822(opts mailOptions, cfg *rfc822Config) ([]byte, error) {
@@ -117,97 +130,186 @@ func buildRFC822(opts mailOptions, cfg *rfc822Config) ([]byte, error) {
 
 	plainBody := normalizeCRLF(opts.Body)
 	htmlBody := normalizeCRLF(opts.BodyHTML)
-	hasPlain := strings.TrimSpace(plainBody) != ""
-	hasHTML := strings.TrimSpace(htmlBody) != ""
+	if len(opts.InlineImages) > 0 && strings.TrimSpace(htmlBody) == "" {
+		return nil, errors.New("inline images require an HTML body")
+	}
 
-	if len(opts.Attachments) == 0 {
-		switch {
-		case hasPlain && hasHTML:
-			altBoundary, err := randomBoundary()
-			if err != nil {
-				return nil, err
-			}
-			writeHeader(&b, "Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", altBoundary))
-			b.WriteString("\r\n")
-
-			writeTextPart(&b, altBoundary, "text/plain; charset=\"utf-8\"", plainBody)
-			writeTextPart(&b, altBoundary, "text/html; charset=\"utf-8\"", htmlBody)
-			b.WriteString(fmt.Sprintf("--%s--\r\n", altBoundary))
-			return b.Bytes(), nil
-		case hasHTML && !hasPlain:
-			writeHeader(&b, "Content-Type", "text/html; charset=\"utf-8\"")
-			writeHeader(&b, "Content-Transfer-Encoding", "7bit")
-			b.WriteString("\r\n")
-			writeBodyWithTrailingCRLF(&b, htmlBody)
-			return b.Bytes(), nil
-		default:
-			writeHeader(&b, "Content-Type", "text/plain; charset=\"utf-8\"")
-			writeHeader(&b, "Content-Transfer-Encoding", "7bit")
-			b.WriteString("\r\n")
-			writeBodyWithTrailingCRLF(&b, plainBody)
-			return b.Bytes(), nil
+	// The body entity (Content-Type onwards) is built separately so it can be
+	// signed and/or encrypted as a unit.
+	var e bytes.Buffer
+	signed := (opts.SMIME != nil && opts.SMIME.Signer != nil) || (opts.PGP != nil && opts.PGP.Signer != nil)
+	if err := writeBodyEntity(&e, opts, plainBody, htmlBody, signed); err != nil {
+		return nil, err
+	}
+	entity := e.Bytes()
+	if opts.SMIME != nil {
+		protected, err := opts.SMIME.protect(entity)
+		if err != nil {
+			return nil, err
+		}
+		entity = protected
+	}
+	if opts.PGP != nil {
+		protected, err := opts.PGP.protect(entity)
+		if err != nil {
+			return nil, err
 		}
+		entity = protected
+	}
+	b.Write(entity)
+	return b.Bytes(), nil
+}
+
+func writeBodyEntity(b *bytes.Buffer, opts mailOptions, plainBody, htmlBody string, signed bool) error {
+	if len(opts.Attachments) == 0 {
+		return writeMessageBody(b, plainBody, htmlBody, opts.InlineImages, opts.Calendar, signed)
 	}
 
 	mixedBoundary, err := randomBoundary()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	writeHeader(&b, "Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", mixedBoundary))
+	writeHeader(b, "Content-Type", fmt.Sprintf("multipart/mixed; boundary=%q", mixedBoundary))
 	b.WriteString("\r\n")
 
 	// Body part
 	b.WriteString(fmt.Sprintf("--%s\r\n", mixedBoundary))
+	if err := writeMessageBody(b, plainBody, htmlBody, opts.InlineImages, opts.Calendar, signed); err != nil {
+		return err
+	}
+
+	// Attachments
+	for _, a := range opts.Attachments {
+		a, err := loadMailAttachment(a)
+		if err != nil {
+			return err
+		}
+
+		b.WriteString(fmt.Sprintf("\r\n--%s\r\n", mixedBoundary))
+		b.WriteString(fmt.Sprintf("Content-Type: %s\r\n", a.MIMEType))
+		if strings.EqualFold(a.MIMEType, "message/rfc822") {
+			// RFC 2046 forbids base64 for message/rfc822; embed the message as-is.
+			encoding := "7bit"
+			if !isASCII(string(a.Data)) {
+				encoding = "8bit"
+			}
+			b.WriteString(fmt.Sprintf("Content-Transfer-Encoding: %s\r\n", encoding))
+			b.WriteString(fmt.Sprintf("Content-Disposition: attachment; %s\r\n\r\n", contentDispositionFilename(a.Filename)))
+			writeBodyWithTrailingCRLF(b, normalizeCRLF(string(a.Data)))
+			continue
+		}
+		b.WriteString("Content-Transfer-Encoding: base64\r\n")
+		b.WriteString(fmt.Sprintf("Content-Disposition: attachment; %s\r\n\r\n", contentDispositionFilename(a.Filename)))
+		b.WriteString(wrapBase64(a.Data))
+		b.WriteString("\r\n")
+	}
+
+	b.WriteString(fmt.Sprintf("--%s--\r\n", mixedBoundary))
+	return nil
+}
+
+// writeMessageBody writes the Content-Type header and content of the message
+// text: text/plain, text/html, or multipart/alternative with both (plus a
+// calendar part for invites). HTML that references inline images is wrapped
+// in multipart/related with those parts. Text in a signed entity is kept
+// 7-bit clean (see writeTextBody).
+func writeMessageBody(b *bytes.Buffer, plainBody, htmlBody string, inline []mailAttachment, calendar *mailCalendarPart, signed bool) error {
+	hasPlain := strings.TrimSpace(plainBody) != ""
+	hasHTML := strings.TrimSpace(htmlBody) != ""
+
 	switch {
-	case hasPlain && hasHTML:
+	case (hasPlain && hasHTML) || calendar != nil:
 		altBoundary, err := randomBoundary()
 		if err != nil {
-			return nil, err
+			return err
+		}
+		writeHeader(b, "Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", altBoundary))
+		b.WriteString("\r\n")
+
+		if hasPlain || !hasHTML {
+			writeTextPart(b, altBoundary, "text/plain; charset=\"utf-8\"", plainBody, signed)
+		}
+		if hasHTML {
+			if len(inline) > 0 {
+				_, _ = fmt.Fprintf(b, "--%s\r\n", altBoundary)
+				if err := writeRelatedBody(b, htmlBody, inline, signed); err != nil {
+					return err
+				}
+			} else {
+				writeTextPart(b, altBoundary, "text/html; charset=\"utf-8\"", htmlBody, signed)
+			}
+		}
+		if calendar != nil {
+			method := strings.ToUpper(strings.TrimSpace(calendar.Method))
+			if method == "" || strings.ContainsAny(method, "\"; \r\n") {
+				return fmt.Errorf("invalid calendar method %q", calendar.Method)
+			}
+			writeTextPart(b, altBoundary, fmt.Sprintf("text/calendar; charset=\"utf-8\"; method=%s", method), normalizeCRLF(calendar.Data), signed)
 		}
-		b.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q\r\n\r\n", altBoundary))
-		writeTextPart(&b, altBoundary, "text/plain; charset=\"utf-8\"", plainBody)
-		writeTextPart(&b, altBoundary, "text/html; charset=\"utf-8\"", htmlBody)
 		b.WriteString(fmt.Sprintf("--%s--\r\n", altBoundary))
 	case hasHTML && !hasPlain:
-		b.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
-		b.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
-		writeBodyWithTrailingCRLF(&b, htmlBody)
+		if len(inline) > 0 {
+			return writeRelatedBody(b, htmlBody, inline, signed)
+		}
+		writeHeader(b, "Content-Type", "text/html; charset=\"utf-8\"")
+		writeTextBody(b, htmlBody, signed)
 	default:
-		b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
-		b.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
-		writeBodyWithTrailingCRLF(&b, plainBody)
+		writeHeader(b, "Content-Type", "text/plain; charset=\"utf-8\"")
+		writeTextBody(b, plainBody, signed)
 	}
+	return nil
+}
 
-	// Attachments
-	for _, a := range opts.Attachments {
-		if a.Filename == "" {
-			a.Filename = filepath.Base(a.Path)
-		}
-		if a.MIMEType == "" {
-			a.MIMEType = mime.TypeByExtension(strings.ToLower(filepath.Ext(a.Filename)))
-			if a.MIMEType == "" {
-				a.MIMEType = "application/octet-stream"
-			}
+func writeRelatedBody(b *bytes.Buffer, htmlBody string, inline []mailAttachment, signed bool) error {
+	relBoundary, err := randomBoundary()
+	if err != nil {
+		return err
+	}
+	writeHeader(b, "Content-Type", fmt.Sprintf("multipart/related; type=\"text/html\"; boundary=%q", relBoundary))
+	b.WriteString("\r\n")
+
+	writeTextPart(b, relBoundary, "text/html; charset=\"utf-8\"", htmlBody, signed)
+	for _, img := range inline {
+		img, err := loadMailAttachment(img)
+		if err != nil {
+			return err
 		}
-		if len(a.Data) == 0 {
-			data, err := os.ReadFile(a.Path)
-			if err != nil {
-				return nil, err
-			}
-			a.Data = data
+		if err := validateHeaderValue(img.ContentID); err != nil || strings.TrimSpace(img.ContentID) == "" {
+			return fmt.Errorf("invalid Content-ID for inline part %q", img.Filename)
 		}
 
-		b.WriteString(fmt.Sprintf("\r\n--%s\r\n", mixedBoundary))
-		b.WriteString(fmt.Sprintf("Content-Type: %s\r\n", a.MIMEType))
+		_, _ = fmt.Fprintf(b, "--%s\r\n", relBoundary)
+		_, _ = fmt.Fprintf(b, "Content-Type: %s\r\n", img.MIMEType)
 		b.WriteString("Content-Transfer-Encoding: base64\r\n")
-		b.WriteString(fmt.Sprintf("Content-Disposition: attachment; %s\r\n\r\n", contentDispositionFilename(a.Filename)))
-		b.WriteString(wrapBase64(a.Data))
+		_, _ = fmt.Fprintf(b, "Content-ID: <%s>\r\n", img.ContentID)
+		_, _ = fmt.Fprintf(b, "Content-Disposition: inline; %s\r\n\r\n", contentDispositionFilename(img.Filename))
+		b.WriteString(wrapBase64(img.Data))
 		b.WriteString("\r\n")
 	}
+	b.WriteString(fmt.Sprintf("--%s--\r\n", relBoundary))
+	return nil
+}
 
-	b.WriteString(fmt.Sprintf("--%s--\r\n", mixedBoundary))
-	return b.Bytes(), nil
+// loadMailAttachment fills in the filename, MIME type, and data of a.
+func loadMailAttachment(a mailAttachment) (mailAttachment, error) {
+	if a.Filename == "" {
+		a.Filename = filepath.Base(a.Path)
+	}
+	if a.MIMEType == "" {
+		a.MIMEType = mime.TypeByExtension(strings.ToLower(filepath.Ext(a.Filename)))
+		if a.MIMEType == "" {
+			a.MIMEType = "application/octet-stream"
+		}
+	}
+	if len(a.Data) == 0 {
+		data, err := os.ReadFile(a.Path)
+		if err != nil {
+			return a, err
+		}
+		a.Data = data
+	}
+	return a, nil
 }
 
 func writeHeader(b *bytes.Buffer, name, value string) {
@@ -289,11 +391,44 @@ func writeBodyWithTrailingCRLF(b *bytes.Buffer, body string) {
 	}
 }
 
-func writeTextPart(b *bytes.Buffer, boundary string, contentType string, body string) {
+func writeTextPart(b *bytes.Buffer, boundary string, contentType string, body string, signed bool) {
 	_, _ = fmt.Fprintf(b, "--%s\r\n", boundary)
 	_, _ = fmt.Fprintf(b, "Content-Type: %s\r\n", contentType)
-	b.WriteString("Content-Transfer-Encoding: 7bit\r\n\r\n")
-	writeBodyWithTrailingCRLF(b, body)
+	writeTextBody(b, body, signed)
+}
+
+// writeTextBody writes the Content-Transfer-Encoding header and body of a
+// text part. Signed content must reach the verifier byte for byte (RFC 1847,
+// RFC 3156 section 3), so when signed is set, text that relays could rewrite
+// (8-bit characters, lines over 998 octets, trailing whitespace) is sent
+// quoted-printable instead.
+func writeTextBody(b *bytes.Buffer, body string, signed bool) {
+	if !signed || is7BitClean(body) {
+		writeHeader(b, "Content-Transfer-Encoding", "7bit")
+		b.WriteString("\r\n")
+		writeBodyWithTrailingCRLF(b, body)
+		return
+	}
+	var qp bytes.Buffer
+	w := quotedprintable.NewWriter(&qp)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+	writeHeader(b, "Content-Transfer-Encoding", "quoted-printable")
+	b.WriteString("\r\n")
+	writeBodyWithTrailingCRLF(b, qp.String())
+}
+
+// is7BitClean reports whether CRLF-normalized text survives any relay as is.
+func is7BitClean(s string) bool {
+	if !isASCII(s) {
+		return false
+	}
+	for _, line := range strings.Split(s, "\r\n") {
+		if len(line) > 998 || strings.HasSuffix(line, " ") || strings.HasSuffix(line, "\t") {
+			return false
+		}
+	}
+	return true
 }
 
 func randomBoundary() (string, error) {
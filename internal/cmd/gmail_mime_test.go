@@ -352,3 +352,45 @@ func TestFormatAddressHeadersParsesCommaSeparatedList(t *testing.T) {
 		t.Fatalf("expected both addresses in output, got %q", got)
 	}
 }
+
+func TestBuildRFC822InlineImagesRelated(t *testing.T) {
+	raw, err := buildRFC822(mailOptions{
+		From:     "a@b.com",
+		To:       []string{"c@d.com"},
+		Subject:  "Hi",
+		Body:     "Plain",
+		BodyHTML: `<p><img src="cid:img1@gogcli"></p>`,
+		InlineImages: []mailAttachment{
+			{Filename: "logo.png", MIMEType: "image/png", Data: []byte("png"), ContentID: "img1@gogcli"},
+		},
+		Attachments: []mailAttachment{
+			{Filename: "x.txt", MIMEType: "text/plain", Data: []byte("abc")},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s := string(raw)
+	mixed := strings.Index(s, "multipart/mixed")
+	alt := strings.Index(s, "multipart/alternative")
+	related := strings.Index(s, "multipart/related")
+	if mixed < 0 || alt < mixed || related < alt {
+		t.Fatalf("expected mixed > alternative > related nesting: %q", s)
+	}
+	if !strings.Contains(s, "Content-ID: <img1@gogcli>\r\nContent-Disposition: inline; filename=\"logo.png\"") {
+		t.Fatalf("missing inline part headers: %q", s)
+	}
+	if strings.Index(s, "Content-ID:") > strings.Index(s, "filename=\"x.txt\"") {
+		t.Fatalf("inline image should precede attachments: %q", s)
+	}
+
+	if _, err := buildRFC822(mailOptions{
+		From:         "a@b.com",
+		To:           []string{"c@d.com"},
+		Subject:      "Hi",
+		Body:         "Plain",
+		InlineImages: []mailAttachment{{Filename: "logo.png", Data: []byte("png"), ContentID: "x@y"}},
+	}, nil); err == nil {
+		t.Fatalf("expected error for inline images without HTML")
+	}
+}
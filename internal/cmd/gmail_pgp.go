@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/pgp"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	pgpKeyringEnv    = "GOG_PGP_KEYRING"
+	pgpPassphraseEnv = "GOG_PGP_PASSPHRASE" //nolint:gosec // env var name, not a credential
+	pgpMaxDepth      = 4
+)
+
+// resolvePGPKeyringPath picks --pgp-keyring, then $GOG_PGP_KEYRING, then the
+// default file in the config dir.
+func resolvePGPKeyringPath(path string) (string, error) {
+	if p := strings.TrimSpace(path); p != "" {
+		return config.ExpandPath(p)
+	}
+	if p := strings.TrimSpace(os.Getenv(pgpKeyringEnv)); p != "" {
+		return config.ExpandPath(p)
+	}
+	return config.PGPKeyringPath()
+}
+
+func loadPGPKeyring(path string) (*pgp.Keyring, error) {
+	resolved, err := resolvePGPKeyringPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, statErr := os.Stat(resolved); errors.Is(statErr, os.ErrNotExist) {
+		return nil, usagef("no OpenPGP keyring at %s; export one with `gpg --export-secret-keys --armor you@example.com > %s` (append recipients with `gpg --export --armor`), or pass --pgp-keyring", resolved, resolved)
+	}
+	return pgp.LoadKeyring(resolved)
+}
+
+// pgpPassphrase returns the passphrase for a locked key from
+// $GOG_PGP_PASSPHRASE or an interactive prompt.
+func pgpPassphrase(flags *RootFlags) func(*pgp.Key) (string, error) {
+	return func(key *pgp.Key) (string, error) {
+		if v, ok := os.LookupEnv(pgpPassphraseEnv); ok {
+			return v, nil
+		}
+		if (flags != nil && flags.NoInput) || !passphraseIsTTY() {
+			return "", usagef("OpenPGP key %s is passphrase-protected; set %s", key.Fingerprint(), pgpPassphraseEnv)
+		}
+		passphrase, err := readPassphrase(fmt.Sprintf("Passphrase for OpenPGP key %s: ", key.Fingerprint()))
+		if err != nil {
+			return "", fmt.Errorf("read passphrase: %w", err)
+		}
+		return passphrase, nil
+	}
+}
+
+// mailPGP signs and/or encrypts the body entity of an outgoing message as
+// PGP/MIME (RFC 3156): multipart/signed with a detached signature, then
+// multipart/encrypted.
+type mailPGP struct {
+	Signer     *pgp.Key
+	Recipients []*pgp.Key
+}
+
+func (p *mailPGP) protect(entity []byte) ([]byte, error) {
+	if p.Signer != nil {
+		signed, err := pgpSignEntity(entity, p.Signer)
+		if err != nil {
+			return nil, err
+		}
+		entity = signed
+	}
+	if len(p.Recipients) > 0 {
+		armored, err := pgp.Encrypt(entity, p.Recipients)
+		if err != nil {
+			return nil, fmt.Errorf("OpenPGP encrypt: %w", err)
+		}
+		boundary, err := randomBoundary()
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		writeHeader(&b, "Content-Type", fmt.Sprintf("multipart/encrypted; protocol=\"application/pgp-encrypted\"; boundary=%q", boundary))
+		b.WriteString("\r\n")
+		_, _ = fmt.Fprintf(&b, "--%s\r\n", boundary)
+		writeHeader(&b, "Content-Type", "application/pgp-encrypted")
+		writeHeader(&b, "Content-Description", "PGP/MIME version identification")
+		b.WriteString("\r\nVersion: 1\r\n\r\n")
+		_, _ = fmt.Fprintf(&b, "--%s\r\n", boundary)
+		writeHeader(&b, "Content-Type", `application/octet-stream; name="encrypted.asc"`)
+		writeHeader(&b, "Content-Description", "OpenPGP encrypted message")
+		writeHeader(&b, "Content-Disposition", `inline; filename="encrypted.asc"`)
+		b.WriteString("\r\n")
+		b.WriteString(normalizeCRLF(string(armored)))
+		_, _ = fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+		entity = b.Bytes()
+	}
+	return entity, nil
+}
+
+func pgpSignEntity(entity []byte, signer *pgp.Key) ([]byte, error) {
+	sig, err := pgp.Sign(entity, signer)
+	if err != nil {
+		return nil, fmt.Errorf("OpenPGP sign: %w", err)
+	}
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	writeHeader(&b, "Content-Type", fmt.Sprintf("multipart/signed; micalg=%s; protocol=\"application/pgp-signature\"; boundary=%q", pgp.MicAlg, boundary))
+	b.WriteString("\r\n")
+	_, _ = fmt.Fprintf(&b, "--%s\r\n", boundary)
+	b.Write(entity)
+	_, _ = fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+	writeHeader(&b, "Content-Type", `application/pgp-signature; name="signature.asc"`)
+	writeHeader(&b, "Content-Description", "OpenPGP digital signature")
+	writeHeader(&b, "Content-Disposition", `attachment; filename="signature.asc"`)
+	b.WriteString("\r\n")
+	b.WriteString(normalizeCRLF(string(sig)))
+	_, _ = fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// resolveMailPGP loads the sender's secret key for signing and recipient
+// public keys for encryption from the keyring. The sender's own key is added
+// to encrypted mail (when present) so the Sent copy stays readable.
+func resolveMailPGP(flags *RootFlags, keyringPath, sendingEmail string, sign, encrypt bool, recipients []string) (*mailPGP, error) {
+	keyring, err := loadPGPKeyring(keyringPath)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &mailPGP{}
+	if sign {
+		key, err := keyring.SecretKey(sendingEmail)
+		if errors.Is(err, pgp.ErrNoSecretKey) {
+			return nil, usagef("no OpenPGP secret key for %s in the keyring", sendingEmail)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if key.Locked() {
+			passphrase, err := pgpPassphrase(flags)(key)
+			if err != nil {
+				return nil, err
+			}
+			if err := key.Unlock(passphrase); err != nil {
+				return nil, err
+			}
+		}
+		out.Signer = key
+	}
+	if encrypt {
+		var missing []string
+		addrs := recipientAddresses(recipients)
+		for _, addr := range addrs {
+			key, err := keyring.PublicKey(addr)
+			if errors.Is(err, pgp.ErrNoPublicKey) {
+				missing = append(missing, addr)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out.Recipients = append(out.Recipients, key)
+		}
+		if len(missing) > 0 {
+			return nil, usagef("no OpenPGP public key for %s; add with `gpg --export --armor <addr> >> <keyring>`", strings.Join(missing, ", "))
+		}
+		if self := strings.ToLower(strings.TrimSpace(sendingEmail)); !slices.Contains(addrs, self) {
+			if key, err := keyring.PublicKey(self); err == nil {
+				out.Recipients = append(out.Recipients, key)
+			}
+		}
+	}
+	return out, nil
+}
+
+// pgpReport is what `gmail get --pgp-verify/--decrypt` learned from a
+// PGP/MIME message.
+type pgpReport struct {
+	Encrypted   bool
+	Decrypted   bool
+	Signed      bool
+	Valid       bool
+	Signer      string
+	KeyID       string
+	Fingerprint string
+	Errors      []string
+	Content     *gmail.MessagePart
+	// from is the outer From address a valid signer must match.
+	from string
+}
+
+func isPGPPayload(p *gmail.MessagePart) bool {
+	if p == nil {
+		return false
+	}
+	mediaType, params, err := mime.ParseMediaType(headerValue(p, "Content-Type"))
+	if err != nil {
+		return false
+	}
+	protocol := strings.ToLower(params["protocol"])
+	switch strings.ToLower(mediaType) {
+	case "multipart/encrypted":
+		return protocol == "application/pgp-encrypted"
+	case "multipart/signed":
+		return protocol == "application/pgp-signature"
+	}
+	return false
+}
+
+// inspectPGP verifies signatures in a raw PGP/MIME message and, with decrypt,
+// decrypts it. Problems are recorded in the report rather than returned so
+// the message can still be shown.
+func inspectPGP(raw []byte, keyring *pgp.Keyring, decrypt bool, passphrase func(*pgp.Key) (string, error)) *pgpReport {
+	report := &pgpReport{}
+	entity := []byte(normalizeCRLF(string(raw)))
+	for depth := 0; depth < pgpMaxDepth; depth++ {
+		header, body, err := splitMIMEEntity(entity)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return report
+		}
+		if depth == 0 {
+			if addr, addrErr := mail.ParseAddress(header.Get("From")); addrErr == nil {
+				report.from = strings.ToLower(addr.Address)
+			}
+		}
+		mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		next, err := openPGPLayer(report, mediaType, params, body, keyring, decrypt, passphrase)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return report
+		}
+		if next == nil {
+			break
+		}
+		entity = next
+	}
+	if report.Encrypted && !report.Decrypted {
+		return report
+	}
+	part, err := mimeEntityToPart(entity)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	report.Content = part
+	return report
+}
+
+// openPGPLayer unwraps one signed or encrypted layer and returns the inner
+// entity, or nil when entity is not PGP/MIME (or may not be decrypted).
+func openPGPLayer(report *pgpReport, mediaType string, params map[string]string, body []byte, keyring *pgp.Keyring, decrypt bool, passphrase func(*pgp.Key) (string, error)) ([]byte, error) {
+	switch strings.ToLower(mediaType) {
+	case "multipart/signed":
+		if !strings.EqualFold(params["protocol"], "application/pgp-signature") {
+			return nil, nil
+		}
+		content, sigEntity, err := splitSignedBody(body, params["boundary"])
+		if err != nil {
+			return nil, err
+		}
+		sigHeader, sigBody, err := splitMIMEEntity(sigEntity)
+		if err != nil {
+			return nil, err
+		}
+		report.Signed = true
+		sig, err := keyring.Verify(content, decodeTransferEncoding(sigBody, sigHeader.Get("Content-Transfer-Encoding")))
+		recordPGPSignature(report, sig, err)
+		return content, nil
+	case "multipart/encrypted":
+		if !strings.EqualFold(params["protocol"], "application/pgp-encrypted") {
+			return nil, nil
+		}
+		report.Encrypted = true
+		if !decrypt {
+			return nil, nil
+		}
+		armored, err := pgpEncryptedPayload(body, params["boundary"])
+		if err != nil {
+			return nil, err
+		}
+		msg, err := keyring.Decrypt(armored, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+		report.Decrypted = true
+		if msg.Signed {
+			report.Signed = true
+			recordPGPSignature(report, msg.Signature, msg.SignatureErr)
+		}
+		return []byte(normalizeCRLF(string(msg.Content))), nil
+	}
+	return nil, nil
+}
+
+// pgpEncryptedPayload returns the OpenPGP message from the second part of a
+// multipart/encrypted body.
+func pgpEncryptedPayload(body []byte, boundary string) ([]byte, error) {
+	if boundary == "" {
+		return nil, errors.New("multipart/encrypted without boundary")
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("multipart/encrypted without encrypted part")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse multipart/encrypted: %w", err)
+		}
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if !strings.EqualFold(mediaType, "application/octet-stream") {
+			continue
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, fmt.Errorf("read encrypted part: %w", err)
+		}
+		return decodeTransferEncoding(data, textproto.MIMEHeader(part.Header).Get("Content-Transfer-Encoding")), nil
+	}
+}
+
+func recordPGPSignature(report *pgpReport, sig *pgp.Signature, err error) {
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("signature: %v", err))
+		return
+	}
+	if sig == nil {
+		return
+	}
+	report.Signer = sig.Signer
+	report.KeyID = sig.KeyID
+	report.Fingerprint = sig.Fingerprint
+	// Any key in the keyring verifies, so the key must also belong to the
+	// sender; otherwise a stranger's key could vouch for someone else's mail.
+	switch {
+	case report.from == "":
+		report.Errors = append(report.Errors, "signing key cannot be matched: message has no From address")
+		return
+	case !slices.Contains(sig.Emails, report.from):
+		report.Errors = append(report.Errors, fmt.Sprintf("signing key (%s) does not match From address %s", strings.Join(sig.Emails, ", "), report.from))
+		return
+	}
+	report.Valid = true
+}
+
+func (r *pgpReport) output() map[string]any {
+	out := map[string]any{
+		"encrypted": r.Encrypted,
+		"signed":    r.Signed,
+	}
+	if r.Encrypted {
+		out["decrypted"] = r.Decrypted
+	}
+	if r.Signed {
+		out["signature_valid"] = r.Valid
+		if r.Signer != "" {
+			out["signer"] = r.Signer
+		}
+		if r.KeyID != "" {
+			out["key_id"] = r.KeyID
+		}
+		if r.Fingerprint != "" {
+			out["fingerprint"] = r.Fingerprint
+		}
+	}
+	if len(r.Errors) > 0 {
+		out["errors"] = r.Errors
+	}
+	return out
+}
+
+func (r *pgpReport) print(u *ui.UI) {
+	if r.Encrypted {
+		u.Out().Printf("pgp_encrypted\ttrue")
+		u.Out().Printf("pgp_decrypted\t%t", r.Decrypted)
+	}
+	if r.Signed {
+		u.Out().Printf("pgp_signed\ttrue")
+		u.Out().Printf("pgp_signature_valid\t%t", r.Valid)
+		if r.Signer != "" {
+			u.Out().Printf("pgp_signer\t%s", r.Signer)
+		}
+		if r.Fingerprint != "" {
+			u.Out().Printf("pgp_fingerprint\t%s", r.Fingerprint)
+		}
+	}
+	for _, e := range r.Errors {
+		u.Out().Printf("pgp_error\t%s", e)
+	}
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"google.golang.org/api/gmail/v1"
+)
+
+// writePGPTestKeyring writes a keyring with the secret key for secret and
+// the public keys for public, and returns its path.
+func writePGPTestKeyring(t *testing.T, dir string, secret *openpgp.Entity, public ...*openpgp.Entity) string {
+	t.Helper()
+
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatalf("armor: %v", err)
+	}
+	if err := secret.SerializePrivate(w, nil); err != nil {
+		t.Fatalf("SerializePrivate: %v", err)
+	}
+	_ = w.Close()
+	b.WriteString("\n")
+	for _, e := range public {
+		w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatalf("armor: %v", err)
+		}
+		if err := e.Serialize(w); err != nil {
+			t.Fatalf("Serialize: %v", err)
+		}
+		_ = w.Close()
+		b.WriteString("\n")
+	}
+
+	path := filepath.Join(dir, "keyring.asc")
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		t.Fatalf("write keyring: %v", err)
+	}
+	return path
+}
+
+func TestGmailSendAndGet_PGP(t *testing.T) {
+	me, err := openpgp.NewEntity("Me", "", "me@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	bob, err := openpgp.NewEntity("Bob", "", "bob@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	keyring := writePGPTestKeyring(t, t.TempDir(), me, bob)
+	t.Setenv("GOG_PGP_KEYRING", keyring)
+
+	var sent []byte
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/settings/sendAs"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"sendAs": []map[string]any{}})
+		case strings.HasSuffix(r.URL.Path, "/messages/send"):
+			var body struct {
+				Raw string `json:"raw"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			sent, _ = base64.RawURLEncoding.DecodeString(body.Raw)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "threadId": "t1"})
+		case strings.HasSuffix(r.URL.Path, "/messages/m1") && r.URL.Query().Get("format") == gmailFormatRaw:
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "raw": base64.RawURLEncoding.EncodeToString(sent)})
+		case strings.HasSuffix(r.URL.Path, "/messages/m1"):
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id":       "m1",
+				"threadId": "t1",
+				"payload": map[string]any{
+					"mimeType": "multipart/encrypted",
+					"headers": []map[string]any{
+						{"name": "Content-Type", "value": `multipart/encrypted; protocol="application/pgp-encrypted"; boundary="x"`},
+						{"name": "Subject", "value": "Secret"},
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "send",
+			"--to", "carol@example.com", "--subject", "Secret", "--body", "hi", "--pgp-encrypt"}); err == nil {
+			t.Fatalf("expected missing public key error")
+		}
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "send",
+			"--to", "bob@example.com", "--subject", "Secret", "--body", "hi", "--pgp-sign", "--smime-sign"}); err == nil {
+			t.Fatalf("expected S/MIME + OpenPGP to be rejected")
+		}
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "send",
+			"--to", "bob@example.com", "--bcc", "me@example.com", "--subject", "Secret", "--body", "hi", "--pgp-encrypt"}); err == nil ||
+			!strings.Contains(err.Error(), "--bcc cannot be combined") {
+			t.Fatalf("expected Bcc + encryption to be rejected, got %v", err)
+		}
+	})
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "me@example.com", "gmail", "send",
+			"--to", "Bob <bob@example.com>", "--subject", "Secret", "--body", "top secret",
+			"--pgp-sign", "--pgp-encrypt"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	})
+
+	header, _, err := splitMIMEEntity(sent)
+	if err != nil {
+		t.Fatalf("parse sent message: %v", err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType != "multipart/encrypted" || params["protocol"] != "application/pgp-encrypted" {
+		t.Fatalf("unexpected content type %q", header.Get("Content-Type"))
+	}
+	for _, want := range []string{"\r\nVersion: 1\r\n", "-----BEGIN PGP MESSAGE-----"} {
+		if !strings.Contains(string(sent), want) {
+			t.Fatalf("sent message missing %q:\n%s", want, sent)
+		}
+	}
+	if strings.Contains(string(sent), "top secret") {
+		t.Fatalf("plaintext leaked:\n%s", sent)
+	}
+
+	getPGP := func(args ...string) (string, map[string]any) {
+		t.Helper()
+		out := captureStdout(t, func() {
+			if err := Execute(append([]string{"--json", "--account", "me@example.com", "gmail", "get", "m1"}, args...)); err != nil {
+				t.Fatalf("get: %v", err)
+			}
+		})
+		var got struct {
+			Body string         `json:"body"`
+			PGP  map[string]any `json:"pgp"`
+		}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("json: %v\n%s", err, out)
+		}
+		return got.Body, got.PGP
+	}
+
+	body, report := getPGP("--decrypt")
+	if strings.TrimSpace(body) != "top secret" {
+		t.Fatalf("unexpected body %q", body)
+	}
+	if report["decrypted"] != true || report["signature_valid"] != true || report["signer"] != "me@example.com" {
+		t.Fatalf("unexpected pgp report: %v", report)
+	}
+
+	if _, report = getPGP("--pgp-verify"); report["encrypted"] != true || report["decrypted"] != false {
+		t.Fatalf("expected encrypted-only report without --decrypt: %v", report)
+	}
+	if _, report = getPGP(); report != nil {
+		t.Fatalf("expected no pgp report without flags: %v", report)
+	}
+}
+
+func TestInspectPGP_SignerMustMatchFrom(t *testing.T) {
+	me, err := openpgp.NewEntity("Me", "", "me@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	keyring, err := loadPGPKeyring(writePGPTestKeyring(t, t.TempDir(), me))
+	if err != nil {
+		t.Fatalf("loadPGPKeyring: %v", err)
+	}
+	signer, err := keyring.SecretKey("me@example.com")
+	if err != nil {
+		t.Fatalf("SecretKey: %v", err)
+	}
+	signed, err := pgpSignEntity([]byte("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\nhi\r\n"), signer)
+	if err != nil {
+		t.Fatalf("pgpSignEntity: %v", err)
+	}
+
+	for _, tc := range []struct {
+		from      string
+		wantValid bool
+		wantErr   string
+	}{
+		{from: "Me <ME@example.com>", wantValid: true},
+		{from: "Mallory <mallory@example.com>", wantErr: "does not match From address mallory@example.com"},
+		{wantErr: "message has no From address"},
+	} {
+		raw := signed
+		if tc.from != "" {
+			raw = append([]byte("From: "+tc.from+"\r\n"), signed...)
+		}
+		report := inspectPGP(raw, keyring, false, nil)
+		if !report.Signed || report.Valid != tc.wantValid || report.Signer != "me@example.com" {
+			t.Fatalf("from %q: unexpected report %+v", tc.from, report)
+		}
+		if got := strings.Join(report.Errors, "\n"); tc.wantErr == "" && got != "" || !strings.Contains(got, tc.wantErr) {
+			t.Fatalf("from %q: errors %q, want %q", tc.from, got, tc.wantErr)
+		}
+	}
+}
+
+func TestBuildRFC822_PGPSignedUTF8BodySurvivesRelay(t *testing.T) {
+	me, err := openpgp.NewEntity("Me", "", "me@example.com", nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	keyring, err := loadPGPKeyring(writePGPTestKeyring(t, t.TempDir(), me))
+	if err != nil {
+		t.Fatalf("loadPGPKeyring: %v", err)
+	}
+	signer, err := keyring.SecretKey("me@example.com")
+	if err != nil {
+		t.Fatalf("SecretKey: %v", err)
+	}
+
+	body := "Grüße aus Köln \nzweite Zeile\n" + strings.Repeat("x", 1200)
+	raw, err := buildRFC822(mailOptions{
+		From:    "me@example.com",
+		To:      []string{"bob@example.com"},
+		Subject: "Hallo",
+		Body:    body,
+		PGP:     &mailPGP{Signer: signer},
+	}, nil)
+	if err != nil {
+		t.Fatalf("buildRFC822: %v", err)
+	}
+
+	// A 7-bit relay re-encodes 8-bit text and folds long lines, which breaks
+	// the signature; the signed entity must give it nothing to rewrite.
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if !is7BitClean(line) {
+			t.Fatalf("signed message has a line a relay would rewrite: %q", line)
+		}
+	}
+	if !strings.Contains(string(raw), "Content-Transfer-Encoding: quoted-printable") {
+		t.Fatalf("expected quoted-printable text part:\n%s", raw)
+	}
+
+	report := inspectPGP(raw, keyring, false, nil)
+	if !report.Signed || !report.Valid || len(report.Errors) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if got := strings.TrimSuffix(bestBodyText(report.Content), "\r\n"); got != normalizeCRLF(body) {
+		t.Fatalf("unexpected decoded body %q", got)
+	}
+}
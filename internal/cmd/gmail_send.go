@@ -26,6 +26,8 @@ type GmailSendCmd struct {
 	Body             string   `name:"body" help:"Body (plain text; required unless --body-html is set)"`
 	BodyFile         string   `name:"body-file" help:"Body file path (plain text; '-' for stdin)"`
 	BodyHTML         string   `name:"body-html" help:"Body (HTML; optional)"`
+	BodyMarkdown     string   `name:"body-markdown" help:"Body (Markdown; rendered to HTML with a plain-text alternative, local images embedded inline)"`
+	BodyMarkdownFile string   `name:"body-markdown-file" help:"Markdown body file path ('-' for stdin; image paths relative to the file)"`
 	ReplyToMessageID string   `name:"reply-to-message-id" aliases:"in-reply-to" help:"Reply to Gmail message ID (sets In-Reply-To/References and thread)"`
 	ThreadID         string   `name:"thread-id" help:"Reply within a Gmail thread (uses latest message for headers)"`
 	ReplyAll         bool     `name:"reply-all" help:"Auto-populate recipients from original message (requires --reply-to-message-id or --thread-id)"`
@@ -36,6 +38,13 @@ type GmailSendCmd struct {
 	TrackSplit       bool     `name:"track-split" help:"Send tracked messages separately per recipient"`
 	Quote            bool     `name:"quote" help:"Include quoted original message in reply (requires --reply-to-message-id or --thread-id)"`
 	SendAt           string   `name:"send-at" help:"Schedule send time (RFC3339). Creates a draft since Gmail API lacks native scheduling."`
+	SMIMESign        bool     `name:"smime-sign" help:"Sign with your S/MIME key (see 'gmail smime import')"`
+	SMIMEEncrypt     bool     `name:"smime-encrypt" help:"Encrypt to every To/Cc recipient's S/MIME certificate (see 'gmail smime add-cert'); not with --bcc"`
+	PGPSign          bool     `name:"pgp-sign" help:"Sign with your OpenPGP key (PGP/MIME)"`
+	PGPEncrypt       bool     `name:"pgp-encrypt" help:"Encrypt to every To/Cc recipient's OpenPGP key (PGP/MIME); not with --bcc"`
+	PGPKeyring       string   `name:"pgp-keyring" help:"OpenPGP keyring file with your secret key and recipients' public keys (default: $GOG_PGP_KEYRING, then pgp/keyring.asc in the config dir)"`
+
+	Invite gmailInviteFlags `embed:""`
 }
 
 type sendBatch struct {
@@ -50,18 +59,24 @@ type sendResult struct {
 	MessageID  string
 	ThreadID   string
 	TrackingID string
+	InviteUID  string
 }
 
 type sendMessageOptions struct {
-	FromAddr    string
-	ReplyTo     string
-	Subject     string
-	Body        string
-	BodyHTML    string
-	ReplyInfo   *replyInfo
-	Attachments []mailAttachment
-	Track       bool
-	TrackingCfg *tracking.Config
+	FromAddr          string
+	ReplyTo           string
+	Subject           string
+	Body              string
+	BodyHTML          string
+	ReplyInfo         *replyInfo
+	Attachments       []mailAttachment
+	InlineImages      []mailAttachment
+	Calendar          *mailCalendarPart
+	SMIME             *mailSMIME
+	PGP               *mailPGP
+	AdditionalHeaders map[string]string
+	Track             bool
+	TrackingCfg       *tracking.Config
 }
 
 func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
@@ -74,6 +89,15 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 	if err != nil {
 		return err
 	}
+	bodyHTML := c.BodyHTML
+	var inlineImages []mailAttachment
+	md, useMarkdown, err := resolveMarkdownBodyInput(c.BodyMarkdown, c.BodyMarkdownFile, strings.TrimSpace(body) != "" || strings.TrimSpace(bodyHTML) != "")
+	if err != nil {
+		return err
+	}
+	if useMarkdown {
+		body, bodyHTML, inlineImages = md.Text, md.HTML, md.Images
+	}
 
 	if replyToMessageID != "" && threadID != "" {
 		return usage("use only one of --reply-to-message-id or --thread-id")
@@ -96,14 +120,29 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 	if strings.TrimSpace(c.Subject) == "" {
 		return usage("required: --subject")
 	}
-	if strings.TrimSpace(body) == "" && strings.TrimSpace(c.BodyHTML) == "" {
-		return usage("required: --body, --body-file, or --body-html")
+	if strings.TrimSpace(body) == "" && strings.TrimSpace(bodyHTML) == "" {
+		return usage("required: --body, --body-file, --body-html, or --body-markdown")
 	}
 	if c.TrackSplit && !c.Track {
 		return usage("--track-split requires --track")
 	}
-	if c.Track && strings.TrimSpace(c.BodyHTML) == "" {
-		return fmt.Errorf("--track requires --body-html (pixel must be in HTML)")
+	if c.Track && strings.TrimSpace(bodyHTML) == "" {
+		return fmt.Errorf("--track requires --body-html or --body-markdown (pixel must be in HTML)")
+	}
+
+	if (c.SMIMESign || c.SMIMEEncrypt) && (c.PGPSign || c.PGPEncrypt) {
+		return usage("use either S/MIME (--smime-*) or OpenPGP (--pgp-*), not both")
+	}
+	if (c.SMIMEEncrypt || c.PGPEncrypt) && strings.TrimSpace(c.Bcc) != "" {
+		// Every recipient can list who a message is encrypted to, so a Bcc
+		// recipient's certificate or key would reveal them.
+		return usage("--bcc cannot be combined with --smime-encrypt or --pgp-encrypt; send Bcc recipients a separate message")
+	}
+
+	if c.Invite.enabled() {
+		if _, _, _, inviteErr := c.Invite.schedule(); inviteErr != nil {
+			return inviteErr
+		}
 	}
 
 	attachPaths := make([]string, 0, len(c.Attach))
@@ -126,10 +165,16 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 		"reply_to":            strings.TrimSpace(c.ReplyTo),
 		"from":                strings.TrimSpace(c.From),
 		"body_len":            len(strings.TrimSpace(body)),
-		"body_html_len":       len(strings.TrimSpace(c.BodyHTML)),
+		"body_html_len":       len(strings.TrimSpace(bodyHTML)),
 		"attachments":         attachPaths,
+		"inline_images":       len(inlineImages),
 		"track":               c.Track,
 		"track_split":         c.TrackSplit,
+		"invite":              c.Invite.enabled(),
+		"smime_sign":          c.SMIMESign,
+		"smime_encrypt":       c.SMIMEEncrypt,
+		"pgp_sign":            c.PGPSign,
+		"pgp_encrypt":         c.PGPEncrypt,
 	}); dryRunErr != nil {
 		return dryRunErr
 	}
@@ -144,48 +189,9 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 		return err
 	}
 
-	sendAsList, sendAsListErr := listSendAs(ctx, svc)
-
-	// Determine the From address
-	fromAddr := account
-	sendingEmail := account // The email we're sending from (without display name)
-	if fromEmail := strings.TrimSpace(c.From); fromEmail != "" {
-		// Validate that this is a configured and verified send-as alias.
-		var sa *gmail.SendAs
-		if sendAsListErr == nil {
-			sa = findSendAsByEmail(sendAsList, fromEmail)
-			if sa == nil {
-				return fmt.Errorf("invalid --from address %q: not found in send-as settings", fromEmail)
-			}
-		} else {
-			// Fallback: preserve legacy behavior if we cannot list settings.
-			var getErr error
-			sa, getErr = svc.Users.Settings.SendAs.Get("me", fromEmail).Context(ctx).Do()
-			if getErr != nil {
-				return fmt.Errorf("invalid --from address %q: %w", fromEmail, getErr)
-			}
-		}
-
-		if sa.VerificationStatus != gmailVerificationAccepted {
-			return fmt.Errorf("--from address %q is not verified (status: %s)", fromEmail, sa.VerificationStatus)
-		}
-
-		sendingEmail = fromEmail
-		fromAddr = fromEmail
-
-		if displayName := strings.TrimSpace(sa.DisplayName); displayName != "" {
-			fromAddr = displayName + " <" + fromEmail + ">"
-		}
-	} else {
-		// No --from specified: best-effort look up the primary account's display name.
-		displayName := ""
-		if sendAsListErr == nil {
-			displayName = primaryDisplayNameFromSendAsList(sendAsList, account)
-		}
-		if displayName != "" {
-			fromAddr = displayName + " <" + account + ">"
-		}
-		// If lookup fails, we just use the plain email address (no error)
+	fromAddr, sendingEmail, err := resolveSendFromAddress(ctx, svc, account, c.From)
+	if err != nil {
+		return err
 	}
 
 	// Fetch reply info (includes recipient headers for reply-all, and body for quoting)
@@ -194,7 +200,7 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 		return err
 	}
 
-	body, htmlBody := applyQuoteToBodies(body, c.BodyHTML, c.Quote, replyInfo)
+	body, htmlBody := applyQuoteToBodies(body, bodyHTML, c.Quote, replyInfo)
 
 	// Determine recipients
 	var toRecipients, ccRecipients []string
@@ -218,11 +224,41 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 
 	bccRecipients := splitCSV(c.Bcc)
 
-	atts := make([]mailAttachment, 0, len(attachPaths))
+	atts := make([]mailAttachment, 0, len(attachPaths)+1)
 	for _, p := range attachPaths {
 		atts = append(atts, mailAttachment{Path: p})
 	}
 
+	allRecipients := append(append([]string{}, toRecipients...), ccRecipients...)
+	var smimeOpts *mailSMIME
+	if c.SMIMESign || c.SMIMEEncrypt {
+		smimeOpts, err = resolveMailSMIME(sendingEmail, c.SMIMESign, c.SMIMEEncrypt, allRecipients)
+		if err != nil {
+			return err
+		}
+	}
+	var pgpOpts *mailPGP
+	if c.PGPSign || c.PGPEncrypt {
+		pgpOpts, err = resolveMailPGP(flags, c.PGPKeyring, sendingEmail, c.PGPSign, c.PGPEncrypt, allRecipients)
+		if err != nil {
+			return err
+		}
+	}
+
+	var calendar *mailCalendarPart
+	inviteUID := ""
+	if c.Invite.enabled() {
+		invite, inviteErr := c.Invite.buildInvite(c.Subject, body, sendingEmail, toRecipients, ccRecipients, time.Now())
+		if inviteErr != nil {
+			return inviteErr
+		}
+		ics := invite.ICS()
+		calendar = &mailCalendarPart{Method: invite.Method, Data: ics}
+		// Some clients only look at attachments, so ship the same object as a file too.
+		atts = append(atts, mailAttachment{Filename: "invite.ics", MIMEType: "application/ics", Data: []byte(ics)})
+		inviteUID = invite.UID
+	}
+
 	var trackingCfg *tracking.Config
 	if c.Track {
 		trackingCfg, err = c.resolveTrackingConfig(account, toRecipients, ccRecipients, bccRecipients, htmlBody)
@@ -251,17 +287,21 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 			references = replyInfo.References
 		}
 		raw, err := buildRFC822(mailOptions{
-			From:        fromAddr,
-			To:          batches[0].To,
-			Cc:          batches[0].Cc,
-			Bcc:         batches[0].Bcc,
-			ReplyTo:     c.ReplyTo,
-			Subject:     c.Subject,
-			Body:        body,
-			BodyHTML:    htmlBody,
-			InReplyTo:   inReplyTo,
-			References:  references,
-			Attachments: atts,
+			From:         fromAddr,
+			To:           batches[0].To,
+			Cc:           batches[0].Cc,
+			Bcc:          batches[0].Bcc,
+			ReplyTo:      c.ReplyTo,
+			Subject:      c.Subject,
+			Body:         body,
+			BodyHTML:     htmlBody,
+			InReplyTo:    inReplyTo,
+			References:   references,
+			Attachments:  atts,
+			InlineImages: inlineImages,
+			Calendar:     calendar,
+			SMIME:        smimeOpts,
+			PGP:          pgpOpts,
 		}, nil)
 		if err != nil {
 			return fmt.Errorf("failed to build message: %w", err)
@@ -284,37 +324,100 @@ func (c *GmailSendCmd) Run(ctx context.Context, flags *RootFlags) error {
 		u.Out().Printf("\n⚠️  Gmail API does not support native scheduled send.\n")
 		u.Out().Printf("To send at the scheduled time, use:\n")
 		u.Out().Printf("  gog gmail drafts send %s\n", draft.Id)
+		if inviteUID != "" {
+			u.Out().Printf("Invite UID: %s\n", inviteUID)
+		}
 		u.Out().Printf("\nOr automate with cron/task scheduler.\n")
 
 		if outfmt.IsJSON(ctx) {
-			return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			resp := map[string]any{
 				"draftId":     draft.Id,
 				"scheduledAt": c.SendAt,
 				"command":     fmt.Sprintf("gog gmail drafts send %s", draft.Id),
-			})
+			}
+			if inviteUID != "" {
+				resp["invite_uid"] = inviteUID
+			}
+			return outfmt.WriteJSON(ctx, os.Stdout, resp)
 		}
 
 		return nil
 	}
 
 	results, err := sendGmailBatches(ctx, svc, sendMessageOptions{
-		FromAddr:    fromAddr,
-		ReplyTo:     c.ReplyTo,
-		Subject:     c.Subject,
-		Body:        body,
-		BodyHTML:    htmlBody,
-		ReplyInfo:   replyInfo,
-		Attachments: atts,
-		Track:       c.Track,
-		TrackingCfg: trackingCfg,
+		FromAddr:     fromAddr,
+		ReplyTo:      c.ReplyTo,
+		Subject:      c.Subject,
+		Body:         body,
+		BodyHTML:     htmlBody,
+		ReplyInfo:    replyInfo,
+		Attachments:  atts,
+		InlineImages: inlineImages,
+		Calendar:     calendar,
+		SMIME:        smimeOpts,
+		PGP:          pgpOpts,
+		Track:        c.Track,
+		TrackingCfg:  trackingCfg,
 	}, batches)
 	if err != nil {
 		return err
 	}
+	for i := range results {
+		results[i].InviteUID = inviteUID
+	}
 
 	return writeSendResults(ctx, u, fromAddr, results)
 }
 
+// resolveSendFromAddress returns the From header value and bare sending
+// address for account, validating an explicit send-as alias.
+func resolveSendFromAddress(ctx context.Context, svc *gmail.Service, account string, from string) (fromAddr string, sendingEmail string, err error) {
+	sendAsList, sendAsListErr := listSendAs(ctx, svc)
+
+	fromAddr = account
+	sendingEmail = account // The email we're sending from (without display name)
+	if fromEmail := strings.TrimSpace(from); fromEmail != "" {
+		// Validate that this is a configured and verified send-as alias.
+		var sa *gmail.SendAs
+		if sendAsListErr == nil {
+			sa = findSendAsByEmail(sendAsList, fromEmail)
+			if sa == nil {
+				return "", "", fmt.Errorf("invalid --from address %q: not found in send-as settings", fromEmail)
+			}
+		} else {
+			// Fallback: preserve legacy behavior if we cannot list settings.
+			var getErr error
+			sa, getErr = svc.Users.Settings.SendAs.Get("me", fromEmail).Context(ctx).Do()
+			if getErr != nil {
+				return "", "", fmt.Errorf("invalid --from address %q: %w", fromEmail, getErr)
+			}
+		}
+
+		if sa.VerificationStatus != gmailVerificationAccepted {
+			return "", "", fmt.Errorf("--from address %q is not verified (status: %s)", fromEmail, sa.VerificationStatus)
+		}
+
+		sendingEmail = fromEmail
+		fromAddr = fromEmail
+
+		if displayName := strings.TrimSpace(sa.DisplayName); displayName != "" {
+			fromAddr = displayName + " <" + fromEmail + ">"
+		}
+	} else {
+		// No --from specified: best-effort look up the primary account's display name.
+		displayName := ""
+		if sendAsListErr == nil {
+			displayName = primaryDisplayNameFromSendAsList(sendAsList, account)
+		}
+		if displayName != "" {
+			fromAddr = displayName + " <" + account + ">"
+		}
+		// If lookup fails, we just use the plain email address (no error)
+	}
+
+	return fromAddr, sendingEmail, nil
+}
+
 func (c *GmailSendCmd) resolveTrackingConfig(account string, toRecipients, ccRecipients, bccRecipients []string, htmlBody string) (*tracking.Config, error) {
 	totalRecipients := len(toRecipients) + len(ccRecipients) + len(bccRecipients)
 	if totalRecipients != 1 && !c.TrackSplit {
@@ -440,17 +543,22 @@ func sendGmailBatches(ctx context.Context, svc *gmail.Service, opts sendMessageO
 		}
 
 		raw, err := buildRFC822(mailOptions{
-			From:        opts.FromAddr,
-			To:          batch.To,
-			Cc:          batch.Cc,
-			Bcc:         batch.Bcc,
-			ReplyTo:     opts.ReplyTo,
-			Subject:     opts.Subject,
-			Body:        opts.Body,
-			BodyHTML:    htmlBody,
-			InReplyTo:   reply.InReplyTo,
-			References:  reply.References,
-			Attachments: opts.Attachments,
+			From:              opts.FromAddr,
+			To:                batch.To,
+			Cc:                batch.Cc,
+			Bcc:               batch.Bcc,
+			ReplyTo:           opts.ReplyTo,
+			Subject:           opts.Subject,
+			Body:              opts.Body,
+			BodyHTML:          htmlBody,
+			InReplyTo:         reply.InReplyTo,
+			References:        reply.References,
+			AdditionalHeaders: opts.AdditionalHeaders,
+			Attachments:       opts.Attachments,
+			InlineImages:      opts.InlineImages,
+			Calendar:          opts.Calendar,
+			SMIME:             opts.SMIME,
+			PGP:               opts.PGP,
 		}, nil)
 		if err != nil {
 			return nil, err
@@ -494,6 +602,9 @@ func writeSendResults(ctx context.Context, u *ui.UI, fromAddr string, results []
 			if results[0].TrackingID != "" {
 				resp["tracking_id"] = results[0].TrackingID
 			}
+			if results[0].InviteUID != "" {
+				resp["invite_uid"] = results[0].InviteUID
+			}
 			return outfmt.WriteJSON(ctx, os.Stdout, resp)
 		}
 
@@ -510,6 +621,9 @@ func writeSendResults(ctx context.Context, u *ui.UI, fromAddr string, results []
 			if r.TrackingID != "" {
 				item["tracking_id"] = r.TrackingID
 			}
+			if r.InviteUID != "" {
+				item["invite_uid"] = r.InviteUID
+			}
 			items = append(items, item)
 		}
 		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"messages": items})
@@ -523,6 +637,9 @@ func writeSendResults(ctx context.Context, u *ui.UI, fromAddr string, results []
 		if results[0].TrackingID != "" {
 			u.Out().Printf("tracking_id\t%s", results[0].TrackingID)
 		}
+		if results[0].InviteUID != "" {
+			u.Out().Printf("invite_uid\t%s", results[0].InviteUID)
+		}
 		return nil
 	}
 
@@ -540,6 +657,9 @@ func writeSendResults(ctx context.Context, u *ui.UI, fromAddr string, results []
 		if r.TrackingID != "" {
 			u.Out().Printf("tracking_id\t%s", r.TrackingID)
 		}
+		if r.InviteUID != "" {
+			u.Out().Printf("invite_uid\t%s", r.InviteUID)
+		}
 	}
 
 	return nil
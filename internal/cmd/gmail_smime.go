@@ -0,0 +1,645 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/smime"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	smimePassphraseEnv = "GOG_SMIME_PASSPHRASE" //nolint:gosec // env var name, not a credential
+	smimeMaxDepth      = 4
+)
+
+var (
+	// smimeRoots is the trust anchor for signer chains; nil uses the system pool.
+	smimeRoots *x509.CertPool
+
+	passphraseIsTTY = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+	readPassphrase  = func(prompt string) (string, error) {
+		_, _ = fmt.Fprint(os.Stderr, prompt)
+		b, err := term.ReadPassword(int(os.Stdin.Fd()))
+		_, _ = fmt.Fprintln(os.Stderr)
+		return string(b), err
+	}
+)
+
+type GmailSMIMECmd struct {
+	Import  GmailSMIMEImportCmd  `cmd:"" name:"import" help:"Import your signing/decryption key (PKCS#12, or PEM certificate + key)"`
+	AddCert GmailSMIMEAddCertCmd `cmd:"" name:"add-cert" aliases:"add" help:"Add recipient certificates used for --smime-encrypt"`
+	List    GmailSMIMEListCmd    `cmd:"" name:"list" aliases:"ls" help:"List stored keys and recipient certificates"`
+	Remove  GmailSMIMERemoveCmd  `cmd:"" name:"remove" aliases:"rm,delete" help:"Remove the key and certificate stored for an address"`
+}
+
+type GmailSMIMEImportCmd struct {
+	Path           string `arg:"" name:"file" help:"PKCS#12 (.p12/.pfx) file, or PEM file with certificate (and key)"`
+	Key            string `name:"key" help:"PEM private key file (when not bundled with the certificate)"`
+	Email          string `name:"email" help:"Address to use this key for (default: from the certificate)"`
+	PassphraseFile string `name:"passphrase-file" help:"Read the PKCS#12 passphrase from a file (default: $GOG_SMIME_PASSPHRASE, then prompt)"`
+}
+
+func (c *GmailSMIMEImportCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	path, err := config.ExpandPath(strings.TrimSpace(c.Path))
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path
+	if err != nil {
+		return err
+	}
+	var keyData []byte
+	if strings.TrimSpace(c.Key) != "" {
+		keyPath, err := config.ExpandPath(strings.TrimSpace(c.Key))
+		if err != nil {
+			return err
+		}
+		if keyData, err = os.ReadFile(keyPath); err != nil { //nolint:gosec // user-provided path
+			return err
+		}
+	}
+
+	passphrase := ""
+	if !bytes.Contains(data, []byte("-----BEGIN ")) {
+		if passphrase, err = resolveSMIMEPassphrase(flags, c.PassphraseFile); err != nil {
+			return err
+		}
+	}
+
+	if dryRunErr := dryRunExit(ctx, flags, "gmail.smime.import", map[string]any{
+		"file":  path,
+		"email": strings.TrimSpace(c.Email),
+	}); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	id, err := smime.ImportIdentity(data, keyData, passphrase, c.Email)
+	if err != nil {
+		return err
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"email":     id.Email,
+			"subject":   id.Certificate.Subject.String(),
+			"issuer":    id.Certificate.Issuer.String(),
+			"not_after": id.Certificate.NotAfter.UTC().Format(time.RFC3339),
+			"chain":     len(id.Chain),
+		})
+	}
+	u.Out().Printf("email\t%s", id.Email)
+	u.Out().Printf("subject\t%s", id.Certificate.Subject.String())
+	u.Out().Printf("issuer\t%s", id.Certificate.Issuer.String())
+	u.Out().Printf("not_after\t%s", id.Certificate.NotAfter.UTC().Format(time.RFC3339))
+	return nil
+}
+
+type GmailSMIMEAddCertCmd struct {
+	Paths []string `arg:"" name:"file" help:"Certificate files (PEM or DER)"`
+}
+
+func (c *GmailSMIMEAddCertCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	paths := make([]string, 0, len(c.Paths))
+	for _, p := range c.Paths {
+		expanded, err := config.ExpandPath(strings.TrimSpace(p))
+		if err != nil {
+			return err
+		}
+		paths = append(paths, expanded)
+	}
+	if dryRunErr := dryRunExit(ctx, flags, "gmail.smime.add_cert", map[string]any{"files": paths}); dryRunErr != nil {
+		return dryRunErr
+	}
+
+	var added []smime.Entry
+	for _, p := range paths {
+		data, err := os.ReadFile(p) //nolint:gosec // user-provided path
+		if err != nil {
+			return err
+		}
+		entries, err := smime.AddCertificates(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		added = append(added, entries...)
+	}
+	return writeSMIMEEntries(ctx, u, added)
+}
+
+type GmailSMIMEListCmd struct{}
+
+func (c *GmailSMIMEListCmd) Run(ctx context.Context, _ *RootFlags) error {
+	u := ui.FromContext(ctx)
+	entries, err := smime.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 && !outfmt.IsJSON(ctx) {
+		u.Err().Println("No S/MIME keys or certificates")
+		return nil
+	}
+	return writeSMIMEEntries(ctx, u, entries)
+}
+
+type GmailSMIMERemoveCmd struct {
+	Email string `arg:"" name:"email" help:"Address whose key and certificate to remove"`
+}
+
+func (c *GmailSMIMERemoveCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	email := strings.TrimSpace(c.Email)
+	if email == "" {
+		return usage("empty email")
+	}
+	if err := confirmDestructive(ctx, flags, fmt.Sprintf("remove S/MIME key and certificate for %s", email)); err != nil {
+		return err
+	}
+	removed, err := smime.Remove(email)
+	if err != nil {
+		return err
+	}
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"email": email, "removed": removed})
+	}
+	u.Out().Printf("removed\t%d", removed)
+	return nil
+}
+
+func writeSMIMEEntries(ctx context.Context, u *ui.UI, entries []smime.Entry) error {
+	if outfmt.IsJSON(ctx) {
+		items := make([]map[string]any, 0, len(entries))
+		for _, e := range entries {
+			item := map[string]any{
+				"email":   e.Email,
+				"kind":    e.Kind,
+				"subject": e.Subject,
+				"issuer":  e.Issuer,
+				"path":    e.Path,
+			}
+			if !e.NotAfter.IsZero() {
+				item["not_after"] = e.NotAfter.UTC().Format(time.RFC3339)
+			}
+			items = append(items, item)
+		}
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"entries": items})
+	}
+
+	u.Out().Println("EMAIL\tKIND\tNOT_AFTER\tSUBJECT")
+	for _, e := range entries {
+		notAfter := ""
+		if !e.NotAfter.IsZero() {
+			notAfter = e.NotAfter.UTC().Format("2006-01-02")
+		}
+		u.Out().Printf("%s\t%s\t%s\t%s", e.Email, e.Kind, notAfter, e.Subject)
+	}
+	return nil
+}
+
+func resolveSMIMEPassphrase(flags *RootFlags, passphraseFile string) (string, error) {
+	if p := strings.TrimSpace(passphraseFile); p != "" {
+		expanded, err := config.ExpandPath(p)
+		if err != nil {
+			return "", err
+		}
+		b, err := os.ReadFile(expanded) //nolint:gosec // user-provided path
+		if err != nil {
+			return "", fmt.Errorf("read passphrase file: %w", err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	if v, ok := os.LookupEnv(smimePassphraseEnv); ok {
+		return v, nil
+	}
+	if (flags != nil && flags.NoInput) || !passphraseIsTTY() {
+		// PKCS#12 files may legitimately have an empty passphrase.
+		return "", nil
+	}
+	passphrase, err := readPassphrase("PKCS#12 passphrase: ")
+	if err != nil {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return passphrase, nil
+}
+
+// mailSMIME signs and/or encrypts the body entity of an outgoing message
+// (RFC 8551): multipart/signed with a detached signature, then
+// application/pkcs7-mime enveloped-data.
+type mailSMIME struct {
+	Signer     *smime.Identity
+	Recipients []*x509.Certificate
+}
+
+func (s *mailSMIME) protect(entity []byte) ([]byte, error) {
+	if s.Signer != nil {
+		signed, err := smimeSignEntity(entity, s.Signer)
+		if err != nil {
+			return nil, err
+		}
+		entity = signed
+	}
+	if len(s.Recipients) > 0 {
+		der, err := smime.Encrypt(entity, s.Recipients)
+		if err != nil {
+			return nil, fmt.Errorf("S/MIME encrypt: %w", err)
+		}
+		var b bytes.Buffer
+		writeHeader(&b, "Content-Type", `application/pkcs7-mime; smime-type=enveloped-data; name="smime.p7m"`)
+		writeHeader(&b, "Content-Transfer-Encoding", "base64")
+		writeHeader(&b, "Content-Disposition", `attachment; filename="smime.p7m"`)
+		b.WriteString("\r\n")
+		b.WriteString(wrapBase64(der))
+		b.WriteString("\r\n")
+		entity = b.Bytes()
+	}
+	return entity, nil
+}
+
+func smimeSignEntity(entity []byte, id *smime.Identity) ([]byte, error) {
+	sig, err := smime.Sign(entity, id, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("S/MIME sign: %w", err)
+	}
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	writeHeader(&b, "Content-Type", fmt.Sprintf("multipart/signed; protocol=\"application/pkcs7-signature\"; micalg=sha-256; boundary=%q", boundary))
+	b.WriteString("\r\n")
+	_, _ = fmt.Fprintf(&b, "--%s\r\n", boundary)
+	// The signed entity ends with CRLF; the CRLF before the next delimiter
+	// belongs to the delimiter, not the content.
+	b.Write(entity)
+	_, _ = fmt.Fprintf(&b, "\r\n--%s\r\n", boundary)
+	writeHeader(&b, "Content-Type", `application/pkcs7-signature; name="smime.p7s"`)
+	writeHeader(&b, "Content-Transfer-Encoding", "base64")
+	writeHeader(&b, "Content-Disposition", `attachment; filename="smime.p7s"`)
+	b.WriteString("\r\n")
+	b.WriteString(wrapBase64(sig))
+	_, _ = fmt.Fprintf(&b, "\r\n--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// resolveMailSMIME loads the sender identity for signing and recipient
+// certificates for encryption. The sender's own certificate is added to
+// encrypted mail (when available) so the Sent copy stays readable.
+func resolveMailSMIME(sendingEmail string, sign, encrypt bool, recipients []string) (*mailSMIME, error) {
+	out := &mailSMIME{}
+	if sign {
+		id, err := smime.LoadIdentity(sendingEmail)
+		if errors.Is(err, smime.ErrNotFound) {
+			return nil, usagef("no S/MIME key for %s; add one with `gog gmail smime import`", sendingEmail)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out.Signer = id
+	}
+	if encrypt {
+		var missing []string
+		addrs := recipientAddresses(recipients)
+		for _, addr := range addrs {
+			cert, err := smime.LoadCertificate(addr)
+			if errors.Is(err, smime.ErrNotFound) {
+				missing = append(missing, addr)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			out.Recipients = append(out.Recipients, cert)
+		}
+		if len(missing) > 0 {
+			return nil, usagef("no S/MIME certificate for %s; add with `gog gmail smime add-cert`", strings.Join(missing, ", "))
+		}
+		if self := strings.ToLower(strings.TrimSpace(sendingEmail)); !slices.Contains(addrs, self) {
+			if cert, err := smime.LoadCertificate(self); err == nil {
+				out.Recipients = append(out.Recipients, cert)
+			}
+		}
+	}
+	return out, nil
+}
+
+// recipientAddresses returns the lower-cased, de-duplicated bare addresses
+// of recipients (which may be "Name <addr>" forms).
+func recipientAddresses(recipients []string) []string {
+	var out []string
+	for _, r := range recipients {
+		addr := strings.ToLower(strings.TrimSpace(r))
+		if parsed, err := mail.ParseAddress(r); err == nil {
+			addr = strings.ToLower(parsed.Address)
+		}
+		if addr != "" && !slices.Contains(out, addr) {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+// smimeReport is what `gmail get` learned from an S/MIME message.
+type smimeReport struct {
+	Encrypted bool
+	Decrypted bool
+	Signed    bool
+	Valid     bool
+	Trusted   bool
+	Signer    string
+	Errors    []string
+	// Content is the innermost entity as a message part tree.
+	Content *gmail.MessagePart
+	// from is the outer From address a trusted signer must match.
+	from string
+}
+
+func isSMIMEPayload(p *gmail.MessagePart) bool {
+	if p == nil {
+		return false
+	}
+	switch normalizeMimeType(p.MimeType) {
+	case "application/pkcs7-mime", "application/x-pkcs7-mime":
+		return true
+	case "multipart/signed":
+		_, params, err := mime.ParseMediaType(headerValue(p, "Content-Type"))
+		return err == nil && strings.Contains(strings.ToLower(params["protocol"]), "pkcs7-signature")
+	}
+	return false
+}
+
+// inspectSMIME decrypts (with the stored key for account) and verifies a raw
+// S/MIME message. Problems are recorded in the report rather than returned so
+// the message can still be shown.
+func inspectSMIME(raw []byte, account string) *smimeReport {
+	report := &smimeReport{}
+	entity := []byte(normalizeCRLF(string(raw)))
+	for depth := 0; depth < smimeMaxDepth; depth++ {
+		header, body, err := splitMIMEEntity(entity)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return report
+		}
+		if depth == 0 {
+			if addr, addrErr := mail.ParseAddress(header.Get("From")); addrErr == nil {
+				report.from = strings.ToLower(addr.Address)
+			}
+		}
+		mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+		next, err := openSMIMELayer(report, mediaType, params, header, body, account)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+			return report
+		}
+		if next == nil {
+			break
+		}
+		entity = next
+	}
+	part, err := mimeEntityToPart(entity)
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report
+	}
+	report.Content = part
+	return report
+}
+
+// openSMIMELayer unwraps one signed or encrypted layer and returns the inner
+// entity, or nil when entity is not S/MIME.
+func openSMIMELayer(report *smimeReport, mediaType string, params map[string]string, header textproto.MIMEHeader, body []byte, account string) ([]byte, error) {
+	switch strings.ToLower(mediaType) {
+	case "multipart/signed":
+		content, sigEntity, err := splitSignedBody(body, params["boundary"])
+		if err != nil {
+			return nil, err
+		}
+		sigHeader, sigBody, err := splitMIMEEntity(sigEntity)
+		if err != nil {
+			return nil, err
+		}
+		der := decodeTransferEncoding(sigBody, sigHeader.Get("Content-Transfer-Encoding"))
+		report.Signed = true
+		sig, err := smime.Verify(der, content)
+		recordSMIMESignature(report, sig, err)
+		return content, nil
+	case "application/pkcs7-mime", "application/x-pkcs7-mime":
+		der := decodeTransferEncoding(body, header.Get("Content-Transfer-Encoding"))
+		if strings.EqualFold(params["smime-type"], "signed-data") {
+			report.Signed = true
+			sig, err := smime.Verify(der, nil)
+			recordSMIMESignature(report, sig, err)
+			if err != nil {
+				return nil, err
+			}
+			return sig.Content, nil
+		}
+		report.Encrypted = true
+		id, err := smime.LoadIdentity(account)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decrypt: %w", err)
+		}
+		plain, err := smime.Decrypt(der, id.Certificate, id.Key)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt: %w", err)
+		}
+		report.Decrypted = true
+		return []byte(normalizeCRLF(string(plain))), nil
+	}
+	return nil, nil
+}
+
+func recordSMIMESignature(report *smimeReport, sig *smime.Signature, err error) {
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("signature: %v", err))
+		return
+	}
+	report.Valid = true
+	report.Signer = sig.Signer.Subject.String()
+	emails := smime.CertificateEmails(sig.Signer)
+	if len(emails) > 0 {
+		report.Signer = emails[0]
+	}
+	// The signing time is chosen by the signer, so the chain is checked now.
+	if chainErr := sig.VerifyChain(smimeRoots, time.Now()); chainErr != nil {
+		report.Errors = append(report.Errors, chainErr.Error())
+		return
+	}
+	switch {
+	case report.from == "":
+		report.Errors = append(report.Errors, "signer certificate cannot be matched: message has no From address")
+		return
+	case !slices.Contains(emails, report.from):
+		report.Errors = append(report.Errors, fmt.Sprintf("signer certificate (%s) does not match From address %s", strings.Join(emails, ", "), report.from))
+		return
+	}
+	report.Trusted = true
+}
+
+func (r *smimeReport) output() map[string]any {
+	out := map[string]any{
+		"encrypted": r.Encrypted,
+		"signed":    r.Signed,
+	}
+	if r.Encrypted {
+		out["decrypted"] = r.Decrypted
+	}
+	if r.Signed {
+		out["signature_valid"] = r.Valid
+		out["trusted"] = r.Trusted
+		if r.Signer != "" {
+			out["signer"] = r.Signer
+		}
+	}
+	if len(r.Errors) > 0 {
+		out["errors"] = r.Errors
+	}
+	return out
+}
+
+func (r *smimeReport) print(u *ui.UI) {
+	if r.Encrypted {
+		u.Out().Printf("smime_encrypted\ttrue")
+		u.Out().Printf("smime_decrypted\t%t", r.Decrypted)
+	}
+	if r.Signed {
+		u.Out().Printf("smime_signed\ttrue")
+		u.Out().Printf("smime_signature_valid\t%t", r.Valid)
+		u.Out().Printf("smime_trusted\t%t", r.Trusted)
+		if r.Signer != "" {
+			u.Out().Printf("smime_signer\t%s", r.Signer)
+		}
+	}
+	for _, e := range r.Errors {
+		u.Out().Printf("smime_error\t%s", e)
+	}
+}
+
+func splitMIMEEntity(entity []byte) (textproto.MIMEHeader, []byte, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(entity))
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse MIME entity: %w", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return textproto.MIMEHeader(msg.Header), body, nil
+}
+
+// splitSignedBody returns the exact bytes of the first part of a
+// multipart/signed body (the signed content) and the signature part.
+func splitSignedBody(body []byte, boundary string) (content, signature []byte, err error) {
+	if boundary == "" {
+		return nil, nil, errors.New("multipart/signed without boundary")
+	}
+	delim := []byte("--" + boundary)
+	start := bytes.Index(body, delim)
+	if start < 0 || (start > 0 && !bytes.HasSuffix(body[:start], []byte("\r\n"))) {
+		return nil, nil, errors.New("multipart/signed: missing first boundary")
+	}
+	lineEnd := bytes.Index(body[start:], []byte("\r\n"))
+	if lineEnd < 0 {
+		return nil, nil, errors.New("multipart/signed: truncated")
+	}
+	rest := body[start+lineEnd+2:]
+
+	sep := append([]byte("\r\n"), delim...)
+	end := bytes.Index(rest, sep)
+	if end < 0 {
+		return nil, nil, errors.New("multipart/signed: missing signature part")
+	}
+	content = rest[:end]
+	rest = rest[end+len(sep):]
+	lineEnd = bytes.Index(rest, []byte("\r\n"))
+	if lineEnd < 0 {
+		return nil, nil, errors.New("multipart/signed: truncated")
+	}
+	rest = rest[lineEnd+2:]
+	if end := bytes.Index(rest, sep); end >= 0 {
+		rest = rest[:end]
+	}
+	return content, rest, nil
+}
+
+// mimeEntityToPart converts a raw MIME entity into a Gmail message part tree
+// so the usual body helpers can render decrypted content.
+func mimeEntityToPart(entity []byte) (*gmail.MessagePart, error) {
+	header, body, err := splitMIMEEntity(entity)
+	if err != nil {
+		return nil, err
+	}
+	return mimePartFromHeader(header, body, 0)
+}
+
+func mimePartFromHeader(header textproto.MIMEHeader, body []byte, depth int) (*gmail.MessagePart, error) {
+	part := &gmail.MessagePart{}
+	for name, values := range header {
+		for _, v := range values {
+			part.Headers = append(part.Headers, &gmail.MessagePartHeader{Name: name, Value: v})
+		}
+	}
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	part.MimeType = mediaType
+	if _, dparams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		part.Filename = dparams["filename"]
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") || depth > 16 {
+		part.Body = &gmail.MessagePartBody{
+			Data: base64.URLEncoding.EncodeToString(body),
+			Size: int64(len(body)),
+		}
+		return part, nil
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		p, err := mr.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse multipart: %w", err)
+		}
+		data, err := io.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		child, err := mimePartFromHeader(p.Header, data, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		part.Parts = append(part.Parts, child)
+	}
+	return part, nil
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/smime"
+)
+
+// writeSMIMETestIdentity creates a self-signed certificate and key for email
+// and returns the PEM file paths.
+func writeSMIMETestIdentity(t *testing.T, dir, email string) (certPath, keyPath string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(24 * time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	certPath = filepath.Join(dir, email+".crt")
+	keyPath = filepath.Join(dir, email+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func setupSMIMETestEnv(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	t.Setenv("GOG_KEYRING_BACKEND", "file")
+	t.Setenv("GOG_KEYRING_PASSWORD", "testpass")
+	return home
+}
+
+func TestGmailSMIMECmd_ImportListRemove(t *testing.T) {
+	home := setupSMIMETestEnv(t)
+	meCert, meKey := writeSMIMETestIdentity(t, home, "me@example.com")
+	bobCert, _ := writeSMIMETestIdentity(t, home, "bob@example.com")
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "gmail", "smime", "import", meCert, "--key", meKey}); err != nil {
+			t.Fatalf("import: %v", err)
+		}
+	})
+	if !strings.Contains(out, `"email": "me@example.com"`) {
+		t.Fatalf("unexpected import output: %s", out)
+	}
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"gmail", "smime", "add-cert", bobCert}); err != nil {
+			t.Fatalf("add-cert: %v", err)
+		}
+	})
+
+	out = captureStdout(t, func() {
+		if err := Execute([]string{"--json", "gmail", "smime", "list"}); err != nil {
+			t.Fatalf("list: %v", err)
+		}
+	})
+	var listed struct {
+		Entries []struct {
+			Email string `json:"email"`
+			Kind  string `json:"kind"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal([]byte(out), &listed); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if len(listed.Entries) != 2 || listed.Entries[0].Email != "bob@example.com" || listed.Entries[1].Kind != smime.KindIdentity {
+		t.Fatalf("unexpected entries: %+v", listed.Entries)
+	}
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"--force", "gmail", "smime", "remove", "bob@example.com"}); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+	})
+	if _, err := smime.LoadCertificate("bob@example.com"); err == nil {
+		t.Fatalf("expected bob certificate to be removed")
+	}
+}
+
+func TestGmailSendAndGet_SMIME(t *testing.T) {
+	home := setupSMIMETestEnv(t)
+	meCert, meKey := writeSMIMETestIdentity(t, home, "me@example.com")
+	bobCert, _ := writeSMIMETestIdentity(t, home, "bob@example.com")
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"gmail", "smime", "import", meCert, "--key", meKey}); err != nil {
+			t.Fatalf("import: %v", err)
+		}
+	})
+
+	var sent []byte
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/settings/sendAs"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"sendAs": []map[string]any{}})
+		case strings.HasSuffix(r.URL.Path, "/messages/send"):
+			var body struct {
+				Raw string `json:"raw"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			sent, _ = base64.RawURLEncoding.DecodeString(body.Raw)
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "threadId": "t1"})
+		case strings.HasSuffix(r.URL.Path, "/messages/m1") && r.URL.Query().Get("format") == gmailFormatRaw:
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "raw": base64.RawURLEncoding.EncodeToString(sent)})
+		case strings.HasSuffix(r.URL.Path, "/messages/m1"):
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"id":       "m1",
+				"threadId": "t1",
+				"payload": map[string]any{
+					"mimeType": "application/pkcs7-mime",
+					"headers": []map[string]any{
+						{"name": "Content-Type", "value": `application/pkcs7-mime; smime-type=enveloped-data; name="smime.p7m"`},
+						{"name": "Subject", "value": "Secret"},
+					},
+				},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	t.Cleanup(closeSrv)
+
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	// Encrypting without a certificate for the recipient is a usage error.
+	_ = captureStderr(t, func() {
+		if err := Execute([]string{"--account", "me@example.com", "gmail", "send",
+			"--to", "bob@example.com", "--subject", "Secret", "--body", "hi", "--smime-encrypt"}); err == nil {
+			t.Fatalf("expected missing certificate error")
+		}
+	})
+
+	_ = captureStdout(t, func() {
+		if err := Execute([]string{"gmail", "smime", "add-cert", bobCert}); err != nil {
+			t.Fatalf("add-cert: %v", err)
+		}
+		if err := Execute([]string{"--json", "--account", "me@example.com", "gmail", "send",
+			"--to", "bob@example.com", "--subject", "Secret", "--body", "top secret",
+			"--smime-sign", "--smime-encrypt"}); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	})
+
+	header, _, err := splitMIMEEntity(sent)
+	if err != nil {
+		t.Fatalf("parse sent message: %v", err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType != "application/pkcs7-mime" || params["smime-type"] != "enveloped-data" {
+		t.Fatalf("unexpected content type %q", header.Get("Content-Type"))
+	}
+	if header.Get("Subject") != "Secret" || strings.Contains(string(sent), "top secret") {
+		t.Fatalf("unexpected sent message:\n%s", sent)
+	}
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "me@example.com", "gmail", "get", "m1"}); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	})
+	var got struct {
+		Body  string         `json:"body"`
+		SMIME map[string]any `json:"smime"`
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if strings.TrimSpace(got.Body) != "top secret" {
+		t.Fatalf("unexpected body %q", got.Body)
+	}
+	if got.SMIME["decrypted"] != true || got.SMIME["signature_valid"] != true || got.SMIME["signer"] != "me@example.com" {
+		t.Fatalf("unexpected smime report: %v", got.SMIME)
+	}
+}
+
+func TestRecordSMIMESignature_TrustRequiresFromMatchAndCurrentValidity(t *testing.T) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-48 * time.Hour),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	origRoots := smimeRoots
+	t.Cleanup(func() { smimeRoots = origRoots })
+	smimeRoots = roots
+
+	leaf := func(serial int64, notAfter time.Time) *x509.Certificate {
+		key, keyErr := rsa.GenerateKey(rand.Reader, 2048)
+		if keyErr != nil {
+			t.Fatalf("GenerateKey: %v", keyErr)
+		}
+		der, certErr := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			SerialNumber:   big.NewInt(serial),
+			Subject:        pkix.Name{CommonName: "Alice"},
+			EmailAddresses: []string{"Alice@Example.com"},
+			NotBefore:      time.Now().Add(-24 * time.Hour),
+			NotAfter:       notAfter,
+			KeyUsage:       x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		}, ca, &key.PublicKey, caKey)
+		if certErr != nil {
+			t.Fatalf("CreateCertificate: %v", certErr)
+		}
+		cert, _ := x509.ParseCertificate(der)
+		return cert
+	}
+	valid := leaf(2, time.Now().Add(24*time.Hour))
+	expired := leaf(3, time.Now().Add(-time.Hour))
+
+	for _, tc := range []struct {
+		name    string
+		from    string
+		signer  *x509.Certificate
+		trusted bool
+		errPart string
+	}{
+		{name: "matching sender", from: "alice@example.com", signer: valid, trusted: true},
+		{name: "spoofed sender", from: "ceo@example.com", signer: valid, errPart: "does not match From address ceo@example.com"},
+		{name: "no sender", signer: valid, errPart: "no From address"},
+		{name: "expired signer backdated", from: "alice@example.com", signer: expired, errPart: "verify certificate chain"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := &smimeReport{from: tc.from}
+			sig := &smime.Signature{
+				Signer:       tc.signer,
+				Certificates: []*x509.Certificate{tc.signer},
+				SigningTime:  time.Now().Add(-2 * time.Hour),
+			}
+			recordSMIMESignature(report, sig, nil)
+			if !report.Valid || report.Trusted != tc.trusted || report.Signer != "alice@example.com" {
+				t.Fatalf("unexpected report: %+v", report)
+			}
+			if tc.errPart != "" && (len(report.Errors) != 1 || !strings.Contains(report.Errors[0], tc.errPart)) {
+				t.Fatalf("expected error %q, got %v", tc.errPart, report.Errors)
+			}
+		})
+	}
+}
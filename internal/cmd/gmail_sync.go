@@ -0,0 +1,619 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/mailbox"
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const (
+	gmailSyncLayoutFolders = "folders"
+	gmailSyncLayoutFlat    = "flat"
+
+	gmailSyncModeFull        = "full"
+	gmailSyncModeIncremental = "incremental"
+
+	gmailSyncStateFile      = ".gog-sync.json"
+	gmailSyncArchiveFolder  = "Archive"
+	gmailSyncCheckpointEach = 500
+	gmailFormatMinimal      = "minimal"
+)
+
+var gmailSyncHistoryTypes = []string{"messageAdded", "messageDeleted", "labelAdded", "labelRemoved"}
+
+// gmailSyncSystemFolders maps the system labels that become Maildir folders.
+// Other system labels (UNREAD, STARRED, IMPORTANT, CATEGORY_*) are flags or ignored.
+var gmailSyncSystemFolders = map[string]string{
+	"INBOX": "INBOX",
+	"SENT":  "Sent",
+	"DRAFT": "Drafts",
+	"SPAM":  "Spam",
+	"TRASH": "Trash",
+}
+
+type GmailSyncCmd struct {
+	Maildir          string `name:"maildir" help:"Local Maildir root" required:""`
+	Layout           string `name:"layout" help:"folders: one Maildir per label (hard links for multi-label messages); flat: one Maildir, labels as flags only" enum:"folders,flat" default:"folders"`
+	IncludeSpamTrash bool   `name:"include-spam-trash" help:"Also mirror Spam and Trash"`
+	Full             bool   `name:"full" help:"Force a full resync instead of applying history"`
+}
+
+type gmailSyncState struct {
+	Account          string                       `json:"account"`
+	Layout           string                       `json:"layout"`
+	IncludeSpamTrash bool                         `json:"includeSpamTrash,omitempty"`
+	HistoryID        string                       `json:"historyId"`
+	Folders          map[string]string            `json:"folders,omitempty"`
+	Messages         map[string]*gmailSyncMessage `json:"messages"`
+	UpdatedAtMs      int64                        `json:"updatedAtMs,omitempty"`
+}
+
+type gmailSyncMessage struct {
+	Unique string   `json:"unique"`
+	Labels []string `json:"labels,omitempty"`
+	Files  []string `json:"files"`
+}
+
+type gmailSyncer struct {
+	root             string
+	statePath        string
+	layout           string
+	includeSpamTrash bool
+	state            *gmailSyncState
+	folders          map[string]string
+
+	downloaded int
+	updated    int
+	deleted    int
+}
+
+func (c *GmailSyncCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+
+	root, err := config.ExpandPath(strings.TrimSpace(c.Maildir))
+	if err != nil {
+		return err
+	}
+	if root == "" {
+		return usage("empty --maildir")
+	}
+
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(root, 0o700); err != nil {
+		return fmt.Errorf("create maildir: %w", err)
+	}
+
+	s := &gmailSyncer{
+		root:             root,
+		statePath:        filepath.Join(root, gmailSyncStateFile),
+		layout:           c.Layout,
+		includeSpamTrash: c.IncludeSpamTrash,
+	}
+	if s.state, err = readGmailSyncState(s.statePath); err != nil {
+		return err
+	}
+	if s.state.Account != "" && !strings.EqualFold(s.state.Account, account) {
+		return usagef("%s is synced with %s; use a separate --maildir per account", root, s.state.Account)
+	}
+
+	full := c.Full || s.state.HistoryID == "" ||
+		s.state.Layout != c.Layout || s.state.IncludeSpamTrash != c.IncludeSpamTrash
+	s.state.Account = account
+	s.state.Layout = c.Layout
+	s.state.IncludeSpamTrash = c.IncludeSpamTrash
+
+	svc, err := newGmailService(ctx, account)
+	if err != nil {
+		return err
+	}
+
+	idToName, err := fetchLabelIDToName(svc)
+	if err != nil {
+		return err
+	}
+	s.folders = gmailSyncFolders(idToName)
+
+	mode := gmailSyncModeIncremental
+	if !full {
+		err = s.incremental(ctx, svc)
+		if err != nil && isStaleHistoryError(err) {
+			if !outfmt.IsJSON(ctx) {
+				u.Err().Println("Stored history ID expired; running full resync")
+			}
+			full = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if full {
+		mode = gmailSyncModeFull
+		if err = s.full(ctx, svc); err != nil {
+			return err
+		}
+	}
+
+	s.state.Folders = s.folders
+	if err = s.save(); err != nil {
+		return err
+	}
+
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{
+			"maildir":    root,
+			"mode":       mode,
+			"historyId":  s.state.HistoryID,
+			"downloaded": s.downloaded,
+			"updated":    s.updated,
+			"deleted":    s.deleted,
+			"messages":   len(s.state.Messages),
+		})
+	}
+
+	u.Out().Printf("maildir\t%s", root)
+	u.Out().Printf("mode\t%s", mode)
+	u.Out().Printf("history_id\t%s", s.state.HistoryID)
+	u.Out().Printf("downloaded\t%d", s.downloaded)
+	u.Out().Printf("updated\t%d", s.updated)
+	u.Out().Printf("deleted\t%d", s.deleted)
+	u.Out().Printf("messages\t%d", len(s.state.Messages))
+	return nil
+}
+
+// full lists every message in scope, drops local messages that are gone,
+// refreshes labels of known ones, and downloads the rest.
+func (s *gmailSyncer) full(ctx context.Context, svc *gmail.Service) error {
+	profile, err := svc.Users.GetProfile("me").Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	// Until the full pass completes, a crash must lead to another full pass.
+	s.state.HistoryID = ""
+
+	ids, err := listGmailMessageIDs(ctx, svc, "", s.includeSpamTrash, 0)
+	if err != nil {
+		return err
+	}
+
+	remote := make(map[string]bool, len(ids))
+	var known, missing []string
+	for _, id := range ids {
+		remote[id] = true
+		if s.state.Messages[id] != nil {
+			known = append(known, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	for id := range s.state.Messages {
+		if !remote[id] {
+			if err := s.remove(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	err = fetchGmailMessages(ctx, svc, known, gmailFormatMinimal, func(id string, msg *gmail.Message, _ []byte, err error) error {
+		if isNotFoundAPIError(err) {
+			return s.remove(id)
+		}
+		if err != nil {
+			return err
+		}
+		ok, placeErr := s.update(id, msg.LabelIds)
+		if placeErr != nil {
+			return placeErr
+		}
+		if !ok {
+			missing = append(missing, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.download(ctx, svc, missing); err != nil {
+		return err
+	}
+
+	s.state.HistoryID = formatHistoryID(profile.HistoryId)
+	return nil
+}
+
+// incremental applies History API changes since the stored history ID.
+func (s *gmailSyncer) incremental(ctx context.Context, svc *gmail.Service) error {
+	startID, err := parseHistoryID(s.state.HistoryID)
+	if err != nil {
+		return err
+	}
+
+	// Folder names follow label names; renames do not show up in history.
+	if !gmailSyncFoldersEqual(s.state.Folders, s.folders) {
+		for id, m := range s.state.Messages {
+			if _, err := s.update(id, m.Labels); err != nil {
+				return err
+			}
+		}
+	}
+
+	var (
+		order     []string
+		latest    = map[string][]string{}
+		deleted   = map[string]bool{}
+		historyID uint64
+	)
+	touch := func(msg *gmail.Message, del bool) {
+		if msg == nil || msg.Id == "" {
+			return
+		}
+		if _, ok := latest[msg.Id]; !ok && !deleted[msg.Id] {
+			order = append(order, msg.Id)
+		}
+		if del {
+			deleted[msg.Id] = true
+			delete(latest, msg.Id)
+			return
+		}
+		delete(deleted, msg.Id)
+		latest[msg.Id] = msg.LabelIds
+	}
+
+	pageToken := ""
+	for {
+		call := svc.Users.History.List("me").
+			StartHistoryId(startID).
+			HistoryTypes(gmailSyncHistoryTypes...).
+			MaxResults(500).
+			Context(ctx)
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		resp, err := call.Do()
+		if err != nil {
+			return err
+		}
+		for _, h := range resp.History {
+			if h == nil {
+				continue
+			}
+			for _, a := range h.MessagesAdded {
+				if a != nil {
+					touch(a.Message, false)
+				}
+			}
+			for _, l := range h.LabelsAdded {
+				if l != nil {
+					touch(l.Message, false)
+				}
+			}
+			for _, l := range h.LabelsRemoved {
+				if l != nil {
+					touch(l.Message, false)
+				}
+			}
+			for _, d := range h.MessagesDeleted {
+				if d != nil {
+					touch(d.Message, true)
+				}
+			}
+		}
+		if resp.HistoryId > historyID {
+			historyID = resp.HistoryId
+		}
+		if resp.NextPageToken == "" {
+			break
+		}
+		pageToken = resp.NextPageToken
+	}
+
+	var missing []string
+	for _, id := range order {
+		labels, ok := latest[id]
+		if deleted[id] || !ok || !s.inScope(labels) {
+			if err := s.remove(id); err != nil {
+				return err
+			}
+			continue
+		}
+		placed, err := s.update(id, labels)
+		if err != nil {
+			return err
+		}
+		if !placed {
+			missing = append(missing, id)
+		}
+	}
+
+	if err := s.download(ctx, svc, missing); err != nil {
+		return err
+	}
+
+	if historyID > 0 {
+		s.state.HistoryID = formatHistoryID(historyID)
+	}
+	return nil
+}
+
+func (s *gmailSyncer) download(ctx context.Context, svc *gmail.Service, ids []string) error {
+	return fetchGmailMessages(ctx, svc, ids, gmailFormatRaw, func(id string, msg *gmail.Message, raw []byte, err error) error {
+		if isNotFoundAPIError(err) {
+			return s.remove(id)
+		}
+		if err != nil {
+			return err
+		}
+		if !s.inScope(msg.LabelIds) {
+			return nil
+		}
+		if err := s.deliver(id, msg.LabelIds, raw, gmailInternalDate(msg)); err != nil {
+			return err
+		}
+		s.downloaded++
+		if s.downloaded%gmailSyncCheckpointEach == 0 {
+			return s.save()
+		}
+		return nil
+	})
+}
+
+func (s *gmailSyncer) inScope(labels []string) bool {
+	for _, id := range labels {
+		switch id {
+		case "CHAT":
+			return false
+		case "SPAM", "TRASH":
+			if !s.includeSpamTrash {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// targetFolders returns the Maildir folders (relative to root) a message
+// with the given labels belongs in.
+func (s *gmailSyncer) targetFolders(labels []string) []string {
+	if s.layout == gmailSyncLayoutFlat {
+		return []string{""}
+	}
+
+	seen := map[string]bool{}
+	var out []string
+	for _, id := range labels {
+		// Spam and Trash hide every other label, like in the Gmail UI.
+		if id == "TRASH" || id == "SPAM" {
+			return []string{gmailSyncSystemFolders[id]}
+		}
+		if folder := s.folders[id]; folder != "" && !seen[folder] {
+			seen[folder] = true
+			out = append(out, folder)
+		}
+	}
+	if len(out) == 0 {
+		return []string{gmailSyncArchiveFolder}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (s *gmailSyncer) deliver(id string, labels []string, raw []byte, received time.Time) error {
+	folders := s.targetFolders(labels)
+	dir := mailbox.Maildir(filepath.Join(s.root, folders[0]))
+	if err := dir.Create(); err != nil {
+		return err
+	}
+	name, err := dir.Deliver(id, gmailMaildirFlags(labels), raw, received)
+	if err != nil {
+		return err
+	}
+
+	unique, _ := mailbox.SplitFileName(name)
+	s.state.Messages[id] = &gmailSyncMessage{
+		Unique: unique,
+		Files:  []string{filepath.Join(folders[0], "cur", name)},
+	}
+	_, _, err = s.place(id, labels)
+	return err
+}
+
+// update brings the local copies of a known message in line with labels.
+// It returns false when no local file is left to work from and the message
+// must be downloaded again.
+func (s *gmailSyncer) update(id string, labels []string) (bool, error) {
+	placed, changed, err := s.place(id, labels)
+	if changed {
+		s.updated++
+	}
+	return placed, err
+}
+
+// place moves, links, or renames the local copies of a message so they
+// match labels, and reports whether anything on disk changed.
+func (s *gmailSyncer) place(id string, labels []string) (placed bool, changed bool, err error) {
+	m := s.state.Messages[id]
+	if m == nil {
+		return false, false, nil
+	}
+
+	source := ""
+	for _, f := range m.Files {
+		if _, err := os.Stat(filepath.Join(s.root, f)); err == nil {
+			source = f
+			break
+		}
+	}
+	if source == "" {
+		delete(s.state.Messages, id)
+		return false, false, nil
+	}
+
+	name := mailbox.FileName(m.Unique, gmailMaildirFlags(labels))
+	folders := s.targetFolders(labels)
+	want := make([]string, 0, len(folders))
+	wanted := map[string]bool{}
+	for _, folder := range folders {
+		rel := filepath.Join(folder, "cur", name)
+		want = append(want, rel)
+		wanted[rel] = true
+	}
+
+	for _, rel := range want {
+		if _, err := os.Stat(filepath.Join(s.root, rel)); err == nil {
+			continue
+		}
+		if err := mailbox.Maildir(filepath.Join(s.root, filepath.Dir(filepath.Dir(rel)))).Create(); err != nil {
+			return false, false, err
+		}
+		if err := linkOrCopyFile(filepath.Join(s.root, source), filepath.Join(s.root, rel)); err != nil {
+			return false, false, err
+		}
+		changed = true
+	}
+	for _, rel := range m.Files {
+		if wanted[rel] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.root, rel)); err != nil && !os.IsNotExist(err) {
+			return false, false, err
+		}
+		changed = true
+	}
+
+	m.Files = want
+	m.Labels = append([]string(nil), labels...)
+	sort.Strings(m.Labels)
+	return true, changed, nil
+}
+
+func (s *gmailSyncer) remove(id string) error {
+	m := s.state.Messages[id]
+	if m == nil {
+		return nil
+	}
+	for _, rel := range m.Files {
+		if err := os.Remove(filepath.Join(s.root, rel)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	delete(s.state.Messages, id)
+	s.deleted++
+	return nil
+}
+
+func (s *gmailSyncer) save() error {
+	s.state.UpdatedAtMs = time.Now().UnixMilli()
+	payload, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.statePath, append(payload, '\n'))
+}
+
+func readGmailSyncState(path string) (*gmailSyncState, error) {
+	state := &gmailSyncState{}
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read sync state: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("parse sync state %s: %w", path, err)
+		}
+	}
+	if state.Messages == nil {
+		state.Messages = map[string]*gmailSyncMessage{}
+	}
+	return state, nil
+}
+
+// gmailSyncFolders maps label IDs to Maildir folder paths. User labels keep
+// their hierarchy ("Projects/Alpha" becomes Projects/Alpha/{cur,new,tmp}).
+func gmailSyncFolders(idToName map[string]string) map[string]string {
+	out := map[string]string{}
+	for id, folder := range gmailSyncSystemFolders {
+		out[id] = folder
+	}
+	for id, name := range idToName {
+		if !strings.HasPrefix(id, "Label_") {
+			continue
+		}
+		if folder := gmailSyncFolderName(name); folder != "" {
+			out[id] = folder
+		}
+	}
+	return out
+}
+
+func gmailSyncFolderName(name string) string {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		part = strings.TrimSpace(strings.Map(func(r rune) rune {
+			if r < 0x20 || strings.ContainsRune(`\:*?"<>|`, r) {
+				return '_'
+			}
+			return r
+		}, part))
+		switch {
+		case part == "":
+			continue
+		case strings.HasPrefix(part, "."), part == "cur", part == "new", part == "tmp":
+			part = "_" + part
+		}
+		parts = append(parts, part)
+	}
+	return filepath.Join(parts...)
+}
+
+func gmailSyncFoldersEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src) //nolint:gosec // path inside the sync root
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec // path inside the sync root
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
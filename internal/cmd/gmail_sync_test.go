@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/mailbox"
+)
+
+type fakeGmailSyncServer struct {
+	mu        sync.Mutex
+	labels    map[string][]string
+	historyID string
+	history   []map[string]any
+	stale     bool
+	startIDs  []string
+	rawGets   []string
+}
+
+func (f *fakeGmailSyncServer) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case strings.HasSuffix(path, "/users/me/profile"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"emailAddress": "a@b.com", "historyId": f.historyID})
+		case strings.HasSuffix(path, "/users/me/labels"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": []map[string]any{
+				{"id": "INBOX", "name": "INBOX", "type": "system"},
+				{"id": "Label_1", "name": "Projects/Alpha", "type": "user"},
+			}})
+		case strings.HasSuffix(path, "/users/me/history"):
+			f.startIDs = append(f.startIDs, r.URL.Query().Get("startHistoryId"))
+			if f.stale {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": 404, "message": "Requested entity was not found."}})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"history": f.history, "historyId": f.historyID})
+		case strings.HasSuffix(path, "/users/me/messages"):
+			ids := make([]string, 0, len(f.labels))
+			for id, labels := range f.labels {
+				if r.URL.Query().Get("includeSpamTrash") != "true" && strings.Contains(strings.Join(labels, ","), "TRASH") {
+					continue
+				}
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			msgs := make([]map[string]any, 0, len(ids))
+			for _, id := range ids {
+				msgs = append(msgs, map[string]any{"id": id})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"messages": msgs})
+		case strings.Contains(path, "/users/me/messages/"):
+			id := path[strings.LastIndex(path, "/")+1:]
+			labels, ok := f.labels[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"code": 404, "message": "Not Found"}})
+				return
+			}
+			resp := map[string]any{"id": id, "labelIds": labels, "internalDate": "1700000000000"}
+			if r.URL.Query().Get("format") == "raw" {
+				f.rawGets = append(f.rawGets, id)
+				resp["raw"] = base64.URLEncoding.EncodeToString([]byte("Subject: " + id + "\r\n\r\nbody\r\n"))
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func syncFiles(t *testing.T, root string) []string {
+	t.Helper()
+
+	var out []string
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() && filepath.Base(filepath.Dir(path)) == "cur" {
+			rel, _ := filepath.Rel(root, path)
+			unique, flags := mailbox.SplitFileName(rel)
+			id := strings.Split(unique, ".")[1]
+			out = append(out, filepath.ToSlash(filepath.Dir(rel))+"/"+id+":"+flags)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk: %v", err)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func runGmailSync(t *testing.T, root string, extra ...string) map[string]any {
+	t.Helper()
+
+	args := append([]string{"--json", "--account", "a@b.com", "gmail", "sync", "--maildir", root}, extra...)
+	stdout := captureStdout(t, func() {
+		_ = captureStderr(t, func() {
+			if err := Execute(args); err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+		})
+	})
+	var result map[string]any
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("unmarshal: %v (%q)", err, stdout)
+	}
+	return result
+}
+
+func TestGmailSync_FullIncrementalAndResync(t *testing.T) {
+	fake := &fakeGmailSyncServer{
+		historyID: "100",
+		labels: map[string][]string{
+			"m1": {"INBOX", "UNREAD"},
+			"m2": {"INBOX", "Label_1", "STARRED"},
+			"m4": {"TRASH"},
+		},
+	}
+	svc, closeSrv := newGmailServiceForTest(t, fake.handler(t))
+	t.Cleanup(closeSrv)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	root := filepath.Join(t.TempDir(), "mail")
+
+	result := runGmailSync(t, root)
+	if result["mode"] != gmailSyncModeFull || result["historyId"] != "100" || result["downloaded"] != float64(2) {
+		t.Fatalf("unexpected first sync: %v", result)
+	}
+	want := []string{"INBOX/cur/m1:", "INBOX/cur/m2:FS", "Projects/Alpha/cur/m2:FS"}
+	if got := syncFiles(t, root); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("files after full sync = %v, want %v", got, want)
+	}
+
+	fake.mu.Lock()
+	fake.historyID = "200"
+	fake.labels["m1"] = []string{"INBOX"}
+	fake.labels["m3"] = []string{"Label_1"}
+	delete(fake.labels, "m2")
+	fake.history = []map[string]any{
+		{"id": "150", "labelsRemoved": []map[string]any{{"message": map[string]any{"id": "m1", "labelIds": []string{"INBOX"}}, "labelIds": []string{"UNREAD"}}}},
+		{"id": "160", "messagesAdded": []map[string]any{{"message": map[string]any{"id": "m3", "labelIds": []string{"Label_1"}}}}},
+		{"id": "170", "messagesDeleted": []map[string]any{{"message": map[string]any{"id": "m2"}}}},
+	}
+	fake.rawGets = nil
+	fake.mu.Unlock()
+
+	result = runGmailSync(t, root)
+	if result["mode"] != gmailSyncModeIncremental || result["historyId"] != "200" ||
+		result["downloaded"] != float64(1) || result["updated"] != float64(1) || result["deleted"] != float64(1) {
+		t.Fatalf("unexpected incremental sync: %v", result)
+	}
+	if fake.startIDs[len(fake.startIDs)-1] != "100" {
+		t.Fatalf("expected history from 100, got %v", fake.startIDs)
+	}
+	if strings.Join(fake.rawGets, ",") != "m3" {
+		t.Fatalf("expected only m3 downloaded, got %v", fake.rawGets)
+	}
+	want = []string{"INBOX/cur/m1:S", "Projects/Alpha/cur/m3:S"}
+	if got := syncFiles(t, root); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("files after incremental sync = %v, want %v", got, want)
+	}
+
+	fake.mu.Lock()
+	fake.stale = true
+	fake.historyID = "300"
+	fake.labels["m3"] = []string{"Label_1", "STARRED"}
+	fake.rawGets = nil
+	fake.mu.Unlock()
+
+	result = runGmailSync(t, root)
+	if result["mode"] != gmailSyncModeFull || result["historyId"] != "300" || result["downloaded"] != float64(0) || result["updated"] != float64(1) {
+		t.Fatalf("unexpected resync: %v", result)
+	}
+	if len(fake.rawGets) != 0 {
+		t.Fatalf("expected no re-downloads on resync, got %v", fake.rawGets)
+	}
+	want = []string{"INBOX/cur/m1:S", "Projects/Alpha/cur/m3:FS"}
+	if got := syncFiles(t, root); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("files after resync = %v, want %v", got, want)
+	}
+
+	// Switching layouts moves the existing files without downloading again.
+	result = runGmailSync(t, root, "--layout", "flat")
+	if result["downloaded"] != float64(0) {
+		t.Fatalf("unexpected flat resync: %v", result)
+	}
+	want = []string{"cur/m1:S", "cur/m3:FS"}
+	if got := syncFiles(t, root); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("files after layout change = %v, want %v", got, want)
+	}
+
+	// A message trashed after the last sync disappears through history.
+	fake.mu.Lock()
+	fake.stale = false
+	fake.historyID = "400"
+	fake.history = []map[string]any{
+		{"id": "350", "labelsAdded": []map[string]any{{"message": map[string]any{"id": "m1", "labelIds": []string{"TRASH"}}, "labelIds": []string{"TRASH"}}}},
+	}
+	fake.mu.Unlock()
+
+	result = runGmailSync(t, root, "--layout", "flat")
+	if result["mode"] != gmailSyncModeIncremental || result["deleted"] != float64(1) || result["messages"] != float64(1) {
+		t.Fatalf("unexpected trash sync: %v", result)
+	}
+}
+
+func TestGmailSyncFolderName(t *testing.T) {
+	cases := map[string]string{
+		"Projects/Alpha": filepath.Join("Projects", "Alpha"),
+		"a:b*c":          "a_b_c",
+		"../etc":         filepath.Join("_..", "etc"),
+		".hidden/cur":    filepath.Join("_.hidden", "_cur"),
+		" / ":            "",
+		"Clients//Acme ": filepath.Join("Clients", "Acme"),
+	}
+	for in, want := range cases {
+		if got := gmailSyncFolderName(in); got != want {
+			t.Fatalf("gmailSyncFolderName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
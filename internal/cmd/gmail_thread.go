@@ -20,6 +20,7 @@ import (
 	"google.golang.org/api/gmail/v1"
 
 	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/htmltext"
 	"github.com/steipete/gogcli/internal/outfmt"
 	"github.com/steipete/gogcli/internal/ui"
 )
@@ -54,10 +55,11 @@ type GmailThreadCmd struct {
 }
 
 type GmailThreadGetCmd struct {
-	ThreadID  string        `arg:"" name:"threadId" help:"Thread ID"`
-	Download  bool          `name:"download" help:"Download attachments"`
-	Full      bool          `name:"full" help:"Show full message bodies"`
-	OutputDir OutputDirFlag `embed:""`
+	ThreadID       string        `arg:"" name:"threadId" help:"Thread ID"`
+	Download       bool          `name:"download" help:"Download attachments"`
+	Full           bool          `name:"full" help:"Show full message bodies"`
+	CollapseQuotes bool          `name:"collapse-quotes" help:"Collapse quoted replies in HTML bodies"`
+	OutputDir      OutputDirFlag `embed:""`
 }
 
 func (c *GmailThreadGetCmd) Run(ctx context.Context, flags *RootFlags) error {
@@ -135,13 +137,7 @@ func (c *GmailThreadGetCmd) Run(ctx context.Context, flags *RootFlags) error {
 		u.Out().Printf("Date: %s", headerValue(msg.Payload, "Date"))
 		u.Out().Println("")
 
-		body, isHTML := bestBodyForDisplay(msg.Payload)
-		if body != "" {
-			cleanBody := body
-			if isHTML {
-				// Strip HTML tags for cleaner text output
-				cleanBody = stripHTMLTags(body)
-			}
+		if cleanBody := renderedBodyText(msg.Payload, c.CollapseQuotes); cleanBody != "" {
 			// Limit body preview to avoid overwhelming output
 			// Use runes to avoid breaking multi-byte UTF-8 characters
 			runes := []rune(cleanBody)
@@ -398,6 +394,16 @@ func bestBodyForDisplay(p *gmail.MessagePart) (string, bool) {
 	return html, true
 }
 
+// renderedBodyText returns the message body as plain text, rendering the
+// HTML part when there is no usable text/plain part.
+func renderedBodyText(p *gmail.MessagePart, collapseQuotes bool) string {
+	body, isHTML := bestBodyForDisplay(p)
+	if isHTML {
+		return htmltext.Render(body, htmltext.Options{CollapseQuotes: collapseQuotes})
+	}
+	return body
+}
+
 func findPartBody(p *gmail.MessagePart, mimeType string) string {
 	if p == nil {
 		return ""
@@ -3,9 +3,11 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/idtoken"
 
+	"github.com/steipete/gogcli/internal/config"
 	"github.com/steipete/gogcli/internal/outfmt"
 	"github.com/steipete/gogcli/internal/ui"
 )
@@ -30,6 +33,7 @@ type GmailWatchCmd struct {
 	Renew  GmailWatchRenewCmd  `cmd:"" name:"renew" aliases:"update" help:"Renew Gmail watch using stored config"`
 	Stop   GmailWatchStopCmd   `cmd:"" name:"stop" aliases:"rm,delete" help:"Stop Gmail watch and clear stored state"`
 	Serve  GmailWatchServeCmd  `cmd:"" name:"serve" help:"Run Pub/Sub push handler"`
+	Replay GmailWatchReplayCmd `cmd:"" name:"replay" aliases:"retry" help:"Redeliver queued or dead-lettered hook payloads"`
 }
 
 type GmailWatchStartCmd struct {
@@ -107,9 +111,18 @@ func (c *GmailWatchStartCmd) Run(ctx context.Context, kctx *kong.Context, flags
 	return writeWatchState(ctx, state)
 }
 
-type GmailWatchStatusCmd struct{}
+type GmailWatchStatusCmd struct {
+	All bool `name:"all" help:"Show stored watch state for every account"`
+}
 
 func (c *GmailWatchStatusCmd) Run(ctx context.Context, flags *RootFlags) error {
+	if c.All {
+		states, err := listGmailWatchStates()
+		if err != nil {
+			return err
+		}
+		return writeWatchStates(ctx, states)
+	}
 	account, err := requireAccount(flags)
 	if err != nil {
 		return err
@@ -168,6 +181,7 @@ func (c *GmailWatchRenewCmd) Run(ctx context.Context, flags *RootFlags) error {
 	}
 	if ttl == 0 {
 		updated.RenewAfterMs = state.RenewAfterMs
+		updated.RenewTTLMs = state.RenewTTLMs
 	}
 
 	if err := store.Update(func(s *gmailWatchState) error {
@@ -216,6 +230,7 @@ type GmailWatchServeCmd struct {
 	Bind          string   `name:"bind" help:"Bind address" default:"127.0.0.1"`
 	Port          int      `name:"port" help:"Listen port" default:"8788"`
 	Path          string   `name:"path" help:"Push handler path" default:"/gmail-pubsub"`
+	ProbeAddr     string   `name:"probe-addr" help:"Serve /healthz, /readyz and /metrics without auth on this host:port instead of behind the push auth (also works with --subscription)"`
 	Timezone      string   `name:"timezone" short:"z" help:"Output timezone (IANA name, e.g. America/New_York, UTC). Default: local"`
 	Local         bool     `name:"local" help:"Use local timezone (default behavior, useful to override --timezone)"`
 	VerifyOIDC    bool     `name:"verify-oidc" help:"Verify Pub/Sub OIDC tokens"`
@@ -224,16 +239,27 @@ type GmailWatchServeCmd struct {
 	SharedToken   string   `name:"token" help:"Shared token for x-gog-token or ?token="`
 	HookURL       string   `name:"hook-url" help:"Webhook URL to forward messages"`
 	HookToken     string   `name:"hook-token" help:"Webhook bearer token"`
+	HookSecret    string   `name:"hook-secret" help:"Sign hook payloads with HMAC-SHA256 (X-Gog-Timestamp, X-Gog-Signature headers)"`
+	Routes        string   `name:"routes" help:"Routes file (JSON5) sending matching messages to other hooks"`
+	HookExec      string   `name:"hook-exec" help:"Run a command per new message with the hook payload on stdin and GOG_* metadata env vars (instead of --hook-url)"`
+	ExecLimit     int      `name:"hook-exec-concurrency" help:"Max concurrently running --hook-exec commands" default:"4"`
+	ExecTimeout   string   `name:"hook-exec-timeout" help:"Kill a --hook-exec command after this long (seconds or Go duration)" default:"30s"`
 	IncludeBody   bool     `name:"include-body" help:"Include text/plain body in hook payload"`
 	MaxBytes      int      `name:"max-bytes" help:"Max bytes of body to include" default:"20000"`
 	HistoryTypes  []string `name:"history-types" help:"History types to include (repeatable, comma-separated: messageAdded,messageDeleted,labelAdded,labelRemoved). Default: messageAdded"`
 	ExcludeLabels string   `name:"exclude-labels" help:"List of Gmail label IDs to exclude from hook payload (e.g. SPAM,TRASH,Label_123). Set to empty string to disable." default:"SPAM,TRASH"`
 	SaveHook      bool     `name:"save-hook" help:"Persist hook settings to watch state"`
+	Accounts      []string `name:"accounts" help:"Serve several accounts from one endpoint, routing pushes by emailAddress (repeatable, comma-separated)"`
+	All           bool     `name:"all" help:"Serve every account with stored watch state"`
+	Subscription  string   `name:"subscription" help:"Pull notifications from this Pub/Sub subscription (projects/<p>/subscriptions/<s>) instead of serving push requests; honors PUBSUB_EMULATOR_HOST"`
+	PullMax       int64    `name:"pull-max" help:"Max messages per Pub/Sub pull" default:"10"`
+	AutoRenew     bool     `name:"auto-renew" help:"Renew the Gmail watch when renewAfterMs passes (or an hour before expiry) (default: true)" default:"true" negatable:"_"`
+	HookAttempts  int      `name:"hook-max-attempts" help:"Delivery attempts before a queued hook payload is dead-lettered (0 disables the queue)" default:"8"`
 }
 
 func (c *GmailWatchServeCmd) Run(ctx context.Context, kctx *kong.Context, flags *RootFlags) error {
 	u := ui.FromContext(ctx)
-	account, err := requireAccount(flags)
+	accounts, err := c.serveAccounts(flags)
 	if err != nil {
 		return err
 	}
@@ -243,7 +269,18 @@ func (c *GmailWatchServeCmd) Run(ctx context.Context, kctx *kong.Context, flags
 	if c.Port <= 0 {
 		return usage("--port must be > 0")
 	}
-	if !c.VerifyOIDC && c.SharedToken == "" && !isLoopbackHost(c.Bind) {
+	if c.Subscription != "" && !validSubscriptionName(c.Subscription) {
+		return usage("--subscription must be projects/<project>/subscriptions/<subscription>")
+	}
+	if c.PullMax <= 0 {
+		return usage("--pull-max must be > 0")
+	}
+	if c.ProbeAddr != "" {
+		if _, _, splitErr := net.SplitHostPort(c.ProbeAddr); splitErr != nil {
+			return usage("--probe-addr must be host:port")
+		}
+	}
+	if c.Subscription == "" && !c.VerifyOIDC && c.SharedToken == "" && !isLoopbackHost(c.Bind) {
 		return usage("--verify-oidc or --token required when binding non-loopback")
 	}
 	if c.OIDCEmail != "" && !c.VerifyOIDC {
@@ -252,6 +289,19 @@ func (c *GmailWatchServeCmd) Run(ctx context.Context, kctx *kong.Context, flags
 	if c.OIDCAudience != "" && !c.VerifyOIDC {
 		return usage("--oidc-audience requires --verify-oidc")
 	}
+	if c.HookAttempts < 0 {
+		return usage("--hook-max-attempts must be >= 0")
+	}
+	if c.HookExec != "" && c.HookURL != "" {
+		return usage("use either --hook-url or --hook-exec")
+	}
+	if c.ExecLimit <= 0 {
+		return usage("--hook-exec-concurrency must be > 0")
+	}
+	execTimeout, err := parseDurationSeconds(c.ExecTimeout)
+	if err != nil || execTimeout <= 0 {
+		return usage("--hook-exec-timeout must be a positive duration")
+	}
 
 	loc, err := resolveOutputLocation(c.Timezone, c.Local)
 	if err != nil {
@@ -263,22 +313,205 @@ func (c *GmailWatchServeCmd) Run(ctx context.Context, kctx *kong.Context, flags
 		return err
 	}
 
+	validator := (*idtoken.Validator)(nil)
+	if c.VerifyOIDC {
+		validator, err = newOIDCValidator(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	base := gmailWatchServeConfig{
+		Bind:            c.Bind,
+		Port:            c.Port,
+		Path:            c.Path,
+		ProbeAddr:       c.ProbeAddr,
+		VerifyOIDC:      c.VerifyOIDC,
+		OIDCEmail:       c.OIDCEmail,
+		OIDCAudience:    c.OIDCAudience,
+		SharedToken:     c.SharedToken,
+		HookTimeout:     defaultHookRequestTimeoutSec * time.Second,
+		HistoryMax:      defaultHistoryMaxResults,
+		ResyncMax:       defaultHistoryResyncMax,
+		HistoryTypes:    historyTypes,
+		DateLocation:    loc,
+		ExcludeLabels:   splitCommaList(c.ExcludeLabels),
+		VerboseOutput:   flags.Verbose,
+		HookAttempts:    c.HookAttempts,
+		HookExecTimeout: execTimeout,
+	}
+	hookClient := &http.Client{Timeout: base.HookTimeout}
+	execSem := make(chan struct{}, c.ExecLimit)
+	newServer := func(cfg gmailWatchServeConfig, store *gmailWatchStore, logf func(string, ...any)) (*gmailWatchServer, error) {
+		server := &gmailWatchServer{
+			cfg:             cfg,
+			store:           store,
+			validator:       validator,
+			newService:      newGmailService,
+			hookClient:      hookClient,
+			execSem:         execSem,
+			excludeLabelIDs: stringSet(cfg.ExcludeLabels),
+			logf:            logf,
+			warnf:           logf,
+		}
+		if cfg.RoutesFile != "" {
+			routes, err := loadGmailHookRoutes(cfg.RoutesFile)
+			if err != nil {
+				return nil, err
+			}
+			server.routes = routes
+		}
+		if server.hasHooks() && cfg.HookAttempts > 0 {
+			queue, err := newGmailHookQueue(cfg.Account, cfg.HookAttempts)
+			if err != nil {
+				return nil, err
+			}
+			server.queue = queue
+		}
+		return server, nil
+	}
+
+	var server *gmailWatchServer
+	if len(accounts) == 1 {
+		cfg, store, cfgErr := c.accountConfig(kctx, base, accounts[0])
+		if cfgErr != nil {
+			return cfgErr
+		}
+		if server, err = newServer(cfg, store, u.Err().Printf); err != nil {
+			return err
+		}
+	} else {
+		if server, err = newServer(base, nil, u.Err().Printf); err != nil {
+			return err
+		}
+		server.accounts = make(map[string]*gmailWatchServer, len(accounts))
+		for _, account := range accounts {
+			cfg, store, cfgErr := c.accountConfig(kctx, base, account)
+			if cfgErr != nil {
+				return fmt.Errorf("%s: %w", account, cfgErr)
+			}
+			target, srvErr := newServer(cfg, store, accountLogf(u.Err().Printf, account))
+			if srvErr != nil {
+				return fmt.Errorf("%s: %w", account, srvErr)
+			}
+			server.accounts[strings.ToLower(account)] = target
+		}
+		u.Err().Printf("watch: serving %d accounts: %s", len(accounts), strings.Join(accounts, ", "))
+	}
+
+	retryCtx, stopRetries := context.WithCancel(ctx)
+	defer stopRetries()
+	go server.runRetryLoop(retryCtx, defaultHookRetryPoll)
+	if c.AutoRenew {
+		go server.runRenewLoop(retryCtx, defaultWatchRenewPoll)
+	}
+
+	if c.ProbeAddr != "" {
+		stopProbes, probeErr := startGmailWatchProbes(c.ProbeAddr, server)
+		if probeErr != nil {
+			return probeErr
+		}
+		defer stopProbes()
+		u.Err().Printf("watch: probes on %s", c.ProbeAddr)
+	}
+
+	if c.Subscription != "" {
+		svc, svcErr := newPubSubService(ctx, accounts[0])
+		if svcErr != nil {
+			return svcErr
+		}
+		u.Err().Printf("watch: pulling %s", c.Subscription)
+		return server.pullSubscription(ctx, svc, c.Subscription, c.PullMax, os.Stdout)
+	}
+
+	addr := net.JoinHostPort(c.Bind, strconv.Itoa(c.Port))
+	u.Err().Printf("watch: listening on %s%s", addr, c.Path)
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           server,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	return listenAndServe(httpServer)
+}
+
+// serveAccounts returns the accounts to serve: --accounts, every account with
+// stored watch state (--all), or the single --account.
+func (c *GmailWatchServeCmd) serveAccounts(flags *RootFlags) ([]string, error) {
+	if c.All && len(c.Accounts) > 0 {
+		return nil, usage("use either --accounts or --all")
+	}
+	var raw []string
+	switch {
+	case c.All:
+		states, err := listGmailWatchStates()
+		if err != nil {
+			return nil, err
+		}
+		for _, state := range states {
+			raw = append(raw, state.Account)
+		}
+		if len(raw) == 0 {
+			return nil, errors.New("no stored watch state; run gmail watch start")
+		}
+	case len(c.Accounts) > 0:
+		for _, v := range c.Accounts {
+			raw = append(raw, splitCommaList(v)...)
+		}
+	default:
+		account, err := requireAccount(flags)
+		if err != nil {
+			return nil, err
+		}
+		return []string{account}, nil
+	}
+
+	out := make([]string, 0, len(raw))
+	seen := make(map[string]struct{}, len(raw))
+	for _, v := range raw {
+		account := strings.TrimSpace(v)
+		if resolved, ok, err := resolveAccountAlias(account); err != nil {
+			return nil, err
+		} else if ok {
+			account = resolved
+		}
+		key := strings.ToLower(account)
+		if _, dup := seen[key]; dup || account == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, account)
+	}
+	if len(out) == 0 {
+		return nil, usage("--accounts requires at least one account")
+	}
+	return out, nil
+}
+
+// accountConfig loads account's watch state and derives its serve config.
+// Hook flags given on the command line apply to every account; otherwise
+// each account uses the hook stored in its own watch state.
+func (c *GmailWatchServeCmd) accountConfig(kctx *kong.Context, base gmailWatchServeConfig, account string) (gmailWatchServeConfig, *gmailWatchStore, error) {
 	store, err := loadGmailWatchStore(account)
 	if err != nil {
-		return err
+		return gmailWatchServeConfig{}, nil, err
 	}
 	state := store.Get()
 
 	hookURL := c.HookURL
 	hookToken := c.HookToken
+	hookSecret := c.HookSecret
 	includeBody := c.IncludeBody
 	maxBytes := c.MaxBytes
 
-	if hookURL == "" && state.Hook != nil {
+	if hookURL == "" && c.HookExec == "" && state.Hook != nil {
 		hookURL = state.Hook.URL
 		if !flagProvided(kctx, "hook-token") {
 			hookToken = state.Hook.Token
 		}
+		if !flagProvided(kctx, "hook-secret") {
+			hookSecret = state.Hook.Secret
+		}
 		if !flagProvided(kctx, "include-body") {
 			includeBody = state.Hook.IncludeBody
 		}
@@ -293,47 +526,46 @@ func (c *GmailWatchServeCmd) Run(ctx context.Context, kctx *kong.Context, flags
 		if errors.Is(err, errNoHookConfigured) {
 			hook = nil
 		} else {
-			return err
+			return gmailWatchServeConfig{}, nil, err
+		}
+	}
+	routesFile := state.Routes
+	if c.Routes != "" {
+		if routesFile, err = absRoutesPath(c.Routes); err != nil {
+			return gmailWatchServeConfig{}, nil, err
 		}
 	}
-	if c.SaveHook && hook != nil {
+	if hook != nil {
+		hook.Secret = hookSecret
+	} else if hookSecret != "" && routesFile == "" {
+		return gmailWatchServeConfig{}, nil, usage("--hook-url or --routes required when using --hook-secret")
+	}
+	if c.SaveHook && (hook != nil || c.Routes != "") {
 		if updateErr := store.Update(func(s *gmailWatchState) error {
-			s.Hook = hook
+			if hook != nil {
+				s.Hook = hook
+			}
+			s.Routes = routesFile
 			s.UpdatedAtMs = time.Now().UnixMilli()
 			return nil
 		}); updateErr != nil {
-			return updateErr
+			return gmailWatchServeConfig{}, nil, updateErr
 		}
 	}
 
-	validator := (*idtoken.Validator)(nil)
-	if c.VerifyOIDC {
-		validator, err = newOIDCValidator(ctx)
-		if err != nil {
-			return err
+	cfg := base
+	cfg.Account = account
+	cfg.HookSecret = hookSecret
+	cfg.RoutesFile = routesFile
+	cfg.AllowNoHook = hook == nil && routesFile == "" && c.HookExec == ""
+	if c.HookExec != "" {
+		if _, err := parseHookExec(c.HookExec); err != nil {
+			return gmailWatchServeConfig{}, nil, err
 		}
+		cfg.HookExec = c.HookExec
 	}
-
-	cfg := gmailWatchServeConfig{
-		Account:       account,
-		Bind:          c.Bind,
-		Port:          c.Port,
-		Path:          c.Path,
-		VerifyOIDC:    c.VerifyOIDC,
-		OIDCEmail:     c.OIDCEmail,
-		OIDCAudience:  c.OIDCAudience,
-		SharedToken:   c.SharedToken,
-		HookTimeout:   defaultHookRequestTimeoutSec * time.Second,
-		HistoryMax:    defaultHistoryMaxResults,
-		ResyncMax:     defaultHistoryResyncMax,
-		HistoryTypes:  historyTypes,
-		AllowNoHook:   hook == nil,
-		IncludeBody:   includeBody,
-		MaxBodyBytes:  maxBytes,
-		DateLocation:  loc,
-		ExcludeLabels: splitCommaList(c.ExcludeLabels),
-		VerboseOutput: flags.Verbose,
-	}
+	cfg.IncludeBody = includeBody
+	cfg.MaxBodyBytes = maxBytes
 	if hook != nil {
 		cfg.HookURL = hook.URL
 		cfg.HookToken = hook.Token
@@ -344,28 +576,56 @@ func (c *GmailWatchServeCmd) Run(ctx context.Context, kctx *kong.Context, flags
 	if cfg.MaxBodyBytes <= 0 {
 		cfg.MaxBodyBytes = defaultHookMaxBytes
 	}
+	return cfg, store, nil
+}
 
-	hookClient := &http.Client{Timeout: cfg.HookTimeout}
-	server := &gmailWatchServer{
-		cfg:             cfg,
-		store:           store,
-		validator:       validator,
-		newService:      newGmailService,
-		hookClient:      hookClient,
-		excludeLabelIDs: stringSet(cfg.ExcludeLabels),
-		logf:            u.Err().Printf,
-		warnf:           u.Err().Printf,
+// absRoutesPath expands path so a routes file saved to watch state keeps
+// working from any directory.
+func absRoutesPath(path string) (string, error) {
+	expanded, err := config.ExpandPath(path)
+	if err != nil {
+		return "", err
 	}
+	return filepath.Abs(expanded)
+}
 
-	addr := net.JoinHostPort(c.Bind, strconv.Itoa(c.Port))
-	u.Err().Printf("watch: listening on %s%s", addr, c.Path)
+// accountLogf tags log lines of a per-account server with its account.
+func accountLogf(logf func(string, ...any), account string) func(string, ...any) {
+	return func(format string, args ...any) {
+		logf(format+" (account=%s)", append(args, account)...)
+	}
+}
 
-	httpServer := &http.Server{
-		Addr:              addr,
-		Handler:           server,
-		ReadHeaderTimeout: 5 * time.Second,
+func writeWatchStates(ctx context.Context, states []gmailWatchState) error {
+	if outfmt.IsJSON(ctx) {
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"watches": states})
 	}
-	return listenAndServe(httpServer)
+	if len(states) == 0 {
+		ui.FromContext(ctx).Err().Println("No watches")
+		return nil
+	}
+	w, flush := tableWriter(ctx)
+	defer flush()
+	fmt.Fprintln(w, "ACCOUNT\tTOPIC\tHISTORY_ID\tEXPIRATION\tHOOK\tLAST_DELIVERY")
+	for _, state := range states {
+		expiration := "-"
+		if state.ExpirationMs > 0 {
+			expiration = formatUnixMillis(state.ExpirationMs)
+		}
+		hook := "-"
+		if state.Hook != nil && state.Hook.URL != "" {
+			hook = state.Hook.URL
+		}
+		delivery := "-"
+		if state.LastDeliveryStatus != "" {
+			delivery = state.LastDeliveryStatus
+			if state.LastDeliveryAtMs > 0 {
+				delivery += " " + formatUnixMillis(state.LastDeliveryAtMs)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", state.Account, state.Topic, state.HistoryID, expiration, hook, delivery)
+	}
+	return nil
 }
 
 func writeWatchState(ctx context.Context, state gmailWatchState) error {
@@ -402,6 +662,12 @@ func writeWatchState(ctx context.Context, state gmailWatchState) error {
 		if state.Hook.Token != "" {
 			u.Out().Printf("hook_token\t%s", state.Hook.Token)
 		}
+		if state.Hook.Secret != "" {
+			u.Out().Printf("hook_signed\ttrue")
+		}
+	}
+	if state.Routes != "" {
+		u.Out().Printf("routes\t%s", state.Routes)
 	}
 	if state.LastDeliveryStatus != "" {
 		u.Out().Printf("last_delivery_status\t%s", state.LastDeliveryStatus)
@@ -415,6 +681,11 @@ func writeWatchState(ctx context.Context, state gmailWatchState) error {
 	if state.LastPushMessageID != "" {
 		u.Out().Printf("last_push_message_id\t%s", state.LastPushMessageID)
 	}
+	for _, d := range state.Deliveries {
+		if d.Status == gmailDeliveryRetrying || d.Status == gmailDeliveryDeadLetter {
+			u.Out().Printf("delivery\t%s\t%s\tattempts=%d\t%s", d.ID, d.Status, d.Attempts, d.LastError)
+		}
+	}
 	return nil
 }
 
@@ -439,6 +710,7 @@ func buildWatchState(account, topic string, labels []string, resp *gmail.WatchRe
 	}
 	if ttl > 0 {
 		state.RenewAfterMs = now.Add(ttl).UnixMilli()
+		state.RenewTTLMs = ttl.Milliseconds()
 	}
 	return state, nil
 }
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/steipete/gogcli/internal/config"
+)
+
+const (
+	defaultHookExecTimeout    = 30 * time.Second
+	gmailWatchStatusExecError = "exec_error"
+	hookExecStderrLimit       = 512
+	hookExecWaitDelay         = time.Second
+)
+
+var errEmptyHookExec = errors.New("empty --hook-exec command")
+
+// parseHookExec splits an exec hook command line into argv, expanding ~ in
+// the program path. Arguments are split on whitespace; no shell is involved.
+func parseHookExec(command string) ([]string, error) {
+	argv := strings.Fields(command)
+	if len(argv) == 0 {
+		return nil, errEmptyHookExec
+	}
+	expanded, err := config.ExpandPath(argv[0])
+	if err != nil {
+		return nil, err
+	}
+	argv[0] = expanded
+	return argv, nil
+}
+
+// execHook runs hook.Exec with the payload on stdin and message metadata in
+// GOG_* environment variables, bounded by the server's concurrency limit and
+// timeout. A non-zero exit status fails the delivery.
+func (s *gmailWatchServer) execHook(ctx context.Context, hook gmailHookEndpoint, data []byte) error {
+	argv, err := parseHookExec(hook.Exec)
+	if err != nil {
+		return err
+	}
+	if s.execSem != nil {
+		select {
+		case s.execSem <- struct{}{}:
+			defer func() { <-s.execSem }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	timeout := s.cfg.HookExecTimeout
+	if timeout <= 0 {
+		timeout = defaultHookExecTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var payload gmailHookPayload
+	_ = json.Unmarshal(data, &payload)
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...) //nolint:gosec // hook command is user-configured
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(), hookExecEnv(&payload)...)
+	// Don't wait on grandchildren that keep the output pipes open after a kill.
+	cmd.WaitDelay = hookExecWaitDelay
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, runErr := cmd.Output()
+	if runErr == nil {
+		if s.cfg.VerboseOutput && len(bytes.TrimSpace(out)) > 0 {
+			s.logf("watch: hook exec output: %s", strings.TrimSpace(string(out)))
+		}
+		s.recordLastDelivery("ok", "")
+		return nil
+	}
+
+	note := runErr.Error()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		note = fmt.Sprintf("timed out after %s", timeout)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		if len(msg) > hookExecStderrLimit {
+			msg = msg[len(msg)-hookExecStderrLimit:]
+		}
+		note += ": " + msg
+	}
+	s.recordLastDelivery(gmailWatchStatusExecError, note)
+	return fmt.Errorf("hook exec %s: %s", argv[0], note)
+}
+
+// hookExecEnv describes the (first) message of payload in environment
+// variables. GOG_ACCOUNT also makes gog calls from the hook use the account.
+func hookExecEnv(payload *gmailHookPayload) []string {
+	env := []string{
+		"GOG_ACCOUNT=" + payload.Account,
+		"GOG_HISTORY_ID=" + payload.HistoryID,
+	}
+	if len(payload.DeletedMessageIDs) > 0 {
+		env = append(env, "GOG_DELETED_MESSAGE_IDS="+strings.Join(payload.DeletedMessageIDs, ","))
+	}
+	if len(payload.Messages) == 0 {
+		return env
+	}
+	msg := payload.Messages[0]
+	return append(env,
+		"GOG_MESSAGE_ID="+msg.ID,
+		"GOG_THREAD_ID="+msg.ThreadID,
+		"GOG_MESSAGE_FROM="+msg.From,
+		"GOG_MESSAGE_TO="+msg.To,
+		"GOG_MESSAGE_SUBJECT="+msg.Subject,
+		"GOG_MESSAGE_DATE="+msg.Date,
+		"GOG_MESSAGE_LABELS="+strings.Join(msg.Labels, ","),
+	)
+}
+
+// splitExecPayloads turns each payload bound for an exec hook into one
+// payload per message (plus one for deleted IDs), so the command runs once
+// per new message.
+func splitExecPayloads(routed []gmailRoutedPayload) []gmailRoutedPayload {
+	out := make([]gmailRoutedPayload, 0, len(routed))
+	for _, r := range routed {
+		if r.hook.Exec == "" || len(r.payload.Messages)+min(len(r.payload.DeletedMessageIDs), 1) <= 1 {
+			out = append(out, r)
+			continue
+		}
+		for _, msg := range r.payload.Messages {
+			single := *r.payload
+			single.Messages = []gmailHookMessage{msg}
+			single.DeletedMessageIDs = nil
+			out = append(out, gmailRoutedPayload{route: r.route, hook: r.hook, payload: &single})
+		}
+		if len(r.payload.DeletedMessageIDs) > 0 {
+			deleted := *r.payload
+			deleted.Messages = []gmailHookMessage{}
+			out = append(out, gmailRoutedPayload{route: r.route, hook: r.hook, payload: &deleted})
+		}
+	}
+	return out
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+// writeHookExecStub writes a hook script that saves its stdin and GOG_*
+// environment to <dir>/<GOG_MESSAGE_ID>.{json,env}, then exits with $1.
+func writeHookExecStub(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("exec hook stub needs /bin/sh")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "hook.sh")
+	script := `#!/bin/sh
+out="` + dir + `/${GOG_MESSAGE_ID:-deleted}"
+cat > "$out.json"
+env | grep '^GOG_' | sort > "$out.env"
+if [ "$1" = "sleep" ]; then sleep 5; fi
+if [ "$1" != "0" ] && [ "$1" != "sleep" ]; then echo "boom $1" >&2; fi
+exit "${1:-0}"
+`
+	if err := os.WriteFile(path, []byte(script), 0o700); err != nil { //nolint:gosec // test stub must be executable
+		t.Fatalf("write stub: %v", err)
+	}
+	return path, dir
+}
+
+func stubGmailHistory(t *testing.T, ids ...string) {
+	t.Helper()
+
+	added := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		added = append(added, map[string]any{"message": map[string]any{"id": id}})
+	}
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.URL.Path, "/history") {
+			_ = json.NewEncoder(w).Encode(map[string]any{"historyId": "200", "history": []map[string]any{{"messagesAdded": added}}})
+			return
+		}
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id": id, "threadId": "t-" + id, "labelIds": []string{"INBOX", "UNREAD"},
+			"payload": map[string]any{"headers": []map[string]any{
+				{"name": "From", "value": "sender@example.com"},
+				{"name": "Subject", "value": "Hello " + id},
+			}},
+		})
+	})
+	t.Cleanup(closeSrv)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+}
+
+func TestGmailWatchServer_HookExecPerMessage(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	stub, dir := writeHookExecStub(t)
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	stubGmailHistory(t, "m1", "m2")
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"}, "--hook-exec", stub+" 0", "--hook-exec-concurrency", "1")
+	if server.cfg.HookExec == "" || cap(server.execSem) != 1 || server.cfg.AllowNoHook {
+		t.Fatalf("unexpected server: %#v", server.cfg)
+	}
+	pushGmailWatch(t, server, `{"emailAddress":"a@b.com","historyId":"200"}`)
+
+	for _, id := range []string{"m1", "m2"} {
+		data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+		if err != nil {
+			t.Fatalf("%s not delivered: %v", id, err)
+		}
+		var payload gmailHookPayload
+		if err := json.Unmarshal(data, &payload); err != nil {
+			t.Fatalf("stdin json: %v", err)
+		}
+		if len(payload.Messages) != 1 || payload.Messages[0].ID != id || payload.Account != "a@b.com" {
+			t.Fatalf("unexpected stdin for %s: %s", id, data)
+		}
+		env, _ := os.ReadFile(filepath.Join(dir, id+".env"))
+		for _, want := range []string{"GOG_ACCOUNT=a@b.com", "GOG_HISTORY_ID=200", "GOG_MESSAGE_ID=" + id, "GOG_THREAD_ID=t-" + id, "GOG_MESSAGE_FROM=sender@example.com", "GOG_MESSAGE_SUBJECT=Hello " + id, "GOG_MESSAGE_LABELS=INBOX,UNREAD"} {
+			if !strings.Contains(string(env), want+"\n") {
+				t.Fatalf("%s env missing %q:\n%s", id, want, env)
+			}
+		}
+	}
+	if reloaded, _ := loadGmailWatchStore("a@b.com"); reloaded.Get().LastDeliveryStatus != "ok" {
+		t.Fatalf("unexpected status: %#v", reloaded.Get())
+	}
+}
+
+func TestGmailWatchServer_HookExecFailures(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	stub, _ := writeHookExecStub(t)
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	stubGmailHistory(t, "m1")
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"}, "--hook-exec", stub+" 3")
+	pushGmailWatch(t, server, `{"emailAddress":"a@b.com","historyId":"200"}`)
+
+	state := server.store.Get()
+	if state.LastDeliveryStatus != gmailWatchStatusExecError || !strings.Contains(state.LastDeliveryStatusNote, "exit status 3: boom 3") {
+		t.Fatalf("unexpected status: %q %q", state.LastDeliveryStatus, state.LastDeliveryStatusNote)
+	}
+	if len(state.Deliveries) != 1 || state.Deliveries[0].Status != gmailDeliveryRetrying || state.Deliveries[0].MessageIDs[0] != "m1" {
+		t.Fatalf("expected queued retry: %#v", state.Deliveries)
+	}
+	if pending, _ := server.queue.Pending(); len(pending) != 1 || pending[0].HookExec != stub+" 3" {
+		t.Fatalf("unexpected queue: %v", pending)
+	}
+
+	server.cfg.HookExecTimeout = 100 * time.Millisecond
+	err := server.execHook(context.Background(), gmailHookEndpoint{Exec: stub + " sleep"}, []byte(`{"messages":[{"id":"m9"}]}`))
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
+
+func TestGmailWatchServeCmd_HookExecFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+
+	for _, args := range [][]string{
+		{"--hook-exec", "x", "--hook-url", "http://example.com"},
+		{"--hook-exec", "x", "--hook-exec-concurrency", "0"},
+		{"--hook-exec", "x", "--hook-exec-timeout", "0"},
+		{"--hook-exec", "   "},
+	} {
+		if err := runKong(t, &GmailWatchServeCmd{}, args, context.Background(), &RootFlags{Account: "a@b.com"}); err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
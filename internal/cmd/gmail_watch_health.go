@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	gmailWatchHealthPath  = "/healthz"
+	gmailWatchReadyPath   = "/readyz"
+	gmailWatchMetricsPath = "/metrics"
+
+	gmailWatchReadyCacheTTL = 30 * time.Second
+	gmailWatchReadyTimeout  = 10 * time.Second
+)
+
+// gmailWatchMetrics counts events for one served account since startup.
+type gmailWatchMetrics struct {
+	pushes        atomic.Int64
+	delivered     atomic.Int64
+	hookFailures  atomic.Int64
+	resyncs       atomic.Int64
+	renewals      atomic.Int64
+	renewFailures atomic.Int64
+}
+
+var gmailWatchCounters = []struct {
+	name  string
+	help  string
+	value func(*gmailWatchMetrics) int64
+}{
+	{"gog_gmail_watch_pushes_received_total", "Gmail notifications received for the account.", func(m *gmailWatchMetrics) int64 { return m.pushes.Load() }},
+	{"gog_gmail_watch_messages_delivered_total", "Messages delivered to hooks.", func(m *gmailWatchMetrics) int64 { return m.delivered.Load() }},
+	{"gog_gmail_watch_hook_failures_total", "Failed hook delivery attempts (including retries).", func(m *gmailWatchMetrics) int64 { return m.hookFailures.Load() }},
+	{"gog_gmail_watch_history_resyncs_total", "Resyncs after Gmail rejected a stale historyId.", func(m *gmailWatchMetrics) int64 { return m.resyncs.Load() }},
+	{"gog_gmail_watch_renewals_total", "Automatic watch renewals.", func(m *gmailWatchMetrics) int64 { return m.renewals.Load() }},
+	{"gog_gmail_watch_renew_failures_total", "Failed automatic watch renewals.", func(m *gmailWatchMetrics) int64 { return m.renewFailures.Load() }},
+}
+
+// countDelivery records the outcome of one hook delivery attempt.
+func (s *gmailWatchServer) countDelivery(messages int, err error) {
+	if err != nil {
+		s.metrics.hookFailures.Add(1)
+		return
+	}
+	s.metrics.delivered.Add(int64(messages))
+}
+
+// gmailWatchProbeHandler serves only the probe endpoints, for the separate
+// --probe-addr listener.
+type gmailWatchProbeHandler struct {
+	server *gmailWatchServer
+}
+
+func (h gmailWatchProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.server.serveProbe(w, r, false) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// startGmailWatchProbes serves the probe endpoints on addr until the
+// returned stop func is called.
+func startGmailWatchProbes(addr string, server *gmailWatchServer) (func(), error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("probe listener: %w", err)
+	}
+	srv := &http.Server{
+		Handler:           gmailWatchProbeHandler{server: server},
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() { _ = srv.Serve(ln) }()
+	return func() { _ = srv.Close() }, nil
+}
+
+// serveProbe answers the health, readiness, and metrics endpoints and
+// reports false for any other request. /healthz never needs auth; with
+// authorize set (probes sharing the push listener), /readyz and /metrics
+// require the same token or OIDC identity as pushes since they list the
+// served accounts and call Gmail.
+func (s *gmailWatchServer) serveProbe(w http.ResponseWriter, r *http.Request, authorize bool) bool {
+	switch r.URL.Path {
+	case gmailWatchHealthPath, gmailWatchReadyPath, gmailWatchMetricsPath:
+	default:
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return true
+	}
+	if authorize && r.URL.Path != gmailWatchHealthPath && !s.authorize(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return true
+	}
+
+	switch r.URL.Path {
+	case gmailWatchHealthPath:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, "ok\n")
+	case gmailWatchReadyPath:
+		ctx, cancel := context.WithTimeout(r.Context(), gmailWatchReadyTimeout)
+		defer cancel()
+		ready := true
+		for _, target := range s.sortedTargets() {
+			if target.checkReady(ctx) != nil {
+				ready = false
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = io.WriteString(w, "not ready\n")
+			return true
+		}
+		_, _ = io.WriteString(w, "ok\n")
+	case gmailWatchMetricsPath:
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeGmailWatchMetrics(w, s.sortedTargets(), time.Now())
+	}
+	return true
+}
+
+func (s *gmailWatchServer) sortedTargets() []*gmailWatchServer {
+	targets := s.targets()
+	sort.Slice(targets, func(i, j int) bool {
+		return strings.ToLower(targets[i].cfg.Account) < strings.ToLower(targets[j].cfg.Account)
+	})
+	return targets
+}
+
+// checkReady verifies the account's state file and Gmail credentials. The
+// result is cached briefly so frequent probes don't hit the Gmail API;
+// failures are logged rather than returned to the prober.
+func (s *gmailWatchServer) checkReady(ctx context.Context) error {
+	s.readyMu.Lock()
+	defer s.readyMu.Unlock()
+	if !s.readyCheckedAt.IsZero() && time.Since(s.readyCheckedAt) < gmailWatchReadyCacheTTL {
+		return s.readyErr
+	}
+	s.readyErr = s.probeReady(ctx)
+	s.readyCheckedAt = time.Now()
+	if s.readyErr != nil {
+		s.warnf("watch: not ready: %v", s.readyErr)
+	}
+	return s.readyErr
+}
+
+func (s *gmailWatchServer) probeReady(ctx context.Context) error {
+	if s.store == nil || s.store.path == "" {
+		return errors.New("state file: not configured")
+	}
+	data, err := os.ReadFile(s.store.path)
+	if err != nil {
+		return fmt.Errorf("state file: %w", err)
+	}
+	var state gmailWatchState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("state file: %w", err)
+	}
+	if strings.TrimSpace(state.HistoryID) == "" {
+		return errors.New("state file: missing historyId")
+	}
+
+	svc, err := s.newService(ctx, s.cfg.Account)
+	if err != nil {
+		return fmt.Errorf("gmail auth: %w", err)
+	}
+	if _, err := svc.Users.GetProfile("me").Context(ctx).Do(); err != nil {
+		return fmt.Errorf("gmail auth: %w", err)
+	}
+	return nil
+}
+
+// writeGmailWatchMetrics renders the counters and watch expiry of every
+// served account in the Prometheus text exposition format.
+func writeGmailWatchMetrics(w io.Writer, targets []*gmailWatchServer, now time.Time) {
+	for _, counter := range gmailWatchCounters {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name)
+		for _, target := range targets {
+			_, _ = fmt.Fprintf(w, "%s{account=\"%s\"} %d\n", counter.name, promLabelValue(target.cfg.Account), counter.value(&target.metrics))
+		}
+	}
+
+	const expiry = "gog_gmail_watch_expiry_seconds"
+	_, _ = fmt.Fprintf(w, "# HELP %s Seconds until the Gmail watch expires (negative once expired).\n# TYPE %s gauge\n", expiry, expiry)
+	for _, target := range targets {
+		if target.store == nil {
+			continue
+		}
+		if state := target.store.Get(); state.ExpirationMs > 0 {
+			seconds := float64(state.ExpirationMs-now.UnixMilli()) / 1000
+			_, _ = fmt.Fprintf(w, "%s{account=\"%s\"} %g\n", expiry, promLabelValue(target.cfg.Account), seconds)
+		}
+	}
+}
+
+// promLabelValue escapes a Prometheus label value.
+func promLabelValue(value string) string {
+	return promLabelEscaper.Replace(value)
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func probeGmailWatch(t *testing.T, server *gmailWatchServer, method, path string) (int, string) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+	return rr.Code, rr.Body.String()
+}
+
+func TestGmailWatchServer_HealthAndMetrics(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	stub, _ := writeHookExecStub(t)
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	stubGmailHistory(t, "m1", "m2")
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"}, "--hook-exec", stub+" 0", "--no-auto-renew")
+
+	if code, body := probeGmailWatch(t, server, http.MethodGet, "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("healthz: %d %q", code, body)
+	}
+	if code, body := probeGmailWatch(t, server, http.MethodGet, "/readyz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("readyz: %d %q", code, body)
+	}
+	if code, _ := probeGmailWatch(t, server, http.MethodPost, "/metrics"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("metrics POST: %d", code)
+	}
+
+	pushGmailWatch(t, server, `{"emailAddress":"a@b.com","historyId":"200"}`)
+	if err := server.store.Update(func(s *gmailWatchState) error {
+		s.ExpirationMs = time.Now().Add(time.Hour).UnixMilli()
+		return nil
+	}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	code, body := probeGmailWatch(t, server, http.MethodGet, "/metrics")
+	if code != http.StatusOK {
+		t.Fatalf("metrics: %d", code)
+	}
+	for _, want := range []string{
+		"# TYPE gog_gmail_watch_pushes_received_total counter\n",
+		`gog_gmail_watch_pushes_received_total{account="a@b.com"} 1` + "\n",
+		`gog_gmail_watch_messages_delivered_total{account="a@b.com"} 2` + "\n",
+		`gog_gmail_watch_hook_failures_total{account="a@b.com"} 0` + "\n",
+		`gog_gmail_watch_history_resyncs_total{account="a@b.com"} 0` + "\n",
+		"# TYPE gog_gmail_watch_expiry_seconds gauge\n",
+		`gog_gmail_watch_expiry_seconds{account="a@b.com"} 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestGmailWatchServer_ReadyzFailures(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	seedGmailWatchState(t, "c@d.com", "500", nil)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return nil, errors.New("token revoked") }
+
+	server := serveGmailWatchForTest(t, &RootFlags{}, "--accounts", "a@b.com,c@d.com", "--no-auto-renew")
+	if err := os.Remove(server.accounts["c@d.com"].store.path); err != nil {
+		t.Fatalf("remove state: %v", err)
+	}
+
+	var logs []string
+	for _, target := range server.accounts {
+		target.warnf = func(format string, args ...any) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	}
+
+	code, body := probeGmailWatch(t, server, http.MethodGet, "/readyz")
+	if code != http.StatusServiceUnavailable || body != "not ready\n" {
+		t.Fatalf("readyz: %d %q", code, body)
+	}
+	joined := strings.Join(logs, "\n")
+	if !strings.Contains(joined, "gmail auth: token revoked") || !strings.Contains(joined, "state file:") {
+		t.Fatalf("readiness failures should be logged: %q", logs)
+	}
+}
+
+func TestGmailWatchServer_ProbesRequirePushAuth(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	stubGmailHistory(t)
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"}, "--path", "/", "--token", "secret", "--no-auto-renew")
+
+	if code, body := probeGmailWatch(t, server, http.MethodGet, "/healthz"); code != http.StatusOK || body != "ok\n" {
+		t.Fatalf("healthz: %d %q", code, body)
+	}
+	for _, path := range []string{"/readyz", "/metrics"} {
+		if code, body := probeGmailWatch(t, server, http.MethodGet, path); code != http.StatusUnauthorized || strings.Contains(body, "a@b.com") {
+			t.Fatalf("%s without token: %d %q", path, code, body)
+		}
+		if code, _ := probeGmailWatch(t, server, http.MethodGet, path+"?token=secret"); code != http.StatusOK {
+			t.Fatalf("%s with token: %d", path, code)
+		}
+	}
+}
+
+func TestGmailWatchServer_ProbeAddr(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	stubGmailHistory(t)
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"}, "--token", "secret", "--probe-addr", "127.0.0.1:0", "--no-auto-renew")
+
+	if code, _ := probeGmailWatch(t, server, http.MethodGet, "/metrics?token=secret"); code != http.StatusNotFound {
+		t.Fatalf("push listener should not serve probes with --probe-addr: %d", code)
+	}
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		rr := httptest.NewRecorder()
+		gmailWatchProbeHandler{server: server}.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("probe listener %s: %d %q", path, rr.Code, rr.Body.String())
+		}
+	}
+	rr := httptest.NewRecorder()
+	gmailWatchProbeHandler{server: server}.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/gmail-pubsub", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("probe listener should not accept pushes: %d", rr.Code)
+	}
+}
+
+func TestPromLabelValue(t *testing.T) {
+	if got := promLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Fatalf("got %q", got)
+	}
+}
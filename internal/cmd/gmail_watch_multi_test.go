@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/gmail/v1"
+
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+func seedGmailWatchState(t *testing.T, account, historyID string, hook *gmailWatchHook) *gmailWatchStore {
+	t.Helper()
+
+	store, err := newGmailWatchStore(account)
+	if err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	if err := store.Update(func(s *gmailWatchState) error {
+		s.Account = account
+		s.Topic = "projects/p/topics/gmail"
+		s.HistoryID = historyID
+		s.Hook = hook
+		return nil
+	}); err != nil {
+		t.Fatalf("seed %s: %v", account, err)
+	}
+	return store
+}
+
+// serveGmailWatchForTest runs `gmail watch serve` with args and returns the
+// handler it would have served.
+func serveGmailWatchForTest(t *testing.T, flags *RootFlags, args ...string) *gmailWatchServer {
+	t.Helper()
+
+	origListen := listenAndServe
+	t.Cleanup(func() { listenAndServe = origListen })
+	var got *gmailWatchServer
+	listenAndServe = func(srv *http.Server) error {
+		got, _ = srv.Handler.(*gmailWatchServer)
+		return nil
+	}
+
+	u, err := ui.New(ui.Options{Stdout: io.Discard, Stderr: io.Discard, Color: "never"})
+	if err != nil {
+		t.Fatalf("ui.New: %v", err)
+	}
+	if err := runKong(t, &GmailWatchServeCmd{}, args, ui.WithUI(context.Background(), u), flags); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if got == nil {
+		t.Fatalf("expected server")
+	}
+	return got
+}
+
+func TestGmailWatchServeCmd_MultiAccount(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	seedGmailWatchState(t, "a@b.com", "100", &gmailWatchHook{URL: "http://example.com/a", Token: "ta"})
+	seedGmailWatchState(t, "c@d.com", "500", nil)
+
+	got := serveGmailWatchForTest(t, &RootFlags{}, "--all")
+	if len(got.accounts) != 2 || got.store != nil {
+		t.Fatalf("expected two account servers, got %#v", got.accounts)
+	}
+	a, c := got.accounts["a@b.com"], got.accounts["c@d.com"]
+	if a == nil || c == nil {
+		t.Fatalf("missing account server: %#v", got.accounts)
+	}
+	if a.cfg.HookURL != "http://example.com/a" || a.cfg.HookToken != "ta" || a.store.Get().HistoryID != "100" {
+		t.Fatalf("unexpected a@b.com config: %#v", a.cfg)
+	}
+	if c.cfg.HookURL != "" || !c.cfg.AllowNoHook || c.store.Get().HistoryID != "500" {
+		t.Fatalf("unexpected c@d.com config: %#v", c.cfg)
+	}
+
+	// Command-line hook flags apply to every account.
+	got = serveGmailWatchForTest(t, &RootFlags{}, "--accounts", "A@b.com,c@d.com", "--accounts", "a@b.com", "--hook-url", "http://example.com/all")
+	if len(got.accounts) != 2 || got.accounts["c@d.com"].cfg.HookURL != "http://example.com/all" {
+		t.Fatalf("unexpected --accounts servers: %#v", got.accounts)
+	}
+
+	// A single account keeps the single-account server.
+	got = serveGmailWatchForTest(t, &RootFlags{}, "--accounts", "c@d.com")
+	if got.accounts != nil || got.cfg.Account != "c@d.com" {
+		t.Fatalf("expected single-account server, got %#v", got)
+	}
+}
+
+func TestGmailWatchServer_RoutesByEmailAddress(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var mu sync.Mutex
+	var hooks []string
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload gmailHookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		hooks = append(hooks, r.URL.Path+" "+payload.Account+" "+r.Header.Get("Authorization"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer hookSrv.Close()
+
+	seedGmailWatchState(t, "a@b.com", "100", &gmailWatchHook{URL: hookSrv.URL + "/a", Token: "ta"})
+	seedGmailWatchState(t, "c@d.com", "500", &gmailWatchHook{URL: hookSrv.URL + "/c", Token: "tc"})
+
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "/history"):
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"historyId": "600",
+				"history":   []map[string]any{{"messagesAdded": []map[string]any{{"message": map[string]any{"id": "m1"}}}}},
+			})
+		case strings.Contains(r.URL.Path, "/messages/m1"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "threadId": "t1", "payload": map[string]any{}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer closeSrv()
+	var requested []string
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(_ context.Context, account string) (*gmail.Service, error) {
+		requested = append(requested, account)
+		return svc, nil
+	}
+
+	server := serveGmailWatchForTest(t, &RootFlags{}, "--accounts", "a@b.com,c@d.com", "--token", "tok")
+
+	push := func(data string) int {
+		env := pubsubPushEnvelope{}
+		env.Message.Data = base64.StdEncoding.EncodeToString([]byte(data))
+		body, _ := json.Marshal(env)
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/gmail-pubsub?token=tok", bytes.NewReader(body)))
+		return rr.Code
+	}
+
+	if code := push(`{"emailAddress":"C@d.com","historyId":"600"}`); code != http.StatusOK {
+		t.Fatalf("status: %d", code)
+	}
+	if strings.Join(hooks, ";") != "/c c@d.com Bearer tc" || strings.Join(requested, ",") != "c@d.com" {
+		t.Fatalf("unexpected routing: hooks=%v services=%v", hooks, requested)
+	}
+	if reloaded, _ := loadGmailWatchStore("c@d.com"); reloaded.Get().HistoryID != "600" {
+		t.Fatalf("c@d.com cursor not advanced: %s", reloaded.Get().HistoryID)
+	}
+	if reloaded, _ := loadGmailWatchStore("a@b.com"); reloaded.Get().HistoryID != "100" {
+		t.Fatalf("a@b.com cursor changed: %s", reloaded.Get().HistoryID)
+	}
+
+	if code := push(`{"emailAddress":"x@y.com","historyId":"700"}`); code != http.StatusAccepted {
+		t.Fatalf("unknown account status: %d", code)
+	}
+	if code := push(`{"historyId":"700"}`); code != http.StatusAccepted {
+		t.Fatalf("missing emailAddress status: %d", code)
+	}
+	if len(hooks) != 1 {
+		t.Fatalf("unexpected hooks: %v", hooks)
+	}
+}
+
+func TestGmailWatchStatusCmd_All(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	seedGmailWatchState(t, "z@b.com", "100", &gmailWatchHook{URL: "http://example.com/z"})
+	seedGmailWatchState(t, "a@b.com", "200", nil)
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "gmail", "watch", "status", "--all"}); err != nil {
+			t.Fatalf("status: %v", err)
+		}
+	})
+	var parsed struct {
+		Watches []gmailWatchState `json:"watches"`
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if len(parsed.Watches) != 2 || parsed.Watches[0].Account != "a@b.com" || parsed.Watches[1].Hook == nil {
+		t.Fatalf("unexpected watches: %s", out)
+	}
+
+	out = captureStdout(t, func() {
+		if err := Execute([]string{"gmail", "watch", "status", "--all"}); err != nil {
+			t.Fatalf("status: %v", err)
+		}
+	})
+	if !strings.Contains(out, "ACCOUNT") || !strings.Contains(out, "http://example.com/z") {
+		t.Fatalf("unexpected table: %q", out)
+	}
+}
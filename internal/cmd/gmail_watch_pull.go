@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	gapi "google.golang.org/api/googleapi"
+	"google.golang.org/api/pubsub/v1"
+
+	"github.com/steipete/gogcli/internal/googleapi"
+)
+
+const (
+	pullRetryBase = 5 * time.Second
+	pullRetryMax  = time.Minute
+)
+
+var newPubSubService = googleapi.NewPubSub
+
+// validSubscriptionName reports whether name is a full Pub/Sub subscription
+// resource name (projects/<project>/subscriptions/<subscription>).
+func validSubscriptionName(name string) bool {
+	parts := strings.Split(name, "/")
+	return len(parts) == 4 && parts[0] == "projects" && parts[1] != "" && parts[2] == "subscriptions" && parts[3] != ""
+}
+
+// pullSubscription consumes Gmail notifications from a Pub/Sub subscription
+// until ctx is done, feeding each through the same pipeline as push requests.
+// Results for accounts without a hook are written to out as JSON lines.
+func (s *gmailWatchServer) pullSubscription(ctx context.Context, svc *pubsub.Service, subscription string, maxMessages int64, out io.Writer) error {
+	backoff := pullRetryBase
+	for ctx.Err() == nil {
+		resp, err := svc.Projects.Subscriptions.Pull(subscription, &pubsub.PullRequest{MaxMessages: maxMessages}).Context(ctx).Do()
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			if isPermanentPullError(err) {
+				return fmt.Errorf("pull %s: %w", subscription, err)
+			}
+			s.warnf("watch: pull failed (retrying in %s): %v", backoff, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, pullRetryMax)
+			continue
+		}
+		backoff = pullRetryBase
+		for _, received := range resp.ReceivedMessages {
+			if received != nil {
+				s.handlePulled(ctx, svc, subscription, received, out)
+			}
+		}
+	}
+	return nil
+}
+
+// handlePulled processes one pulled message and acknowledges it, or asks for
+// redelivery when processing failed the way a push would get a 500.
+func (s *gmailWatchServer) handlePulled(ctx context.Context, svc *pubsub.Service, subscription string, received *pubsub.ReceivedMessage, out io.Writer) {
+	status := http.StatusBadRequest
+	if received.Message == nil {
+		s.warnf("watch: pulled message without payload")
+	} else {
+		envelope := &pubsubPushEnvelope{Subscription: subscription}
+		envelope.Message.Data = received.Message.Data
+		envelope.Message.MessageID = received.Message.MessageId
+		envelope.Message.PublishTime = received.Message.PublishTime
+		envelope.Message.Attributes = received.Message.Attributes
+		if payload, err := decodeGmailPushPayload(envelope); err != nil {
+			s.warnf("watch: invalid pulled data: %v", err)
+		} else {
+			var result *gmailHookPayload
+			result, status = s.processPush(ctx, payload)
+			if result != nil {
+				if data, err := json.Marshal(result); err == nil {
+					_, _ = fmt.Fprintf(out, "%s\n", data)
+				}
+			}
+		}
+	}
+
+	var err error
+	if status == http.StatusInternalServerError {
+		_, err = svc.Projects.Subscriptions.ModifyAckDeadline(subscription, &pubsub.ModifyAckDeadlineRequest{
+			AckIds:             []string{received.AckId},
+			AckDeadlineSeconds: 0,
+		}).Context(ctx).Do()
+	} else {
+		_, err = svc.Projects.Subscriptions.Acknowledge(subscription, &pubsub.AcknowledgeRequest{
+			AckIds: []string{received.AckId},
+		}).Context(ctx).Do()
+	}
+	if err != nil && ctx.Err() == nil {
+		s.warnf("watch: ack failed: %v", err)
+	}
+}
+
+// isPermanentPullError reports errors retrying won't fix: a missing
+// subscription, bad request, or missing permission.
+func isPermanentPullError(err error) bool {
+	var gerr *gapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	switch gerr.Code {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
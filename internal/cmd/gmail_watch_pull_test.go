@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+	gapi "google.golang.org/api/googleapi"
+
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+// fakePubSubEmulator serves messages once from projects/p/subscriptions/s
+// and records acks and nacks. cancel is called once every message is settled.
+type fakePubSubEmulator struct {
+	mu    sync.Mutex
+	acked []string
+	nacks []string
+}
+
+func startFakePubSubEmulator(t *testing.T, cancel context.CancelFunc, data ...string) *fakePubSubEmulator {
+	t.Helper()
+
+	emu := &fakePubSubEmulator{}
+	served := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		var body struct {
+			AckIDs             []string `json:"ackIds"`
+			AckDeadlineSeconds int64    `json:"ackDeadlineSeconds"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		emu.mu.Lock()
+		defer emu.mu.Unlock()
+		switch r.URL.Path {
+		case "/v1/projects/p/subscriptions/s:pull":
+			received := []map[string]any{}
+			if !served {
+				served = true
+				for i, d := range data {
+					received = append(received, map[string]any{
+						"ackId":   "ack-" + string(rune('0'+i)),
+						"message": map[string]any{"data": base64.StdEncoding.EncodeToString([]byte(d)), "messageId": "pm" + string(rune('0'+i))},
+					})
+				}
+			} else {
+				time.Sleep(10 * time.Millisecond)
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"receivedMessages": received})
+			return
+		case "/v1/projects/p/subscriptions/s:acknowledge":
+			emu.acked = append(emu.acked, body.AckIDs...)
+		case "/v1/projects/p/subscriptions/s:modifyAckDeadline":
+			if body.AckDeadlineSeconds == 0 {
+				emu.nacks = append(emu.nacks, body.AckIDs...)
+			}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if len(emu.acked)+len(emu.nacks) >= len(data) {
+			cancel()
+		}
+		_, _ = io.WriteString(w, "{}")
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("PUBSUB_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+	return emu
+}
+
+func runGmailWatchPull(t *testing.T, ctx context.Context, args ...string) error {
+	t.Helper()
+
+	u, err := ui.New(ui.Options{Stdout: io.Discard, Stderr: io.Discard, Color: "never"})
+	if err != nil {
+		t.Fatalf("ui.New: %v", err)
+	}
+	origListen := listenAndServe
+	t.Cleanup(func() { listenAndServe = origListen })
+	listenAndServe = func(*http.Server) error {
+		t.Fatalf("unexpected listen in pull mode")
+		return nil
+	}
+	args = append([]string{"--subscription", "projects/p/subscriptions/s"}, args...)
+	return runKong(t, &GmailWatchServeCmd{}, args, ui.WithUI(ctx, u), &RootFlags{Account: "a@b.com"})
+}
+
+func TestGmailWatchServeCmd_PullDeliversAndAcks(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	stub, dir := writeHookExecStub(t)
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	stubGmailHistory(t, "m1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	emu := startFakePubSubEmulator(t, cancel, `{"emailAddress":"a@b.com","historyId":"200"}`, "not json")
+
+	if err := runGmailWatchPull(t, ctx, "--hook-exec", stub+" 0"); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "m1.json")); err != nil {
+		t.Fatalf("m1 not delivered: %v", err)
+	}
+	if len(emu.acked) != 2 || len(emu.nacks) != 0 {
+		t.Fatalf("unexpected acks=%v nacks=%v", emu.acked, emu.nacks)
+	}
+	if reloaded, _ := loadGmailWatchStore("a@b.com"); reloaded.Get().HistoryID != "200" {
+		t.Fatalf("history not advanced: %#v", reloaded.Get())
+	}
+}
+
+func TestGmailWatchServeCmd_PullNacksFailures(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return nil, errors.New("gmail down") }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	emu := startFakePubSubEmulator(t, cancel, `{"emailAddress":"a@b.com","historyId":"200"}`)
+
+	if err := runGmailWatchPull(t, ctx); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if len(emu.acked) != 0 || len(emu.nacks) != 1 {
+		t.Fatalf("unexpected acks=%v nacks=%v", emu.acked, emu.nacks)
+	}
+}
+
+func TestGmailWatchServeCmd_PullFlags(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+
+	for _, args := range [][]string{
+		{"--subscription", "my-sub"},
+		{"--subscription", "projects/p/topics/t"},
+		{"--subscription", "projects/p/subscriptions/s", "--pull-max", "0"},
+	} {
+		err := runKong(t, &GmailWatchServeCmd{}, args, context.Background(), &RootFlags{Account: "a@b.com"})
+		if err == nil {
+			t.Fatalf("%v: expected error", args)
+		}
+	}
+}
+
+func TestIsPermanentPullError(t *testing.T) {
+	for code, want := range map[int]bool{
+		http.StatusNotFound:            true,
+		http.StatusForbidden:           true,
+		http.StatusTooManyRequests:     false,
+		http.StatusServiceUnavailable:  false,
+		http.StatusInternalServerError: false,
+	} {
+		if got := isPermanentPullError(&gapi.Error{Code: code}); got != want {
+			t.Fatalf("%d: got %v, want %v", code, got, want)
+		}
+	}
+	if isPermanentPullError(errors.New("connection reset")) {
+		t.Fatalf("expected transient network error")
+	}
+}
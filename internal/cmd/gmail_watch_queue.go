@@ -0,0 +1,457 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/steipete/gogcli/internal/config"
+)
+
+const (
+	defaultHookMaxAttempts  = 8
+	defaultHookRetryBase    = 10 * time.Second
+	defaultHookRetryMax     = 30 * time.Minute
+	defaultHookRetryPoll    = 5 * time.Second
+	hookClaimStaleAfter     = 10 * time.Minute
+	maxRecordedDeliveries   = 50
+	gmailDeliveryQueued     = "queued"
+	gmailDeliveryOK         = "ok"
+	gmailDeliveryRetrying   = "retrying"
+	gmailDeliveryDeadLetter = "dead"
+)
+
+var errDeliveryNotFound = errors.New("delivery not found")
+
+// gmailHookDelivery is one hook payload waiting in the queue (or the
+// dead-letter directory), stored as <dir>/<id>.json.
+type gmailHookDelivery struct {
+	ID              string          `json:"id"`
+	Account         string          `json:"account"`
+	Route           string          `json:"route,omitempty"`
+	HookURL         string          `json:"hookUrl,omitempty"`
+	HookExec        string          `json:"hookExec,omitempty"`
+	HistoryID       string          `json:"historyId,omitempty"`
+	MessageIDs      []string        `json:"messageIds,omitempty"`
+	Payload         json.RawMessage `json:"payload"`
+	Attempts        int             `json:"attempts"`
+	CreatedAtMs     int64           `json:"createdAtMs"`
+	NextAttemptAtMs int64           `json:"nextAttemptAtMs,omitempty"`
+	LastError       string          `json:"lastError,omitempty"`
+}
+
+// gmailWatchDelivery is the per-delivery status kept in gmailWatchState.
+type gmailWatchDelivery struct {
+	ID          string   `json:"id"`
+	Route       string   `json:"route,omitempty"`
+	HistoryID   string   `json:"historyId,omitempty"`
+	MessageIDs  []string `json:"messageIds,omitempty"`
+	Status      string   `json:"status"`
+	Attempts    int      `json:"attempts"`
+	LastError   string   `json:"lastError,omitempty"`
+	UpdatedAtMs int64    `json:"updatedAtMs"`
+}
+
+// gmailHookQueue persists hook deliveries so a hook outage does not lose
+// notifications. Failed deliveries are retried with exponential backoff and
+// moved to the dead-letter directory after maxAttempts. A delivery being sent
+// lives in the in-flight directory, so a server and `gmail watch replay`
+// never send the same payload concurrently.
+type gmailHookQueue struct {
+	dir         string
+	deadDir     string
+	inflightDir string
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	inflight map[string]struct{}
+}
+
+func newGmailHookQueue(account string, maxAttempts int) (*gmailHookQueue, error) {
+	dir, err := config.EnsureGmailWatchDir()
+	if err != nil {
+		return nil, err
+	}
+	name := sanitizeAccountForPath(account)
+	q := &gmailHookQueue{
+		dir:         filepath.Join(dir, "queue", name),
+		deadDir:     filepath.Join(dir, "dead", name),
+		inflightDir: filepath.Join(dir, "inflight", name),
+		maxAttempts: maxAttempts,
+		baseDelay:   defaultHookRetryBase,
+		maxDelay:    defaultHookRetryMax,
+		now:         time.Now,
+		inflight:    map[string]struct{}{},
+	}
+	for _, d := range []string{q.dir, q.deadDir, q.inflightDir} {
+		if err := os.MkdirAll(d, 0o700); err != nil {
+			return nil, fmt.Errorf("ensure hook queue dir: %w", err)
+		}
+	}
+	return q, nil
+}
+
+func newGmailHookDelivery(account string, routed gmailRoutedPayload, now time.Time) (*gmailHookDelivery, error) {
+	payload := routed.payload
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(payload.Messages))
+	for _, m := range payload.Messages {
+		ids = append(ids, m.ID)
+	}
+	return &gmailHookDelivery{
+		// Zero-padded millis keep IDs (and file names) in creation order.
+		ID:          fmt.Sprintf("%013d-%s", now.UnixMilli(), hex.EncodeToString(b[:])),
+		Account:     account,
+		Route:       routed.route,
+		HookURL:     routed.hook.URL,
+		HookExec:    routed.hook.Exec,
+		HistoryID:   payload.HistoryID,
+		MessageIDs:  ids,
+		Payload:     data,
+		CreatedAtMs: now.UnixMilli(),
+	}, nil
+}
+
+func (q *gmailHookQueue) Enqueue(d *gmailHookDelivery) error {
+	return writeHookDelivery(q.dir, d)
+}
+
+// Pending returns queued deliveries, oldest first.
+func (q *gmailHookQueue) Pending() ([]*gmailHookDelivery, error) {
+	return readHookDeliveries(q.dir)
+}
+
+// Dead returns dead-lettered deliveries, oldest first.
+func (q *gmailHookQueue) Dead() ([]*gmailHookDelivery, error) {
+	return readHookDeliveries(q.deadDir)
+}
+
+// Due returns pending deliveries whose next attempt is due and claims them;
+// callers must Release each one.
+func (q *gmailHookQueue) Due() ([]*gmailHookDelivery, error) {
+	q.recoverStale()
+	pending, err := q.Pending()
+	if err != nil {
+		return nil, err
+	}
+	now := q.now().UnixMilli()
+	due := make([]*gmailHookDelivery, 0, len(pending))
+	for _, d := range pending {
+		if d.NextAttemptAtMs <= now && q.Claim(d.ID) {
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+// Claim marks a queued delivery as in flight by moving it into the in-flight
+// directory. The rename is atomic, so only one process (and, through the
+// in-memory set, one goroutine) wins.
+func (q *gmailHookQueue) Claim(id string) bool {
+	return q.claimFrom(q.dir, id)
+}
+
+func (q *gmailHookQueue) claimFrom(dir, id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, busy := q.inflight[id]; busy {
+		return false
+	}
+	path := hookDeliveryPath(q.inflightDir, id)
+	if err := os.Rename(hookDeliveryPath(dir, id), path); err != nil {
+		return false
+	}
+	// Rename keeps the old mtime; stale-claim recovery needs the claim time.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	q.inflight[id] = struct{}{}
+	return true
+}
+
+// Release ends a claim. A delivery that was neither Done nor Failed (e.g.
+// the sender bailed out) goes back into the queue.
+func (q *gmailHookQueue) Release(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inflight, id)
+	_ = os.Rename(hookDeliveryPath(q.inflightDir, id), hookDeliveryPath(q.dir, id))
+}
+
+// recoverStale returns claims left behind by a crashed process to the queue.
+func (q *gmailHookQueue) recoverStale() {
+	entries, err := os.ReadDir(q.inflightDir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-hookClaimStaleAfter)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if _, mine := q.inflight[id]; mine || entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		_ = os.Rename(hookDeliveryPath(q.inflightDir, id), hookDeliveryPath(q.dir, id))
+	}
+}
+
+// Done removes a delivered payload from the queue.
+func (q *gmailHookQueue) Done(d *gmailHookDelivery) error {
+	d.Attempts++
+	return removeHookDelivery(d.ID, q.inflightDir, q.dir)
+}
+
+// Fail records a failed attempt and either schedules a retry or moves the
+// delivery to the dead-letter directory. It returns the resulting status.
+func (q *gmailHookQueue) Fail(d *gmailHookDelivery, cause error) (string, error) {
+	d.Attempts++
+	d.LastError = cause.Error()
+	if d.Attempts >= q.maxAttempts {
+		d.NextAttemptAtMs = 0
+		if err := writeHookDelivery(q.deadDir, d); err != nil {
+			return "", err
+		}
+		if err := removeHookDelivery(d.ID, q.inflightDir, q.dir); err != nil {
+			return "", err
+		}
+		return gmailDeliveryDeadLetter, nil
+	}
+	d.NextAttemptAtMs = q.now().Add(q.backoff(d.Attempts)).UnixMilli()
+	if err := writeHookDelivery(q.dir, d); err != nil {
+		return "", err
+	}
+	if err := removeHookDelivery(d.ID, q.inflightDir); err != nil {
+		return "", err
+	}
+	return gmailDeliveryRetrying, nil
+}
+
+// Revive claims a dead-lettered delivery with a fresh attempt budget. It
+// reports false when another process revived it first; callers must Release
+// a revived delivery.
+func (q *gmailHookQueue) Revive(d *gmailHookDelivery) (bool, error) {
+	if !q.claimFrom(q.deadDir, d.ID) {
+		return false, nil
+	}
+	d.Attempts = 0
+	d.NextAttemptAtMs = 0
+	if err := writeHookDelivery(q.inflightDir, d); err != nil {
+		q.Release(d.ID)
+		return false, err
+	}
+	return true, nil
+}
+
+// backoff doubles the base delay per failed attempt, capped at maxDelay.
+func (q *gmailHookQueue) backoff(attempts int) time.Duration {
+	delay := q.baseDelay
+	for i := 1; i < attempts && delay < q.maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, q.maxDelay)
+}
+
+func hookDeliveryPath(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
+
+func removeHookDelivery(id string, dirs ...string) error {
+	for _, dir := range dirs {
+		if err := os.Remove(hookDeliveryPath(dir, id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHookDelivery(dir string, d *gmailHookDelivery) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(hookDeliveryPath(dir, d.ID), append(data, '\n'))
+}
+
+func readHookDeliveries(dir string) ([]*gmailHookDelivery, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out := make([]*gmailHookDelivery, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		var d gmailHookDelivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out = append(out, &d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+// queueHook persists payload and attempts delivery right away; failures are
+// left to the retry loop.
+func (s *gmailWatchServer) queueHook(ctx context.Context, routed gmailRoutedPayload) (string, error) {
+	d, err := newGmailHookDelivery(s.cfg.Account, routed, s.queue.now())
+	if err != nil {
+		return "", err
+	}
+	if err := s.queue.Enqueue(d); err != nil {
+		return "", err
+	}
+	s.recordDelivery(d, gmailDeliveryQueued)
+	if !s.queue.Claim(d.ID) {
+		// A concurrent retry or replay picked it up and delivers it.
+		return gmailDeliveryQueued, nil
+	}
+	defer s.queue.Release(d.ID)
+	return s.deliverQueued(ctx, d)
+}
+
+// deliverQueued sends one claimed delivery and updates the queue and state.
+func (s *gmailWatchServer) deliverQueued(ctx context.Context, d *gmailHookDelivery) (string, error) {
+	hook, sendErr := s.hookFor(d.Route, d.HookURL, d.HookExec)
+	if sendErr == nil {
+		sendErr = s.postHook(ctx, hook, d.Payload)
+	}
+	s.countDelivery(len(d.MessageIDs), sendErr)
+	if sendErr == nil {
+		if err := s.queue.Done(d); err != nil {
+			return "", err
+		}
+		s.recordDelivery(d, gmailDeliveryOK)
+		return gmailDeliveryOK, nil
+	}
+	status, err := s.queue.Fail(d, sendErr)
+	if err != nil {
+		return "", err
+	}
+	s.recordDelivery(d, status)
+	return status, sendErr
+}
+
+// retryDue delivers every due queued payload of every served account.
+func (s *gmailWatchServer) retryDue(ctx context.Context) {
+	for _, target := range s.targets() {
+		if target.queue == nil {
+			continue
+		}
+		due, err := target.queue.Due()
+		if err != nil {
+			target.warnf("watch: read hook queue: %v", err)
+			continue
+		}
+		for _, d := range due {
+			status, err := target.deliverQueued(ctx, d)
+			target.queue.Release(d.ID)
+			if err != nil {
+				target.warnf("watch: hook retry %s failed (%s, attempt %d): %v", d.ID, status, d.Attempts, err)
+			} else if target.cfg.VerboseOutput {
+				target.logf("watch: hook retry %s delivered", d.ID)
+			}
+		}
+	}
+}
+
+// runRetryLoop retries queued deliveries until ctx is done.
+func (s *gmailWatchServer) runRetryLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.retryDue(ctx)
+		}
+	}
+}
+
+func (s *gmailWatchServer) targets() []*gmailWatchServer {
+	if s.accounts == nil {
+		return []*gmailWatchServer{s}
+	}
+	out := make([]*gmailWatchServer, 0, len(s.accounts))
+	for _, target := range s.accounts {
+		out = append(out, target)
+	}
+	return out
+}
+
+func (s *gmailWatchServer) recordDelivery(d *gmailHookDelivery, status string) {
+	if s.store == nil {
+		return
+	}
+	entry := gmailWatchDelivery{
+		ID:          d.ID,
+		Route:       d.Route,
+		HistoryID:   d.HistoryID,
+		MessageIDs:  d.MessageIDs,
+		Status:      status,
+		Attempts:    d.Attempts,
+		LastError:   d.LastError,
+		UpdatedAtMs: time.Now().UnixMilli(),
+	}
+	if status == gmailDeliveryOK {
+		entry.LastError = ""
+	}
+	if err := s.store.Update(func(state *gmailWatchState) error {
+		state.Deliveries = upsertWatchDelivery(state.Deliveries, entry)
+		return nil
+	}); err != nil {
+		s.warnf("watch: failed to record delivery: %v", err)
+	}
+}
+
+// upsertWatchDelivery replaces the entry with the same ID or appends it,
+// keeping the most recent maxRecordedDeliveries entries.
+func upsertWatchDelivery(list []gmailWatchDelivery, entry gmailWatchDelivery) []gmailWatchDelivery {
+	for i := range list {
+		if list[i].ID == entry.ID {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	list = append(list, entry)
+	if len(list) > maxRecordedDeliveries {
+		list = list[len(list)-maxRecordedDeliveries:]
+	}
+	return list
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestGmailHookQueue_BackoffAndDeadLetter(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	q, err := newGmailHookQueue("a@b.com", 3)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	now := time.UnixMilli(1_700_000_000_000)
+	q.now = func() time.Time { return now }
+
+	if got := []time.Duration{q.backoff(1), q.backoff(2), q.backoff(3), q.backoff(20)}; got[0] != 10*time.Second || got[1] != 20*time.Second || got[2] != 40*time.Second || got[3] != 30*time.Minute {
+		t.Fatalf("unexpected backoff: %v", got)
+	}
+
+	d, err := newGmailHookDelivery("a@b.com", gmailRoutedPayload{payload: &gmailHookPayload{Source: "gmail", HistoryID: "9", Messages: []gmailHookMessage{{ID: "m1"}}}}, now)
+	if err != nil {
+		t.Fatalf("delivery: %v", err)
+	}
+	if err := q.Enqueue(d); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	status, err := q.Fail(d, errors.New("down"))
+	if err != nil || status != gmailDeliveryRetrying {
+		t.Fatalf("fail 1: %q %v", status, err)
+	}
+	if d.NextAttemptAtMs != now.Add(10*time.Second).UnixMilli() {
+		t.Fatalf("unexpected next attempt: %d", d.NextAttemptAtMs)
+	}
+	if due, _ := q.Due(); len(due) != 0 {
+		t.Fatalf("expected nothing due yet, got %d", len(due))
+	}
+	now = now.Add(time.Minute)
+	due, err := q.Due()
+	if err != nil || len(due) != 1 || due[0].MessageIDs[0] != "m1" {
+		t.Fatalf("expected one due delivery, got %v %v", due, err)
+	}
+	if again, _ := q.Due(); len(again) != 0 {
+		t.Fatalf("claimed delivery returned twice")
+	}
+	q.Release(d.ID)
+
+	_, _ = q.Fail(d, errors.New("down"))
+	status, err = q.Fail(d, errors.New("still down"))
+	if err != nil || status != gmailDeliveryDeadLetter {
+		t.Fatalf("fail 3: %q %v", status, err)
+	}
+	pending, _ := q.Pending()
+	dead, _ := q.Dead()
+	if len(pending) != 0 || len(dead) != 1 || dead[0].Attempts != 3 || dead[0].LastError != "still down" {
+		t.Fatalf("unexpected queue: pending=%v dead=%v", pending, dead)
+	}
+
+	if revived, err := q.Revive(dead[0]); err != nil || !revived {
+		t.Fatalf("revive: %v %v", revived, err)
+	}
+	if again, _ := q.Revive(dead[0]); again {
+		t.Fatalf("revived twice")
+	}
+	q.Release(dead[0].ID)
+	pending, _ = q.Pending()
+	dead, _ = q.Dead()
+	if len(pending) != 1 || len(dead) != 0 || pending[0].Attempts != 0 {
+		t.Fatalf("unexpected queue after revive: pending=%v dead=%v", pending, dead)
+	}
+}
+
+func TestGmailWatchServer_QueuesHookUntilDelivered(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var up atomic.Bool
+	var delivered atomic.Int32
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		delivered.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer hookSrv.Close()
+
+	store := seedGmailWatchState(t, "a@b.com", "100", nil)
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(r.URL.Path, "/history"):
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"historyId": "200",
+				"history":   []map[string]any{{"messagesAdded": []map[string]any{{"message": map[string]any{"id": "m1"}}}}},
+			})
+		case strings.Contains(r.URL.Path, "/messages/m1"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"id": "m1", "threadId": "t1", "payload": map[string]any{}})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer closeSrv()
+
+	queue, err := newGmailHookQueue("a@b.com", 5)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	server := &gmailWatchServer{
+		cfg:        gmailWatchServeConfig{Account: "a@b.com", Path: "/gmail-pubsub", HookURL: hookSrv.URL, HistoryMax: 10},
+		store:      store,
+		queue:      queue,
+		newService: func(context.Context, string) (*gmail.Service, error) { return svc, nil },
+		hookClient: hookSrv.Client(),
+		logf:       func(string, ...any) {},
+		warnf:      func(string, ...any) {},
+	}
+
+	env := pubsubPushEnvelope{}
+	env.Message.Data = base64.StdEncoding.EncodeToString([]byte(`{"emailAddress":"a@b.com","historyId":"200"}`))
+	body, _ := json.Marshal(env)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/gmail-pubsub", bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status: %d", rr.Code)
+	}
+
+	pending, _ := queue.Pending()
+	if len(pending) != 1 || pending[0].Attempts != 1 {
+		t.Fatalf("expected queued delivery, got %v", pending)
+	}
+	deliveries := store.Get().Deliveries
+	if len(deliveries) != 1 || deliveries[0].Status != gmailDeliveryRetrying || deliveries[0].MessageIDs[0] != "m1" || deliveries[0].HistoryID != "200" {
+		t.Fatalf("unexpected deliveries: %#v", deliveries)
+	}
+
+	// Not due yet: the retry loop leaves it alone.
+	server.retryDue(context.Background())
+	if delivered.Load() != 0 {
+		t.Fatalf("retried before backoff elapsed")
+	}
+
+	up.Store(true)
+	queue.now = func() time.Time { return time.Now().Add(time.Hour) }
+	server.retryDue(context.Background())
+	if delivered.Load() != 1 {
+		t.Fatalf("expected retry delivery, got %d", delivered.Load())
+	}
+	if pending, _ = queue.Pending(); len(pending) != 0 {
+		t.Fatalf("queue not drained: %v", pending)
+	}
+	state := store.Get()
+	if len(state.Deliveries) != 1 || state.Deliveries[0].Status != gmailDeliveryOK || state.Deliveries[0].Attempts != 2 || state.Deliveries[0].LastError != "" {
+		t.Fatalf("unexpected deliveries: %#v", state.Deliveries)
+	}
+	if state.LastDeliveryStatus != "ok" {
+		t.Fatalf("unexpected last delivery: %q", state.LastDeliveryStatus)
+	}
+}
+
+func TestUpsertWatchDelivery_KeepsRecent(t *testing.T) {
+	var list []gmailWatchDelivery
+	for i := 0; i < maxRecordedDeliveries+5; i++ {
+		list = upsertWatchDelivery(list, gmailWatchDelivery{ID: fmt.Sprintf("d%02d", i), Status: gmailDeliveryQueued})
+	}
+	if len(list) != maxRecordedDeliveries {
+		t.Fatalf("expected %d entries, got %d", maxRecordedDeliveries, len(list))
+	}
+	last := list[len(list)-1].ID
+	list = upsertWatchDelivery(list, gmailWatchDelivery{ID: list[0].ID, Status: gmailDeliveryOK})
+	if len(list) != maxRecordedDeliveries || list[len(list)-1].Status != gmailDeliveryOK || list[len(list)-2].ID != last {
+		t.Fatalf("unexpected upsert: %#v", list[len(list)-2:])
+	}
+}
+
+func TestGmailWatchReplayCmd(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var got []gmailHookPayload
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload gmailHookPayload
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		got = append(got, payload)
+		if r.Header.Get("Authorization") != "Bearer stored" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer hookSrv.Close()
+
+	seedGmailWatchState(t, "a@b.com", "100", &gmailWatchHook{URL: hookSrv.URL, Token: "stored"})
+	q, err := newGmailHookQueue("a@b.com", 1)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	d, _ := newGmailHookDelivery("a@b.com", gmailRoutedPayload{payload: &gmailHookPayload{Source: "gmail", Account: "a@b.com", HistoryID: "150"}}, time.Now())
+	_ = q.Enqueue(d)
+	if status, _ := q.Fail(d, errors.New("down")); status != gmailDeliveryDeadLetter {
+		t.Fatalf("expected dead letter, got %q", status)
+	}
+
+	out := captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "a@b.com", "gmail", "watch", "replay", "--list"}); err != nil {
+			t.Fatalf("list: %v", err)
+		}
+	})
+	if !strings.Contains(out, d.ID) || !strings.Contains(out, `"state": "dead"`) {
+		t.Fatalf("unexpected list: %s", out)
+	}
+
+	if err := Execute([]string{"--account", "a@b.com", "gmail", "watch", "replay", "missing"}); err == nil || !strings.Contains(err.Error(), "delivery not found") {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	out = captureStdout(t, func() {
+		if err := Execute([]string{"--json", "--account", "a@b.com", "gmail", "watch", "replay"}); err != nil {
+			t.Fatalf("replay: %v", err)
+		}
+	})
+	var parsed struct {
+		Replayed []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"replayed"`
+	}
+	if err := json.Unmarshal([]byte(out), &parsed); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if len(parsed.Replayed) != 1 || parsed.Replayed[0].ID != d.ID || parsed.Replayed[0].Status != gmailDeliveryOK {
+		t.Fatalf("unexpected replay: %s", out)
+	}
+	if len(got) != 1 || got[0].HistoryID != "150" {
+		t.Fatalf("unexpected hook payloads: %#v", got)
+	}
+	if dead, _ := q.Dead(); len(dead) != 0 {
+		t.Fatalf("dead letter not cleared: %v", dead)
+	}
+	if pending, _ := q.Pending(); len(pending) != 0 {
+		t.Fatalf("queue not drained: %v", pending)
+	}
+	if reloaded, _ := loadGmailWatchStore("a@b.com"); len(reloaded.Get().Deliveries) != 1 || reloaded.Get().Deliveries[0].Status != gmailDeliveryOK {
+		t.Fatalf("delivery status not recorded: %#v", reloaded.Get().Deliveries)
+	}
+}
+
+func TestGmailHookQueue_ClaimAcrossProcesses(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	server, err := newGmailHookQueue("a@b.com", 3)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	replay, err := newGmailHookQueue("a@b.com", 3)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	d, _ := newGmailHookDelivery("a@b.com", gmailRoutedPayload{payload: &gmailHookPayload{Source: "gmail", HistoryID: "9"}}, time.Now())
+	if err := server.Enqueue(d); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	if !server.Claim(d.ID) {
+		t.Fatalf("server claim failed")
+	}
+	if replay.Claim(d.ID) {
+		t.Fatalf("second process claimed an in-flight delivery")
+	}
+	if pending, _ := replay.Pending(); len(pending) != 0 {
+		t.Fatalf("in-flight delivery still listed as pending: %v", pending)
+	}
+	if err := server.Done(d); err != nil {
+		t.Fatalf("done: %v", err)
+	}
+	server.Release(d.ID)
+	if replay.Claim(d.ID) {
+		t.Fatalf("claimed a delivered payload")
+	}
+
+	// A claim abandoned by a crashed process returns to the queue once stale.
+	d2, _ := newGmailHookDelivery("a@b.com", gmailRoutedPayload{payload: &gmailHookPayload{Source: "gmail", HistoryID: "10"}}, time.Now())
+	_ = server.Enqueue(d2)
+	if !server.Claim(d2.ID) {
+		t.Fatalf("claim failed")
+	}
+	if due, _ := replay.Due(); len(due) != 0 {
+		t.Fatalf("fresh claim recovered: %v", due)
+	}
+	old := time.Now().Add(-2 * hookClaimStaleAfter)
+	if err := os.Chtimes(hookDeliveryPath(server.inflightDir, d2.ID), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if due, _ := replay.Due(); len(due) != 1 || due[0].ID != d2.ID {
+		t.Fatalf("expected stale claim to be recovered, got %v", due)
+	}
+}
+
+func TestGmailWatchStore_SharedUpdateKeepsServerCursor(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+
+	replay, err := loadGmailWatchStore("a@b.com")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	replay.shared = true
+
+	server, err := loadGmailWatchStore("a@b.com")
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if err := server.Update(func(s *gmailWatchState) error {
+		s.HistoryID = "500"
+		return nil
+	}); err != nil {
+		t.Fatalf("server update: %v", err)
+	}
+
+	if err := replay.Update(func(s *gmailWatchState) error {
+		s.Deliveries = upsertWatchDelivery(s.Deliveries, gmailWatchDelivery{ID: "d1", Status: gmailDeliveryOK})
+		return nil
+	}); err != nil {
+		t.Fatalf("replay update: %v", err)
+	}
+
+	reloaded, err := loadGmailWatchStore("a@b.com")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if state := reloaded.Get(); state.HistoryID != "500" || len(state.Deliveries) != 1 {
+		t.Fatalf("replay clobbered server state: %#v", state)
+	}
+
+	// The server's next write keeps what replay recorded, and adds to it.
+	if err := server.Update(func(s *gmailWatchState) error {
+		s.HistoryID = "600"
+		s.Deliveries = upsertWatchDelivery(s.Deliveries, gmailWatchDelivery{ID: "d2", Status: gmailDeliveryOK})
+		return nil
+	}); err != nil {
+		t.Fatalf("server update: %v", err)
+	}
+	reloaded, err = loadGmailWatchStore("a@b.com")
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if state := reloaded.Get(); state.HistoryID != "600" || len(state.Deliveries) != 2 || state.Deliveries[0].ID != "d1" {
+		t.Fatalf("server clobbered replay deliveries: %#v", state)
+	}
+	if _, err := os.Stat(reloaded.path + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("expected state lock to be released, got %v", err)
+	}
+}
+
+func TestLockGmailWatchState_BreaksStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.json")
+	unlock, err := lockGmailWatchState(path)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	old := time.Now().Add(-2 * gmailWatchStateLockStale)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	unlock2, err := lockGmailWatchState(path)
+	if err != nil {
+		t.Fatalf("expected stale lock to be broken: %v", err)
+	}
+	unlock2()
+	unlock()
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWatchRenewPoll = time.Minute
+	watchRenewRetryDelay  = 5 * time.Minute
+	// watchRenewExpiryMargin renews watches started without --ttl before
+	// Gmail expires them.
+	watchRenewExpiryMargin = time.Hour
+)
+
+// watchRenewDue reports whether a stored watch should be renewed: once
+// RenewAfterMs has passed or, without one, within an hour of expiring.
+func watchRenewDue(state gmailWatchState, now time.Time) bool {
+	if strings.TrimSpace(state.Topic) == "" {
+		return false
+	}
+	if state.RenewAfterMs > 0 {
+		return now.UnixMilli() >= state.RenewAfterMs
+	}
+	return state.ExpirationMs > 0 && now.Add(watchRenewExpiryMargin).UnixMilli() >= state.ExpirationMs
+}
+
+// runRenewLoop renews due watches of every served account until ctx is done.
+func (s *gmailWatchServer) runRenewLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.renewDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *gmailWatchServer) renewDue(ctx context.Context, now time.Time) {
+	for _, target := range s.targets() {
+		if target.store == nil || now.Before(target.renewRetryAt) || !watchRenewDue(target.store.Get(), now) {
+			continue
+		}
+		if err := target.renewWatch(ctx, now); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			target.metrics.renewFailures.Add(1)
+			target.renewRetryAt = now.Add(watchRenewRetryDelay)
+			target.warnf("watch: renew failed (retrying in %s): %v", watchRenewRetryDelay, err)
+			continue
+		}
+		target.metrics.renewals.Add(1)
+		target.logf("watch: renewed watch for %s (expires %s)", target.cfg.Account, formatUnixMillis(target.store.Get().ExpirationMs))
+	}
+}
+
+// renewWatch re-registers the stored watch like `watch renew`, but only
+// updates the expiry and the next renewal time: the history cursor and
+// delivery state stay with the running server.
+func (s *gmailWatchServer) renewWatch(ctx context.Context, now time.Time) error {
+	state := s.store.Get()
+	svc, err := s.newService(ctx, s.cfg.Account)
+	if err != nil {
+		return err
+	}
+	resp, err := requestGmailWatch(ctx, svc, state.Topic, state.Labels)
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Expiration == 0 {
+		return errors.New("watch response missing expiration")
+	}
+	return s.store.Update(func(st *gmailWatchState) error {
+		st.ExpirationMs = resp.Expiration
+		st.ProviderExpirationMs = resp.Expiration
+		st.RenewAfterMs = 0
+		if st.RenewTTLMs > 0 {
+			st.RenewAfterMs = now.UnixMilli() + st.RenewTTLMs
+		}
+		st.UpdatedAtMs = now.UnixMilli()
+		return nil
+	})
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func TestWatchRenewDue(t *testing.T) {
+	now := time.UnixMilli(1_000_000_000)
+	ms := func(d time.Duration) int64 { return now.Add(d).UnixMilli() }
+	tests := []struct {
+		name  string
+		state gmailWatchState
+		want  bool
+	}{
+		{"renew after passed", gmailWatchState{Topic: "t", RenewAfterMs: ms(-time.Second), ExpirationMs: ms(48 * time.Hour)}, true},
+		{"renew after pending", gmailWatchState{Topic: "t", RenewAfterMs: ms(time.Minute), ExpirationMs: ms(30 * time.Minute)}, false},
+		{"near expiry", gmailWatchState{Topic: "t", ExpirationMs: ms(30 * time.Minute)}, true},
+		{"far from expiry", gmailWatchState{Topic: "t", ExpirationMs: ms(48 * time.Hour)}, false},
+		{"no topic", gmailWatchState{RenewAfterMs: ms(-time.Second)}, false},
+		{"no expiry", gmailWatchState{Topic: "t"}, false},
+	}
+	for _, tt := range tests {
+		if got := watchRenewDue(tt.state, now); got != tt.want {
+			t.Fatalf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGmailWatchServer_AutoRenew(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	store := seedGmailWatchState(t, "a@b.com", "100", nil)
+	now := time.Now()
+	if err := store.Update(func(s *gmailWatchState) error {
+		s.Labels = []string{"INBOX"}
+		s.ExpirationMs = now.Add(48 * time.Hour).UnixMilli()
+		s.RenewAfterMs = now.Add(-time.Minute).UnixMilli()
+		s.RenewTTLMs = time.Hour.Milliseconds()
+		return nil
+	}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	var calls atomic.Int32
+	var fail atomic.Bool
+	expiration := now.Add(7 * 24 * time.Hour).UnixMilli()
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/watch") {
+			http.NotFound(w, r)
+			return
+		}
+		calls.Add(1)
+		if fail.Load() {
+			http.Error(w, `{"error":{"code":500,"message":"backend"}}`, http.StatusInternalServerError)
+			return
+		}
+		var req gmail.WatchRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.TopicName != "projects/p/topics/gmail" || len(req.LabelIds) != 1 {
+			t.Errorf("unexpected watch request: %#v", req)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"historyId": "999", "expiration": strconv.FormatInt(expiration, 10)})
+	})
+	defer closeSrv()
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"}, "--no-auto-renew")
+	server.renewDue(context.Background(), now)
+
+	state := server.store.Get()
+	if state.ExpirationMs != expiration || state.HistoryID != "100" || state.RenewAfterMs != now.Add(time.Hour).UnixMilli() {
+		t.Fatalf("unexpected state after renew: %#v", state)
+	}
+	if server.metrics.renewals.Load() != 1 || calls.Load() != 1 {
+		t.Fatalf("renewals=%d calls=%d", server.metrics.renewals.Load(), calls.Load())
+	}
+
+	// Not due again until RenewAfterMs; failures back off before retrying.
+	server.renewDue(context.Background(), now.Add(30*time.Minute))
+	fail.Store(true)
+	later := now.Add(2 * time.Hour)
+	server.renewDue(context.Background(), later)
+	server.renewDue(context.Background(), later.Add(time.Minute))
+	if calls.Load() != 2 || server.metrics.renewFailures.Load() != 1 {
+		t.Fatalf("calls=%d failures=%d", calls.Load(), server.metrics.renewFailures.Load())
+	}
+	server.renewDue(context.Background(), later.Add(watchRenewRetryDelay))
+	if calls.Load() != 3 {
+		t.Fatalf("expected retry after delay, calls=%d", calls.Load())
+	}
+}
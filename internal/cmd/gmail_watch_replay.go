@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/steipete/gogcli/internal/outfmt"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+type GmailWatchReplayCmd struct {
+	IDs        []string `arg:"" optional:"" name:"id" help:"Delivery IDs to replay (default: every dead-lettered delivery)"`
+	Pending    bool     `name:"pending" help:"Also deliver queued payloads that are waiting for a retry"`
+	List       bool     `name:"list" help:"List queued and dead-lettered deliveries without sending"`
+	HookURL    string   `name:"hook-url" help:"Webhook URL (default: stored hook)"`
+	HookToken  string   `name:"hook-token" help:"Webhook bearer token (default: stored hook token)"`
+	HookSecret string   `name:"hook-secret" help:"HMAC signing secret (default: stored hook secret)"`
+	HookExec   string   `name:"hook-exec" help:"Command for deliveries queued by gmail watch serve --hook-exec"`
+	Routes     string   `name:"routes" help:"Routes file for routed deliveries (default: stored routes)"`
+}
+
+type gmailReplayItem struct {
+	delivery *gmailHookDelivery
+	dead     bool
+}
+
+func (c *GmailWatchReplayCmd) Run(ctx context.Context, flags *RootFlags) error {
+	u := ui.FromContext(ctx)
+	account, err := requireAccount(flags)
+	if err != nil {
+		return err
+	}
+	queue, err := newGmailHookQueue(account, defaultHookMaxAttempts)
+	if err != nil {
+		return err
+	}
+	items, err := c.selectDeliveries(queue)
+	if err != nil {
+		return err
+	}
+	if c.List {
+		return writeReplayList(ctx, items)
+	}
+	if len(items) == 0 {
+		if outfmt.IsJSON(ctx) {
+			return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"replayed": []any{}})
+		}
+		u.Err().Println("No deliveries to replay")
+		return nil
+	}
+
+	store, err := loadGmailWatchStore(account)
+	if err != nil {
+		return err
+	}
+	// gmail watch serve may be running and advancing the history cursor.
+	store.shared = true
+	state := store.Get()
+	hookURL, hookToken, hookSecret := c.HookURL, c.HookToken, c.HookSecret
+	if c.HookExec != "" && c.HookURL != "" {
+		return usage("use either --hook-url or --hook-exec")
+	}
+	if hookURL == "" && c.HookExec == "" && state.Hook != nil {
+		hookURL = state.Hook.URL
+		if hookToken == "" {
+			hookToken = state.Hook.Token
+		}
+		if hookSecret == "" {
+			hookSecret = state.Hook.Secret
+		}
+	}
+	routesFile := state.Routes
+	if c.Routes != "" {
+		routesFile = c.Routes
+	}
+	if hookURL == "" && c.HookExec == "" && routesFile == "" {
+		return usage("no hook configured; pass --hook-url or run gmail watch serve --save-hook")
+	}
+	var routes []*gmailHookRoute
+	if routesFile != "" {
+		if routes, err = loadGmailHookRoutes(routesFile); err != nil {
+			return err
+		}
+	}
+
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.delivery.ID)
+	}
+	if err := dryRunExit(ctx, flags, "gmail.watch.replay", map[string]any{
+		"account":    account,
+		"hookUrl":    hookURL,
+		"routes":     routesFile,
+		"deliveries": ids,
+	}); err != nil {
+		return err
+	}
+
+	server := &gmailWatchServer{
+		cfg:        gmailWatchServeConfig{Account: account, HookURL: hookURL, HookToken: hookToken, HookSecret: hookSecret, HookExec: c.HookExec},
+		store:      store,
+		queue:      queue,
+		routes:     routes,
+		hookClient: &http.Client{Timeout: defaultHookRequestTimeoutSec * time.Second},
+		logf:       u.Err().Printf,
+		warnf:      u.Err().Printf,
+	}
+	type replayResult struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+		Error  string `json:"error,omitempty"`
+	}
+	results := make([]replayResult, 0, len(items))
+	failed := 0
+	for _, item := range items {
+		d := item.delivery
+		if item.dead {
+			revived, err := queue.Revive(d)
+			if err != nil {
+				return err
+			}
+			if !revived {
+				continue
+			}
+		} else if !queue.Claim(d.ID) {
+			// gmail watch serve (or another replay) is sending it right now.
+			continue
+		}
+		status, sendErr := server.deliverQueued(ctx, d)
+		queue.Release(d.ID)
+		res := replayResult{ID: d.ID, Status: status}
+		if sendErr != nil {
+			if status == "" {
+				return sendErr
+			}
+			failed++
+			res.Error = sendErr.Error()
+		}
+		results = append(results, res)
+	}
+
+	if outfmt.IsJSON(ctx) {
+		if err := outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"replayed": results}); err != nil {
+			return err
+		}
+	} else {
+		w, flush := tableWriter(ctx)
+		fmt.Fprintln(w, "ID\tSTATUS\tERROR")
+		for _, res := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", res.ID, res.Status, orDash(res.Error))
+		}
+		flush()
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deliveries failed", failed, len(results))
+	}
+	return nil
+}
+
+// selectDeliveries returns the deliveries named on the command line, or every
+// dead-lettered delivery (plus pending ones with --pending or --list).
+func (c *GmailWatchReplayCmd) selectDeliveries(queue *gmailHookQueue) ([]gmailReplayItem, error) {
+	dead, err := queue.Dead()
+	if err != nil {
+		return nil, err
+	}
+	pending, err := queue.Pending()
+	if err != nil {
+		return nil, err
+	}
+	all := make([]gmailReplayItem, 0, len(dead)+len(pending))
+	for _, d := range dead {
+		all = append(all, gmailReplayItem{delivery: d, dead: true})
+	}
+	for _, d := range pending {
+		all = append(all, gmailReplayItem{delivery: d})
+	}
+
+	if len(c.IDs) == 0 {
+		if c.Pending || c.List {
+			return all, nil
+		}
+		return all[:len(dead)], nil
+	}
+	byID := make(map[string]gmailReplayItem, len(all))
+	for _, item := range all {
+		byID[item.delivery.ID] = item
+	}
+	out := make([]gmailReplayItem, 0, len(c.IDs))
+	seen := make(map[string]struct{}, len(c.IDs))
+	for _, raw := range c.IDs {
+		for _, id := range splitCommaList(raw) {
+			item, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", errDeliveryNotFound, id)
+			}
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func writeReplayList(ctx context.Context, items []gmailReplayItem) error {
+	if outfmt.IsJSON(ctx) {
+		type listed struct {
+			*gmailHookDelivery
+			State string `json:"state"`
+		}
+		out := make([]listed, 0, len(items))
+		for _, item := range items {
+			out = append(out, listed{gmailHookDelivery: item.delivery, State: replayItemState(item)})
+		}
+		return outfmt.WriteJSON(ctx, os.Stdout, map[string]any{"deliveries": out})
+	}
+	if len(items) == 0 {
+		ui.FromContext(ctx).Err().Println("No queued deliveries")
+		return nil
+	}
+	w, flush := tableWriter(ctx)
+	defer flush()
+	fmt.Fprintln(w, "ID\tSTATE\tROUTE\tATTEMPTS\tHISTORY_ID\tMESSAGES\tNEXT_ATTEMPT\tLAST_ERROR")
+	for _, item := range items {
+		d := item.delivery
+		next := "-"
+		if d.NextAttemptAtMs > 0 {
+			next = formatUnixMillis(d.NextAttemptAtMs)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%d\t%s\t%s\n", d.ID, replayItemState(item), orDash(d.Route), d.Attempts, orDash(d.HistoryID), len(d.MessageIDs), next, orDash(strings.TrimSpace(d.LastError)))
+	}
+	return nil
+}
+
+func replayItemState(item gmailReplayItem) string {
+	if item.dead {
+		return gmailDeliveryDeadLetter
+	}
+	return gmailDeliveryQueued
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,504 @@
+package cmd
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yosuke-furukawa/json5/encoding/json5"
+
+	"github.com/steipete/gogcli/internal/config"
+)
+
+const (
+	gmailHookTimestampHeader = "X-Gog-Timestamp"
+	gmailHookSignatureHeader = "X-Gog-Signature"
+
+	// Label names used by routes are reloaded this often, and at most every
+	// gmailWatchLabelMinRefresh when a message has an unknown user label.
+	gmailWatchLabelTTL        = 10 * time.Minute
+	gmailWatchLabelMinRefresh = 30 * time.Second
+)
+
+var (
+	errHookRouteNotFound = errors.New("hook route not configured")
+	errInvalidRouteQuery = errors.New("invalid route query")
+)
+
+// gmailHookEndpoint is one webhook a payload is POSTed to, or a command
+// run with the payload on stdin.
+type gmailHookEndpoint struct {
+	URL    string `json:"url,omitempty"`
+	Token  string `json:"token,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	Exec   string `json:"exec,omitempty"`
+}
+
+// target names the endpoint in logs.
+func (h gmailHookEndpoint) target() string {
+	if h.Exec != "" {
+		return "exec:" + h.Exec
+	}
+	return h.URL
+}
+
+// gmailHookRouteSpec is one entry of the routes file. Every predicate that is
+// set must match; a route without predicates matches every message.
+type gmailHookRouteSpec struct {
+	Name        string              `json:"name"`
+	From        []string            `json:"from,omitempty"`
+	To          []string            `json:"to,omitempty"`
+	Subject     string              `json:"subject,omitempty"`
+	Labels      []string            `json:"labels,omitempty"`
+	Query       string              `json:"query,omitempty"`
+	Final       bool                `json:"final,omitempty"`
+	IncludeBody bool                `json:"includeBody,omitempty"`
+	MaxBytes    int                 `json:"maxBytes,omitempty"`
+	Hooks       []gmailHookEndpoint `json:"hooks"`
+}
+
+type gmailHookRoutesFile struct {
+	Routes []gmailHookRouteSpec `json:"routes"`
+}
+
+type gmailHookRoute struct {
+	gmailHookRouteSpec
+	subject *regexp.Regexp
+	terms   []gmailRouteTerm
+}
+
+// gmailRouteTerm is one predicate of a route query, e.g. from:alerts or
+// -label:Newsletters. An empty field matches free text.
+type gmailRouteTerm struct {
+	negate bool
+	field  string
+	value  string
+}
+
+// gmailRoutedPayload is a payload bound for one hook of one route; route is
+// empty for the default hook.
+type gmailRoutedPayload struct {
+	route   string
+	hook    gmailHookEndpoint
+	payload *gmailHookPayload
+}
+
+func loadGmailHookRoutes(path string) ([]*gmailHookRoute, error) {
+	expanded, err := config.ExpandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(expanded) //nolint:gosec // user-provided routes file
+	if err != nil {
+		return nil, err
+	}
+	var file gmailHookRoutesFile
+	if err := json5.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse routes %s: %w", path, err)
+	}
+	if len(file.Routes) == 0 {
+		return nil, fmt.Errorf("routes %s: no routes defined", path)
+	}
+	routes := make([]*gmailHookRoute, 0, len(file.Routes))
+	seen := make(map[string]struct{}, len(file.Routes))
+	for i, spec := range file.Routes {
+		route, err := compileGmailHookRoute(spec, i)
+		if err != nil {
+			return nil, fmt.Errorf("routes %s: %w", path, err)
+		}
+		if _, dup := seen[route.Name]; dup {
+			return nil, fmt.Errorf("routes %s: duplicate route name %q", path, route.Name)
+		}
+		seen[route.Name] = struct{}{}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+func compileGmailHookRoute(spec gmailHookRouteSpec, index int) (*gmailHookRoute, error) {
+	spec.Name = strings.TrimSpace(spec.Name)
+	if spec.Name == "" {
+		spec.Name = fmt.Sprintf("route-%d", index+1)
+	}
+	if len(spec.Hooks) == 0 {
+		return nil, fmt.Errorf("route %q: at least one hook is required", spec.Name)
+	}
+	for _, hook := range spec.Hooks {
+		hasURL, hasExec := strings.TrimSpace(hook.URL) != "", strings.TrimSpace(hook.Exec) != ""
+		if hasURL == hasExec {
+			return nil, fmt.Errorf("route %q: each hook needs either url or exec", spec.Name)
+		}
+	}
+	if spec.MaxBytes < 0 {
+		return nil, fmt.Errorf("route %q: maxBytes must be >= 0", spec.Name)
+	}
+	if spec.IncludeBody && spec.MaxBytes == 0 {
+		spec.MaxBytes = defaultHookMaxBytes
+	}
+	route := &gmailHookRoute{gmailHookRouteSpec: spec}
+	if spec.Subject != "" {
+		re, err := regexp.Compile("(?i)" + spec.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("route %q: subject: %w", spec.Name, err)
+		}
+		route.subject = re
+	}
+	terms, err := parseGmailRouteQuery(spec.Query)
+	if err != nil {
+		return nil, fmt.Errorf("route %q: %w", spec.Name, err)
+	}
+	route.terms = terms
+	return route, nil
+}
+
+// parseGmailRouteQuery parses a small subset of Gmail search syntax:
+// whitespace-separated terms (all must match), "quoted phrases", a leading
+// '-' for negation, and the from:, to:, subject:, label:, is:, in: and
+// category: operators. Other words match from, to, subject, snippet or body.
+func parseGmailRouteQuery(query string) ([]gmailRouteTerm, error) {
+	var terms []gmailRouteTerm
+	for _, token := range splitRouteQuery(query) {
+		term := gmailRouteTerm{}
+		if strings.HasPrefix(token, "-") && len(token) > 1 {
+			term.negate = true
+			token = token[1:]
+		}
+		if field, value, ok := strings.Cut(token, ":"); ok && !strings.HasPrefix(token, "\"") {
+			term.field = strings.ToLower(field)
+			term.value = strings.Trim(value, "\"")
+			switch term.field {
+			case "from", "to", "subject", "label":
+			case "is", "in":
+				label, known := map[string]string{
+					"unread": "UNREAD", "starred": "STARRED", "important": "IMPORTANT",
+					"inbox": "INBOX", "sent": "SENT", "spam": "SPAM", "trash": "TRASH",
+				}[strings.ToLower(term.value)]
+				if !known {
+					return nil, fmt.Errorf("%w: unsupported %s:%s", errInvalidRouteQuery, term.field, term.value)
+				}
+				term.field, term.value = "label", label
+			case "category":
+				term.field, term.value = "label", "CATEGORY_"+strings.ToUpper(term.value)
+			default:
+				return nil, fmt.Errorf("%w: unsupported operator %s:", errInvalidRouteQuery, term.field)
+			}
+		} else {
+			term.value = strings.Trim(token, "\"")
+		}
+		if term.value == "" {
+			return nil, fmt.Errorf("%w: empty term %q", errInvalidRouteQuery, token)
+		}
+		terms = append(terms, term)
+	}
+	return terms, nil
+}
+
+// splitRouteQuery splits on whitespace outside double quotes.
+func splitRouteQuery(query string) []string {
+	var out []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if cur.Len() > 0 {
+				out = append(out, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
+
+func (r *gmailHookRoute) catchAll() bool {
+	return len(r.From) == 0 && len(r.To) == 0 && r.subject == nil && len(r.Labels) == 0 && len(r.terms) == 0
+}
+
+func (r *gmailHookRoute) usesLabels() bool {
+	if len(r.Labels) > 0 {
+		return true
+	}
+	for _, term := range r.terms {
+		if term.field == "label" {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether msg satisfies every predicate of the route.
+// labelNames maps label IDs to names so routes may use either.
+func (r *gmailHookRoute) matches(msg gmailHookMessage, labelNames map[string]string) bool {
+	if len(r.From) > 0 && !containsAnyFold(msg.From, r.From) {
+		return false
+	}
+	if len(r.To) > 0 && !containsAnyFold(msg.To, r.To) {
+		return false
+	}
+	if r.subject != nil && !r.subject.MatchString(msg.Subject) {
+		return false
+	}
+	for _, label := range r.Labels {
+		if !hasLabelFold(msg.Labels, label, labelNames) {
+			return false
+		}
+	}
+	for _, term := range r.terms {
+		if term.matches(msg, labelNames) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (t gmailRouteTerm) matches(msg gmailHookMessage, labelNames map[string]string) bool {
+	switch t.field {
+	case "from":
+		return containsFold(msg.From, t.value)
+	case "to":
+		return containsFold(msg.To, t.value)
+	case "subject":
+		return containsFold(msg.Subject, t.value)
+	case "label":
+		return hasLabelFold(msg.Labels, t.value, labelNames)
+	default:
+		return containsAnyField(msg, t.value)
+	}
+}
+
+func containsAnyField(msg gmailHookMessage, value string) bool {
+	for _, field := range []string{msg.From, msg.To, msg.Subject, msg.Snippet, msg.Body} {
+		if containsFold(field, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func containsAnyFold(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if containsFold(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasLabelFold(labelIDs []string, want string, labelNames map[string]string) bool {
+	for _, id := range labelIDs {
+		if strings.EqualFold(id, want) || strings.EqualFold(labelNames[id], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeHookPayloads splits payload into the payloads for each matching route
+// hook. Messages no route matched go to the default hook (if configured);
+// deleted message IDs carry no metadata and go to the default hook and to
+// catch-all routes.
+func (s *gmailWatchServer) routeHookPayloads(ctx context.Context, payload *gmailHookPayload) []gmailRoutedPayload {
+	defaultHook := s.defaultHook()
+	if len(s.routes) == 0 {
+		if !s.hasDefaultHook() {
+			return nil
+		}
+		return splitExecPayloads([]gmailRoutedPayload{{hook: defaultHook, payload: payload}})
+	}
+
+	labelNames := s.routeLabelNames(ctx, payload.Messages)
+	matched := make([][]gmailHookMessage, len(s.routes))
+	var unmatched []gmailHookMessage
+	for _, msg := range payload.Messages {
+		hit := false
+		for i, route := range s.routes {
+			if !route.matches(msg, labelNames) {
+				continue
+			}
+			matched[i] = append(matched[i], msg)
+			hit = true
+			if route.Final {
+				break
+			}
+		}
+		if !hit {
+			unmatched = append(unmatched, msg)
+		}
+	}
+
+	var out []gmailRoutedPayload
+	for i, route := range s.routes {
+		var deleted []string
+		if route.catchAll() {
+			deleted = payload.DeletedMessageIDs
+		}
+		if len(matched[i]) == 0 && len(deleted) == 0 {
+			continue
+		}
+		routed := &gmailHookPayload{
+			Source:            payload.Source,
+			Account:           payload.Account,
+			HistoryID:         payload.HistoryID,
+			Messages:          shapeHookMessages(matched[i], route.IncludeBody, route.MaxBytes),
+			DeletedMessageIDs: deleted,
+		}
+		for _, hook := range route.Hooks {
+			if hook.Secret == "" {
+				hook.Secret = s.cfg.HookSecret
+			}
+			out = append(out, gmailRoutedPayload{route: route.Name, hook: hook, payload: routed})
+		}
+	}
+	if s.hasDefaultHook() && (len(unmatched) > 0 || len(payload.DeletedMessageIDs) > 0) {
+		out = append(out, gmailRoutedPayload{hook: defaultHook, payload: &gmailHookPayload{
+			Source:            payload.Source,
+			Account:           payload.Account,
+			HistoryID:         payload.HistoryID,
+			Messages:          shapeHookMessages(unmatched, s.cfg.IncludeBody, s.cfg.MaxBodyBytes),
+			DeletedMessageIDs: payload.DeletedMessageIDs,
+		}})
+	}
+	return splitExecPayloads(out)
+}
+
+// shapeHookMessages applies a hook's body settings to messages that may
+// have been fetched with a larger (or any) body for another route.
+func shapeHookMessages(msgs []gmailHookMessage, includeBody bool, maxBytes int) []gmailHookMessage {
+	out := make([]gmailHookMessage, len(msgs))
+	for i, msg := range msgs {
+		if !includeBody {
+			msg.Body, msg.BodyTruncated = "", false
+		} else if body, truncated := truncateUTF8Bytes(msg.Body, maxBytes); truncated {
+			msg.Body, msg.BodyTruncated = body, true
+		}
+		out[i] = msg
+	}
+	return out
+}
+
+// bodyFetch returns whether message bodies must be fetched and the largest
+// body any hook receives.
+func (s *gmailWatchServer) bodyFetch() (bool, int) {
+	include, maxBytes := s.cfg.IncludeBody, 0
+	if include {
+		maxBytes = s.cfg.MaxBodyBytes
+	}
+	for _, route := range s.routes {
+		if route.IncludeBody {
+			include = true
+			maxBytes = max(maxBytes, route.MaxBytes)
+		}
+	}
+	return include, maxBytes
+}
+
+// routeLabelNames lazily loads label names when a route matches on labels,
+// so routes can name user labels instead of their opaque IDs. The names are
+// reloaded after gmailWatchLabelTTL, and sooner when msgs carry a user label
+// created (or renamed) since the last load.
+func (s *gmailWatchServer) routeLabelNames(ctx context.Context, msgs []gmailHookMessage) map[string]string {
+	needed := false
+	for _, route := range s.routes {
+		needed = needed || route.usesLabels()
+	}
+	if !needed {
+		return nil
+	}
+	s.labelMu.Lock()
+	defer s.labelMu.Unlock()
+	age := time.Since(s.labelsAt)
+	if s.labelNames != nil && age < gmailWatchLabelTTL &&
+		(age < gmailWatchLabelMinRefresh || !hasUnknownUserLabel(msgs, s.labelNames)) {
+		return s.labelNames
+	}
+	svc, err := s.newService(ctx, s.cfg.Account)
+	var names map[string]string
+	if err == nil {
+		names, err = fetchLabelIDToName(svc)
+	}
+	if err != nil {
+		s.warnf("watch: load labels for routes: %v", err)
+		// Keep matching with the names we have rather than none at all.
+		return s.labelNames
+	}
+	s.labelNames, s.labelsAt = names, time.Now()
+	return s.labelNames
+}
+
+func hasUnknownUserLabel(msgs []gmailHookMessage, labelNames map[string]string) bool {
+	for _, msg := range msgs {
+		for _, id := range msg.Labels {
+			if _, ok := labelNames[id]; !ok && strings.HasPrefix(id, "Label_") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *gmailWatchServer) hasHooks() bool {
+	return s.hasDefaultHook() || len(s.routes) > 0
+}
+
+func (s *gmailWatchServer) hasDefaultHook() bool {
+	return s.cfg.HookURL != "" || s.cfg.HookExec != ""
+}
+
+func (s *gmailWatchServer) defaultHook() gmailHookEndpoint {
+	return gmailHookEndpoint{URL: s.cfg.HookURL, Token: s.cfg.HookToken, Secret: s.cfg.HookSecret, Exec: s.cfg.HookExec}
+}
+
+// hookFor resolves the endpoint of a queued delivery. The default hook is
+// always the currently configured one; route hooks must still exist.
+func (s *gmailWatchServer) hookFor(route, url, execCmd string) (gmailHookEndpoint, error) {
+	if route == "" {
+		if !s.hasDefaultHook() {
+			return gmailHookEndpoint{}, errNoHookConfigured
+		}
+		return s.defaultHook(), nil
+	}
+	for _, r := range s.routes {
+		if r.Name != route {
+			continue
+		}
+		for _, hook := range r.Hooks {
+			if hook.URL == url && hook.Exec == execCmd {
+				if hook.Secret == "" {
+					hook.Secret = s.cfg.HookSecret
+				}
+				return hook, nil
+			}
+		}
+	}
+	return gmailHookEndpoint{}, fmt.Errorf("%w: %s %s", errHookRouteNotFound, route, gmailHookEndpoint{URL: url, Exec: execCmd}.target())
+}
+
+// signGmailHook returns the signature header value for body: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with secret.
+func signGmailHook(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
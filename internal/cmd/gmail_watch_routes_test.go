@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/gmail/v1"
+)
+
+func writeRoutesFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "routes.json5")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write routes: %v", err)
+	}
+	return path
+}
+
+func TestGmailHookRoute_Matches(t *testing.T) {
+	msg := gmailHookMessage{
+		From:    "Billing <billing@stripe.com>",
+		To:      "ops@example.com",
+		Subject: "Your invoice #42",
+		Snippet: "Payment received",
+		Labels:  []string{"INBOX", "UNREAD", "Label_7"},
+	}
+	names := map[string]string{"Label_7": "Finance"}
+
+	tests := []struct {
+		name string
+		spec gmailHookRouteSpec
+		want bool
+	}{
+		{"catch-all", gmailHookRouteSpec{}, true},
+		{"from", gmailHookRouteSpec{From: []string{"nobody@", "@STRIPE.com"}}, true},
+		{"from miss", gmailHookRouteSpec{From: []string{"@paypal.com"}}, false},
+		{"subject regex", gmailHookRouteSpec{Subject: "invoice|receipt"}, true},
+		{"subject miss", gmailHookRouteSpec{Subject: "^receipt"}, false},
+		{"label id and name", gmailHookRouteSpec{Labels: []string{"inbox", "finance"}}, true},
+		{"label missing", gmailHookRouteSpec{Labels: []string{"INBOX", "Travel"}}, false},
+		{"query", gmailHookRouteSpec{Query: `from:stripe is:unread label:Finance "payment received"`}, true},
+		{"query negation", gmailHookRouteSpec{Query: "from:stripe -in:inbox"}, false},
+		{"query to", gmailHookRouteSpec{Query: "to:ops@ -category:promotions"}, true},
+		{"predicates combine", gmailHookRouteSpec{From: []string{"stripe"}, Query: "subject:refund"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := tt.spec
+			spec.Hooks = []gmailHookEndpoint{{URL: "http://example.com"}}
+			route, err := compileGmailHookRoute(spec, 0)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if got := route.matches(msg, names); got != tt.want {
+				t.Fatalf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadGmailHookRoutes(t *testing.T) {
+	routes, err := loadGmailHookRoutes(writeRoutesFile(t, `{
+  // comments and trailing commas are fine (JSON5)
+  routes: [
+    {name: "billing", from: ["@stripe.com"], includeBody: true, hooks: [{url: "http://example.com/billing"}]},
+    {hooks: [{url: "http://example.com/all", secret: "s"}],},
+  ],
+}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(routes) != 2 || routes[0].Name != "billing" || routes[0].MaxBytes != defaultHookMaxBytes || routes[1].Name != "route-2" || !routes[1].catchAll() {
+		t.Fatalf("unexpected routes: %#v", routes)
+	}
+
+	for name, content := range map[string]string{
+		"no routes":    `{routes: []}`,
+		"no hooks":     `{routes: [{name: "a"}]}`,
+		"missing url":  `{routes: [{name: "a", hooks: [{token: "t"}]}]}`,
+		"bad regex":    `{routes: [{subject: "(", hooks: [{url: "http://x"}]}]}`,
+		"bad operator": `{routes: [{query: "has:attachment", hooks: [{url: "http://x"}]}]}`,
+		"duplicate":    `{routes: [{name: "a", hooks: [{url: "http://x"}]}, {name: "a", hooks: [{url: "http://y"}]}]}`,
+	} {
+		if _, err := loadGmailHookRoutes(writeRoutesFile(t, content)); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestGmailWatchServer_RoutesAndSignsHooks(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	type received struct {
+		payload gmailHookPayload
+		header  http.Header
+		body    []byte
+	}
+	var mu sync.Mutex
+	got := map[string]received{}
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload gmailHookPayload
+		_ = json.Unmarshal(body, &payload)
+		mu.Lock()
+		got[r.URL.Path] = received{payload: payload, header: r.Header.Clone(), body: body}
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer hookSrv.Close()
+
+	routesPath := writeRoutesFile(t, `{routes: [
+  {name: "billing", from: ["@stripe.com"], includeBody: true, maxBytes: 4, final: true,
+   hooks: [{url: "`+hookSrv.URL+`/billing"}, {url: "`+hookSrv.URL+`/ledger", secret: "ledger-secret"}]},
+  {name: "finance", labels: ["Finance"], hooks: [{url: "`+hookSrv.URL+`/finance"}]},
+]}`)
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		msg := func(id, from string, labels ...string) map[string]any {
+			return map[string]any{
+				"id": id, "threadId": "t" + id, "labelIds": labels,
+				"payload": map[string]any{
+					"mimeType": "text/plain",
+					"headers":  []map[string]any{{"name": "From", "value": from}},
+					"body":     map[string]any{"data": "aGVsbG8gd29ybGQ"},
+				},
+			}
+		}
+		switch {
+		case strings.Contains(r.URL.Path, "/history"):
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"historyId": "200",
+				"history": []map[string]any{{"messagesAdded": []map[string]any{
+					{"message": map[string]any{"id": "m1"}},
+					{"message": map[string]any{"id": "m2"}},
+					{"message": map[string]any{"id": "m3"}},
+				}}},
+			})
+		case strings.Contains(r.URL.Path, "/labels"):
+			_ = json.NewEncoder(w).Encode(map[string]any{"labels": []map[string]any{{"id": "Label_7", "name": "Finance"}}})
+		case strings.Contains(r.URL.Path, "/messages/m1"):
+			_ = json.NewEncoder(w).Encode(msg("m1", "billing@stripe.com", "INBOX", "Label_7"))
+		case strings.Contains(r.URL.Path, "/messages/m2"):
+			_ = json.NewEncoder(w).Encode(msg("m2", "cfo@example.com", "INBOX", "Label_7"))
+		case strings.Contains(r.URL.Path, "/messages/m3"):
+			_ = json.NewEncoder(w).Encode(msg("m3", "friend@example.com", "INBOX"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer closeSrv()
+	origNew := newGmailService
+	t.Cleanup(func() { newGmailService = origNew })
+	newGmailService = func(context.Context, string) (*gmail.Service, error) { return svc, nil }
+
+	server := serveGmailWatchForTest(t, &RootFlags{Account: "a@b.com"},
+		"--hook-url", hookSrv.URL+"/default", "--hook-secret", "default-secret",
+		"--routes", routesPath, "--hook-max-attempts", "0")
+	if len(server.routes) != 2 || server.queue != nil {
+		t.Fatalf("unexpected server: routes=%d queue=%v", len(server.routes), server.queue)
+	}
+
+	pushGmailWatch(t, server, `{"emailAddress":"a@b.com","historyId":"200"}`)
+
+	ids := func(p gmailHookPayload) string {
+		out := make([]string, 0, len(p.Messages))
+		for _, m := range p.Messages {
+			out = append(out, m.ID+"="+m.Body)
+		}
+		return strings.Join(out, ",")
+	}
+	// m1 matches billing (final, so not finance), m2 finance, m3 nothing.
+	for path, want := range map[string]string{
+		"/billing": "m1=hell",
+		"/ledger":  "m1=hell",
+		"/finance": "m2=",
+		"/default": "m3=",
+	} {
+		r, ok := got[path]
+		if !ok {
+			t.Fatalf("no delivery to %s: %v", path, got)
+		}
+		if ids(r.payload) != want {
+			t.Fatalf("%s: got %s, want %s", path, ids(r.payload), want)
+		}
+	}
+	if !got["/billing"].payload.Messages[0].BodyTruncated {
+		t.Fatalf("expected truncated body")
+	}
+
+	for path, secret := range map[string]string{"/default": "default-secret", "/billing": "default-secret", "/ledger": "ledger-secret"} {
+		r := got[path]
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(r.header.Get(gmailHookTimestampHeader) + "."))
+		mac.Write(r.body)
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.header.Get(gmailHookSignatureHeader) != want {
+			t.Fatalf("%s: bad signature %q", path, r.header.Get(gmailHookSignatureHeader))
+		}
+	}
+}
+
+func TestGmailWatchServeCmd_HookSecretRequiresHook(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	seedGmailWatchState(t, "a@b.com", "100", nil)
+
+	err := runKong(t, &GmailWatchServeCmd{}, []string{"--hook-secret", "s"}, context.Background(), &RootFlags{Account: "a@b.com"})
+	if err == nil || !strings.Contains(err.Error(), "--hook-secret") {
+		t.Fatalf("expected usage error, got %v", err)
+	}
+}
+
+func pushGmailWatch(t *testing.T, server *gmailWatchServer, data string) {
+	t.Helper()
+
+	env := pubsubPushEnvelope{}
+	env.Message.Data = base64.StdEncoding.EncodeToString([]byte(data))
+	body, _ := json.Marshal(env)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, server.cfg.Path, bytes.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("push status: %d", rr.Code)
+	}
+}
+
+func TestGmailWatchServer_RouteLabelNamesRefresh(t *testing.T) {
+	var calls atomic.Int32
+	labels := []map[string]any{{"id": "Label_7", "name": "Finance"}}
+	svc, closeSrv := newGmailServiceForTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Path, "/labels") {
+			http.NotFound(w, r)
+			return
+		}
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"labels": labels})
+	})
+	defer closeSrv()
+
+	route := &gmailHookRoute{gmailHookRouteSpec: gmailHookRouteSpec{Name: "receipts", Labels: []string{"Receipts"}}}
+	server := &gmailWatchServer{
+		routes:     []*gmailHookRoute{route},
+		newService: func(context.Context, string) (*gmail.Service, error) { return svc, nil },
+		warnf:      func(string, ...any) {},
+	}
+	ctx := context.Background()
+	receipt := []gmailHookMessage{{ID: "m1", Labels: []string{"INBOX", "Label_9"}}}
+
+	if names := server.routeLabelNames(ctx, nil); names["Label_7"] != "Finance" || calls.Load() != 1 {
+		t.Fatalf("unexpected first load: %v (%d calls)", names, calls.Load())
+	}
+	// The label was created after the first load; a fresh cache is not
+	// reloaded for it right away.
+	labels = append(labels, map[string]any{"id": "Label_9", "name": "Receipts"})
+	if names := server.routeLabelNames(ctx, receipt); route.matches(receipt[0], names) || calls.Load() != 1 {
+		t.Fatalf("expected cached labels, got %v (%d calls)", names, calls.Load())
+	}
+
+	server.labelsAt = time.Now().Add(-2 * gmailWatchLabelMinRefresh)
+	if names := server.routeLabelNames(ctx, receipt); !route.matches(receipt[0], names) || calls.Load() != 2 {
+		t.Fatalf("expected reload for unknown label, got %v (%d calls)", names, calls.Load())
+	}
+	if server.routeLabelNames(ctx, receipt); calls.Load() != 2 {
+		t.Fatalf("known labels reloaded: %d calls", calls.Load())
+	}
+
+	// Renames are picked up once the cache expires.
+	labels[1]["name"] = "Invoices"
+	server.labelsAt = time.Now().Add(-2 * gmailWatchLabelTTL)
+	if names := server.routeLabelNames(ctx, nil); names["Label_9"] != "Invoices" || calls.Load() != 3 {
+		t.Fatalf("expected reload after TTL, got %v (%d calls)", names, calls.Load())
+	}
+}
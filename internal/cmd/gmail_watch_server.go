@@ -10,7 +10,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/api/gmail/v1"
@@ -28,15 +30,32 @@ const (
 type gmailWatchServer struct {
 	cfg             gmailWatchServeConfig
 	store           *gmailWatchStore
+	accounts        map[string]*gmailWatchServer
+	queue           *gmailHookQueue
+	routes          []*gmailHookRoute
+	execSem         chan struct{}
 	validator       *idtoken.Validator
 	newService      func(context.Context, string) (*gmail.Service, error)
 	hookClient      *http.Client
 	excludeLabelIDs map[string]struct{}
 	logf            func(string, ...any)
 	warnf           func(string, ...any)
+
+	labelMu    sync.Mutex
+	labelNames map[string]string
+	labelsAt   time.Time
+
+	metrics        gmailWatchMetrics
+	readyMu        sync.Mutex
+	readyCheckedAt time.Time
+	readyErr       error
+	renewRetryAt   time.Time
 }
 
 func (s *gmailWatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.ProbeAddr == "" && s.serveProbe(w, r, true) {
+		return
+	}
 	if !pathMatches(s.cfg.Path, r.URL.Path) {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -63,42 +82,96 @@ func (s *gmailWatchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if payload.EmailAddress != "" && !strings.EqualFold(payload.EmailAddress, s.cfg.Account) {
-		s.warnf("watch: ignoring push for %s", payload.EmailAddress)
-		w.WriteHeader(http.StatusAccepted)
+	result, status := s.processPush(r.Context(), payload)
+	if result != nil {
+		_ = json.NewEncoder(w).Encode(result)
 		return
 	}
+	w.WriteHeader(status)
+}
+
+// processPush routes a decoded Gmail notification to its account, fetches
+// the new messages, and delivers them to the hooks. It returns the HTTP
+// status for the push (500 means "redeliver") and, for accounts without any
+// hook, the payload to hand back to the caller instead.
+func (s *gmailWatchServer) processPush(ctx context.Context, payload gmailPushPayload) (*gmailHookPayload, int) {
+	target := s.route(payload.EmailAddress)
+	if target == nil {
+		if payload.EmailAddress == "" {
+			s.warnf("watch: ignoring push without emailAddress")
+		} else {
+			s.warnf("watch: ignoring push for %s", payload.EmailAddress)
+		}
+		return nil, http.StatusAccepted
+	}
+	target.metrics.pushes.Add(1)
 
-	result, err := s.handlePush(r.Context(), payload)
+	result, err := target.handlePush(ctx, payload)
 	if err != nil {
 		if errors.Is(err, errNoNewMessages) {
-			w.WriteHeader(http.StatusAccepted)
-			return
+			return nil, http.StatusAccepted
 		}
-		s.warnf("watch: handle push failed: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		target.warnf("watch: handle push failed: %v", err)
+		return nil, http.StatusInternalServerError
 	}
 	if result == nil {
-		w.WriteHeader(http.StatusAccepted)
-		return
+		return nil, http.StatusAccepted
 	}
 
-	if s.cfg.HookURL == "" {
-		if s.cfg.AllowNoHook {
-			_ = json.NewEncoder(w).Encode(result)
-			return
+	if !target.hasHooks() {
+		if target.cfg.AllowNoHook {
+			return result, http.StatusOK
 		}
-		w.WriteHeader(http.StatusAccepted)
-		return
+		return nil, http.StatusAccepted
 	}
 
-	if err := s.sendHook(r.Context(), result); err != nil {
-		s.warnf("watch: hook failed: %v", err)
-		w.WriteHeader(http.StatusOK)
-		return
+	target.deliverHooks(ctx, result)
+	return nil, http.StatusOK
+}
+
+// deliverHooks sends payload to every hook it is routed to, concurrently.
+// Failures are logged (and queued for retry when the queue is enabled).
+func (s *gmailWatchServer) deliverHooks(ctx context.Context, payload *gmailHookPayload) {
+	var wg sync.WaitGroup
+	for _, routed := range s.routeHookPayloads(ctx, payload) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.queue != nil {
+				if status, err := s.queueHook(ctx, routed); err != nil {
+					s.warnf("watch: hook %s failed (%s): %v", routed.hook.target(), status, err)
+				}
+				return
+			}
+			err := s.sendHookTo(ctx, routed.hook, routed.payload)
+			s.countDelivery(len(routed.payload.Messages), err)
+			if err != nil {
+				s.warnf("watch: hook failed: %v", err)
+			}
+		}()
 	}
-	w.WriteHeader(http.StatusOK)
+	wg.Wait()
+}
+
+// route returns the server handling pushes for emailAddress: s itself when
+// serving a single account, or the per-account server when serving several.
+// It returns nil for pushes that belong to no served account.
+func (s *gmailWatchServer) route(emailAddress string) *gmailWatchServer {
+	if s.accounts == nil {
+		if emailAddress == "" || strings.EqualFold(emailAddress, s.cfg.Account) {
+			return s
+		}
+		return nil
+	}
+	if emailAddress == "" {
+		if len(s.accounts) == 1 {
+			for _, target := range s.accounts {
+				return target
+			}
+		}
+		return nil
+	}
+	return s.accounts[strings.ToLower(emailAddress)]
 }
 
 func (s *gmailWatchServer) authorize(r *http.Request) bool {
@@ -231,6 +304,7 @@ func (s *gmailWatchServer) handlePush(ctx context.Context, payload gmailPushPayl
 }
 
 func (s *gmailWatchServer) resyncHistory(ctx context.Context, svc *gmail.Service, historyID string, messageID string) (*gmailHookPayload, error) {
+	s.metrics.resyncs.Add(1)
 	list, err := svc.Users.Messages.List("me").MaxResults(s.cfg.ResyncMax).Do()
 	if err != nil {
 		return nil, err
@@ -270,8 +344,9 @@ func (s *gmailWatchServer) resyncHistory(ctx context.Context, svc *gmail.Service
 func (s *gmailWatchServer) fetchMessages(ctx context.Context, svc *gmail.Service, ids []string) ([]gmailHookMessage, int, error) {
 	messages := make([]gmailHookMessage, 0, len(ids))
 	excluded := 0
+	includeBody, maxBodyBytes := s.bodyFetch()
 	format := gmailWatchFormatMetadata
-	if s.cfg.IncludeBody {
+	if includeBody {
 		format = gmailFormatFull
 	}
 	for _, id := range ids {
@@ -309,9 +384,9 @@ func (s *gmailWatchServer) fetchMessages(ctx context.Context, svc *gmail.Service
 			Snippet:  msg.Snippet,
 			Labels:   msg.LabelIds,
 		}
-		if s.cfg.IncludeBody {
+		if includeBody {
 			body := bestBodyText(msg.Payload)
-			item.Body, item.BodyTruncated = truncateUTF8Bytes(body, s.cfg.MaxBodyBytes)
+			item.Body, item.BodyTruncated = truncateUTF8Bytes(body, maxBodyBytes)
 		}
 		messages = append(messages, item)
 	}
@@ -335,45 +410,58 @@ func (s *gmailWatchServer) isExcludedLabel(labelIDs []string) bool {
 }
 
 func (s *gmailWatchServer) sendHook(ctx context.Context, payload *gmailHookPayload) error {
+	return s.sendHookTo(ctx, s.defaultHook(), payload)
+}
+
+func (s *gmailWatchServer) sendHookTo(ctx context.Context, hook gmailHookEndpoint, payload *gmailHookPayload) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.HookURL, bytes.NewReader(data))
+	return s.postHook(ctx, hook, data)
+}
+
+// postHook POSTs an encoded hook payload and records the outcome as the
+// last delivery status. With a secret the body is signed at send time, so
+// retried deliveries carry a fresh timestamp.
+func (s *gmailWatchServer) postHook(ctx context.Context, hook gmailHookEndpoint, data []byte) error {
+	if hook.Exec != "" {
+		return s.execHook(ctx, hook, data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(data))
 	if err != nil {
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	if s.cfg.HookToken != "" {
-		req.Header.Set("Authorization", "Bearer "+s.cfg.HookToken)
+	if hook.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+hook.Token)
+	}
+	if hook.Secret != "" {
+		now := time.Now()
+		req.Header.Set(gmailHookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(gmailHookSignatureHeader, signGmailHook(hook.Secret, now, data))
 	}
 	resp, err := s.hookClient.Do(req)
 	if err != nil {
-		_ = s.store.Update(func(state *gmailWatchState) error {
-			state.LastDeliveryStatus = "error"
-			state.LastDeliveryAtMs = time.Now().UnixMilli()
-			state.LastDeliveryStatusNote = err.Error()
-			return nil
-		})
+		s.recordLastDelivery("error", err.Error())
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		_ = s.store.Update(func(state *gmailWatchState) error {
-			state.LastDeliveryStatus = gmailWatchStatusHTTPError
-			state.LastDeliveryAtMs = time.Now().UnixMilli()
-			state.LastDeliveryStatusNote = fmt.Sprintf("status %d", resp.StatusCode)
-			return nil
-		})
+		s.recordLastDelivery(gmailWatchStatusHTTPError, fmt.Sprintf("status %d", resp.StatusCode))
 		return fmt.Errorf("hook status %d", resp.StatusCode)
 	}
+	s.recordLastDelivery("ok", "")
+	return nil
+}
+
+func (s *gmailWatchServer) recordLastDelivery(status, note string) {
 	_ = s.store.Update(func(state *gmailWatchState) error {
-		state.LastDeliveryStatus = "ok"
+		state.LastDeliveryStatus = status
 		state.LastDeliveryAtMs = time.Now().UnixMilli()
-		state.LastDeliveryStatusNote = ""
+		state.LastDeliveryStatusNote = note
 		return nil
 	})
-	return nil
 }
 
 func parsePubSubPush(r *http.Request) (*pubsubPushEnvelope, error) {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -15,10 +16,18 @@ import (
 	"github.com/steipete/gogcli/internal/config"
 )
 
+const (
+	gmailWatchStateLockWait  = 5 * time.Second
+	gmailWatchStateLockStale = 30 * time.Second
+)
+
 type gmailWatchStore struct {
 	path  string
 	mu    sync.Mutex
 	state gmailWatchState
+	// shared re-reads the whole file before each Update, for processes that
+	// write next to a running gmail watch serve without owning its cursor.
+	shared bool
 }
 
 func gmailWatchStatePath(account string) (string, error) {
@@ -80,6 +89,41 @@ func loadGmailWatchStore(account string) (*gmailWatchStore, error) {
 	return store, nil
 }
 
+// listGmailWatchStates loads the stored watch state of every account,
+// sorted by account.
+func listGmailWatchStates() ([]gmailWatchState, error) {
+	dir, err := config.EnsureGmailWatchDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]gmailWatchState, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var state gmailWatchState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		if strings.TrimSpace(state.Account) == "" {
+			continue
+		}
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return strings.ToLower(states[i].Account) < strings.ToLower(states[j].Account)
+	})
+	return states, nil
+}
+
 func (s *gmailWatchStore) Get() gmailWatchState {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -89,12 +133,84 @@ func (s *gmailWatchStore) Get() gmailWatchState {
 func (s *gmailWatchStore) Update(fn func(*gmailWatchState) error) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.update(fn)
+}
+
+// update is Update with s.mu held. The file is locked across the
+// read-modify-write so a server and gmail watch replay never drop each
+// other's changes.
+func (s *gmailWatchStore) update(fn func(*gmailWatchState) error) error {
+	if s.path != "" {
+		unlock, err := lockGmailWatchState(s.path)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if err := s.reload(); err != nil {
+			return err
+		}
+	}
 	if err := fn(&s.state); err != nil {
 		return err
 	}
 	return s.Save()
 }
 
+// reload picks up what other processes wrote: the whole state for shared
+// stores, otherwise only the delivery log and status, which gmail watch
+// replay records while the server owns everything else.
+func (s *gmailWatchStore) reload() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !s.shared {
+			return nil
+		}
+		return err
+	}
+	var fresh gmailWatchState
+	if err := json.Unmarshal(data, &fresh); err != nil {
+		if s.shared {
+			return err
+		}
+		// The server's own state replaces an unreadable file.
+		return nil
+	}
+	if s.shared {
+		s.state = fresh
+		return nil
+	}
+	s.state.Deliveries = fresh.Deliveries
+	s.state.LastDeliveryStatus = fresh.LastDeliveryStatus
+	s.state.LastDeliveryAtMs = fresh.LastDeliveryAtMs
+	s.state.LastDeliveryStatusNote = fresh.LastDeliveryStatusNote
+	return nil
+}
+
+// lockGmailWatchState takes an exclusive lock file next to the state file.
+// A lock left behind by a crashed process is broken once it is stale.
+func lockGmailWatchState(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(gmailWatchStateLockWait)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600) //nolint:gosec // path under the config dir
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > gmailWatchStateLockStale {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("watch state is locked by another process (remove %s if none is running)", lockPath)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
 func (s *gmailWatchStore) Save() error {
 	if s.path == "" {
 		return errors.New("missing watch state path")
@@ -103,7 +219,7 @@ func (s *gmailWatchStore) Save() error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(s.path, append(payload, '\n'), 0o600)
+	return writeFileAtomic(s.path, append(payload, '\n'))
 }
 
 func (s *gmailWatchStore) StartHistoryID(pushHistory string) (uint64, error) {
@@ -123,9 +239,11 @@ func (s *gmailWatchStore) StartHistoryID(pushHistory string) (uint64, error) {
 		if pushErr != nil {
 			return 0, pushErr
 		}
-		s.state.HistoryID = formatHistoryID(pushID)
-		s.state.UpdatedAtMs = time.Now().UnixMilli()
-		_ = s.Save()
+		_ = s.update(func(state *gmailWatchState) error {
+			state.HistoryID = formatHistoryID(pushID)
+			state.UpdatedAtMs = time.Now().UnixMilli()
+			return nil
+		})
 		return pushID, nil
 	}
 
@@ -21,46 +21,56 @@ type gmailWatchHook struct {
 	Token       string `json:"token,omitempty"`
 	IncludeBody bool   `json:"includeBody,omitempty"`
 	MaxBytes    int    `json:"maxBytes,omitempty"`
+	Secret      string `json:"secret,omitempty"`
 }
 
 type gmailWatchState struct {
-	Account                string          `json:"account"`
-	Topic                  string          `json:"topic"`
-	Labels                 []string        `json:"labels,omitempty"`
-	HistoryID              string          `json:"historyId"`
-	ExpirationMs           int64           `json:"expirationMs,omitempty"`
-	ProviderExpirationMs   int64           `json:"providerExpirationMs,omitempty"`
-	RenewAfterMs           int64           `json:"renewAfterMs,omitempty"`
-	UpdatedAtMs            int64           `json:"updatedAtMs,omitempty"`
-	Hook                   *gmailWatchHook `json:"hook,omitempty"`
-	LastDeliveryStatus     string          `json:"lastDeliveryStatus,omitempty"`
-	LastDeliveryAtMs       int64           `json:"lastDeliveryAtMs,omitempty"`
-	LastDeliveryStatusNote string          `json:"lastDeliveryStatusNote,omitempty"`
-	LastPushMessageID      string          `json:"lastPushMessageId,omitempty"`
+	Account                string               `json:"account"`
+	Topic                  string               `json:"topic"`
+	Labels                 []string             `json:"labels,omitempty"`
+	HistoryID              string               `json:"historyId"`
+	ExpirationMs           int64                `json:"expirationMs,omitempty"`
+	ProviderExpirationMs   int64                `json:"providerExpirationMs,omitempty"`
+	RenewAfterMs           int64                `json:"renewAfterMs,omitempty"`
+	RenewTTLMs             int64                `json:"renewTtlMs,omitempty"`
+	UpdatedAtMs            int64                `json:"updatedAtMs,omitempty"`
+	Hook                   *gmailWatchHook      `json:"hook,omitempty"`
+	LastDeliveryStatus     string               `json:"lastDeliveryStatus,omitempty"`
+	LastDeliveryAtMs       int64                `json:"lastDeliveryAtMs,omitempty"`
+	LastDeliveryStatusNote string               `json:"lastDeliveryStatusNote,omitempty"`
+	LastPushMessageID      string               `json:"lastPushMessageId,omitempty"`
+	Routes                 string               `json:"routes,omitempty"`
+	Deliveries             []gmailWatchDelivery `json:"deliveries,omitempty"`
 }
 
 type gmailWatchServeConfig struct {
-	Account       string
-	Bind          string
-	Port          int
-	Path          string
-	VerifyOIDC    bool
-	OIDCEmail     string
-	OIDCAudience  string
-	SharedToken   string
-	HookURL       string
-	HookToken     string
-	IncludeBody   bool
-	MaxBodyBytes  int
-	ExcludeLabels []string
-	HistoryMax    int64
-	ResyncMax     int64
-	HistoryTypes  []string
-	HookTimeout   time.Duration
-	DateLocation  *time.Location
-	PersistHook   bool
-	AllowNoHook   bool
-	VerboseOutput bool
+	Account         string
+	Bind            string
+	Port            int
+	Path            string
+	ProbeAddr       string
+	VerifyOIDC      bool
+	OIDCEmail       string
+	OIDCAudience    string
+	SharedToken     string
+	HookURL         string
+	HookToken       string
+	HookSecret      string
+	HookExec        string
+	HookExecTimeout time.Duration
+	RoutesFile      string
+	IncludeBody     bool
+	MaxBodyBytes    int
+	ExcludeLabels   []string
+	HistoryMax      int64
+	ResyncMax       int64
+	HistoryTypes    []string
+	HookTimeout     time.Duration
+	DateLocation    *time.Location
+	PersistHook     bool
+	AllowNoHook     bool
+	HookAttempts    int
+	VerboseOutput   bool
 }
 
 var gmailHistoryTypes = []string{
@@ -168,6 +168,11 @@ func Execute(args []string) (err error) {
 	kctx.Bind(&cli.RootFlags)
 
 	err = kctx.Run()
+	if retry, upgradeErr := offerScopeUpgrade(ctx, &cli.RootFlags, err, scopeUpgradeRetryable(kctx)); retry {
+		err = kctx.Run()
+	} else {
+		err = upgradeErr
+	}
 	if err == nil {
 		return nil
 	}
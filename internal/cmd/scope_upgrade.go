@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"golang.org/x/term"
+
+	"github.com/steipete/gogcli/internal/googleapi"
+	"github.com/steipete/gogcli/internal/googleauth"
+	"github.com/steipete/gogcli/internal/input"
+	"github.com/steipete/gogcli/internal/secrets"
+	"github.com/steipete/gogcli/internal/ui"
+)
+
+const scopeUpgradeTimeout = 2 * time.Minute
+
+var (
+	scopeUpgradeIsInteractive = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+	scopeUpgradePrompt        = input.PromptLine
+)
+
+// scopeUpgradeRetrier marks commands that can safely run again from the
+// start after a scope upgrade: read-only, and not consuming stdin. Anything
+// else (sends, merges, batch modify/delete, imports) may have partly
+// completed, so the user re-runs it themselves.
+type scopeUpgradeRetrier interface {
+	retryAfterScopeUpgrade()
+}
+
+func (*GmailSearchCmd) retryAfterScopeUpgrade()            {}
+func (*GmailMessagesSearchCmd) retryAfterScopeUpgrade()    {}
+func (*GmailGetCmd) retryAfterScopeUpgrade()               {}
+func (*GmailThreadGetCmd) retryAfterScopeUpgrade()         {}
+func (*GmailThreadAttachmentsCmd) retryAfterScopeUpgrade() {}
+func (*GmailHistoryCmd) retryAfterScopeUpgrade()           {}
+func (*GmailLabelsListCmd) retryAfterScopeUpgrade()        {}
+func (*GmailLabelsGetCmd) retryAfterScopeUpgrade()         {}
+func (*CalendarCalendarsCmd) retryAfterScopeUpgrade()      {}
+func (*CalendarEventsCmd) retryAfterScopeUpgrade()         {}
+func (*CalendarSearchCmd) retryAfterScopeUpgrade()         {}
+func (*DriveLsCmd) retryAfterScopeUpgrade()                {}
+func (*DriveSearchCmd) retryAfterScopeUpgrade()            {}
+func (*DriveGetCmd) retryAfterScopeUpgrade()               {}
+func (*DocsInfoCmd) retryAfterScopeUpgrade()               {}
+func (*DocsCatCmd) retryAfterScopeUpgrade()                {}
+func (*SheetsGetCmd) retryAfterScopeUpgrade()              {}
+func (*SheetsMetadataCmd) retryAfterScopeUpgrade()         {}
+func (*ContactsListCmd) retryAfterScopeUpgrade()           {}
+func (*ContactsSearchCmd) retryAfterScopeUpgrade()         {}
+func (*PeopleMeCmd) retryAfterScopeUpgrade()               {}
+func (*TasksListCmd) retryAfterScopeUpgrade()              {}
+
+// scopeUpgradeRetryable reports whether the selected command is marked safe
+// to run again after a scope upgrade.
+func scopeUpgradeRetryable(kctx *kong.Context) bool {
+	if kctx == nil {
+		return false
+	}
+	node := kctx.Selected()
+	if node == nil || !node.Target.IsValid() {
+		return false
+	}
+	target := node.Target
+	if target.Kind() != reflect.Ptr {
+		if !target.CanAddr() {
+			return false
+		}
+		target = target.Addr()
+	}
+	_, ok := target.Interface().(scopeUpgradeRetrier)
+	return ok
+}
+
+// offerScopeUpgrade handles a 403 insufficient-scope failure. In interactive
+// mode it asks to authorize the missing scopes (merged into the stored token)
+// and reports whether the command should be retried; commands that are not
+// retryable get the new token stored and an error asking to re-run them.
+// Otherwise err is returned unchanged so errfmt prints the exact
+// `gog auth add` invocation.
+func offerScopeUpgrade(ctx context.Context, flags *RootFlags, err error, retryable bool) (bool, error) {
+	var scopeErr *googleapi.InsufficientScopesError
+	if !errors.As(err, &scopeErr) {
+		return false, err
+	}
+	if flags == nil || flags.NoInput || !scopeUpgradeIsInteractive() {
+		return false, err
+	}
+
+	u := ui.FromContext(ctx)
+	if u != nil {
+		u.Err().Printf("Token for %s is missing scopes needed for %s:", scopeErr.Email, scopeErr.Service)
+		for _, scope := range scopeErr.Missing {
+			u.Err().Printf("  %s", scope)
+		}
+	}
+
+	prompt := "Authorize the missing scopes now? [y/N]: "
+	if retryable {
+		prompt = "Authorize the missing scopes now and retry? [y/N]: "
+	}
+	line, readErr := scopeUpgradePrompt(ctx, prompt)
+	if readErr != nil && !errors.Is(readErr, io.EOF) {
+		return false, fmt.Errorf("read confirmation: %w", readErr)
+	}
+	ans := strings.TrimSpace(strings.ToLower(line))
+	if ans != "y" && ans != "yes" {
+		return false, err
+	}
+
+	if upgradeErr := upgradeTokenScopes(ctx, scopeErr); upgradeErr != nil {
+		return false, upgradeErr
+	}
+	if !retryable {
+		return false, fmt.Errorf("authorized the missing scopes for %s; re-run the command (it is not retried automatically because it may have partly completed or read stdin)", scopeErr.Email)
+	}
+	return true, nil
+}
+
+func upgradeTokenScopes(ctx context.Context, scopeErr *googleapi.InsufficientScopesError) error {
+	serviceNames := scopeErr.ServicesToAuthorize()
+	sort.Strings(serviceNames)
+	services := make([]googleauth.Service, 0, len(serviceNames))
+	for _, name := range serviceNames {
+		svc, err := googleauth.ParseService(name)
+		if err != nil {
+			return err
+		}
+		services = append(services, svc)
+	}
+	scopes := scopeErr.ScopesToAuthorize()
+
+	if keychainErr := ensureKeychainAccessIfNeeded(); keychainErr != nil {
+		return fmt.Errorf("keychain access: %w", keychainErr)
+	}
+
+	refreshToken, err := authorizeGoogle(ctx, googleauth.AuthorizeOptions{
+		Services:     services,
+		Scopes:       scopes,
+		ForceConsent: true,
+		Timeout:      scopeUpgradeTimeout,
+		Client:       scopeErr.Client,
+	})
+	if err != nil {
+		return err
+	}
+
+	authorizedEmail, err := fetchAuthorizedEmail(ctx, scopeErr.Client, refreshToken, scopes, 15*time.Second)
+	if err != nil {
+		return fmt.Errorf("fetch authorized email: %w", err)
+	}
+	if normalizeEmail(authorizedEmail) != normalizeEmail(scopeErr.Email) {
+		return fmt.Errorf("authorized as %s, expected %s", authorizedEmail, scopeErr.Email)
+	}
+
+	store, err := openSecretsStore()
+	if err != nil {
+		return err
+	}
+	return store.SetToken(scopeErr.Client, authorizedEmail, secrets.Token{
+		Client:       scopeErr.Client,
+		Email:        authorizedEmail,
+		Services:     serviceNames,
+		Scopes:       scopes,
+		RefreshToken: refreshToken,
+	})
+}
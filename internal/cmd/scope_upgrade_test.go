@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/googleapi"
+	"github.com/steipete/gogcli/internal/googleauth"
+	"github.com/steipete/gogcli/internal/secrets"
+)
+
+func TestOfferScopeUpgrade_NoInputReturnsError(t *testing.T) {
+	origInteractive := scopeUpgradeIsInteractive
+	t.Cleanup(func() { scopeUpgradeIsInteractive = origInteractive })
+	scopeUpgradeIsInteractive = func() bool { return true }
+
+	in := &googleapi.InsufficientScopesError{Service: "gmail", Email: "a@b.com"}
+	retry, err := offerScopeUpgrade(context.Background(), &RootFlags{NoInput: true}, in, true)
+	if retry || !errors.Is(err, in) {
+		t.Fatalf("expected passthrough, got retry=%v err=%v", retry, err)
+	}
+
+	other := errors.New("boom")
+	if retry, err := offerScopeUpgrade(context.Background(), &RootFlags{}, other, true); retry || !errors.Is(err, other) {
+		t.Fatalf("expected passthrough for unrelated error, got retry=%v err=%v", retry, err)
+	}
+}
+
+func TestOfferScopeUpgrade_InteractiveMergesScopes(t *testing.T) {
+	t.Setenv("GOG_KEYRING_BACKEND", "file")
+
+	origInteractive := scopeUpgradeIsInteractive
+	origPrompt := scopeUpgradePrompt
+	origAuth := authorizeGoogle
+	origFetch := fetchAuthorizedEmail
+	origOpen := openSecretsStore
+	t.Cleanup(func() {
+		scopeUpgradeIsInteractive = origInteractive
+		scopeUpgradePrompt = origPrompt
+		authorizeGoogle = origAuth
+		fetchAuthorizedEmail = origFetch
+		openSecretsStore = origOpen
+	})
+
+	store := newMemSecretsStore()
+	openSecretsStore = func() (secrets.Store, error) { return store, nil }
+	scopeUpgradeIsInteractive = func() bool { return true }
+	scopeUpgradePrompt = func(context.Context, string) (string, error) { return "y", nil }
+
+	var gotOpts googleauth.AuthorizeOptions
+	authorizeGoogle = func(_ context.Context, opts googleauth.AuthorizeOptions) (string, error) {
+		gotOpts = opts
+		return "rt-new", nil
+	}
+	fetchAuthorizedEmail = func(context.Context, string, string, []string, time.Duration) (string, error) {
+		return "a@b.com", nil
+	}
+
+	in := &googleapi.InsufficientScopesError{
+		Service:  "drive",
+		Email:    "a@b.com",
+		Client:   config.DefaultClientName,
+		Services: []string{"gmail"},
+		Granted:  []string{"g1"},
+		Required: []string{"d1"},
+		Missing:  []string{"d1"},
+	}
+	retry, err := offerScopeUpgrade(context.Background(), &RootFlags{}, in, true)
+	if err != nil || !retry {
+		t.Fatalf("expected retry, got retry=%v err=%v", retry, err)
+	}
+	if !gotOpts.ForceConsent || !reflect.DeepEqual(gotOpts.Scopes, []string{"g1", "d1"}) {
+		t.Fatalf("unexpected authorize opts: %#v", gotOpts)
+	}
+
+	tok, err := store.GetToken(config.DefaultClientName, "a@b.com")
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if tok.RefreshToken != "rt-new" || !reflect.DeepEqual(tok.Services, []string{"drive", "gmail"}) || !reflect.DeepEqual(tok.Scopes, []string{"g1", "d1"}) {
+		t.Fatalf("unexpected stored token: %#v", tok)
+	}
+
+	// Commands that are not safe to repeat keep the new token but are not retried.
+	authorizeGoogle = func(context.Context, googleauth.AuthorizeOptions) (string, error) { return "rt-newer", nil }
+	retry, err = offerScopeUpgrade(context.Background(), &RootFlags{}, in, false)
+	if retry || err == nil || !strings.Contains(err.Error(), "re-run the command") {
+		t.Fatalf("expected re-run error without retry, got retry=%v err=%v", retry, err)
+	}
+	if tok, _ := store.GetToken(config.DefaultClientName, "a@b.com"); tok.RefreshToken != "rt-newer" {
+		t.Fatalf("expected upgraded token to be stored, got %#v", tok)
+	}
+}
+
+func TestScopeUpgradeRetryable(t *testing.T) {
+	for args, want := range map[string]bool{
+		"gmail search in:inbox":                         true,
+		"gmail get m1":                                  true,
+		"drive ls":                                      true,
+		"gmail send --to a@b.com --subject x":           false,
+		"gmail batch delete m1":                         false,
+		"gmail merge --data x.csv --subject-template s": false,
+		"calendar create primary --summary x":           false,
+	} {
+		parser, _, err := newParser("test")
+		if err != nil {
+			t.Fatalf("newParser: %v", err)
+		}
+		kctx, err := parser.Parse(strings.Fields(args))
+		if err != nil {
+			t.Fatalf("%s: parse: %v", args, err)
+		}
+		if got := scopeUpgradeRetryable(kctx); got != want {
+			t.Fatalf("%s: retryable=%v, want %v", args, got, want)
+		}
+	}
+}
+
+func TestOfferScopeUpgrade_Declined(t *testing.T) {
+	origInteractive := scopeUpgradeIsInteractive
+	origPrompt := scopeUpgradePrompt
+	t.Cleanup(func() {
+		scopeUpgradeIsInteractive = origInteractive
+		scopeUpgradePrompt = origPrompt
+	})
+	scopeUpgradeIsInteractive = func() bool { return true }
+	scopeUpgradePrompt = func(context.Context, string) (string, error) { return "n", nil }
+
+	in := &googleapi.InsufficientScopesError{Service: "gmail", Email: "a@b.com"}
+	if retry, err := offerScopeUpgrade(context.Background(), &RootFlags{}, in, true); retry || !errors.Is(err, in) {
+		t.Fatalf("expected passthrough, got retry=%v err=%v", retry, err)
+	}
+}
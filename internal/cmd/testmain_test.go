@@ -21,6 +21,8 @@ func TestMain(m *testing.M) {
 	_ = os.MkdirAll(xdg, 0o755)
 	_ = os.Setenv("HOME", home)
 	_ = os.Setenv("XDG_CONFIG_HOME", xdg)
+	// Never pick up the developer's gcloud credentials or probe the GCE metadata server.
+	_ = os.Setenv("GOG_ADC", "off")
 
 	code := m.Run()
 
@@ -15,6 +15,9 @@ type File struct {
 	AccountAliases  map[string]string `json:"account_aliases,omitempty"`
 	AccountClients  map[string]string `json:"account_clients,omitempty"`
 	ClientDomains   map[string]string `json:"client_domains,omitempty"`
+	// ImpersonateServiceAccount is the service account that application default
+	// credentials impersonate through the IAM Credentials API.
+	ImpersonateServiceAccount string `json:"impersonate_service_account,omitempty"`
 }
 
 func ConfigPath() (string, error) {
@@ -12,6 +12,7 @@ type Key string
 const (
 	KeyTimezone       Key = "timezone"
 	KeyKeyringBackend Key = "keyring_backend"
+	KeyImpersonate    Key = "impersonate_service_account"
 )
 
 type KeySpec struct {
@@ -25,6 +26,7 @@ type KeySpec struct {
 var keyOrder = []Key{
 	KeyTimezone,
 	KeyKeyringBackend,
+	KeyImpersonate,
 }
 
 var keySpecs = map[Key]KeySpec{
@@ -63,6 +65,25 @@ var keySpecs = map[Key]KeySpec{
 			return "(not set, using auto)"
 		},
 	},
+	KeyImpersonate: {
+		Key: KeyImpersonate,
+		Get: func(cfg File) string {
+			return cfg.ImpersonateServiceAccount
+		},
+		Set: func(cfg *File, value string) error {
+			if !strings.Contains(value, "@") {
+				return fmt.Errorf("invalid service account %q (expected an email like name@project.iam.gserviceaccount.com)", value)
+			}
+			cfg.ImpersonateServiceAccount = value
+			return nil
+		},
+		Unset: func(cfg *File) {
+			cfg.ImpersonateServiceAccount = ""
+		},
+		EmptyHint: func() string {
+			return "(not set)"
+		},
+	},
 }
 
 var (
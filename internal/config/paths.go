@@ -135,6 +135,26 @@ func GmailWatchDir() (string, error) {
 	return filepath.Join(dir, "state", "gmail-watch"), nil
 }
 
+// SMIMEDir holds S/MIME identities (keys/) and recipient certificates (certs/).
+func SMIMEDir() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "smime"), nil
+}
+
+// PGPKeyringPath is the default OpenPGP keyring file (armored or binary).
+func PGPKeyringPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "pgp", "keyring.asc"), nil
+}
+
 func KeepServiceAccountPath(email string) (string, error) {
 	dir, err := Dir()
 	if err != nil {
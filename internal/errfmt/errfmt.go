@@ -37,6 +37,17 @@ func Format(err error) string {
 		)
 	}
 
+	var scopeErr *gogapi.InsufficientScopesError
+	if errors.As(err, &scopeErr) {
+		return fmt.Sprintf(
+			"Token for %s is missing scopes needed for %s:\n  %s\n\nGrant them with:\n  %s",
+			scopeErr.Email,
+			scopeErr.Service,
+			strings.Join(scopeErr.Missing, "\n  "),
+			scopeErr.AuthAddCommand(),
+		)
+	}
+
 	var credErr *config.CredentialsMissingError
 	if errors.As(err, &credErr) {
 		return fmt.Sprintf(
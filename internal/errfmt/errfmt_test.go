@@ -34,6 +34,21 @@ func TestFormat_AuthRequired(t *testing.T) {
 	}
 }
 
+func TestFormat_InsufficientScopes(t *testing.T) {
+	err := &gogapi.InsufficientScopesError{
+		Service:  "drive",
+		Email:    "a@b.com",
+		Client:   "work",
+		Services: []string{"gmail"},
+		Missing:  []string{"https://www.googleapis.com/auth/drive"},
+	}
+	got := Format(err)
+
+	if !containsAll(got, "https://www.googleapis.com/auth/drive", "gog auth add a@b.com --services gmail,drive --client work --force-consent") {
+		t.Fatalf("unexpected: %q", got)
+	}
+}
+
 func TestFormat_CredentialsMissing(t *testing.T) {
 	err := &config.CredentialsMissingError{Path: "/tmp/creds.json", Cause: errNope}
 	got := Format(err)
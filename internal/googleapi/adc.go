@@ -0,0 +1,289 @@
+package googleapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+
+	"github.com/steipete/gogcli/internal/config"
+)
+
+// Credential sources reported by `gog auth status`.
+const (
+	CredentialSourceOAuth          = "oauth"
+	CredentialSourceServiceAccount = "service_account"
+	CredentialSourceADC            = "adc"
+	CredentialSourceNone           = "none"
+)
+
+// ADC credential types: the "type" field of the JSON file, or the metadata server.
+const (
+	adcTypeServiceAccount             = "service_account"
+	adcTypeAuthorizedUser             = "authorized_user"
+	adcTypeExternalAccount            = "external_account"
+	adcTypeExternalAccountAuthorized  = "external_account_authorized_user"
+	adcTypeImpersonatedServiceAccount = "impersonated_service_account"
+	adcTypeMetadata                   = "gce_metadata"
+)
+
+// Where ADC was found.
+const (
+	adcOriginEnv      = "env"
+	adcOriginGcloud   = "gcloud"
+	adcOriginMetadata = "metadata"
+)
+
+const (
+	adcDisableEnv           = "GOG_ADC"
+	adcCredentialsEnv       = "GOOGLE_APPLICATION_CREDENTIALS"
+	adcCloudSDKConfigEnv    = "CLOUDSDK_CONFIG"
+	adcWellKnownFileName    = "application_default_credentials.json"
+	impersonateEnv          = "GOG_IMPERSONATE_SERVICE_ACCOUNT"
+	cloudPlatformScope      = "https://www.googleapis.com/auth/cloud-platform"
+	adcMetadataProbeTimeout = 2 * time.Second
+)
+
+var (
+	errUnsupportedADCType    = errors.New("unsupported application default credentials type")
+	errADCNeedsImpersonation = errors.New("application default credentials cannot act as a Workspace user without impersonation")
+)
+
+var (
+	adcOnGCE = func(ctx context.Context) bool {
+		ctx, cancel := context.WithTimeout(ctx, adcMetadataProbeTimeout)
+		defer cancel()
+
+		return metadata.OnGCEWithContext(ctx)
+	}
+	adcImpersonateTokenSource = impersonate.CredentialsTokenSource
+	adcCredentialsFromJSON    = google.CredentialsFromJSONWithParams
+)
+
+// CredentialSource describes where gog gets access tokens for an account.
+type CredentialSource struct {
+	// Kind is one of CredentialSourceOAuth, CredentialSourceServiceAccount,
+	// CredentialSourceADC, or CredentialSourceNone.
+	Kind string
+	// Type is the ADC credential type (service_account, authorized_user,
+	// external_account, impersonated_service_account, gce_metadata).
+	Type string
+	// Origin is where ADC was found: env, gcloud, or metadata.
+	Origin string
+	// Path is the credentials file, when there is one.
+	Path string
+	// Impersonate is the service account impersonated through IAM Credentials.
+	Impersonate string
+}
+
+// adcCredentials is a located but not yet exchanged ADC configuration.
+type adcCredentials struct {
+	source CredentialSource
+	json   []byte
+}
+
+// ImpersonateServiceAccount returns the configured impersonation target
+// (GOG_IMPERSONATE_SERVICE_ACCOUNT, then config.json), or "".
+func ImpersonateServiceAccount() (string, error) {
+	if v := strings.TrimSpace(os.Getenv(impersonateEnv)); v != "" {
+		return v, nil
+	}
+
+	cfg, err := config.ReadConfig()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(cfg.ImpersonateServiceAccount), nil
+}
+
+// FindApplicationDefaultCredentials locates ADC the same way Google client
+// libraries do (GOOGLE_APPLICATION_CREDENTIALS, gcloud's well-known file, then
+// the GCE metadata server) without exchanging any tokens.
+func FindApplicationDefaultCredentials(ctx context.Context) (CredentialSource, bool, error) {
+	creds, ok, err := findADC(ctx)
+	return creds.source, ok, err
+}
+
+func findADC(ctx context.Context) (adcCredentials, bool, error) {
+	creds, ok, err := locateADC(ctx)
+	if err != nil || !ok {
+		return adcCredentials{}, ok, err
+	}
+
+	target, err := ImpersonateServiceAccount()
+	if err != nil {
+		return adcCredentials{}, false, err
+	}
+
+	creds.source.Impersonate = target
+
+	return creds, true, nil
+}
+
+func locateADC(ctx context.Context) (adcCredentials, bool, error) {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(adcDisableEnv))) {
+	case "0", "false", "no", "off":
+		return adcCredentials{}, false, nil
+	}
+
+	if path := strings.TrimSpace(os.Getenv(adcCredentialsEnv)); path != "" {
+		creds, err := readADCFile(path, adcOriginEnv)
+		if err != nil {
+			return adcCredentials{}, false, fmt.Errorf("%s: %w", adcCredentialsEnv, err)
+		}
+
+		return creds, true, nil
+	}
+
+	if path := adcWellKnownFile(); path != "" {
+		if _, err := os.Stat(path); err == nil {
+			creds, readErr := readADCFile(path, adcOriginGcloud)
+			if readErr != nil {
+				return adcCredentials{}, false, readErr
+			}
+
+			return creds, true, nil
+		}
+	}
+
+	if adcOnGCE(ctx) {
+		return adcCredentials{source: CredentialSource{
+			Kind:   CredentialSourceADC,
+			Type:   adcTypeMetadata,
+			Origin: adcOriginMetadata,
+		}}, true, nil
+	}
+
+	return adcCredentials{}, false, nil
+}
+
+func readADCFile(path string, origin string) (adcCredentials, error) {
+	path, err := config.ExpandPath(path)
+	if err != nil {
+		return adcCredentials{}, err
+	}
+
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided ADC path
+	if err != nil {
+		return adcCredentials{}, fmt.Errorf("read credentials: %w", err)
+	}
+
+	var head struct {
+		Type string `json:"type"`
+	}
+
+	if err := json.Unmarshal(data, &head); err != nil {
+		return adcCredentials{}, fmt.Errorf("decode credentials %s: %w", path, err)
+	}
+
+	switch head.Type {
+	case adcTypeServiceAccount, adcTypeAuthorizedUser, adcTypeExternalAccount,
+		adcTypeExternalAccountAuthorized, adcTypeImpersonatedServiceAccount:
+	default:
+		// OAuth client files ("installed"/"web") would start a browser flow here.
+		return adcCredentials{}, fmt.Errorf("%w: %q in %s", errUnsupportedADCType, head.Type, path)
+	}
+
+	return adcCredentials{
+		source: CredentialSource{Kind: CredentialSourceADC, Type: head.Type, Origin: origin, Path: path},
+		json:   data,
+	}, nil
+}
+
+func adcWellKnownFile() string {
+	if dir := strings.TrimSpace(os.Getenv(adcCloudSDKConfigEnv)); dir != "" {
+		return filepath.Join(dir, adcWellKnownFileName)
+	}
+
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud", adcWellKnownFileName)
+		}
+
+		return ""
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
+	}
+
+	return filepath.Join(home, ".config", "gcloud", adcWellKnownFileName)
+}
+
+// tokenSourceForADC builds a token source from application default credentials
+// for email. Service-account keys use domain-wide delegation directly; any
+// other credential needs impersonate_service_account with DWD to act as a
+// Workspace user.
+func tokenSourceForADC(ctx context.Context, email string, scopes []string) (oauth2.TokenSource, CredentialSource, bool, error) {
+	creds, ok, err := findADC(ctx)
+	if err != nil || !ok {
+		return nil, CredentialSource{}, ok, err
+	}
+
+	target := creds.source.Impersonate
+	if target == "" && creds.source.Type != adcTypeServiceAccount {
+		// Only a service-account key can set the DWD subject itself; anything
+		// else would silently run as the ADC principal's own mailbox.
+		return nil, CredentialSource{}, false, fmt.Errorf("%w: %s credentials need impersonate_service_account (or %s) set to a DWD-enabled service account to act as %s",
+			errADCNeedsImpersonation, creds.source.Type, impersonateEnv, email)
+	}
+
+	// Ensure token exchanges don't hang forever.
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: defaultHTTPTimeout})
+
+	baseScopes := scopes
+	subject := email
+
+	if target != "" {
+		// The IAM Credentials API only needs cloud-platform on the caller.
+		baseScopes = []string{cloudPlatformScope}
+		subject = ""
+	}
+
+	var base oauth2.TokenSource
+
+	if creds.source.Type == adcTypeMetadata {
+		base = google.ComputeTokenSource("", baseScopes...)
+	} else {
+		params := google.CredentialsParams{Scopes: baseScopes}
+		if creds.source.Type == adcTypeServiceAccount {
+			params.Subject = subject
+		}
+
+		c, credErr := adcCredentialsFromJSON(ctx, creds.json, params)
+		if credErr != nil {
+			return nil, CredentialSource{}, false, fmt.Errorf("parse %s credentials: %w", creds.source.Type, credErr)
+		}
+
+		base = c.TokenSource
+	}
+
+	if target == "" {
+		return base, creds.source, true, nil
+	}
+
+	ts, err := adcImpersonateTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: target,
+		Scopes:          scopes,
+		Subject:         email,
+	}, option.WithTokenSource(base))
+	if err != nil {
+		return nil, CredentialSource{}, false, fmt.Errorf("impersonate %s: %w", target, err)
+	}
+
+	return ts, creds.source, true, nil
+}
@@ -0,0 +1,304 @@
+package googleapi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/keyring"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/secrets"
+)
+
+const testAuthorizedUserJSON = `{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"rt"}`
+
+func setupADCEnv(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+	t.Setenv("CLOUDSDK_CONFIG", filepath.Join(home, "gcloud"))
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("GOG_IMPERSONATE_SERVICE_ACCOUNT", "")
+	t.Setenv("GOG_ADC", "")
+
+	origOnGCE := adcOnGCE
+	t.Cleanup(func() { adcOnGCE = origOnGCE })
+	adcOnGCE = func(context.Context) bool { return false }
+
+	return home
+}
+
+func writeADCFile(t *testing.T, dir string, name string, data string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	return path
+}
+
+func TestFindApplicationDefaultCredentials_Env(t *testing.T) {
+	home := setupADCEnv(t)
+	path := writeADCFile(t, home, "wif.json", `{"type":"external_account","audience":"//iam.googleapis.com/x"}`)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", path)
+	t.Setenv("GOG_IMPERSONATE_SERVICE_ACCOUNT", "gog@proj.iam.gserviceaccount.com")
+
+	src, ok, err := FindApplicationDefaultCredentials(context.Background())
+	if err != nil || !ok {
+		t.Fatalf("expected ADC, got ok=%v err=%v", ok, err)
+	}
+
+	if src.Kind != CredentialSourceADC || src.Type != "external_account" || src.Origin != "env" || src.Path != path {
+		t.Fatalf("unexpected source: %#v", src)
+	}
+
+	if src.Impersonate != "gog@proj.iam.gserviceaccount.com" {
+		t.Fatalf("unexpected impersonation target: %q", src.Impersonate)
+	}
+}
+
+func TestFindApplicationDefaultCredentials_GcloudAndConfig(t *testing.T) {
+	home := setupADCEnv(t)
+	path := writeADCFile(t, filepath.Join(home, "gcloud"), "application_default_credentials.json", testAuthorizedUserJSON)
+
+	if err := config.WriteConfig(config.File{ImpersonateServiceAccount: "cfg@proj.iam.gserviceaccount.com"}); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	src, ok, err := FindApplicationDefaultCredentials(context.Background())
+	if err != nil || !ok {
+		t.Fatalf("expected ADC, got ok=%v err=%v", ok, err)
+	}
+
+	if src.Type != "authorized_user" || src.Origin != "gcloud" || src.Path != path || src.Impersonate != "cfg@proj.iam.gserviceaccount.com" {
+		t.Fatalf("unexpected source: %#v", src)
+	}
+}
+
+func TestFindApplicationDefaultCredentials_Metadata(t *testing.T) {
+	setupADCEnv(t)
+	adcOnGCE = func(context.Context) bool { return true }
+
+	src, ok, err := FindApplicationDefaultCredentials(context.Background())
+	if err != nil || !ok || src.Type != "gce_metadata" || src.Origin != "metadata" {
+		t.Fatalf("unexpected: %#v ok=%v err=%v", src, ok, err)
+	}
+}
+
+func TestFindApplicationDefaultCredentials_NoneOrDisabled(t *testing.T) {
+	home := setupADCEnv(t)
+
+	if _, ok, err := FindApplicationDefaultCredentials(context.Background()); err != nil || ok {
+		t.Fatalf("expected no ADC, got ok=%v err=%v", ok, err)
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "adc.json", testAuthorizedUserJSON))
+	t.Setenv("GOG_ADC", "off")
+
+	if _, ok, err := FindApplicationDefaultCredentials(context.Background()); err != nil || ok {
+		t.Fatalf("expected ADC disabled, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestFindApplicationDefaultCredentials_RejectsClientFile(t *testing.T) {
+	home := setupADCEnv(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "client.json", `{"installed":{"client_id":"x"}}`))
+
+	if _, _, err := FindApplicationDefaultCredentials(context.Background()); !errors.Is(err, errUnsupportedADCType) {
+		t.Fatalf("expected errUnsupportedADCType, got %v", err)
+	}
+}
+
+func TestOptionsForAccountScopes_FallsBackToADC(t *testing.T) {
+	home := setupADCEnv(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "adc.json", testAuthorizedUserJSON))
+	t.Setenv("GOG_IMPERSONATE_SERVICE_ACCOUNT", "gog@proj.iam.gserviceaccount.com")
+	stubADCImpersonation(t)
+
+	origRead := readClientCredentials
+	t.Cleanup(func() { readClientCredentials = origRead })
+
+	readClientCredentials = func(string) (config.ClientCredentials, error) {
+		return config.ClientCredentials{}, &config.CredentialsMissingError{Path: "/nope", Cause: os.ErrNotExist}
+	}
+
+	opts, err := optionsForAccountScopes(context.Background(), "gmail", "a@b.com", []string{"s1"})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if len(opts) == 0 {
+		t.Fatalf("expected client options")
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	_, err = optionsForAccountScopes(context.Background(), "gmail", "a@b.com", []string{"s1"})
+
+	var credErr *config.CredentialsMissingError
+	if !errors.As(err, &credErr) {
+		t.Fatalf("expected original CredentialsMissingError without ADC, got %v", err)
+	}
+}
+
+func TestOptionsForAccountScopes_UnusableADCKeepsAuthRequired(t *testing.T) {
+	home := setupADCEnv(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "adc.json", testAuthorizedUserJSON))
+	stubADCImpersonation(t)
+
+	origRead := readClientCredentials
+	origOpen := openSecretsStore
+
+	t.Cleanup(func() {
+		readClientCredentials = origRead
+		openSecretsStore = origOpen
+	})
+
+	readClientCredentials = func(string) (config.ClientCredentials, error) {
+		return config.ClientCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	openSecretsStore = func() (secrets.Store, error) {
+		return &stubStore{err: keyring.ErrKeyNotFound}, nil
+	}
+
+	_, err := optionsForAccountScopes(context.Background(), "gmail", "a@b.com", []string{"s1"})
+
+	var are *AuthRequiredError
+	if !errors.As(err, &are) || are.Email != "a@b.com" {
+		t.Fatalf("expected AuthRequiredError, got %T %v", err, err)
+	}
+
+	if errors.Is(err, errADCNeedsImpersonation) {
+		t.Fatalf("ADC impersonation error should not surface: %v", err)
+	}
+}
+
+func TestTokenSourceForADC_Impersonation(t *testing.T) {
+	home := setupADCEnv(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "adc.json", testAuthorizedUserJSON))
+	t.Setenv("GOG_IMPERSONATE_SERVICE_ACCOUNT", "gog@proj.iam.gserviceaccount.com")
+
+	origImpersonate := adcImpersonateTokenSource
+	t.Cleanup(func() { adcImpersonateTokenSource = origImpersonate })
+
+	var got impersonate.CredentialsConfig
+
+	adcImpersonateTokenSource = func(_ context.Context, cfg impersonate.CredentialsConfig, opts ...option.ClientOption) (oauth2.TokenSource, error) {
+		got = cfg
+
+		if len(opts) != 1 {
+			t.Fatalf("expected base token source option, got %d", len(opts))
+		}
+
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "impersonated"}), nil
+	}
+
+	ts, src, ok, err := tokenSourceForADC(context.Background(), "a@b.com", []string{"s1"})
+	if err != nil || !ok {
+		t.Fatalf("expected token source, got ok=%v err=%v", ok, err)
+	}
+
+	if got.TargetPrincipal != "gog@proj.iam.gserviceaccount.com" || got.Subject != "a@b.com" || len(got.Scopes) != 1 || got.Scopes[0] != "s1" {
+		t.Fatalf("unexpected impersonation config: %#v", got)
+	}
+
+	if src.Impersonate != got.TargetPrincipal {
+		t.Fatalf("unexpected source: %#v", src)
+	}
+
+	tok, err := ts.Token()
+	if err != nil || tok.AccessToken != "impersonated" {
+		t.Fatalf("unexpected token: %#v err=%v", tok, err)
+	}
+}
+
+func stubADCImpersonation(t *testing.T) {
+	t.Helper()
+
+	origImpersonate := adcImpersonateTokenSource
+	t.Cleanup(func() { adcImpersonateTokenSource = origImpersonate })
+
+	adcImpersonateTokenSource = func(context.Context, impersonate.CredentialsConfig, ...option.ClientOption) (oauth2.TokenSource, error) {
+		return oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "impersonated"}), nil
+	}
+}
+
+func TestTokenSourceForADC_RequiresImpersonation(t *testing.T) {
+	for name, data := range map[string]string{
+		"authorized_user":                  testAuthorizedUserJSON,
+		"external_account":                 `{"type":"external_account","audience":"//iam.googleapis.com/x","subject_token_type":"urn:ietf:params:oauth:token-type:jwt","token_url":"https://sts.googleapis.com/v1/token","credential_source":{"file":"/tmp/token"}}`,
+		"external_account_authorized_user": `{"type":"external_account_authorized_user","audience":"//iam.googleapis.com/x","client_id":"id","client_secret":"secret","refresh_token":"rt","token_url":"https://sts.googleapis.com/v1/oauthtoken"}`,
+		"impersonated_service_account":     `{"type":"impersonated_service_account","service_account_impersonation_url":"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@p.iam.gserviceaccount.com:generateAccessToken","source_credentials":` + testAuthorizedUserJSON + `}`,
+		"gce_metadata":                     "",
+	} {
+		t.Run(name, func(t *testing.T) {
+			home := setupADCEnv(t)
+			if data == "" {
+				adcOnGCE = func(context.Context) bool { return true }
+			} else {
+				t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "adc.json", data))
+			}
+
+			_, _, _, err := tokenSourceForADC(context.Background(), "a@b.com", []string{"s1"})
+			if !errors.Is(err, errADCNeedsImpersonation) {
+				t.Fatalf("expected errADCNeedsImpersonation, got %v", err)
+			}
+
+			t.Setenv("GOG_IMPERSONATE_SERVICE_ACCOUNT", "gog@proj.iam.gserviceaccount.com")
+			stubADCImpersonation(t)
+
+			if _, src, ok, err := tokenSourceForADC(context.Background(), "a@b.com", []string{"s1"}); err != nil || !ok || src.Type != name {
+				t.Fatalf("expected impersonated token source, got ok=%v src=%#v err=%v", ok, src, err)
+			}
+		})
+	}
+}
+
+func TestTokenSourceForADC_ServiceAccountKeyUsesSubject(t *testing.T) {
+	home := setupADCEnv(t)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", writeADCFile(t, home, "sa.json", `{"type":"service_account","client_email":"sa@proj.iam.gserviceaccount.com"}`))
+
+	origCreds := adcCredentialsFromJSON
+	t.Cleanup(func() { adcCredentialsFromJSON = origCreds })
+
+	var got google.CredentialsParams
+
+	adcCredentialsFromJSON = func(_ context.Context, _ []byte, params google.CredentialsParams) (*google.Credentials, error) {
+		got = params
+		return &google.Credentials{TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "sa"})}, nil
+	}
+
+	_, src, ok, err := tokenSourceForADC(context.Background(), "a@b.com", []string{"s1"})
+	if err != nil || !ok || src.Type != "service_account" {
+		t.Fatalf("expected token source, got ok=%v src=%#v err=%v", ok, src, err)
+	}
+
+	if got.Subject != "a@b.com" || len(got.Scopes) != 1 || got.Scopes[0] != "s1" {
+		t.Fatalf("expected DWD subject and scopes, got %#v", got)
+	}
+}
+
+func TestShouldFallBackToADC(t *testing.T) {
+	if shouldFallBackToADC(errBoom) {
+		t.Fatalf("unexpected fallback for generic error")
+	}
+
+	if shouldFallBackToADC(&AuthRequiredError{Service: "gmail", Email: "a@b.com", Cause: errBoom}) {
+		t.Fatalf("unexpected fallback for non-missing token")
+	}
+}
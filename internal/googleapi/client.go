@@ -50,10 +50,15 @@ func tokenSourceForAccount(ctx context.Context, service googleauth.Service, emai
 }
 
 func tokenSourceForAccountScopes(ctx context.Context, serviceLabel string, email string, client string, clientID string, clientSecret string, requiredScopes []string) (oauth2.TokenSource, error) {
+	ts, _, err := tokenAndSourceForAccountScopes(ctx, serviceLabel, email, client, clientID, clientSecret, requiredScopes)
+	return ts, err
+}
+
+func tokenAndSourceForAccountScopes(ctx context.Context, serviceLabel string, email string, client string, clientID string, clientSecret string, requiredScopes []string) (oauth2.TokenSource, secrets.Token, error) {
 	var store secrets.Store
 
 	if s, err := openSecretsStore(); err != nil {
-		return nil, fmt.Errorf("open secrets store: %w", err)
+		return nil, secrets.Token{}, fmt.Errorf("open secrets store: %w", err)
 	} else {
 		store = s
 	}
@@ -62,10 +67,10 @@ func tokenSourceForAccountScopes(ctx context.Context, serviceLabel string, email
 
 	if t, err := store.GetToken(client, email); err != nil {
 		if errors.Is(err, keyring.ErrKeyNotFound) {
-			return nil, &AuthRequiredError{Service: serviceLabel, Email: email, Client: client, Cause: err}
+			return nil, secrets.Token{}, &AuthRequiredError{Service: serviceLabel, Email: email, Client: client, Cause: err}
 		}
 
-		return nil, fmt.Errorf("get token for %s: %w", email, err)
+		return nil, secrets.Token{}, fmt.Errorf("get token for %s: %w", email, err)
 	} else {
 		tok = t
 	}
@@ -80,7 +85,7 @@ func tokenSourceForAccountScopes(ctx context.Context, serviceLabel string, email
 	// Ensure refresh-token exchanges don't hang forever.
 	ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Timeout: defaultHTTPTimeout})
 
-	return cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: tok.RefreshToken}), nil
+	return cfg.TokenSource(ctx, &oauth2.Token{RefreshToken: tok.RefreshToken}), tok, nil
 }
 
 func optionsForAccount(ctx context.Context, service googleauth.Service, email string) ([]option.ClientOption, error) {
@@ -95,41 +100,49 @@ func optionsForAccount(ctx context.Context, service googleauth.Service, email st
 func optionsForAccountScopes(ctx context.Context, serviceLabel string, email string, scopes []string) ([]option.ClientOption, error) {
 	slog.Debug("creating client options with custom scopes", "serviceLabel", serviceLabel, "email", email)
 
-	var creds config.ClientCredentials
-
 	var ts oauth2.TokenSource
 
+	// scopeCheck is only set for OAuth tokens; service accounts get their
+	// scopes from domain-wide delegation, not from an incremental consent.
+	var scopeCheck *scopeErrorTransport
+
 	if serviceAccountTS, saPath, ok, err := tokenSourceForServiceAccountScopes(ctx, email, scopes); err != nil {
 		return nil, fmt.Errorf("service account token source: %w", err)
 	} else if ok {
 		slog.Debug("using service account credentials", "email", email, "path", saPath)
 		ts = serviceAccountTS
-	} else {
-		client, err := authclient.ResolveClient(ctx, email)
-		if err != nil {
-			return nil, fmt.Errorf("resolve client: %w", err)
-		}
-
-		if c, err := readClientCredentials(client); err != nil {
-			return nil, fmt.Errorf("read credentials: %w", err)
-		} else {
-			creds = c
+	} else if tokenSource, check, err := oauthTokenSourceForAccountScopes(ctx, serviceLabel, email, scopes); err == nil {
+		ts = tokenSource
+		scopeCheck = check
+	} else if !shouldFallBackToADC(err) {
+		return nil, err
+	} else if adcTS, source, ok, adcErr := tokenSourceForADC(ctx, email, scopes); adcErr != nil {
+		if errors.Is(adcErr, errADCNeedsImpersonation) || errors.Is(adcErr, errUnsupportedADCType) {
+			// ADC that can't act for this account (e.g. a developer's
+			// gcloud login) shouldn't mask the usual "run gog auth add".
+			slog.Debug("application default credentials not usable", "email", email, "err", adcErr)
+			return nil, err
 		}
 
-		if tokenSource, err := tokenSourceForAccountScopes(ctx, serviceLabel, email, client, creds.ClientID, creds.ClientSecret, scopes); err != nil {
-			return nil, fmt.Errorf("token source: %w", err)
-		} else {
-			ts = tokenSource
-		}
+		return nil, fmt.Errorf("application default credentials: %w", adcErr)
+	} else if !ok {
+		return nil, err
+	} else {
+		slog.Debug("using application default credentials", "email", email, "type", source.Type, "path", source.Path, "impersonate", source.Impersonate)
+		ts = adcTS
 	}
 	baseTransport := newBaseTransport()
 	// Wrap with retry logic for 429 and 5xx errors
-	retryTransport := NewRetryTransport(&oauth2.Transport{
+	var transport http.RoundTripper = NewRetryTransport(&oauth2.Transport{
 		Source: ts,
 		Base:   baseTransport,
 	})
+	if scopeCheck != nil {
+		scopeCheck.base = transport
+		transport = scopeCheck
+	}
 	c := &http.Client{
-		Transport: retryTransport,
+		Transport: transport,
 		Timeout:   defaultHTTPTimeout,
 	}
 
@@ -138,6 +151,48 @@ func optionsForAccountScopes(ctx context.Context, serviceLabel string, email str
 	return []option.ClientOption{option.WithHTTPClient(c)}, nil
 }
 
+// oauthTokenSourceForAccountScopes returns the stored refresh-token source for
+// email together with the transport that turns 403s into scope upgrades.
+func oauthTokenSourceForAccountScopes(ctx context.Context, serviceLabel string, email string, scopes []string) (oauth2.TokenSource, *scopeErrorTransport, error) {
+	client, err := authclient.ResolveClient(ctx, email)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve client: %w", err)
+	}
+
+	creds, err := readClientCredentials(client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read credentials: %w", err)
+	}
+
+	tokenSource, tok, err := tokenAndSourceForAccountScopes(ctx, serviceLabel, email, client, creds.ClientID, creds.ClientSecret, scopes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("token source: %w", err)
+	}
+
+	return tokenSource, &scopeErrorTransport{
+		service:  serviceLabel,
+		email:    email,
+		client:   client,
+		services: tok.Services,
+		granted:  tok.Scopes,
+		required: scopes,
+	}, nil
+}
+
+// shouldFallBackToADC reports whether err means the account has no OAuth
+// setup at all (no client credentials or no stored refresh token), in which
+// case application default credentials are tried instead.
+func shouldFallBackToADC(err error) bool {
+	var credErr *config.CredentialsMissingError
+	if errors.As(err, &credErr) {
+		return true
+	}
+
+	var authErr *AuthRequiredError
+
+	return errors.As(err, &authErr) && errors.Is(authErr.Cause, keyring.ErrKeyNotFound)
+}
+
 func newBaseTransport() *http.Transport {
 	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
 	if !ok || defaultTransport == nil {
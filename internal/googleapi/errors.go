@@ -3,6 +3,7 @@ package googleapi
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -25,6 +26,28 @@ func (e *AuthRequiredError) Unwrap() error {
 	return e.Cause
 }
 
+// InsufficientScopesError indicates the stored OAuth token lacks scopes an API
+// call needs (403 insufficientPermissions), e.g. after `auth add --readonly`.
+type InsufficientScopesError struct {
+	Service string
+	Email   string
+	Client  string
+	// Services and Granted describe the stored token.
+	Services []string
+	Granted  []string
+	Required []string
+	Missing  []string
+	Cause    error
+}
+
+func (e *InsufficientScopesError) Error() string {
+	return fmt.Sprintf("insufficient scopes for %s %s: missing %s", e.Service, e.Email, strings.Join(e.Missing, " "))
+}
+
+func (e *InsufficientScopesError) Unwrap() error {
+	return e.Cause
+}
+
 // RateLimitError indicates rate limit was exceeded
 type RateLimitError struct {
 	RetryAfter time.Duration
@@ -122,3 +145,9 @@ func IsPermissionDeniedError(err error) bool {
 	var e *PermissionDeniedError
 	return errors.As(err, &e)
 }
+
+// IsInsufficientScopesError checks if the error is an insufficient scopes error
+func IsInsufficientScopesError(err error) bool {
+	var e *InsufficientScopesError
+	return errors.As(err, &e)
+}
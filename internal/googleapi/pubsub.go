@@ -0,0 +1,39 @@
+package googleapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/pubsub/v1"
+
+	"github.com/steipete/gogcli/internal/googleauth"
+)
+
+// envPubSubEmulatorHost is the variable the Google Cloud SDKs use to
+// target a local Pub/Sub emulator (e.g. localhost:8085).
+const envPubSubEmulatorHost = "PUBSUB_EMULATOR_HOST"
+
+// NewPubSub creates a Pub/Sub REST service, used to pull Gmail watch
+// notifications. With PUBSUB_EMULATOR_HOST set it talks to the emulator
+// without credentials.
+func NewPubSub(ctx context.Context, email string) (*pubsub.Service, error) {
+	if host := strings.TrimSpace(os.Getenv(envPubSubEmulatorHost)); host != "" {
+		svc, err := pubsub.NewService(ctx, option.WithEndpoint("http://"+host+"/"), option.WithoutAuthentication())
+		if err != nil {
+			return nil, fmt.Errorf("create pubsub emulator service: %w", err)
+		}
+
+		return svc, nil
+	}
+
+	if opts, err := optionsForAccount(ctx, googleauth.ServicePubSub, email); err != nil {
+		return nil, fmt.Errorf("pubsub options: %w", err)
+	} else if svc, err := pubsub.NewService(ctx, opts...); err != nil {
+		return nil, fmt.Errorf("create pubsub service: %w", err)
+	} else {
+		return svc, nil
+	}
+}
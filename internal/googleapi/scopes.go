@@ -0,0 +1,161 @@
+package googleapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	ggoogleapi "google.golang.org/api/googleapi"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/googleauth"
+)
+
+// maxScopeErrorBody caps how much of a 403 body is buffered for inspection.
+const maxScopeErrorBody = 1 << 20
+
+// scopeErrorTransport turns 403 insufficient-scope responses into an
+// InsufficientScopesError so callers can offer an incremental authorization.
+type scopeErrorTransport struct {
+	base     http.RoundTripper
+	service  string
+	email    string
+	client   string
+	services []string
+	granted  []string
+	required []string
+}
+
+func (t *scopeErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusForbidden {
+		return resp, err
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxScopeErrorBody))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		return resp, nil
+	}
+
+	gerr, ok := ggoogleapi.CheckResponse(resp).(*ggoogleapi.Error)
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if !ok || !isInsufficientScopes(resp, gerr) {
+		return resp, nil
+	}
+
+	missing := googleauth.MissingScopes(t.required, t.granted)
+	if len(missing) == 0 {
+		// The stored token claims the scopes, but Google disagrees (for example
+		// the user unticked a scope on the granular consent screen).
+		missing = googleauth.MissingScopes(t.required, nil)
+	}
+
+	return nil, &InsufficientScopesError{
+		Service:  t.service,
+		Email:    t.email,
+		Client:   t.client,
+		Services: append([]string(nil), t.services...),
+		Granted:  append([]string(nil), t.granted...),
+		Required: append([]string(nil), t.required...),
+		Missing:  missing,
+		Cause:    gerr,
+	}
+}
+
+func isInsufficientScopes(resp *http.Response, gerr *ggoogleapi.Error) bool {
+	if strings.Contains(resp.Header.Get("WWW-Authenticate"), "insufficient_scope") {
+		return true
+	}
+
+	// insufficientPermissions is also used for ACL failures (e.g. calendar
+	// writer access), so require the scope wording as well.
+	for _, item := range gerr.Errors {
+		if strings.EqualFold(item.Reason, "insufficientPermissions") && strings.Contains(strings.ToLower(gerr.Message), "scope") {
+			return true
+		}
+	}
+
+	return strings.Contains(gerr.Body, "ACCESS_TOKEN_SCOPE_INSUFFICIENT")
+}
+
+// serviceForLabel maps the service labels used by optionsForAccountScopes to
+// auth services.
+func serviceForLabel(label string) (googleauth.Service, bool) {
+	if label == "cloudidentity" {
+		return googleauth.ServiceGroups, true
+	}
+
+	svc, err := googleauth.ParseService(label)
+	if err != nil {
+		return "", false
+	}
+
+	return svc, true
+}
+
+// ServicesToAuthorize returns the stored token's services plus the service
+// that needs the missing scopes.
+func (e *InsufficientScopesError) ServicesToAuthorize() []string {
+	seen := make(map[string]struct{}, len(e.Services)+1)
+	out := make([]string, 0, len(e.Services)+1)
+
+	add := func(s string) {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" || s == string(googleauth.ServiceKeep) {
+			return
+		}
+
+		if _, ok := seen[s]; ok {
+			return
+		}
+
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+
+	for _, s := range e.Services {
+		add(s)
+	}
+
+	if svc, ok := serviceForLabel(e.Service); ok {
+		add(string(svc))
+	}
+
+	return out
+}
+
+// ScopesToAuthorize returns the union of the granted and required scopes.
+func (e *InsufficientScopesError) ScopesToAuthorize() []string {
+	seen := make(map[string]struct{}, len(e.Granted)+len(e.Required))
+	out := make([]string, 0, len(e.Granted)+len(e.Required))
+
+	for _, s := range append(append([]string(nil), e.Granted...), e.Required...) {
+		if _, ok := seen[s]; ok || s == "" {
+			continue
+		}
+
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+
+	return out
+}
+
+// AuthAddCommand returns the `gog auth add` invocation that grants the missing scopes.
+func (e *InsufficientScopesError) AuthAddCommand() string {
+	parts := []string{"gog auth add", e.Email}
+	if services := e.ServicesToAuthorize(); len(services) > 0 {
+		parts = append(parts, "--services", strings.Join(services, ","))
+	}
+
+	if e.Client != "" && e.Client != config.DefaultClientName {
+		parts = append(parts, "--client", e.Client)
+	}
+
+	parts = append(parts, "--force-consent")
+
+	return strings.Join(parts, " ")
+}
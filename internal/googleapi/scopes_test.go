@@ -0,0 +1,142 @@
+package googleapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	ggoogleapi "google.golang.org/api/googleapi"
+
+	"github.com/steipete/gogcli/internal/googleauth"
+)
+
+func newScopeErrorTestServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestScopeErrorTransport_InsufficientScopes(t *testing.T) {
+	srv := newScopeErrorTestServer(t, `{"error":{"code":403,"message":"Request had insufficient authentication scopes.","errors":[{"reason":"insufficientPermissions"}],"status":"PERMISSION_DENIED"}}`)
+
+	tr := &scopeErrorTransport{
+		base:     http.DefaultTransport,
+		service:  "gmail",
+		email:    "a@b.com",
+		client:   "default",
+		services: []string{"calendar"},
+		granted:  []string{"https://www.googleapis.com/auth/gmail.readonly", "cal"},
+		required: []string{"https://www.googleapis.com/auth/gmail.modify", "https://www.googleapis.com/auth/gmail.readonly"},
+	}
+
+	_, err := (&http.Client{Transport: tr}).Get(srv.URL)
+
+	var scopeErr *InsufficientScopesError
+	if !errors.As(err, &scopeErr) {
+		t.Fatalf("expected InsufficientScopesError, got %v", err)
+	}
+
+	if !reflect.DeepEqual(scopeErr.Missing, []string{"https://www.googleapis.com/auth/gmail.modify"}) {
+		t.Fatalf("unexpected missing: %#v", scopeErr.Missing)
+	}
+
+	var gerr *ggoogleapi.Error
+	if !errors.As(err, &gerr) || gerr.Code != http.StatusForbidden {
+		t.Fatalf("expected wrapped googleapi error, got %#v", gerr)
+	}
+
+	if got := scopeErr.AuthAddCommand(); got != "gog auth add a@b.com --services calendar,gmail --force-consent" {
+		t.Fatalf("unexpected command: %q", got)
+	}
+
+	if got := scopeErr.ScopesToAuthorize(); len(got) != 3 {
+		t.Fatalf("unexpected scopes: %#v", got)
+	}
+}
+
+func TestScopeErrorTransport_PubSubSuggestsPubSubService(t *testing.T) {
+	srv := newScopeErrorTestServer(t, `{"error":{"code":403,"message":"Request had insufficient authentication scopes.","status":"PERMISSION_DENIED","details":[{"reason":"ACCESS_TOKEN_SCOPE_INSUFFICIENT"}]}}`)
+
+	required, err := googleauth.Scopes(googleauth.ServicePubSub)
+	if err != nil {
+		t.Fatalf("Scopes: %v", err)
+	}
+
+	tr := &scopeErrorTransport{
+		base:     http.DefaultTransport,
+		service:  "pubsub",
+		email:    "a@b.com",
+		client:   "default",
+		services: []string{"gmail"},
+		granted:  []string{"https://www.googleapis.com/auth/gmail.modify"},
+		required: required,
+	}
+
+	_, err = (&http.Client{Transport: tr}).Get(srv.URL)
+
+	var scopeErr *InsufficientScopesError
+	if !errors.As(err, &scopeErr) {
+		t.Fatalf("expected InsufficientScopesError, got %v", err)
+	}
+
+	if !reflect.DeepEqual(scopeErr.Missing, []string{"https://www.googleapis.com/auth/pubsub"}) {
+		t.Fatalf("unexpected missing: %#v", scopeErr.Missing)
+	}
+
+	if got := scopeErr.AuthAddCommand(); got != "gog auth add a@b.com --services gmail,pubsub --force-consent" {
+		t.Fatalf("unexpected command: %q", got)
+	}
+
+	// The suggested services must grant the missing scope.
+	services := make([]googleauth.Service, 0, len(scopeErr.ServicesToAuthorize()))
+	for _, name := range scopeErr.ServicesToAuthorize() {
+		svc, parseErr := googleauth.ParseService(name)
+		if parseErr != nil {
+			t.Fatalf("ParseService(%q): %v", name, parseErr)
+		}
+
+		services = append(services, svc)
+	}
+
+	scopes, err := googleauth.ScopesForServices(services)
+	if err != nil {
+		t.Fatalf("ScopesForServices: %v", err)
+	}
+
+	if missing := googleauth.MissingScopes(scopeErr.Missing, scopes); len(missing) != 0 {
+		t.Fatalf("suggested services still miss %v", missing)
+	}
+}
+
+func TestScopeErrorTransport_PassesThroughOtherForbidden(t *testing.T) {
+	srv := newScopeErrorTestServer(t, `{"error":{"code":403,"message":"You need to have writer access to this calendar.","errors":[{"reason":"insufficientPermissions"}]}}`)
+
+	tr := &scopeErrorTransport{base: http.DefaultTransport, service: "calendar", email: "a@b.com"}
+
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	gerr, ok := ggoogleapi.CheckResponse(resp).(*ggoogleapi.Error)
+	if !ok || gerr.Code != http.StatusForbidden || gerr.Message == "" {
+		t.Fatalf("expected body to remain readable, got %#v", gerr)
+	}
+}
+
+func TestInsufficientScopesError_ServicesToAuthorize(t *testing.T) {
+	e := &InsufficientScopesError{Service: "cloudidentity", Services: []string{"gmail", "keep", "gmail"}}
+	if got := e.ServicesToAuthorize(); !reflect.DeepEqual(got, []string{"gmail", "groups"}) {
+		t.Fatalf("unexpected services: %#v", got)
+	}
+}
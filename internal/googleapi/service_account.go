@@ -82,3 +82,20 @@ func tokenSourceForServiceAccountScopes(ctx context.Context, email string, scope
 
 	return nil, "", false, nil
 }
+
+// ServiceAccountToken mints an access token for email using the stored
+// service account key (domain-wide delegation). ok is false when no service
+// account is configured for email.
+func ServiceAccountToken(ctx context.Context, email string, scopes []string) (*oauth2.Token, string, bool, error) {
+	ts, path, ok, err := tokenSourceForServiceAccountScopes(ctx, email, scopes)
+	if err != nil || !ok {
+		return nil, path, ok, err
+	}
+
+	tok, err := ts.Token()
+	if err != nil {
+		return nil, path, true, fmt.Errorf("service account token: %w", err)
+	}
+
+	return tok, path, true, nil
+}
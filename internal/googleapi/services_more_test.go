@@ -70,6 +70,10 @@ func TestNewServicesWithStoredToken(t *testing.T) {
 		t.Fatalf("NewCloudIdentityGroups: %v", err)
 	}
 
+	if _, err := NewPubSub(ctx, "a@b.com"); err != nil {
+		t.Fatalf("NewPubSub: %v", err)
+	}
+
 	if _, err := NewPeopleContacts(ctx, "a@b.com"); err != nil {
 		t.Fatalf("NewPeopleContacts: %v", err)
 	}
@@ -74,7 +74,7 @@ func shouldEnsureKeychainAccess() (bool, error) {
 		return false, err
 	}
 
-	return backendInfo.Value != "file", nil
+	return backendInfo.Value != "file" && !secrets.IsHelperBackend(backendInfo.Value), nil
 }
 
 // StartManageServer starts the accounts management server and opens browser
@@ -25,6 +25,7 @@ const (
 	ServiceAppScript Service = "appscript"
 	ServiceGroups    Service = "groups"
 	ServiceKeep      Service = "keep"
+	ServicePubSub    Service = "pubsub"
 )
 
 const (
@@ -74,6 +75,7 @@ var serviceOrder = []Service{
 	ServiceAppScript,
 	ServiceGroups,
 	ServiceKeep,
+	ServicePubSub,
 }
 
 var serviceInfoByService = map[Service]serviceInfo{
@@ -203,6 +205,12 @@ var serviceInfoByService = map[Service]serviceInfo{
 		apis:   []string{"Keep API"},
 		note:   "Workspace only; service account (domain-wide delegation)",
 	},
+	ServicePubSub: {
+		scopes: []string{"https://www.googleapis.com/auth/pubsub"},
+		user:   false,
+		apis:   []string{"Cloud Pub/Sub API"},
+		note:   "Opt-in; pulls Gmail watch notifications (watch serve --subscription)",
+	},
 }
 
 func ParseService(s string) (Service, error) {
@@ -522,6 +530,8 @@ func scopesForServiceWithOptions(service Service, opts ScopeOptions) ([]string,
 		return Scopes(service)
 	case ServiceKeep:
 		return Scopes(service)
+	case ServicePubSub:
+		return Scopes(service)
 	default:
 		return nil, errUnknownService
 	}
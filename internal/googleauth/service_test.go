@@ -23,6 +23,7 @@ func TestParseService(t *testing.T) {
 		{"appscript", ServiceAppScript},
 		{"groups", ServiceGroups},
 		{"keep", ServiceKeep},
+		{"pubsub", ServicePubSub},
 	}
 	for _, tt := range tests {
 		got, err := ParseService(tt.in)
@@ -65,7 +66,7 @@ func TestExtractCodeAndState_Errors(t *testing.T) {
 
 func TestAllServices(t *testing.T) {
 	svcs := AllServices()
-	if len(svcs) != 15 {
+	if len(svcs) != 16 {
 		t.Fatalf("unexpected: %v", svcs)
 	}
 	seen := make(map[Service]bool)
@@ -74,7 +75,7 @@ func TestAllServices(t *testing.T) {
 		seen[s] = true
 	}
 
-	for _, want := range []Service{ServiceGmail, ServiceCalendar, ServiceChat, ServiceClassroom, ServiceDrive, ServiceDocs, ServiceSlides, ServiceContacts, ServiceTasks, ServicePeople, ServiceSheets, ServiceForms, ServiceAppScript, ServiceGroups, ServiceKeep} {
+	for _, want := range []Service{ServiceGmail, ServiceCalendar, ServiceChat, ServiceClassroom, ServiceDrive, ServiceDocs, ServiceSlides, ServiceContacts, ServiceTasks, ServicePeople, ServiceSheets, ServiceForms, ServiceAppScript, ServiceGroups, ServiceKeep, ServicePubSub} {
 		if !seen[want] {
 			t.Fatalf("missing %q", want)
 		}
@@ -98,8 +99,8 @@ func TestUserServices(t *testing.T) {
 			seenSlides = true
 		case ServiceForms, ServiceAppScript:
 			// expected user services
-		case ServiceKeep:
-			t.Fatalf("unexpected keep in user services")
+		case ServiceKeep, ServicePubSub:
+			t.Fatalf("unexpected %s in user services", s)
 		}
 	}
 
@@ -0,0 +1,120 @@
+package googleauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var tokenInfoURL = "https://oauth2.googleapis.com/tokeninfo"
+
+var errTokenInfoRequestFailed = errors.New("tokeninfo request failed")
+
+// TokenInfo is the subset of Google's tokeninfo response used for diagnostics.
+type TokenInfo struct {
+	Email     string
+	Scopes    []string
+	ExpiresIn int64
+	// ServerTime is taken from the response Date header (zero if missing).
+	ServerTime time.Time
+}
+
+// FetchTokenInfo looks up the granted scopes for an access token.
+func FetchTokenInfo(ctx context.Context, accessToken string, timeout time.Duration) (TokenInfo, error) {
+	if strings.TrimSpace(accessToken) == "" {
+		return TokenInfo{}, errMissingAccessToken
+	}
+
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u := tokenInfoURL + "?access_token=" + url.QueryEscape(accessToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("create tokeninfo request: %w", err)
+	}
+
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return TokenInfo{}, fmt.Errorf("tokeninfo request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return TokenInfo{}, fmt.Errorf("%w: %s: %s", errTokenInfoRequestFailed, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var parsed struct {
+		Email     string `json:"email"`
+		Scope     string `json:"scope"`
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return TokenInfo{}, fmt.Errorf("decode tokeninfo: %w", err)
+	}
+
+	info := TokenInfo{
+		Email:  strings.TrimSpace(parsed.Email),
+		Scopes: strings.Fields(parsed.Scope),
+	}
+	if n, err := strconv.ParseInt(strings.TrimSpace(parsed.ExpiresIn), 10, 64); err == nil {
+		info.ExpiresIn = n
+	}
+	if date := resp.Header.Get("Date"); date != "" {
+		if t, err := http.ParseTime(date); err == nil {
+			info.ServerTime = t
+		}
+	}
+
+	return info, nil
+}
+
+// ScopesWithOptions returns the scopes requested for a single service under opts.
+func ScopesWithOptions(service Service, opts ScopeOptions) ([]string, error) {
+	if _, ok := serviceInfoByService[service]; !ok {
+		return nil, errUnknownService
+	}
+
+	return scopesForServiceWithOptions(service, opts)
+}
+
+// MissingScopes returns the required scopes that are not present in granted, sorted.
+func MissingScopes(required []string, granted []string) []string {
+	have := make(map[string]struct{}, len(granted))
+	for _, s := range granted {
+		have[strings.TrimSpace(s)] = struct{}{}
+	}
+
+	var missing []string
+
+	for _, s := range required {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+
+		if _, ok := have[s]; ok {
+			continue
+		}
+
+		missing = append(missing, s)
+	}
+
+	sort.Strings(missing)
+
+	return missing
+}
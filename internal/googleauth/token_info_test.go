@@ -0,0 +1,49 @@
+package googleauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchTokenInfo(t *testing.T) {
+	origURL := tokenInfoURL
+	t.Cleanup(func() { tokenInfoURL = origURL })
+
+	date := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("access_token") != "tok" {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Date", date.Format(http.TimeFormat))
+		_, _ = w.Write([]byte(`{"email":"a@b.com","scope":"s1 s2","expires_in":"3599"}`))
+	}))
+	defer srv.Close()
+	tokenInfoURL = srv.URL
+
+	info, err := FetchTokenInfo(context.Background(), "tok", time.Second)
+	if err != nil {
+		t.Fatalf("FetchTokenInfo: %v", err)
+	}
+	if info.Email != "a@b.com" || !reflect.DeepEqual(info.Scopes, []string{"s1", "s2"}) || info.ExpiresIn != 3599 || !info.ServerTime.Equal(date) {
+		t.Fatalf("unexpected info: %#v", info)
+	}
+
+	if _, err := FetchTokenInfo(context.Background(), "bad", time.Second); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestMissingScopes(t *testing.T) {
+	got := MissingScopes([]string{"c", "a", "b", ""}, []string{"b"})
+	if !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Fatalf("unexpected missing: %#v", got)
+	}
+	if got := MissingScopes([]string{"a"}, []string{"a", "x"}); len(got) != 0 {
+		t.Fatalf("expected none, got %#v", got)
+	}
+}
@@ -0,0 +1,427 @@
+package htmltext
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Options controls Render.
+type Options struct {
+	// CollapseQuotes replaces quoted replies (<blockquote>) with a one-line
+	// "[quoted text hidden: N lines]" marker instead of "> "-prefixed lines.
+	CollapseQuotes bool
+}
+
+// Render converts an HTML email body into readable plain text: block
+// elements become lines, lists get markers, tables are flattened to one line
+// per row, links become numbered footnotes, and quoted replies are prefixed
+// with "> " (or collapsed). Scripts, styles, and hidden elements are dropped.
+func Render(src string, opts Options) string {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return strings.TrimSpace(src)
+	}
+	links := &footnotes{index: map[string]int{}}
+	r := &renderer{opts: opts, links: links}
+	r.children(doc)
+
+	out := tidy(r.buf.String())
+	if len(links.urls) == 0 {
+		return out
+	}
+	var b strings.Builder
+	b.WriteString(out)
+	b.WriteString("\n\n")
+	for i, u := range links.urls {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, u)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+type footnotes struct {
+	urls  []string
+	index map[string]int
+}
+
+func (f *footnotes) add(u string) int {
+	if n, ok := f.index[u]; ok {
+		return n
+	}
+	f.urls = append(f.urls, u)
+	f.index[u] = len(f.urls)
+	return len(f.urls)
+}
+
+type renderer struct {
+	opts  Options
+	links *footnotes
+	buf   strings.Builder
+	space bool
+	pre   int
+	// spaces holds trailing spaces not yet written, so block can drop them
+	// without rewriting buf; nl counts the line breaks buf ends with.
+	spaces string
+	nl     int
+}
+
+// sub renders nodes into a separate buffer sharing the footnotes, for
+// content that is post-processed (cells, quotes, list items).
+func (r *renderer) sub(n *html.Node) string {
+	s := &renderer{opts: r.opts, links: r.links, pre: r.pre}
+	s.children(n)
+	return tidy(s.buf.String())
+}
+
+func (r *renderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.node(c)
+	}
+}
+
+var skipped = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Title: true,
+	atom.Noscript: true, atom.Template: true, atom.Iframe: true, atom.Object: true,
+	atom.Svg: true, atom.Button: true, atom.Select: true,
+}
+
+var blocks = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Main: true,
+	atom.Center: true, atom.Address: true, atom.Form: true, atom.Fieldset: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Ul: true, atom.Ol: true, atom.Tr: true, atom.Caption: true,
+}
+
+var paragraphs = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true,
+}
+
+func (r *renderer) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.ElementNode:
+	case html.DocumentNode:
+		r.children(n)
+		return
+	default:
+		return
+	}
+	if skipped[n.DataAtom] || hidden(n) {
+		return
+	}
+
+	switch {
+	case n.DataAtom == atom.Br:
+		r.write("\n")
+		r.space = false
+	case n.DataAtom == atom.Hr:
+		r.block(1)
+		r.write("---")
+		r.block(1)
+	case n.DataAtom == atom.A:
+		r.link(n)
+	case n.DataAtom == atom.Img:
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			r.text(alt)
+		}
+	case n.DataAtom == atom.Li:
+		r.item(n)
+	case n.DataAtom == atom.Blockquote:
+		r.quote(n)
+	case n.DataAtom == atom.Table:
+		r.table(n)
+	case n.DataAtom == atom.Pre:
+		r.block(1)
+		r.pre++
+		r.children(n)
+		r.pre--
+		r.block(1)
+	case paragraphs[n.DataAtom]:
+		r.block(2)
+		r.children(n)
+		r.block(2)
+	case blocks[n.DataAtom]:
+		r.block(1)
+		r.children(n)
+		r.block(1)
+	default:
+		r.children(n)
+	}
+}
+
+// text writes a text node, collapsing whitespace outside <pre>.
+func (r *renderer) text(s string) {
+	if r.pre > 0 {
+		r.write(s)
+		return
+	}
+	s = strings.ReplaceAll(s, "\u00a0", " ")
+	if s != "" && isSpace(s[0]) {
+		r.space = true
+	}
+	for i, word := range strings.Fields(s) {
+		if i > 0 || r.space {
+			r.writeSpace()
+		}
+		r.write(word)
+		r.space = false
+	}
+	if s != "" && isSpace(s[len(s)-1]) {
+		r.space = true
+	}
+}
+
+func (r *renderer) inline(s string) {
+	if s == "" {
+		return
+	}
+	if r.space {
+		r.writeSpace()
+	}
+	r.write(s)
+	r.space = false
+}
+
+// write appends s to the output. Its trailing spaces are held back until
+// more text follows.
+func (r *renderer) write(s string) {
+	body := strings.TrimRight(s, " ")
+	if body == "" {
+		r.spaces += s
+		return
+	}
+	if r.spaces != "" {
+		r.buf.WriteString(r.spaces)
+		r.nl = 0
+	}
+	r.buf.WriteString(body)
+	r.spaces = s[len(body):]
+	if breaks := len(body) - len(strings.TrimRight(body, "\n")); breaks == len(body) {
+		r.nl += breaks
+	} else {
+		r.nl = breaks
+	}
+}
+
+func (r *renderer) writeSpace() {
+	if r.buf.Len() > 0 && r.nl == 0 && r.spaces == "" {
+		r.spaces = " "
+	}
+}
+
+// block ends the current line and makes sure n line breaks separate what
+// follows (2 = blank line). Nothing is added at the start of the output.
+func (r *renderer) block(n int) {
+	r.space = false
+	r.spaces = ""
+	if r.buf.Len() == 0 || r.nl >= n {
+		return
+	}
+	r.buf.WriteString(strings.Repeat("\n", n-r.nl))
+	r.nl = n
+}
+
+func (r *renderer) writeBlock(s string) {
+	if s == "" {
+		return
+	}
+	r.block(1)
+	r.write(s)
+	r.block(1)
+}
+
+func (r *renderer) link(n *html.Node) {
+	label := strings.Join(strings.Fields(r.sub(n)), " ")
+	href := strings.TrimSpace(attr(n, "href"))
+	if label == "" {
+		return
+	}
+	if !footnoteURL(href) || sameTarget(label, href) {
+		r.inline(label)
+		return
+	}
+	r.inline(fmt.Sprintf("%s [%d]", label, r.links.add(href)))
+}
+
+func footnoteURL(href string) bool {
+	lower := strings.ToLower(href)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+func sameTarget(label, href string) bool {
+	trim := func(s string) string {
+		s = strings.ToLower(strings.TrimSpace(s))
+		for _, prefix := range []string{"mailto:", "https://", "http://", "www."} {
+			s = strings.TrimPrefix(s, prefix)
+		}
+		return strings.TrimSuffix(s, "/")
+	}
+	return trim(label) == trim(href)
+}
+
+func (r *renderer) item(n *html.Node) {
+	marker := "- "
+	if p := n.Parent; p != nil && p.DataAtom == atom.Ol {
+		pos := 1
+		if start, err := strconv.Atoi(attr(p, "start")); err == nil {
+			pos = start
+		}
+		for c := p.FirstChild; c != nil && c != n; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.DataAtom == atom.Li {
+				pos++
+			}
+		}
+		marker = strconv.Itoa(pos) + ". "
+	}
+	body := r.sub(n)
+	if body == "" {
+		return
+	}
+	indent := strings.Repeat(" ", len(marker))
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if i == 0 {
+			lines[i] = marker + line
+		} else if line != "" {
+			lines[i] = indent + line
+		}
+	}
+	r.writeBlock(strings.Join(lines, "\n"))
+}
+
+func (r *renderer) quote(n *html.Node) {
+	body := r.sub(n)
+	if body == "" {
+		return
+	}
+	lines := strings.Split(body, "\n")
+	if r.opts.CollapseQuotes {
+		count := 0
+		for _, line := range lines {
+			if line != "" {
+				count++
+			}
+		}
+		noun := "lines"
+		if count == 1 {
+			noun = "line"
+		}
+		r.block(2)
+		r.write(fmt.Sprintf("[quoted text hidden: %d %s]", count, noun))
+		r.block(2)
+		return
+	}
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	r.block(2)
+	r.write(strings.Join(lines, "\n"))
+	r.block(2)
+}
+
+// table flattens a table to one line per row with cells joined by " | ".
+// Rows whose cells span several lines (layout tables) keep each cell on its
+// own lines instead.
+func (r *renderer) table(n *html.Node) {
+	r.block(1)
+	for _, row := range tableRows(n) {
+		var cells []string
+		multiline := false
+		for c := row.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || (c.DataAtom != atom.Td && c.DataAtom != atom.Th) || hidden(c) {
+				continue
+			}
+			if text := r.sub(c); text != "" {
+				cells = append(cells, text)
+				multiline = multiline || strings.Contains(text, "\n")
+			}
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		if multiline {
+			for _, cell := range cells {
+				r.writeBlock(cell)
+			}
+			continue
+		}
+		r.writeBlock(strings.Join(cells, " | "))
+	}
+	r.block(1)
+}
+
+func tableRows(n *html.Node) []*html.Node {
+	var rows []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || hidden(c) {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Tr:
+			rows = append(rows, c)
+		case atom.Thead, atom.Tbody, atom.Tfoot:
+			rows = append(rows, tableRows(c)...)
+		}
+	}
+	return rows
+}
+
+var displayNonePattern = regexp.MustCompile(`(?i)(^|;)\s*(display\s*:\s*none|visibility\s*:\s*hidden|max-height\s*:\s*0(px)?\s*(;|$))`)
+
+// hidden reports elements that mail clients don't show, like preheader
+// text in newsletters.
+func hidden(n *html.Node) bool {
+	for _, a := range n.Attr {
+		switch strings.ToLower(a.Key) {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if strings.EqualFold(a.Val, "true") && n.DataAtom != atom.Img {
+				return true
+			}
+		case "style":
+			if displayNonePattern.MatchString(a.Val) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
+
+var blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+
+// tidy trims trailing spaces on every line and squeezes blank-line runs.
+func tidy(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLinesPattern.ReplaceAllString(s, "\n\n")
+	return strings.Trim(s, "\n")
+}
@@ -0,0 +1,103 @@
+package htmltext
+
+import (
+	"strings"
+	"testing"
+)
+
+//nolint:wsl_v5
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		html string
+		opts Options
+		want string
+	}{
+		{
+			name: "paragraphs and breaks",
+			html: "<html><head><title>x</title><style>p{}</style></head><body><p>Hello\n   <b>there</b>,</p><p>line one<br>line two</p><script>alert(1)</script></body></html>",
+			want: "Hello there,\n\nline one\nline two",
+		},
+		{
+			name: "links as footnotes",
+			html: `<p>Read <a href="https://example.com/a">the post</a> or <a href="https://example.com/a">this</a>, mail <a href="mailto:me@example.com">me@example.com</a>, <a href="#top">top</a>.</p><p><a href="https://example.com/b">Unsubscribe</a></p>`,
+			want: "Read the post [1] or this [1], mail me@example.com, top.\n\nUnsubscribe [2]\n\n[1] https://example.com/a\n[2] https://example.com/b",
+		},
+		{
+			name: "tables flattened",
+			html: "<table><thead><tr><th>Item</th><th>Qty</th></tr></thead><tbody><tr><td>Apples</td><td>3</td></tr><tr><td></td><td></td></tr><tr><td>Pears</td><td>5</td></tr></tbody></table>",
+			want: "Item | Qty\nApples | 3\nPears | 5",
+		},
+		{
+			name: "layout tables",
+			html: "<table><tr><td><table><tr><td><p>Top story</p><p>Body</p></td></tr></table></td><td>Sidebar</td></tr></table>",
+			want: "Top story\n\nBody\nSidebar",
+		},
+		{
+			name: "lists",
+			html: `<ul><li>one</li><li>two<br>cont</li></ul><ol start="3"><li>three</li><li>four</li></ol>`,
+			want: "- one\n- two\n  cont\n3. three\n4. four",
+		},
+		{
+			name: "quoted reply",
+			html: "<div>Sounds good.</div><blockquote><p>Shall we meet?</p><p>-- A</p></blockquote>",
+			want: "Sounds good.\n\n> Shall we meet?\n>\n> -- A",
+		},
+		{
+			name: "collapsed quote",
+			html: "<div>Sounds good.</div><blockquote><p>Shall we meet?</p><p>-- A</p></blockquote><p>Bye</p>",
+			opts: Options{CollapseQuotes: true},
+			want: "Sounds good.\n\n[quoted text hidden: 2 lines]\n\nBye",
+		},
+		{
+			name: "hidden preheader and images",
+			html: `<div style="display: none; max-height:0">preview text</div><span hidden>x</span><img src="a.png" alt="Logo"> Hi&nbsp;there<hr>end`,
+			want: "Logo Hi there\n---\nend",
+		},
+		{
+			name: "preformatted",
+			html: "<p>Code:</p><pre>a  b\n  c</pre>",
+			want: "Code:\n\na  b\n  c",
+		},
+		{
+			name: "spaces before blocks",
+			html: "<div>one </div> <div> two <br> </div><pre>x  </pre>three",
+			want: "one\ntwo\nx\nthree",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := Render(tc.html, tc.opts)
+			if got != tc.want {
+				t.Fatalf("Render() =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRender_PlainTextFallsThrough(t *testing.T) {
+	t.Parallel()
+
+	if got := Render("  just text  ", Options{}); got != "just text" {
+		t.Fatalf("got %q", got)
+	}
+	if got := Render("", Options{}); strings.TrimSpace(got) != "" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRender_ManyBlocks(t *testing.T) {
+	t.Parallel()
+
+	// Long newsletters have tens of thousands of blocks; each must cost
+	// the same no matter how much output precedes it.
+	const n = 50000
+	got := Render(strings.Repeat("<div>row</div>", n), Options{})
+	if lines := strings.Count(got, "\n") + 1; lines != n {
+		t.Fatalf("expected %d lines, got %d", n, lines)
+	}
+}
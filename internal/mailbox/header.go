@@ -0,0 +1,139 @@
+package mailbox
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+)
+
+// LabelsHeader is the header Gmail Takeout and Thunderbird use for Gmail labels.
+const LabelsHeader = "X-Gmail-Labels"
+
+// SetHeader returns raw with any existing name: header removed and a fresh
+// name: value header prepended. The line ending style of raw is preserved.
+func SetHeader(raw []byte, name string, value string) []byte {
+	eol := "\n"
+	if bytes.Contains(raw, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+
+	headerEnd := len(raw)
+	if i := bytes.Index(raw, []byte(eol+eol)); i >= 0 {
+		headerEnd = i + len(eol)
+	}
+
+	var out bytes.Buffer
+
+	out.Grow(len(raw) + len(name) + len(value) + 4)
+	out.WriteString(name + ": " + sanitizeHeaderValue(value) + eol)
+
+	skipping := false
+	for _, line := range bytes.SplitAfter(raw[:headerEnd], []byte(eol)) {
+		if len(line) == 0 {
+			continue
+		}
+
+		// Folded continuation lines belong to the previous header.
+		if line[0] == ' ' || line[0] == '\t' {
+			if !skipping {
+				out.Write(line)
+			}
+
+			continue
+		}
+
+		colon := bytes.IndexByte(line, ':')
+		skipping = colon > 0 && strings.EqualFold(strings.TrimSpace(string(line[:colon])), name)
+
+		if !skipping {
+			out.Write(line)
+		}
+	}
+
+	out.Write(raw[headerEnd:])
+
+	return out.Bytes()
+}
+
+// HeaderValue returns the first value of the named header in raw, unfolded.
+func HeaderValue(raw []byte, name string) string {
+	raw = bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	if i := bytes.Index(raw, []byte("\n\n")); i >= 0 {
+		raw = raw[:i+1]
+	}
+
+	var (
+		value strings.Builder
+		found bool
+	)
+
+	for _, line := range strings.SplitAfter(string(raw), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if found {
+				value.WriteString(" " + strings.TrimSpace(line))
+			}
+
+			continue
+		}
+
+		if found {
+			break
+		}
+
+		colon := strings.IndexByte(line, ':')
+		if colon > 0 && strings.EqualFold(strings.TrimSpace(line[:colon]), name) {
+			found = true
+			value.WriteString(strings.TrimSpace(line[colon+1:]))
+		}
+	}
+
+	return value.String()
+}
+
+// FormatLabels renders label names for LabelsHeader, quoting names that
+// contain commas or quotes the way Gmail Takeout does.
+func FormatLabels(names []string) string {
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		if strings.ContainsAny(name, ",\"") {
+			name = `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		}
+
+		parts = append(parts, name)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// ParseLabels splits a LabelsHeader value into label names.
+func ParseLabels(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	r := csv.NewReader(strings.NewReader(value))
+	r.LazyQuotes = true
+	r.TrimLeadingSpace = true
+
+	fields, err := r.Read()
+	if err != nil {
+		fields = strings.Split(value, ",")
+	}
+
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+
+	return out
+}
+
+func sanitizeHeaderValue(v string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(v)
+}
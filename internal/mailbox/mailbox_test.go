@@ -0,0 +1,164 @@
+package mailbox
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMboxRoundTrip(t *testing.T) {
+	msgs := [][]byte{
+		[]byte("From: Alice <alice@example.com>\r\nSubject: hi\r\n\r\nFrom the top\r\n>From quoted\r\n>>From deeper\r\nplain\r\n"),
+		[]byte("From: bob@example.com\nSubject: no trailing newline\n\nbody"),
+		[]byte("Subject: no sender\n\n\nFrom start after blank\n"),
+	}
+
+	var buf bytes.Buffer
+
+	w := NewMboxWriter(&buf)
+	date := time.Date(2024, 3, 5, 7, 8, 9, 0, time.UTC)
+
+	for _, m := range msgs {
+		if err := w.WriteMessage(m, date); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"From alice@example.com Tue Mar  5 07:08:09 2024\n",
+		"\n>From the top\n>>From quoted\n>>>From deeper\nplain\n",
+		"From bob@example.com Tue Mar  5 07:08:09 2024\n",
+		"From MAILER-DAEMON Tue Mar  5 07:08:09 2024\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+
+	if strings.Contains(out, "\r") {
+		t.Fatalf("expected LF line endings in mbox")
+	}
+
+	r := NewMboxReader(strings.NewReader(out))
+
+	for i, m := range msgs {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next %d: %v", i, err)
+		}
+
+		want := strings.ReplaceAll(string(m), "\r\n", "\n")
+		if !strings.HasSuffix(want, "\n") {
+			want += "\n"
+		}
+
+		if string(got) != want {
+			t.Fatalf("message %d mismatch:\ngot  %q\nwant %q", i, got, want)
+		}
+	}
+
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestMboxReaderRejectsNonMbox(t *testing.T) {
+	r := NewMboxReader(strings.NewReader("Subject: x\n\nbody\n"))
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "not an mbox") {
+		t.Fatalf("expected not-an-mbox error, got %v", err)
+	}
+
+	if _, err := NewMboxReader(strings.NewReader("")).Next(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected EOF for empty file, got %v", err)
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	raw := []byte("X-Gmail-Labels: old,\r\n folded\r\nSubject: s\r\n\r\nX-Gmail-Labels: body stays\r\n")
+
+	got := string(SetHeader(raw, LabelsHeader, "Inbox,Work\nInjected: x"))
+	want := "X-Gmail-Labels: Inbox,Work Injected: x\r\nSubject: s\r\n\r\nX-Gmail-Labels: body stays\r\n"
+
+	if got != want {
+		t.Fatalf("SetHeader:\ngot  %q\nwant %q", got, want)
+	}
+
+	if v := HeaderValue(raw, "x-gmail-labels"); v != "old, folded" {
+		t.Fatalf("HeaderValue = %q", v)
+	}
+
+	if v := HeaderValue(raw, "Message-ID"); v != "" {
+		t.Fatalf("expected empty header, got %q", v)
+	}
+}
+
+func TestLabelsRoundTrip(t *testing.T) {
+	names := []string{"INBOX", "Work, Projects", `Say "hi"`, "A/B"}
+
+	value := FormatLabels(names)
+	if value != `INBOX,"Work, Projects","Say ""hi""",A/B` {
+		t.Fatalf("FormatLabels = %q", value)
+	}
+
+	got := ParseLabels(value)
+	if strings.Join(got, "|") != strings.Join(names, "|") {
+		t.Fatalf("ParseLabels = %q", got)
+	}
+
+	if got := ParseLabels("Inbox, Unread,Category Updates"); strings.Join(got, "|") != "Inbox|Unread|Category Updates" {
+		t.Fatalf("ParseLabels takeout = %q", got)
+	}
+}
+
+func TestMaildirDeliver(t *testing.T) {
+	dir := Maildir(filepath.Join(t.TempDir(), "INBOX"))
+	if err := dir.Create(); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	name, err := dir.Deliver("abc123", "SFS", []byte("Subject: x\n\nbody\n"), time.Unix(1700000000, 0))
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	if !strings.HasPrefix(name, "1700000000.abc123.") || !strings.HasSuffix(name, InfoSeparator+"2,FS") {
+		t.Fatalf("unexpected name %q", name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(string(dir), "cur", name))
+	if err != nil || string(data) != "Subject: x\n\nbody\n" {
+		t.Fatalf("unexpected content %q err=%v", data, err)
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(string(dir), "tmp"))
+	if len(entries) != 0 {
+		t.Fatalf("expected empty tmp, got %d entries", len(entries))
+	}
+
+	if unique, flags := SplitFileName(name); flags != "FS" || FileName(unique, "SF") != name {
+		t.Fatalf("SplitFileName = %q, %q", unique, flags)
+	}
+
+	if unique, flags := SplitFileName("new/123.abc.host"); unique != "123.abc.host" || flags != "" {
+		t.Fatalf("SplitFileName without info = %q, %q", unique, flags)
+	}
+
+	if !IsMaildir(string(dir)) || IsMaildir(t.TempDir()) {
+		t.Fatalf("IsMaildir mismatch")
+	}
+
+	msgs, err := dir.Messages()
+	if err != nil || len(msgs) != 1 || msgs[0] != filepath.Join("cur", name) {
+		t.Fatalf("Messages = %v err=%v", msgs, err)
+	}
+
+	if _, err := dir.Deliver("../x", "", nil, time.Time{}); !errors.Is(err, errInvalidMaildirKey) {
+		t.Fatalf("expected invalid key error, got %v", err)
+	}
+}
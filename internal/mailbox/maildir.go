@@ -0,0 +1,143 @@
+package mailbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+var errInvalidMaildirKey = errors.New("invalid maildir key")
+
+// Maildir flags (see https://cr.yp.to/proto/maildir.html), kept in ASCII order.
+const (
+	FlagDraft   = 'D'
+	FlagFlagged = 'F'
+	FlagReplied = 'R'
+	FlagSeen    = 'S'
+	FlagTrashed = 'T'
+)
+
+// InfoSeparator precedes the ":2," flag suffix. Windows forbids ':' in file
+// names, so the common '!' substitute is used there.
+var InfoSeparator = func() string {
+	if runtime.GOOS == "windows" {
+		return "!"
+	}
+
+	return ":"
+}()
+
+// Maildir is a directory with cur/, new/, and tmp/ subdirectories.
+type Maildir string
+
+// Create makes the Maildir and its subdirectories if they do not exist.
+func (d Maildir) Create() error {
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(string(d), sub), 0o700); err != nil {
+			return fmt.Errorf("create maildir: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Deliver writes data into cur/ with the given unique key and flags, going
+// through tmp/ so readers never see a partial message. It returns the final
+// file name. key must be stable and unique per message (e.g. a Gmail message ID).
+func (d Maildir) Deliver(key string, flags string, data []byte, received time.Time) (string, error) {
+	if key == "" || strings.ContainsAny(key, "/:!\\") {
+		return "", fmt.Errorf("%w: %q", errInvalidMaildirKey, key)
+	}
+
+	if received.IsZero() {
+		received = time.Now()
+	}
+
+	host, _ := os.Hostname()
+	host = strings.NewReplacer("/", `\057`, ":", `\072`, "!", `\041`).Replace(host)
+	unique := fmt.Sprintf("%d.%s.%s", received.Unix(), key, host)
+
+	tmp := filepath.Join(string(d), "tmp", unique)
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("write maildir message: %w", err)
+	}
+
+	name := FileName(unique, flags)
+	if err := os.Rename(tmp, filepath.Join(string(d), "cur", name)); err != nil {
+		_ = os.Remove(tmp)
+		return "", fmt.Errorf("deliver maildir message: %w", err)
+	}
+
+	return name, nil
+}
+
+// Messages returns the paths of the messages in new/ and cur/, relative to
+// the Maildir and sorted by name.
+func (d Maildir) Messages() ([]string, error) {
+	var out []string
+
+	for _, sub := range []string{"new", "cur"} {
+		entries, err := os.ReadDir(filepath.Join(string(d), sub))
+		if err != nil {
+			return nil, fmt.Errorf("read maildir: %w", err)
+		}
+
+		for _, e := range entries {
+			if e.Type().IsRegular() && !strings.HasPrefix(e.Name(), ".") {
+				out = append(out, filepath.Join(sub, e.Name()))
+			}
+		}
+	}
+
+	sort.Strings(out)
+
+	return out, nil
+}
+
+// IsMaildir reports whether dir looks like a Maildir (has a cur/ subdirectory).
+func IsMaildir(dir string) bool {
+	st, err := os.Stat(filepath.Join(dir, "cur"))
+	return err == nil && st.IsDir()
+}
+
+// FileName joins a unique message name and its flags into a cur/ file name.
+func FileName(unique string, flags string) string {
+	return unique + InfoSeparator + "2," + NormalizeFlags(flags)
+}
+
+// SplitFileName splits a Maildir file name into its unique part and info
+// flags. Names without a ":2," (or "!2,") suffix have no flags.
+func SplitFileName(name string) (unique string, flags string) {
+	name = filepath.Base(name)
+	for _, sep := range []string{":2,", "!2,"} {
+		if i := strings.LastIndex(name, sep); i >= 0 {
+			return name[:i], NormalizeFlags(name[i+len(sep):])
+		}
+	}
+
+	return name, ""
+}
+
+// NormalizeFlags de-duplicates and sorts Maildir info flags.
+func NormalizeFlags(flags string) string {
+	seen := map[rune]bool{}
+	out := make([]rune, 0, len(flags))
+
+	for _, r := range flags {
+		if r < 'A' || r > 'Z' || seen[r] {
+			continue
+		}
+
+		seen[r] = true
+		out = append(out, r)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+
+	return string(out)
+}
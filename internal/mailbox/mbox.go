@@ -0,0 +1,197 @@
+// Package mailbox reads and writes local mail stores: mboxrd files (RFC 4155),
+// single-message .eml files, and Maildir directories.
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const mboxDefaultSender = "MAILER-DAEMON"
+
+var errMessageTooLarge = errors.New("mbox message exceeds size limit")
+
+// MaxMessageSize bounds a single message read from an mbox file. Gmail caps
+// messages at 50 MB; leave headroom for encoding overhead.
+const MaxMessageSize = 64 << 20
+
+// MboxWriter writes messages in mboxrd format: each message starts with a
+// "From " separator line, and body lines matching ^>*From are quoted with one
+// extra '>' so readers can reverse the escaping exactly.
+type MboxWriter struct {
+	w *bufio.Writer
+}
+
+func NewMboxWriter(w io.Writer) *MboxWriter {
+	return &MboxWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteMessage appends raw (an RFC 5322 message, CRLF or LF line endings).
+// The envelope sender is taken from the From header; date is the receive time.
+func (m *MboxWriter) WriteMessage(raw []byte, date time.Time) error {
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	if _, err := fmt.Fprintf(m.w, "From %s %s\n", envelopeSender(raw), date.UTC().Format(time.ANSIC)); err != nil {
+		return err
+	}
+
+	body := bytes.ReplaceAll(raw, []byte("\r\n"), []byte("\n"))
+	for len(body) > 0 {
+		line := body
+		if i := bytes.IndexByte(body, '\n'); i >= 0 {
+			line = body[:i+1]
+		}
+
+		body = body[len(line):]
+
+		if isFromLine(line) {
+			if err := m.w.WriteByte('>'); err != nil {
+				return err
+			}
+		}
+
+		if _, err := m.w.Write(line); err != nil {
+			return err
+		}
+	}
+
+	if !bytes.HasSuffix(raw, []byte("\n")) {
+		if err := m.w.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+
+	// Blank separator line between messages.
+	if err := m.w.WriteByte('\n'); err != nil {
+		return err
+	}
+
+	return m.w.Flush()
+}
+
+// MboxReader iterates the messages in an mboxrd (or plain mboxo) file.
+type MboxReader struct {
+	r       *bufio.Reader
+	started bool
+	done    bool
+}
+
+func NewMboxReader(r io.Reader) *MboxReader {
+	return &MboxReader{r: bufio.NewReaderSize(r, 64<<10)}
+}
+
+// Next returns the next message with mboxrd quoting removed and LF line
+// endings, or io.EOF when the file is exhausted.
+func (m *MboxReader) Next() ([]byte, error) {
+	if m.done {
+		return nil, io.EOF
+	}
+
+	if !m.started {
+		if err := m.skipToFirstSeparator(); err != nil {
+			return nil, err
+		}
+	}
+
+	var msg bytes.Buffer
+
+	for {
+		line, err := m.readLine()
+		if len(line) > 0 {
+			if bytes.HasPrefix(line, []byte("From ")) {
+				return trimSeparator(msg.Bytes()), nil
+			}
+
+			if isQuotedFromLine(line) {
+				line = line[1:]
+			}
+
+			if msg.Len()+len(line) > MaxMessageSize {
+				return nil, errMessageTooLarge
+			}
+
+			msg.Write(line)
+		}
+
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+
+			m.done = true
+
+			return trimSeparator(msg.Bytes()), nil
+		}
+	}
+}
+
+func (m *MboxReader) skipToFirstSeparator() error {
+	for {
+		line, err := m.readLine()
+		if len(line) > 0 {
+			if bytes.HasPrefix(line, []byte("From ")) {
+				m.started = true
+				return nil
+			}
+
+			// Tolerate leading blank lines before the first separator.
+			if len(bytes.TrimSpace(line)) > 0 {
+				return fmt.Errorf("not an mbox file: first line %q", strings.TrimSpace(string(line)))
+			}
+		}
+
+		if err != nil {
+			m.done = true
+			return err
+		}
+	}
+}
+
+func (m *MboxReader) readLine() ([]byte, error) {
+	line, err := m.r.ReadBytes('\n')
+	if bytes.HasSuffix(line, []byte("\r\n")) {
+		line = append(line[:len(line)-2], '\n')
+	}
+
+	return line, err
+}
+
+// trimSeparator drops the blank line that separates messages.
+func trimSeparator(b []byte) []byte {
+	out := append([]byte(nil), b...)
+	if bytes.HasSuffix(out, []byte("\n\n")) {
+		out = out[:len(out)-1]
+	}
+
+	return out
+}
+
+func isFromLine(line []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From "))
+}
+
+func isQuotedFromLine(line []byte) bool {
+	return len(line) > 0 && line[0] == '>' && isFromLine(line)
+}
+
+func envelopeSender(raw []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return mboxDefaultSender
+	}
+
+	addrs, err := msg.Header.AddressList("From")
+	if err != nil || len(addrs) == 0 || strings.ContainsAny(addrs[0].Address, " \t") {
+		return mboxDefaultSender
+	}
+
+	return addrs[0].Address
+}
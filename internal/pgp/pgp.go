@@ -0,0 +1,366 @@
+// Package pgp implements the OpenPGP operations behind PGP/MIME mail
+// (RFC 3156): detached signatures, encryption, and decryption against a local
+// keyring file. Everything runs locally; no agent or key server is involved.
+//
+// RSA, DSA/ElGamal, and ECC keys (Ed25519/Cv25519, the GnuPG default since
+// 2.3, plus NIST curves) are supported.
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const (
+	// MicAlg is the multipart/signed micalg parameter for Sign's hash.
+	MicAlg = "pgp-sha256"
+
+	armorBegin = "-----BEGIN PGP "
+)
+
+var (
+	ErrNoSecretKey   = errors.New("no OpenPGP secret key")
+	ErrNoPublicKey   = errors.New("no OpenPGP public key")
+	ErrBadSignature  = errors.New("bad signature")
+	ErrUnknownSigner = errors.New("signing key not in keyring")
+	ErrBadPassphrase = errors.New("wrong passphrase")
+
+	errNoKeys = errors.New("no OpenPGP keys found")
+)
+
+// Keyring is a set of public and secret keys read from a keyring file.
+type Keyring struct {
+	entities openpgp.EntityList
+}
+
+// Key is one key (with its subkeys) from a Keyring.
+type Key struct {
+	entity *openpgp.Entity
+}
+
+// Signature describes a verified signature.
+type Signature struct {
+	KeyID       string
+	Fingerprint string
+	Signer      string
+	// Emails are the (lower-cased) addresses of the signing key's user IDs.
+	Emails  []string
+	Created time.Time
+}
+
+// Message is the result of Decrypt.
+type Message struct {
+	Content []byte
+	// Signed is set when the encrypted payload carried an inline signature;
+	// Signature is set when it verified, SignatureErr otherwise.
+	Signed       bool
+	Signature    *Signature
+	SignatureErr error
+}
+
+// ReadKeyring parses binary or ASCII-armored key material. Several armored
+// blocks may be concatenated (e.g. `gpg --export-secret-keys -a me` followed
+// by `gpg --export -a bob`).
+func ReadKeyring(data []byte) (*Keyring, error) {
+	k := &Keyring{}
+	if !bytes.Contains(data, []byte(armorBegin)) {
+		entities, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("read keyring: %w", err)
+		}
+		k.entities = entities
+	}
+	for rest := data; ; {
+		i := bytes.Index(rest, []byte(armorBegin))
+		if i < 0 {
+			break
+		}
+		rest = rest[i:]
+		block, err := armor.Decode(bytes.NewReader(rest))
+		if err != nil {
+			return nil, fmt.Errorf("read keyring: %w", err)
+		}
+		if block.Type == openpgp.PublicKeyType || block.Type == openpgp.PrivateKeyType {
+			entities, err := openpgp.ReadKeyRing(block.Body)
+			if err != nil {
+				return nil, fmt.Errorf("read keyring: %w", err)
+			}
+			k.entities = append(k.entities, entities...)
+		}
+		rest = rest[len(armorBegin):]
+	}
+	if len(k.entities) == 0 {
+		return nil, errNoKeys
+	}
+	return k, nil
+}
+
+// LoadKeyring reads the keyring file at path.
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // user-provided path
+	if err != nil {
+		return nil, fmt.Errorf("read keyring: %w", err)
+	}
+	return ReadKeyring(data)
+}
+
+// SecretKey returns the key with secret material for email.
+func (k *Keyring) SecretKey(email string) (*Key, error) {
+	for _, e := range k.entities {
+		if e.PrivateKey != nil && entityHasEmail(e, email) {
+			return &Key{entity: e}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w for %s", ErrNoSecretKey, email)
+}
+
+// PublicKey returns the key for email, preferring one that is not only a
+// secret key export.
+func (k *Keyring) PublicKey(email string) (*Key, error) {
+	var fallback *openpgp.Entity
+	for _, e := range k.entities {
+		if !entityHasEmail(e, email) {
+			continue
+		}
+		if e.PrivateKey == nil {
+			return &Key{entity: e}, nil
+		}
+		if fallback == nil {
+			fallback = e
+		}
+	}
+	if fallback != nil {
+		return &Key{entity: fallback}, nil
+	}
+	return nil, fmt.Errorf("%w for %s", ErrNoPublicKey, email)
+}
+
+func entityHasEmail(e *openpgp.Entity, email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	for _, id := range e.Identities {
+		if id.UserId != nil && strings.ToLower(id.UserId.Email) == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Fingerprint is the upper-case hex fingerprint of the primary key.
+func (key *Key) Fingerprint() string {
+	return fmt.Sprintf("%X", key.entity.PrimaryKey.Fingerprint)
+}
+
+// Locked reports whether the secret key material is passphrase-protected.
+func (key *Key) Locked() bool {
+	if key.entity.PrivateKey != nil && key.entity.PrivateKey.Encrypted {
+		return true
+	}
+	for _, sub := range key.entity.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			return true
+		}
+	}
+	return false
+}
+
+// Unlock decrypts the secret key and subkeys with passphrase.
+func (key *Key) Unlock(passphrase string) error {
+	if key.entity.PrivateKey != nil && key.entity.PrivateKey.Encrypted {
+		if err := key.entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+			return ErrBadPassphrase
+		}
+	}
+	for _, sub := range key.entity.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			if err := sub.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return ErrBadPassphrase
+			}
+		}
+	}
+	return nil
+}
+
+func config() *packet.Config {
+	return &packet.Config{DefaultHash: crypto.SHA256, DefaultCipher: packet.CipherAES256}
+}
+
+// Sign returns an ASCII-armored detached signature over content. The key
+// must be unlocked.
+func Sign(content []byte, signer *Key) ([]byte, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, signer.entity, bytes.NewReader(content), config()); err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// Encrypt returns an ASCII-armored OpenPGP message for recipients.
+func Encrypt(content []byte, recipients []*Key) ([]byte, error) {
+	to := make([]*openpgp.Entity, 0, len(recipients))
+	for _, r := range recipients {
+		to = append(to, r.entity)
+	}
+
+	var b bytes.Buffer
+	aw, err := armor.Encode(&b, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	w, err := openpgp.Encrypt(aw, to, nil, &openpgp.FileHints{IsBinary: true}, config())
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	b.WriteString("\n")
+	return b.Bytes(), nil
+}
+
+// Verify checks a detached (armored or binary) signature over content.
+func (k *Keyring) Verify(content, signature []byte) (*Signature, error) {
+	body, err := dearmor(signature)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := parseSignature(body)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := openpgp.CheckDetachedSignature(k.entities, bytes.NewReader(content), bytes.NewReader(body), config())
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return nil, fmt.Errorf("%w (key %s)", ErrUnknownSigner, sig.KeyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+	sig.fill(signer)
+	return sig, nil
+}
+
+// Decrypt decrypts an (armored or binary) OpenPGP message. passphrase is
+// called once for a locked secret key; it may be nil when keys are unlocked.
+func (k *Keyring) Decrypt(data []byte, passphrase func(*Key) (string, error)) (*Message, error) {
+	body, err := dearmor(data)
+	if err != nil {
+		return nil, err
+	}
+
+	prompted := false
+	prompt := func(keys []openpgp.Key, _ bool) ([]byte, error) {
+		if prompted || passphrase == nil || len(keys) == 0 {
+			return nil, ErrBadPassphrase
+		}
+		prompted = true
+		key := &Key{entity: keys[0].Entity}
+		p, err := passphrase(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := key.Unlock(p); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	md, err := openpgp.ReadMessage(bytes.NewReader(body), k.entities, prompt, config())
+	if errors.Is(err, pgperrors.ErrKeyIncorrect) {
+		return nil, ErrNoSecretKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+	content, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt: %w", err)
+	}
+
+	msg := &Message{Content: content, Signed: md.IsSigned}
+	if md.IsSigned {
+		switch {
+		case md.SignedBy == nil:
+			msg.SignatureErr = fmt.Errorf("%w (key %016X)", ErrUnknownSigner, md.SignedByKeyId)
+		case md.SignatureError != nil:
+			msg.SignatureErr = fmt.Errorf("%w: %w", ErrBadSignature, md.SignatureError)
+		default:
+			sig := &Signature{KeyID: fmt.Sprintf("%016X", md.SignedByKeyId)}
+			if md.Signature != nil {
+				sig.Created = md.Signature.CreationTime
+			}
+			sig.fill(md.SignedBy.Entity)
+			msg.Signature = sig
+		}
+	}
+	return msg, nil
+}
+
+func (s *Signature) fill(signer *openpgp.Entity) {
+	if signer == nil {
+		return
+	}
+	s.Fingerprint = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	for _, id := range signer.Identities {
+		if id.UserId == nil {
+			continue
+		}
+		if email := strings.ToLower(strings.TrimSpace(id.UserId.Email)); email != "" {
+			s.Emails = append(s.Emails, email)
+		}
+		if s.Signer == "" || (id.SelfSignature != nil && id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId) {
+			s.Signer = id.UserId.Email
+			if s.Signer == "" {
+				s.Signer = id.Name
+			}
+		}
+	}
+}
+
+func parseSignature(body []byte) (*Signature, error) {
+	p, err := packet.NewReader(bytes.NewReader(body)).Next()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+	switch sig := p.(type) {
+	case *packet.Signature:
+		out := &Signature{Created: sig.CreationTime}
+		if sig.IssuerKeyId != nil {
+			out.KeyID = fmt.Sprintf("%016X", *sig.IssuerKeyId)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%w: not a signature", ErrBadSignature)
+}
+
+func dearmor(data []byte) ([]byte, error) {
+	i := bytes.Index(data, []byte(armorBegin))
+	if i < 0 {
+		return data, nil
+	}
+	block, err := armor.Decode(bytes.NewReader(data[i:]))
+	if err != nil {
+		return nil, fmt.Errorf("decode armor: %w", err)
+	}
+	body, err := io.ReadAll(block.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decode armor: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,190 @@
+package pgp
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func newTestEntity(t *testing.T, name, email string) *openpgp.Entity {
+	t.Helper()
+	e, err := openpgp.NewEntity(name, "", email, nil)
+	if err != nil {
+		t.Fatalf("NewEntity: %v", err)
+	}
+	return e
+}
+
+func armored(t *testing.T, e *openpgp.Entity, private bool) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	blockType := openpgp.PublicKeyType
+	if private {
+		blockType = openpgp.PrivateKeyType
+	}
+	w, err := armor.Encode(&b, blockType, nil)
+	if err != nil {
+		t.Fatalf("armor: %v", err)
+	}
+	if private {
+		err = e.SerializePrivate(w, nil)
+	} else {
+		err = e.Serialize(w)
+	}
+	if err != nil {
+		t.Fatalf("serialize: %v", err)
+	}
+	_ = w.Close()
+	b.WriteString("\n")
+	return b.Bytes()
+}
+
+func TestReadKeyring_Concatenated(t *testing.T) {
+	me := newTestEntity(t, "Me", "me@example.com")
+	bob := newTestEntity(t, "Bob", "bob@example.com")
+
+	data := append(armored(t, me, true), armored(t, bob, false)...)
+	k, err := ReadKeyring(data)
+	if err != nil {
+		t.Fatalf("ReadKeyring: %v", err)
+	}
+	if _, err := k.SecretKey("ME@example.com"); err != nil {
+		t.Fatalf("SecretKey(me): %v", err)
+	}
+	if _, err := k.SecretKey("bob@example.com"); !errors.Is(err, ErrNoSecretKey) {
+		t.Fatalf("expected ErrNoSecretKey, got %v", err)
+	}
+	if key, err := k.PublicKey("bob@example.com"); err != nil || key.Locked() {
+		t.Fatalf("PublicKey(bob) = %v, %v", key, err)
+	}
+	if _, err := k.PublicKey("nobody@example.com"); !errors.Is(err, ErrNoPublicKey) {
+		t.Fatalf("expected ErrNoPublicKey, got %v", err)
+	}
+
+	var binary bytes.Buffer
+	_ = bob.Serialize(&binary)
+	if _, err := ReadKeyring(binary.Bytes()); err != nil {
+		t.Fatalf("ReadKeyring(binary): %v", err)
+	}
+	if _, err := ReadKeyring([]byte("not a key")); err == nil {
+		t.Fatalf("expected error for garbage input")
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	me := newTestEntity(t, "Me", "me@example.com")
+	k, err := ReadKeyring(armored(t, me, true))
+	if err != nil {
+		t.Fatalf("ReadKeyring: %v", err)
+	}
+	signer, _ := k.SecretKey("me@example.com")
+
+	content := []byte("Content-Type: text/plain\r\n\r\nhello\r\n")
+	sig, err := Sign(content, signer)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !bytes.HasPrefix(sig, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		t.Fatalf("expected armored signature, got %q", sig)
+	}
+
+	info, err := k.Verify(content, sig)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if info.Signer != "me@example.com" || info.Fingerprint != signer.Fingerprint() || len(info.KeyID) != 16 {
+		t.Fatalf("unexpected signature info: %+v", info)
+	}
+
+	if _, err := k.Verify([]byte("tampered"), sig); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+
+	other, _ := ReadKeyring(armored(t, newTestEntity(t, "Other", "other@example.com"), false))
+	if _, err := other.Verify(content, sig); !errors.Is(err, ErrUnknownSigner) {
+		t.Fatalf("expected ErrUnknownSigner, got %v", err)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	me := newTestEntity(t, "Me", "me@example.com")
+	bob := newTestEntity(t, "Bob", "bob@example.com")
+
+	sender, _ := ReadKeyring(append(armored(t, me, true), armored(t, bob, false)...))
+	meKey, _ := sender.PublicKey("me@example.com")
+	bobKey, _ := sender.PublicKey("bob@example.com")
+
+	content := []byte("secret entity\r\n")
+	ct, err := Encrypt(content, []*Key{bobKey, meKey})
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if bytes.Contains(ct, content) || !bytes.HasPrefix(ct, []byte("-----BEGIN PGP MESSAGE-----")) {
+		t.Fatalf("unexpected ciphertext: %q", ct)
+	}
+
+	recipient, _ := ReadKeyring(armored(t, bob, true))
+	msg, err := recipient.Decrypt(ct, nil)
+	if err != nil {
+		t.Fatalf("Decrypt(bob): %v", err)
+	}
+	if !bytes.Equal(msg.Content, content) || msg.Signed {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if msg, err := sender.Decrypt(ct, nil); err != nil || !bytes.Equal(msg.Content, content) {
+		t.Fatalf("Decrypt(me) = %v, %v", msg, err)
+	}
+
+	stranger, _ := ReadKeyring(armored(t, newTestEntity(t, "Carol", "carol@example.com"), true))
+	if _, err := stranger.Decrypt(ct, nil); !errors.Is(err, ErrNoSecretKey) {
+		t.Fatalf("expected ErrNoSecretKey, got %v", err)
+	}
+}
+
+// TestEd25519Keys covers the key type GnuPG generates by default since 2.3:
+// an Ed25519 primary (signing) key with a Cv25519 encryption subkey.
+func TestEd25519Keys(t *testing.T) {
+	cfg := &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA, Curve: packet.Curve25519}
+	newKey := func(name, email string) *openpgp.Entity {
+		e, err := openpgp.NewEntity(name, "", email, cfg)
+		if err != nil {
+			t.Fatalf("NewEntity: %v", err)
+		}
+		if e.PrimaryKey.PubKeyAlgo != packet.PubKeyAlgoEdDSA || len(e.Subkeys) != 1 || e.Subkeys[0].PublicKey.PubKeyAlgo != packet.PubKeyAlgoECDH {
+			t.Fatalf("expected Ed25519 + Cv25519 key, got %v / %+v", e.PrimaryKey.PubKeyAlgo, e.Subkeys)
+		}
+		return e
+	}
+	me := newKey("Me", "me@example.com")
+	bob := newKey("Bob", "bob@example.com")
+
+	sender, err := ReadKeyring(append(armored(t, me, true), armored(t, bob, false)...))
+	if err != nil {
+		t.Fatalf("ReadKeyring: %v", err)
+	}
+	signer, _ := sender.SecretKey("me@example.com")
+	bobKey, _ := sender.PublicKey("bob@example.com")
+
+	content := []byte("Content-Type: text/plain\r\n\r\nhello\r\n")
+	sig, err := Sign(content, signer)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if info, err := sender.Verify(content, sig); err != nil || info.Signer != "me@example.com" {
+		t.Fatalf("Verify = %+v, %v", info, err)
+	}
+
+	ct, err := Encrypt(content, []*Key{bobKey})
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	recipient, _ := ReadKeyring(armored(t, bob, true))
+	msg, err := recipient.Decrypt(ct, nil)
+	if err != nil || !bytes.Equal(msg.Content, content) {
+		t.Fatalf("Decrypt = %+v, %v", msg, err)
+	}
+}
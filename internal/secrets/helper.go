@@ -0,0 +1,239 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/99designs/keyring"
+
+	"github.com/steipete/gogcli/internal/config"
+)
+
+// Credential helper backend
+//
+// keyring_backend "helper:<cmd>" delegates storage to an external program.
+// For each operation gog runs <cmd> with the operation name appended as the
+// last argument (get|set|delete|list) and writes one JSON request to stdin:
+//
+//	{"version":1,"op":"get","service":"gogcli","key":"token:default:a@b.com"}
+//	{"version":1,"op":"set","service":"gogcli","key":"...","value":"<base64>"}
+//
+// The helper answers with one JSON object on stdout:
+//
+//	get:    {"value":"<base64>"}
+//	list:   {"keys":["..."]}
+//	set/delete: {}
+//
+// A missing key is reported as {"error":"not_found"}; any other "error" value
+// or a non-zero exit status fails the operation (stderr is included).
+//
+// <cmd> is split on whitespace; wrap a path or argument containing spaces in
+// single or double quotes ('helper:"/Applications/My Vault/helper" --json').
+// Backslashes are kept as-is so Windows paths need no escaping.
+
+const (
+	keyringBackendHelperPrefix = "helper:"
+	helperProtocolVersion      = 1
+	helperErrorNotFound        = "not_found"
+)
+
+var (
+	helperTimeout        = 30 * time.Second
+	errEmptyHelperCmd    = errors.New("empty credential helper command")
+	errHelperQuote       = errors.New("unterminated quote in credential helper command")
+	errHelperFailed      = errors.New("credential helper failed")
+	helperCommandContext = exec.CommandContext
+)
+
+type helperRequest struct {
+	Version int    `json:"version"`
+	Op      string `json:"op"`
+	Service string `json:"service"`
+	Key     string `json:"key,omitempty"`
+	Value   []byte `json:"value,omitempty"`
+}
+
+type helperResponse struct {
+	Value []byte   `json:"value,omitempty"`
+	Keys  []string `json:"keys,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// helperKeyring implements keyring.Keyring on top of an external helper.
+type helperKeyring struct {
+	argv []string
+}
+
+// IsHelperBackend reports whether a keyring backend value selects an external helper.
+func IsHelperBackend(value string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), keyringBackendHelperPrefix)
+}
+
+// HelperCommand returns the command of a "helper:<cmd>" backend value, or ""
+// for other backends.
+func HelperCommand(value string) string {
+	if !IsHelperBackend(value) {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimSpace(value)[len(keyringBackendHelperPrefix):])
+}
+
+// HelperArgv splits the command of a "helper:<cmd>" backend value into
+// arguments, honoring single and double quotes.
+func HelperArgv(value string) ([]string, error) {
+	var (
+		argv  []string
+		arg   strings.Builder
+		inArg bool
+		quote rune
+	)
+
+	for _, r := range HelperCommand(value) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				argv = append(argv, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errHelperQuote
+	}
+
+	if inArg {
+		argv = append(argv, arg.String())
+	}
+
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, errEmptyHelperCmd
+	}
+
+	return argv, nil
+}
+
+func newHelperKeyring(backend string) (*helperKeyring, error) {
+	argv, err := HelperArgv(backend)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := config.ExpandPath(argv[0])
+	if err != nil {
+		return nil, err
+	}
+
+	argv[0] = expanded
+
+	return &helperKeyring{argv: argv}, nil
+}
+
+func (h *helperKeyring) call(op string, key string, value []byte) (helperResponse, error) {
+	req, err := json.Marshal(helperRequest{
+		Version: helperProtocolVersion,
+		Op:      op,
+		Service: config.AppName,
+		Key:     key,
+		Value:   value,
+	})
+	if err != nil {
+		return helperResponse{}, fmt.Errorf("encode helper request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), helperTimeout)
+	defer cancel()
+
+	args := append(append([]string(nil), h.argv[1:]...), op)
+	cmd := helperCommandContext(ctx, h.argv[0], args...) //nolint:gosec // helper command is user-configured
+	cmd.Stdin = bytes.NewReader(req)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if runErr := cmd.Run(); runErr != nil {
+		if ctx.Err() != nil {
+			return helperResponse{}, fmt.Errorf("%w: %s %s: timed out after %s", errHelperFailed, h.argv[0], op, helperTimeout)
+		}
+
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return helperResponse{}, fmt.Errorf("%w: %s %s: %w: %s", errHelperFailed, h.argv[0], op, runErr, msg)
+		}
+
+		return helperResponse{}, fmt.Errorf("%w: %s %s: %w", errHelperFailed, h.argv[0], op, runErr)
+	}
+
+	var resp helperResponse
+
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, &resp); err != nil {
+			return helperResponse{}, fmt.Errorf("%w: %s %s: invalid JSON response: %w", errHelperFailed, h.argv[0], op, err)
+		}
+	}
+
+	switch resp.Error {
+	case "":
+		return resp, nil
+	case helperErrorNotFound:
+		return resp, keyring.ErrKeyNotFound
+	default:
+		return resp, fmt.Errorf("%w: %s %s: %s", errHelperFailed, h.argv[0], op, resp.Error)
+	}
+}
+
+func (h *helperKeyring) Get(key string) (keyring.Item, error) {
+	resp, err := h.call("get", key, nil)
+	if err != nil {
+		return keyring.Item{}, err
+	}
+
+	return keyringItem(key, resp.Value), nil
+}
+
+func (h *helperKeyring) GetMetadata(_ string) (keyring.Metadata, error) {
+	return keyring.Metadata{}, keyring.ErrMetadataNotSupported
+}
+
+func (h *helperKeyring) Set(item keyring.Item) error {
+	_, err := h.call("set", item.Key, item.Data)
+	return err
+}
+
+func (h *helperKeyring) Remove(key string) error {
+	_, err := h.call("delete", key, nil)
+	return err
+}
+
+func (h *helperKeyring) Keys() ([]string, error) {
+	resp, err := h.call("list", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := append([]string(nil), resp.Keys...)
+	sort.Strings(keys)
+
+	return keys, nil
+}
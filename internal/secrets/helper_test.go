@@ -0,0 +1,271 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/99designs/keyring"
+)
+
+// TestHelperProcess is not a real test; it acts as a credential helper when
+// re-executed by useFakeHelper. Secrets are kept in the JSON file named by
+// GOG_TEST_HELPER_STORE.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GOG_TEST_HELPER") != "1" {
+		return
+	}
+
+	os.Exit(runFakeHelper(os.Args[len(os.Args)-1]))
+}
+
+func runFakeHelper(op string) int {
+	if mode := os.Getenv("GOG_TEST_HELPER_MODE"); mode != "" {
+		switch mode {
+		case "crash":
+			fmt.Fprint(os.Stderr, "vault sealed")
+			return 3
+		case "garbage":
+			fmt.Fprint(os.Stdout, "not json")
+			return 0
+		case "denied":
+			fmt.Fprint(os.Stdout, `{"error":"access denied"}`)
+			return 0
+		}
+	}
+
+	var req helperRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		return 1
+	}
+
+	if req.Op != op || req.Version != helperProtocolVersion || req.Service != "gogcli" {
+		fmt.Fprintf(os.Stderr, "unexpected request %+v for op %s", req, op)
+		return 1
+	}
+
+	path := os.Getenv("GOG_TEST_HELPER_STORE")
+	store := map[string][]byte{}
+
+	if b, err := os.ReadFile(path); err == nil { //nolint:gosec // test fixture
+		_ = json.Unmarshal(b, &store)
+	}
+
+	var resp helperResponse
+
+	switch op {
+	case "get":
+		v, ok := store[req.Key]
+		if !ok {
+			resp.Error = helperErrorNotFound
+		}
+
+		resp.Value = v
+	case "set":
+		store[req.Key] = req.Value
+	case "delete":
+		if _, ok := store[req.Key]; !ok {
+			resp.Error = helperErrorNotFound
+		}
+
+		delete(store, req.Key)
+	case "list":
+		for k := range store {
+			resp.Keys = append(resp.Keys, k)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown op %q", op)
+		return 2
+	}
+
+	b, _ := json.Marshal(store)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		fmt.Fprint(os.Stderr, err)
+		return 1
+	}
+
+	_ = json.NewEncoder(os.Stdout).Encode(resp)
+
+	return 0
+}
+
+func useFakeHelper(t *testing.T, mode string) string {
+	t.Helper()
+
+	storePath := filepath.Join(t.TempDir(), "store.json")
+
+	orig := helperCommandContext
+	t.Cleanup(func() { helperCommandContext = orig })
+
+	helperCommandContext = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		if name != "fake-helper" {
+			t.Errorf("unexpected helper command %q", name)
+		}
+
+		cmdArgs := append([]string{"-test.run=TestHelperProcess", "--"}, args...)
+		cmd := exec.CommandContext(ctx, os.Args[0], cmdArgs...) //nolint:gosec // test binary
+		cmd.Env = append(os.Environ(),
+			"GOG_TEST_HELPER=1",
+			"GOG_TEST_HELPER_MODE="+mode,
+			"GOG_TEST_HELPER_STORE="+storePath,
+		)
+
+		return cmd
+	}
+
+	return storePath
+}
+
+func TestHelperKeyringRoundTrip(t *testing.T) {
+	useFakeHelper(t, "")
+
+	ring, err := newHelperKeyring("helper:fake-helper --vault test")
+	if err != nil {
+		t.Fatalf("newHelperKeyring: %v", err)
+	}
+
+	if _, err = ring.Get("missing"); !errors.Is(err, keyring.ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+
+	if err = ring.Set(keyringItem("b", []byte{0, 1, 2})); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if err = ring.Set(keyringItem("a", []byte("secret"))); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	item, err := ring.Get("b")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if string(item.Data) != "\x00\x01\x02" || item.Key != "b" {
+		t.Fatalf("unexpected item: %+v", item)
+	}
+
+	keys, err := ring.Keys()
+	if err != nil {
+		t.Fatalf("Keys: %v", err)
+	}
+
+	if !sort.StringsAreSorted(keys) || strings.Join(keys, ",") != "a,b" {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+
+	if err = ring.Remove("a"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	if err = ring.Remove("a"); !errors.Is(err, keyring.ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound on second remove, got %v", err)
+	}
+
+	if _, err = ring.GetMetadata("b"); !errors.Is(err, keyring.ErrMetadataNotSupported) {
+		t.Fatalf("expected ErrMetadataNotSupported, got %v", err)
+	}
+}
+
+func TestHelperKeyringErrors(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{mode: "crash", want: "vault sealed"},
+		{mode: "garbage", want: "invalid JSON response"},
+		{mode: "denied", want: "access denied"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.mode, func(t *testing.T) {
+			useFakeHelper(t, tc.mode)
+
+			ring, err := newHelperKeyring("helper:fake-helper")
+			if err != nil {
+				t.Fatalf("newHelperKeyring: %v", err)
+			}
+
+			_, err = ring.Get("k")
+			if !errors.Is(err, errHelperFailed) || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected %q helper error, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestNewHelperKeyringEmpty(t *testing.T) {
+	if _, err := newHelperKeyring("helper:  "); !errors.Is(err, errEmptyHelperCmd) {
+		t.Fatalf("expected errEmptyHelperCmd, got %v", err)
+	}
+}
+
+func TestHelperArgv(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		err  error
+	}{
+		{in: "helper:gog-helper --vault test", want: []string{"gog-helper", "--vault", "test"}},
+		{in: `helper:"/Applications/My Vault/helper" --profile 'Work Team'`, want: []string{"/Applications/My Vault/helper", "--profile", "Work Team"}},
+		{in: `HELPER:  "C:\Program Files\Vault\helper.exe"  get`, want: []string{`C:\Program Files\Vault\helper.exe`, "get"}},
+		{in: `helper:helper --label=""`, want: []string{"helper", "--label="}},
+		{in: `helper:"/opt/vault/helper`, err: errHelperQuote},
+		{in: `helper:""`, err: errEmptyHelperCmd},
+		{in: "keychain", err: errEmptyHelperCmd},
+	}
+	for _, tc := range tests {
+		got, err := HelperArgv(tc.in)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("HelperArgv(%q) err = %v, want %v", tc.in, err, tc.err)
+			}
+
+			continue
+		}
+
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("HelperArgv(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+}
+
+func TestHelperBackendViaStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg-config"))
+	t.Setenv(keyringBackendEnv, "  HELPER:fake-helper  ")
+	useFakeHelper(t, "")
+
+	info, err := ResolveKeyringBackendInfo()
+	if err != nil {
+		t.Fatalf("ResolveKeyringBackendInfo: %v", err)
+	}
+
+	if info.Value != "helper:fake-helper" {
+		t.Fatalf("unexpected backend value %q", info.Value)
+	}
+
+	store, err := OpenDefault()
+	if err != nil {
+		t.Fatalf("OpenDefault: %v", err)
+	}
+
+	if err = store.SetDefaultAccount("default", "a@b.com"); err != nil {
+		t.Fatalf("SetDefaultAccount: %v", err)
+	}
+
+	got, err := store.GetDefaultAccount("default")
+	if err != nil || got != "a@b.com" {
+		t.Fatalf("GetDefaultAccount = %q, %v", got, err)
+	}
+}
@@ -75,7 +75,7 @@ const (
 )
 
 func ResolveKeyringBackendInfo() (KeyringBackendInfo, error) {
-	if v := normalizeKeyringBackend(os.Getenv(keyringBackendEnv)); v != "" {
+	if v := NormalizeBackend(os.Getenv(keyringBackendEnv)); v != "" {
 		return KeyringBackendInfo{Value: v, Source: keyringBackendSourceEnv}, nil
 	}
 
@@ -85,7 +85,7 @@ func ResolveKeyringBackendInfo() (KeyringBackendInfo, error) {
 	}
 
 	if cfg.KeyringBackend != "" {
-		if v := normalizeKeyringBackend(cfg.KeyringBackend); v != "" {
+		if v := NormalizeBackend(cfg.KeyringBackend); v != "" {
 			return KeyringBackendInfo{Value: v, Source: keyringBackendSourceConfig}, nil
 		}
 	}
@@ -102,7 +102,7 @@ func allowedBackends(info KeyringBackendInfo) ([]keyring.BackendType, error) {
 	case "file":
 		return []keyring.BackendType{keyring.FileBackend}, nil
 	default:
-		return nil, fmt.Errorf("%w: %q (expected %s, keychain, or file)", errInvalidKeyringBackend, info.Value, keyringBackendAuto)
+		return nil, fmt.Errorf("%w: %q (expected %s, keychain, file, or helper:<cmd>)", errInvalidKeyringBackend, info.Value, keyringBackendAuto)
 	}
 }
 
@@ -139,8 +139,15 @@ func fileKeyringPasswordFunc() keyring.PromptFunc {
 	return fileKeyringPasswordFuncFrom(password, passwordSet, term.IsTerminal(int(os.Stdin.Fd())))
 }
 
-func normalizeKeyringBackend(value string) string {
-	return strings.ToLower(strings.TrimSpace(value))
+// NormalizeBackend lowercases a keyring backend value. Helper commands are
+// case-sensitive paths, so only their prefix is normalized.
+func NormalizeBackend(value string) string {
+	value = strings.TrimSpace(value)
+	if IsHelperBackend(value) {
+		return keyringBackendHelperPrefix + HelperCommand(value)
+	}
+
+	return strings.ToLower(value)
 }
 
 // keyringOpenTimeout is the maximum time to wait for keyring.Open() to complete.
@@ -170,6 +177,10 @@ func openKeyring() (keyring.Keyring, error) {
 		return nil, err
 	}
 
+	if IsHelperBackend(backendInfo.Value) {
+		return newHelperKeyring(backendInfo.Value)
+	}
+
 	backends, err := allowedBackends(backendInfo)
 	if err != nil {
 		return nil, err
@@ -292,6 +303,25 @@ func GetSecret(key string) ([]byte, error) {
 	return item.Data, nil
 }
 
+// DeleteSecret removes key; a missing key is not an error.
+func DeleteSecret(key string) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return errMissingSecretKey
+	}
+
+	ring, err := openKeyringFunc()
+	if err != nil {
+		return err
+	}
+
+	if err := ring.Remove(key); err != nil && !errors.Is(err, keyring.ErrKeyNotFound) && !errors.Is(err, os.ErrNotExist) {
+		return wrapKeychainError(fmt.Errorf("delete secret: %w", err))
+	}
+
+	return nil
+}
+
 func (s *KeyringStore) Keys() ([]string, error) {
 	keys, err := s.ring.Keys()
 	if err != nil {
@@ -1,6 +1,7 @@
 package secrets
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 	"time"
@@ -69,3 +70,23 @@ func TestEnsureKeyringDir(t *testing.T) {
 		t.Fatalf("EnsureKeyringDir: %v", err)
 	}
 }
+
+func TestDeleteSecret_FileBackend(t *testing.T) {
+	setupKeyringEnv(t)
+
+	if err := SetSecret("test/delete", []byte("value")); err != nil {
+		t.Fatalf("SetSecret: %v", err)
+	}
+
+	if err := DeleteSecret("test/delete"); err != nil {
+		t.Fatalf("DeleteSecret: %v", err)
+	}
+
+	if _, err := GetSecret("test/delete"); !errors.Is(err, keyring.ErrKeyNotFound) {
+		t.Fatalf("expected missing key after delete, got %v", err)
+	}
+
+	if err := DeleteSecret("test/delete"); err != nil {
+		t.Fatalf("DeleteSecret missing key: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package smime
+
+import "errors"
+
+const maxBERDepth = 64
+
+var errBERTruncated = errors.New("truncated BER data")
+
+// berToDER re-encodes BER input with definite lengths so it can be read with
+// cryptobyte. Many mail clients emit indefinite-length CMS structures.
+// Constructed strings are kept as-is; readOctets joins their segments.
+func berToDER(in []byte) ([]byte, error) {
+	out, _, err := berElement(in, 0)
+	return out, err
+}
+
+func berElement(in []byte, depth int) (der []byte, rest []byte, err error) {
+	if depth > maxBERDepth {
+		return nil, nil, errMalformed
+	}
+	if len(in) < 2 {
+		return nil, nil, errBERTruncated
+	}
+
+	tagEnd := 1
+	if in[0]&0x1f == 0x1f {
+		for tagEnd < len(in) && in[tagEnd]&0x80 != 0 {
+			tagEnd++
+		}
+		tagEnd++
+	}
+	if tagEnd >= len(in) {
+		return nil, nil, errBERTruncated
+	}
+	tag := in[:tagEnd]
+	constructed := in[0]&0x20 != 0
+
+	lenByte := in[tagEnd]
+	pos := tagEnd + 1
+
+	if lenByte == 0x80 {
+		if !constructed {
+			return nil, nil, errMalformed
+		}
+		var body []byte
+		rest = in[pos:]
+		for {
+			if len(rest) < 2 {
+				return nil, nil, errBERTruncated
+			}
+			if rest[0] == 0 && rest[1] == 0 {
+				rest = rest[2:]
+				break
+			}
+			child, r, err := berElement(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			body = append(body, child...)
+			rest = r
+		}
+		return encodeTLV(tag, body), rest, nil
+	}
+
+	length := int(lenByte)
+	if lenByte&0x80 != 0 {
+		n := int(lenByte & 0x7f)
+		if n > 4 || pos+n > len(in) {
+			return nil, nil, errMalformed
+		}
+		length = 0
+		for _, c := range in[pos : pos+n] {
+			length = length<<8 | int(c)
+		}
+		pos += n
+	}
+	if length < 0 || pos+length > len(in) {
+		return nil, nil, errBERTruncated
+	}
+	content := in[pos : pos+length]
+	rest = in[pos+length:]
+
+	if !constructed {
+		return encodeTLV(tag, content), rest, nil
+	}
+	var body []byte
+	for len(content) > 0 {
+		child, r, err := berElement(content, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		body = append(body, child...)
+		content = r
+	}
+	return encodeTLV(tag, body), rest, nil
+}
+
+func encodeTLV(tag, body []byte) []byte {
+	out := make([]byte, 0, len(tag)+6+len(body))
+	out = append(out, tag...)
+	n := len(body)
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	case n <= 0xffffff:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x84, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, body...)
+}
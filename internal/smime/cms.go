@@ -0,0 +1,788 @@
+// Package smime implements the parts of CMS (RFC 5652) needed for S/MIME
+// mail: detached SignedData, RSA key-transport EnvelopedData, and a local
+// store of identities and recipient certificates.
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des" //nolint:gosec // 3DES is only used to read legacy messages
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" //nolint:gosec // RSA-OAEP default parameters and legacy digests
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"sort"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+	casn1 "golang.org/x/crypto/cryptobyte/asn1"
+)
+
+var (
+	oidData             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidEnvelopedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidAttrContentType  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigst = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidAttrSigningTime  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAOAEP         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	oidSHA1WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECPublicKey     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+var (
+	ErrNoRecipient  = errors.New("message is not encrypted to this key")
+	ErrBadSignature = errors.New("signature does not match content")
+
+	errMalformed          = errors.New("malformed CMS structure")
+	errNotSignedData      = errors.New("not a CMS SignedData object")
+	errNotEnvelopedData   = errors.New("not a CMS EnvelopedData object")
+	errNoSigner           = errors.New("signature has no signer")
+	errSignerCertMissing  = errors.New("signer certificate not included in signature")
+	errMissingDigest      = errors.New("signed attributes lack a message digest")
+	errUnsupportedDigest  = errors.New("unsupported digest algorithm")
+	errUnsupportedSigAlg  = errors.New("unsupported signature algorithm")
+	errUnsupportedCipher  = errors.New("unsupported content encryption algorithm")
+	errUnsupportedKeyAlg  = errors.New("unsupported key encryption algorithm")
+	errUnsupportedKeyType = errors.New("unsupported key type")
+	errNoRecipients       = errors.New("no recipients")
+	errBadPadding         = errors.New("invalid padding")
+	errNoContent          = errors.New("signature has no content")
+)
+
+var (
+	tagContext0  = casn1.Tag(0).ContextSpecific()
+	tagContext0C = casn1.Tag(0).ContextSpecific().Constructed()
+	tagContext1C = casn1.Tag(1).ContextSpecific().Constructed()
+	tagOctetsC   = casn1.OCTET_STRING.Constructed()
+)
+
+// Signature describes a verified SignedData object.
+type Signature struct {
+	Signer       *x509.Certificate
+	Certificates []*x509.Certificate
+	SigningTime  time.Time
+	// Content is the encapsulated content for opaque (non-detached) signatures.
+	Content []byte
+}
+
+// VerifyChain checks the signer certificate against roots (the system pool
+// when nil) for e-mail protection, using the other included certificates as
+// intermediates.
+func (s *Signature) VerifyChain(roots *x509.CertPool, at time.Time) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range s.Certificates {
+		if c != s.Signer {
+			intermediates.AddCert(c)
+		}
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	_, err := s.Signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	})
+	if err != nil {
+		return fmt.Errorf("verify certificate chain: %w", err)
+	}
+	return nil
+}
+
+// Sign returns a detached SHA-256 SignedData object over content, including
+// the signer certificate and chain.
+func Sign(content []byte, id *Identity, now time.Time) ([]byte, error) {
+	sigAlg, err := signatureAlgorithmFor(id.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(content)
+	attrs, err := signedAttributes(digest[:], now)
+	if err != nil {
+		return nil, err
+	}
+	attrsDigest := sha256.Sum256(attrs)
+	sig, err := id.Key.Sign(rand.Reader, attrsDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+
+	// Signed attributes are signed as a SET but stored as [0] IMPLICIT.
+	taggedAttrs := append([]byte{byte(tagContext0C)}, attrs[1:]...)
+
+	var b cryptobyte.Builder
+	b.AddASN1(casn1.SEQUENCE, func(ci *cryptobyte.Builder) {
+		ci.AddASN1ObjectIdentifier(oidSignedData)
+		ci.AddASN1(tagContext0C, func(e *cryptobyte.Builder) {
+			e.AddASN1(casn1.SEQUENCE, func(sd *cryptobyte.Builder) {
+				sd.AddASN1Int64(1)
+				sd.AddASN1(casn1.SET, func(s *cryptobyte.Builder) {
+					addAlgorithm(s, oidSHA256, false)
+				})
+				sd.AddASN1(casn1.SEQUENCE, func(eci *cryptobyte.Builder) {
+					eci.AddASN1ObjectIdentifier(oidData)
+				})
+				sd.AddASN1(tagContext0C, func(certs *cryptobyte.Builder) {
+					certs.AddBytes(id.Certificate.Raw)
+					for _, c := range id.Chain {
+						certs.AddBytes(c.Raw)
+					}
+				})
+				sd.AddASN1(casn1.SET, func(infos *cryptobyte.Builder) {
+					infos.AddASN1(casn1.SEQUENCE, func(si *cryptobyte.Builder) {
+						si.AddASN1Int64(1)
+						addIssuerAndSerial(si, id.Certificate)
+						addAlgorithm(si, oidSHA256, false)
+						si.AddBytes(taggedAttrs)
+						addAlgorithm(si, sigAlg, sigAlg.Equal(oidRSAEncryption))
+						si.AddASN1OctetString(sig)
+					})
+				})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// Verify checks a SignedData object. For detached signatures content is the
+// signed data; pass nil to verify an opaque signature against its
+// encapsulated content. The signer certificate chain is not validated; see
+// Signature.VerifyChain.
+func Verify(der []byte, content []byte) (*Signature, error) {
+	der, err := berToDER(der)
+	if err != nil {
+		return nil, err
+	}
+	sd, err := readContentInfo(der, oidSignedData, errNotSignedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		version    int64
+		digestAlgs cryptobyte.String
+		eci        cryptobyte.String
+		eType      asn1.ObjectIdentifier
+	)
+	if !sd.ReadASN1Integer(&version) ||
+		!sd.ReadASN1(&digestAlgs, casn1.SET) ||
+		!sd.ReadASN1(&eci, casn1.SEQUENCE) ||
+		!eci.ReadASN1ObjectIdentifier(&eType) {
+		return nil, errMalformed
+	}
+	var eContent []byte
+	if eci.PeekASN1Tag(tagContext0C) {
+		var explicit cryptobyte.String
+		if !eci.ReadASN1(&explicit, tagContext0C) {
+			return nil, errMalformed
+		}
+		if eContent, err = readOctets(&explicit, casn1.OCTET_STRING, tagOctetsC); err != nil {
+			return nil, err
+		}
+	}
+
+	sig := &Signature{}
+	if sd.PeekASN1Tag(tagContext0C) {
+		var certs cryptobyte.String
+		if !sd.ReadASN1(&certs, tagContext0C) {
+			return nil, errMalformed
+		}
+		for !certs.Empty() {
+			var (
+				raw cryptobyte.String
+				tag casn1.Tag
+			)
+			if !certs.ReadAnyASN1Element(&raw, &tag) {
+				return nil, errMalformed
+			}
+			if tag != casn1.SEQUENCE {
+				continue // attribute or other certificate formats
+			}
+			c, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return nil, fmt.Errorf("parse signer certificate: %w", err)
+			}
+			sig.Certificates = append(sig.Certificates, c)
+		}
+	}
+	if !sd.SkipOptionalASN1(tagContext1C) {
+		return nil, errMalformed
+	}
+
+	var infos, si cryptobyte.String
+	if !sd.ReadASN1(&infos, casn1.SET) {
+		return nil, errMalformed
+	}
+	if infos.Empty() {
+		return nil, errNoSigner
+	}
+	if !infos.ReadASN1(&si, casn1.SEQUENCE) {
+		return nil, errMalformed
+	}
+
+	signer, err := readSignerIdentifier(&si, sig.Certificates)
+	if err != nil {
+		return nil, err
+	}
+	sig.Signer = signer
+
+	var digestAlg, sigAlg asn1.ObjectIdentifier
+	if err := readAlgorithm(&si, &digestAlg, nil); err != nil {
+		return nil, err
+	}
+	var signedAttrs cryptobyte.String
+	hasAttrs := si.PeekASN1Tag(tagContext0C)
+	if hasAttrs && !si.ReadASN1Element(&signedAttrs, tagContext0C) {
+		return nil, errMalformed
+	}
+	if err := readAlgorithm(&si, &sigAlg, nil); err != nil {
+		return nil, err
+	}
+	var signature []byte
+	if !si.ReadASN1Bytes(&signature, casn1.OCTET_STRING) {
+		return nil, errMalformed
+	}
+
+	if content == nil {
+		if eContent == nil {
+			return nil, errNoContent
+		}
+		content = eContent
+		sig.Content = eContent
+	}
+
+	newHash, err := hashFor(digestAlg)
+	if err != nil {
+		return nil, err
+	}
+	h := newHash()
+	h.Write(content)
+	contentDigest := h.Sum(nil)
+
+	signed := content
+	if hasAttrs {
+		digest, signingTime, err := parseSignedAttributes(signedAttrs)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(digest, contentDigest) {
+			return nil, ErrBadSignature
+		}
+		sig.SigningTime = signingTime
+		signed = append([]byte{byte(casn1.SET)}, signedAttrs[1:]...)
+	}
+
+	algo, err := x509SignatureAlgorithm(digestAlg, sigAlg)
+	if err != nil {
+		return nil, err
+	}
+	if err := signer.CheckSignature(algo, signed, signature); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadSignature, err)
+	}
+	return sig, nil
+}
+
+// Encrypt returns an EnvelopedData object with content encrypted using
+// AES-256-CBC, with the content key wrapped for each RSA recipient.
+func Encrypt(content []byte, recipients []*x509.Certificate) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errNoRecipients
+	}
+
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := pkcs7Pad(content, aes.BlockSize)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	wrapped := make([][]byte, 0, len(recipients))
+	for _, r := range recipients {
+		pub, ok := r.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: certificate for %s has a %T key; only RSA recipients are supported", errUnsupportedKeyType, r.Subject.CommonName, r.PublicKey)
+		}
+		ek, err := rsa.EncryptPKCS1v15(rand.Reader, pub, key)
+		if err != nil {
+			return nil, fmt.Errorf("wrap content key: %w", err)
+		}
+		wrapped = append(wrapped, ek)
+	}
+
+	var b cryptobyte.Builder
+	b.AddASN1(casn1.SEQUENCE, func(ci *cryptobyte.Builder) {
+		ci.AddASN1ObjectIdentifier(oidEnvelopedData)
+		ci.AddASN1(tagContext0C, func(e *cryptobyte.Builder) {
+			e.AddASN1(casn1.SEQUENCE, func(ed *cryptobyte.Builder) {
+				ed.AddASN1Int64(0)
+				ed.AddASN1(casn1.SET, func(ris *cryptobyte.Builder) {
+					for i, r := range recipients {
+						ris.AddASN1(casn1.SEQUENCE, func(ktri *cryptobyte.Builder) {
+							ktri.AddASN1Int64(0)
+							addIssuerAndSerial(ktri, r)
+							addAlgorithm(ktri, oidRSAEncryption, true)
+							ktri.AddASN1OctetString(wrapped[i])
+						})
+					}
+				})
+				ed.AddASN1(casn1.SEQUENCE, func(eci *cryptobyte.Builder) {
+					eci.AddASN1ObjectIdentifier(oidData)
+					eci.AddASN1(casn1.SEQUENCE, func(alg *cryptobyte.Builder) {
+						alg.AddASN1ObjectIdentifier(oidAES256CBC)
+						alg.AddASN1OctetString(iv)
+					})
+					eci.AddASN1(tagContext0, func(c *cryptobyte.Builder) {
+						c.AddBytes(ciphertext)
+					})
+				})
+			})
+		})
+	})
+	return b.Bytes()
+}
+
+// Decrypt opens an EnvelopedData object addressed to cert. It returns
+// ErrNoRecipient when the message was not encrypted to that certificate.
+func Decrypt(der []byte, cert *x509.Certificate, key crypto.PrivateKey) ([]byte, error) {
+	der, err := berToDER(der)
+	if err != nil {
+		return nil, err
+	}
+	ed, err := readContentInfo(der, oidEnvelopedData, errNotEnvelopedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		version int64
+		ris     cryptobyte.String
+		eci     cryptobyte.String
+	)
+	if !ed.ReadASN1Integer(&version) ||
+		!ed.SkipOptionalASN1(tagContext0C) ||
+		!ed.ReadASN1(&ris, casn1.SET) ||
+		!ed.ReadASN1(&eci, casn1.SEQUENCE) {
+		return nil, errMalformed
+	}
+
+	var (
+		encryptedKey []byte
+		keyAlg       asn1.ObjectIdentifier
+	)
+	for !ris.Empty() {
+		var (
+			ri  cryptobyte.String
+			tag casn1.Tag
+		)
+		if !ris.ReadAnyASN1(&ri, &tag) {
+			return nil, errMalformed
+		}
+		if tag != casn1.SEQUENCE {
+			continue // key agreement, KEK, and password recipients
+		}
+		var riVersion int64
+		if !ri.ReadASN1Integer(&riVersion) {
+			return nil, errMalformed
+		}
+		match, err := matchRecipientIdentifier(&ri, cert)
+		if err != nil {
+			return nil, err
+		}
+		var alg asn1.ObjectIdentifier
+		if err := readAlgorithm(&ri, &alg, nil); err != nil {
+			return nil, err
+		}
+		var ek []byte
+		if !ri.ReadASN1Bytes(&ek, casn1.OCTET_STRING) {
+			return nil, errMalformed
+		}
+		if match {
+			encryptedKey, keyAlg = ek, alg
+			break
+		}
+	}
+	if encryptedKey == nil {
+		return nil, ErrNoRecipient
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errUnsupportedKeyType, key)
+	}
+	var contentKey []byte
+	switch {
+	case keyAlg.Equal(oidRSAEncryption):
+		contentKey, err = rsa.DecryptPKCS1v15(nil, rsaKey, encryptedKey)
+	case keyAlg.Equal(oidRSAOAEP):
+		contentKey, err = rsa.DecryptOAEP(sha1.New(), nil, rsaKey, encryptedKey, nil) //nolint:gosec // RFC 3560 default parameters
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedKeyAlg, keyAlg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unwrap content key: %w", err)
+	}
+
+	var (
+		contentType asn1.ObjectIdentifier
+		cipherAlg   asn1.ObjectIdentifier
+		iv          []byte
+	)
+	if !eci.ReadASN1ObjectIdentifier(&contentType) {
+		return nil, errMalformed
+	}
+	if err := readAlgorithm(&eci, &cipherAlg, &iv); err != nil {
+		return nil, err
+	}
+	ciphertext, err := readOctets(&eci, tagContext0, tagContext0C)
+	if err != nil {
+		return nil, err
+	}
+
+	var block cipher.Block
+	switch {
+	case cipherAlg.Equal(oidAES128CBC), cipherAlg.Equal(oidAES192CBC), cipherAlg.Equal(oidAES256CBC):
+		block, err = aes.NewCipher(contentKey)
+	case cipherAlg.Equal(oidDESEDE3CBC):
+		block, err = des.NewTripleDESCipher(contentKey) //nolint:gosec // legacy messages
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedCipher, cipherAlg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("content key: %w", err)
+	}
+	if len(iv) != block.BlockSize() || len(ciphertext) == 0 || len(ciphertext)%block.BlockSize() != 0 {
+		return nil, errMalformed
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	return pkcs7Unpad(plaintext, block.BlockSize())
+}
+
+func readContentInfo(der []byte, want asn1.ObjectIdentifier, wrongType error) (cryptobyte.String, error) {
+	input := cryptobyte.String(der)
+	var (
+		ci, explicit, inner cryptobyte.String
+		contentType         asn1.ObjectIdentifier
+	)
+	if !input.ReadASN1(&ci, casn1.SEQUENCE) || !ci.ReadASN1ObjectIdentifier(&contentType) {
+		return nil, errMalformed
+	}
+	if !contentType.Equal(want) {
+		return nil, wrongType
+	}
+	if !ci.ReadASN1(&explicit, tagContext0C) || !explicit.ReadASN1(&inner, casn1.SEQUENCE) {
+		return nil, errMalformed
+	}
+	return inner, nil
+}
+
+func readSignerIdentifier(si *cryptobyte.String, certs []*x509.Certificate) (*x509.Certificate, error) {
+	var version int64
+	if !si.ReadASN1Integer(&version) {
+		return nil, errMalformed
+	}
+	for _, c := range certs {
+		probe := *si
+		match, err := matchRecipientIdentifier(&probe, c)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			*si = probe
+			return c, nil
+		}
+	}
+	return nil, errSignerCertMissing
+}
+
+// matchRecipientIdentifier consumes an IssuerAndSerialNumber or [0]
+// SubjectKeyIdentifier and reports whether it names cert.
+func matchRecipientIdentifier(s *cryptobyte.String, cert *x509.Certificate) (bool, error) {
+	if s.PeekASN1Tag(tagContext0) {
+		var ski []byte
+		if !s.ReadASN1Bytes(&ski, tagContext0) {
+			return false, errMalformed
+		}
+		return len(cert.SubjectKeyId) > 0 && bytes.Equal(ski, cert.SubjectKeyId), nil
+	}
+	var (
+		ias    cryptobyte.String
+		issuer cryptobyte.String
+		serial = new(big.Int)
+	)
+	if !s.ReadASN1(&ias, casn1.SEQUENCE) ||
+		!ias.ReadASN1Element(&issuer, casn1.SEQUENCE) ||
+		!ias.ReadASN1Integer(serial) {
+		return false, errMalformed
+	}
+	return bytes.Equal(issuer, cert.RawIssuer) && serial.Cmp(cert.SerialNumber) == 0, nil
+}
+
+// readAlgorithm reads an AlgorithmIdentifier. When params is non-nil the
+// parameters must be an OCTET STRING (a CBC IV).
+func readAlgorithm(s *cryptobyte.String, oid *asn1.ObjectIdentifier, params *[]byte) error {
+	var alg cryptobyte.String
+	if !s.ReadASN1(&alg, casn1.SEQUENCE) || !alg.ReadASN1ObjectIdentifier(oid) {
+		return errMalformed
+	}
+	if params != nil && !alg.ReadASN1Bytes(params, casn1.OCTET_STRING) {
+		return errMalformed
+	}
+	return nil
+}
+
+// readOctets reads a primitive string with tag prim or a BER constructed
+// string with tag cons, concatenating the segments.
+func readOctets(s *cryptobyte.String, prim, cons casn1.Tag) ([]byte, error) {
+	var out []byte
+	switch {
+	case s.PeekASN1Tag(prim):
+		if !s.ReadASN1Bytes(&out, prim) {
+			return nil, errMalformed
+		}
+	case s.PeekASN1Tag(cons):
+		var segments cryptobyte.String
+		if !s.ReadASN1(&segments, cons) {
+			return nil, errMalformed
+		}
+		for !segments.Empty() {
+			seg, err := readOctets(&segments, casn1.OCTET_STRING, tagOctetsC)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, seg...)
+		}
+	default:
+		return nil, errMalformed
+	}
+	return out, nil
+}
+
+func parseSignedAttributes(tagged cryptobyte.String) (digest []byte, signingTime time.Time, err error) {
+	var attrs cryptobyte.String
+	if !tagged.ReadASN1(&attrs, tagContext0C) {
+		return nil, time.Time{}, errMalformed
+	}
+	for !attrs.Empty() {
+		var (
+			attr, values cryptobyte.String
+			oid          asn1.ObjectIdentifier
+		)
+		if !attrs.ReadASN1(&attr, casn1.SEQUENCE) ||
+			!attr.ReadASN1ObjectIdentifier(&oid) ||
+			!attr.ReadASN1(&values, casn1.SET) {
+			return nil, time.Time{}, errMalformed
+		}
+		switch {
+		case oid.Equal(oidAttrMessageDigst):
+			if !values.ReadASN1Bytes(&digest, casn1.OCTET_STRING) {
+				return nil, time.Time{}, errMalformed
+			}
+		case oid.Equal(oidAttrSigningTime):
+			switch {
+			case values.PeekASN1Tag(casn1.UTCTime):
+				_ = values.ReadASN1UTCTime(&signingTime)
+			case values.PeekASN1Tag(casn1.GeneralizedTime):
+				_ = values.ReadASN1GeneralizedTime(&signingTime)
+			}
+		}
+	}
+	if digest == nil {
+		return nil, time.Time{}, errMissingDigest
+	}
+	return digest, signingTime, nil
+}
+
+func signedAttributes(digest []byte, now time.Time) ([]byte, error) {
+	attr := func(oid asn1.ObjectIdentifier, value func(*cryptobyte.Builder)) ([]byte, error) {
+		var b cryptobyte.Builder
+		b.AddASN1(casn1.SEQUENCE, func(a *cryptobyte.Builder) {
+			a.AddASN1ObjectIdentifier(oid)
+			a.AddASN1(casn1.SET, value)
+		})
+		return b.Bytes()
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	parts := make([][]byte, 0, 3)
+	for _, build := range []func() ([]byte, error){
+		func() ([]byte, error) {
+			return attr(oidAttrContentType, func(v *cryptobyte.Builder) { v.AddASN1ObjectIdentifier(oidData) })
+		},
+		func() ([]byte, error) {
+			return attr(oidAttrSigningTime, func(v *cryptobyte.Builder) {
+				if now.Year() >= 2050 {
+					v.AddASN1GeneralizedTime(now)
+					return
+				}
+				v.AddASN1UTCTime(now)
+			})
+		},
+		func() ([]byte, error) {
+			return attr(oidAttrMessageDigst, func(v *cryptobyte.Builder) { v.AddASN1OctetString(digest) })
+		},
+	} {
+		p, err := build()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, p)
+	}
+	// DER requires SET OF members in ascending byte order.
+	sort.Slice(parts, func(i, j int) bool { return bytes.Compare(parts[i], parts[j]) < 0 })
+
+	var b cryptobyte.Builder
+	b.AddASN1(casn1.SET, func(set *cryptobyte.Builder) {
+		for _, p := range parts {
+			set.AddBytes(p)
+		}
+	})
+	return b.Bytes()
+}
+
+func addAlgorithm(b *cryptobyte.Builder, oid asn1.ObjectIdentifier, nullParams bool) {
+	b.AddASN1(casn1.SEQUENCE, func(alg *cryptobyte.Builder) {
+		alg.AddASN1ObjectIdentifier(oid)
+		if nullParams {
+			alg.AddASN1NULL()
+		}
+	})
+}
+
+func addIssuerAndSerial(b *cryptobyte.Builder, cert *x509.Certificate) {
+	b.AddASN1(casn1.SEQUENCE, func(ias *cryptobyte.Builder) {
+		ias.AddBytes(cert.RawIssuer)
+		ias.AddASN1BigInt(cert.SerialNumber)
+	})
+}
+
+func signatureAlgorithmFor(key crypto.Signer) (asn1.ObjectIdentifier, error) {
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		return oidRSAEncryption, nil
+	case *ecdsa.PublicKey:
+		return oidECDSAWithSHA256, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", errUnsupportedKeyType, key.Public())
+	}
+}
+
+func hashFor(oid asn1.ObjectIdentifier) (func() hash.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA256):
+		return sha256.New, nil
+	case oid.Equal(oidSHA384):
+		return sha512.New384, nil
+	case oid.Equal(oidSHA512):
+		return sha512.New, nil
+	case oid.Equal(oidSHA1):
+		return sha1.New, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedDigest, oid)
+	}
+}
+
+func x509SignatureAlgorithm(digest, sig asn1.ObjectIdentifier) (x509.SignatureAlgorithm, error) {
+	switch {
+	case sig.Equal(oidRSAEncryption):
+		switch {
+		case digest.Equal(oidSHA256):
+			return x509.SHA256WithRSA, nil
+		case digest.Equal(oidSHA384):
+			return x509.SHA384WithRSA, nil
+		case digest.Equal(oidSHA512):
+			return x509.SHA512WithRSA, nil
+		case digest.Equal(oidSHA1):
+			return x509.SHA1WithRSA, nil
+		}
+	case sig.Equal(oidECPublicKey):
+		switch {
+		case digest.Equal(oidSHA256):
+			return x509.ECDSAWithSHA256, nil
+		case digest.Equal(oidSHA384):
+			return x509.ECDSAWithSHA384, nil
+		case digest.Equal(oidSHA512):
+			return x509.ECDSAWithSHA512, nil
+		case digest.Equal(oidSHA1):
+			return x509.ECDSAWithSHA1, nil
+		}
+	case sig.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case sig.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case sig.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case sig.Equal(oidSHA1WithRSA):
+		return x509.SHA1WithRSA, nil
+	case sig.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case sig.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case sig.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("%w: %s with %s", errUnsupportedSigAlg, sig, digest)
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	n := blockSize - len(data)%blockSize
+	out := make([]byte, len(data), len(data)+n)
+	copy(out, data)
+	return append(out, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errBadPadding
+	}
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, errBadPadding
+	}
+	for _, c := range data[len(data)-n:] {
+		if int(c) != n {
+			return nil, errBadPadding
+		}
+	}
+	return data[:len(data)-n], nil
+}
@@ -0,0 +1,230 @@
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestIdentity(t *testing.T, email string, key crypto.Signer) *Identity {
+	t.Helper()
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: email},
+		EmailAddresses: []string{email},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(24 * time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return &Identity{Email: email, Certificate: cert, Key: key}
+}
+
+func newRSAIdentity(t *testing.T, email string) *Identity {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	return newTestIdentity(t, email, key)
+}
+
+func identityPEM(t *testing.T, id *Identity) []byte {
+	t.Helper()
+	data, err := encodePEMIdentity(id)
+	if err != nil {
+		t.Fatalf("encodePEMIdentity: %v", err)
+	}
+	return data
+}
+
+func setupStoreEnv(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "xdg"))
+	t.Setenv("GOG_KEYRING_BACKEND", "file")
+	t.Setenv("GOG_KEYRING_PASSWORD", "testpass")
+}
+
+func TestSignVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	for name, id := range map[string]*Identity{
+		"rsa":   newRSAIdentity(t, "rsa@example.com"),
+		"ecdsa": newTestIdentity(t, "ec@example.com", ecKey),
+	} {
+		t.Run(name, func(t *testing.T) {
+			content := []byte("Content-Type: text/plain\r\n\r\nhello\r\n")
+			now := time.Now().UTC().Truncate(time.Second)
+			sig, err := Sign(content, id, now)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			info, err := Verify(sig, content)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if !info.Signer.Equal(id.Certificate) || !info.SigningTime.Equal(now) {
+				t.Fatalf("unexpected signature info: %+v", info)
+			}
+
+			if _, err := Verify(sig, []byte("tampered")); !errors.Is(err, ErrBadSignature) {
+				t.Fatalf("expected bad signature, got %v", err)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AddCert(id.Certificate)
+			if err := info.VerifyChain(roots, now); err != nil {
+				t.Fatalf("VerifyChain: %v", err)
+			}
+			if err := info.VerifyChain(x509.NewCertPool(), now); err == nil {
+				t.Fatalf("expected untrusted chain error")
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	alice := newRSAIdentity(t, "alice@example.com")
+	bob := newRSAIdentity(t, "bob@example.com")
+	carol := newRSAIdentity(t, "carol@example.com")
+
+	content := []byte("secret entity\r\n")
+	der, err := Encrypt(content, []*x509.Certificate{alice.Certificate, bob.Certificate})
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	for _, id := range []*Identity{alice, bob} {
+		got, err := Decrypt(der, id.Certificate, id.Key)
+		if err != nil {
+			t.Fatalf("Decrypt(%s): %v", id.Email, err)
+		}
+		if !bytes.Equal(got, content) {
+			t.Fatalf("Decrypt(%s) = %q", id.Email, got)
+		}
+	}
+	if _, err := Decrypt(der, carol.Certificate, carol.Key); !errors.Is(err, ErrNoRecipient) {
+		t.Fatalf("expected ErrNoRecipient, got %v", err)
+	}
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ec := newTestIdentity(t, "ec@example.com", ecKey)
+	if _, err := Encrypt(content, []*x509.Certificate{ec.Certificate}); err == nil {
+		t.Fatalf("expected EC recipient to be rejected")
+	}
+}
+
+func TestBERToDER_IndefiniteLength(t *testing.T) {
+	// SEQUENCE (indefinite) { OCTET STRING (constructed, indefinite) { "ab", "c" } }
+	ber := []byte{0x30, 0x80, 0x24, 0x80, 0x04, 0x02, 'a', 'b', 0x04, 0x01, 'c', 0x00, 0x00, 0x00, 0x00}
+	der, err := berToDER(ber)
+	if err != nil {
+		t.Fatalf("berToDER: %v", err)
+	}
+	want := []byte{0x30, 0x09, 0x24, 0x07, 0x04, 0x02, 'a', 'b', 0x04, 0x01, 'c'}
+	if !bytes.Equal(der, want) {
+		t.Fatalf("berToDER = % x, want % x", der, want)
+	}
+	if _, err := berToDER([]byte{0x30, 0x80, 0x04}); err == nil {
+		t.Fatalf("expected truncated input error")
+	}
+}
+
+func TestStore(t *testing.T) {
+	setupStoreEnv(t)
+
+	me := newRSAIdentity(t, "Me@Example.com")
+	id, err := ImportIdentity(identityPEM(t, me), nil, "", "")
+	if err != nil {
+		t.Fatalf("ImportIdentity: %v", err)
+	}
+	if id.Email != "me@example.com" {
+		t.Fatalf("unexpected email %q", id.Email)
+	}
+	loaded, err := LoadIdentity("me@example.com")
+	if err != nil {
+		t.Fatalf("LoadIdentity: %v", err)
+	}
+	if !loaded.Certificate.Equal(me.Certificate) {
+		t.Fatalf("loaded identity does not match")
+	}
+
+	bob := newRSAIdentity(t, "bob@example.com")
+	entries, err := AddCertificates(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: bob.Certificate.Raw}))
+	if err != nil || len(entries) != 1 || entries[0].Email != "bob@example.com" {
+		t.Fatalf("AddCertificates = %+v, %v", entries, err)
+	}
+	if c, err := LoadCertificate("BOB@example.com"); err != nil || !c.Equal(bob.Certificate) {
+		t.Fatalf("LoadCertificate(bob) = %v, %v", c, err)
+	}
+	if c, err := LoadCertificate("me@example.com"); err != nil || !c.Equal(me.Certificate) {
+		t.Fatalf("LoadCertificate(me) should fall back to the identity: %v, %v", c, err)
+	}
+	if _, err := LoadCertificate("nobody@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	list, err := List()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("List = %+v, %v", list, err)
+	}
+	if list[0].Email != "bob@example.com" || list[0].Kind != KindCertificate || list[1].Kind != KindIdentity {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+
+	if n, err := Remove("me@example.com"); err != nil || n != 1 {
+		t.Fatalf("Remove = %d, %v", n, err)
+	}
+	if _, err := LoadIdentity("me@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected identity removed, got %v", err)
+	}
+	if _, err := LoadIdentity("../x@example.com"); err == nil {
+		t.Fatalf("expected invalid address error")
+	}
+}
+
+func TestParseIdentity_Errors(t *testing.T) {
+	me := newRSAIdentity(t, "me@example.com")
+	other := newRSAIdentity(t, "other@example.com")
+
+	certOnly := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: me.Certificate.Raw})
+	if _, err := ParseIdentity(certOnly, nil, ""); !errors.Is(err, errNoPrivateKey) {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(other.Key)
+	wrongKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if _, err := ParseIdentity(certOnly, wrongKey, ""); !errors.Is(err, errKeyMismatch) {
+		t.Fatalf("expected key mismatch, got %v", err)
+	}
+
+	encrypted := pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte{1}})
+	if _, err := ParseIdentity(certOnly, encrypted, ""); !errors.Is(err, errEncryptedPEM) {
+		t.Fatalf("expected encrypted PEM error, got %v", err)
+	}
+}
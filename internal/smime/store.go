@@ -0,0 +1,563 @@
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/99designs/keyring"
+	"golang.org/x/crypto/pkcs12"
+
+	"github.com/steipete/gogcli/internal/config"
+	"github.com/steipete/gogcli/internal/secrets"
+)
+
+const (
+	KindIdentity    = "identity"
+	KindCertificate = "certificate"
+
+	keysDir  = "keys"
+	certsDir = "certs"
+	extP12   = ".p12"
+	extPEM   = ".pem"
+)
+
+var oidEmailAddress = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+var (
+	// ErrNotFound is returned when no identity or certificate is stored for an address.
+	ErrNotFound = errors.New("no S/MIME key or certificate")
+
+	errNoCertificate = errors.New("no certificate found")
+	errNoPrivateKey  = errors.New("no private key found")
+	errKeyMismatch   = errors.New("private key does not match any certificate")
+	errNoEmail       = errors.New("certificate has no e-mail address")
+	errEncryptedPEM  = errors.New("encrypted PEM private keys are not supported; export a PKCS#12 file instead")
+	errInvalidEmail  = errors.New("invalid e-mail address")
+)
+
+// Identity is a signing/decryption certificate with its private key.
+type Identity struct {
+	Email       string
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	Key         crypto.Signer
+}
+
+// Entry describes a stored identity or recipient certificate.
+type Entry struct {
+	Email    string
+	Kind     string
+	Subject  string
+	Issuer   string
+	NotAfter time.Time
+	Path     string
+}
+
+// ParseIdentity reads a PKCS#12 bundle or PEM certificate(s) with a private
+// key. keyData may hold the key separately for PEM input.
+func ParseIdentity(data, keyData []byte, passphrase string) (*Identity, error) {
+	var (
+		certs []*x509.Certificate
+		key   crypto.Signer
+		err   error
+	)
+	if isPEM(data) {
+		certs, key, err = parsePEMBundle(append(append([]byte{}, data...), keyData...))
+	} else {
+		certs, key, err = parsePKCS12(data, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errNoCertificate
+	}
+	if key == nil {
+		return nil, errNoPrivateKey
+	}
+
+	id := &Identity{Key: key}
+	for _, c := range certs {
+		if id.Certificate == nil && publicKeysEqual(c.PublicKey, key.Public()) {
+			id.Certificate = c
+			continue
+		}
+		id.Chain = append(id.Chain, c)
+	}
+	if id.Certificate == nil {
+		return nil, errKeyMismatch
+	}
+	id.Email = firstEmail(id.Certificate)
+	return id, nil
+}
+
+// ParseCertificates reads PEM or DER encoded certificates.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if !isPEM(data) {
+		certs, err := x509.ParseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		return certs, nil
+	}
+	certs, _, err := parsePEMBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errNoCertificate
+	}
+	return certs, nil
+}
+
+// CertificateEmails returns the lower-cased addresses a certificate covers.
+func CertificateEmails(c *x509.Certificate) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(v string) {
+		v = strings.ToLower(strings.TrimSpace(v))
+		if v != "" && !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	for _, e := range c.EmailAddresses {
+		add(e)
+	}
+	for _, n := range c.Subject.Names {
+		if n.Type.Equal(oidEmailAddress) {
+			if s, ok := n.Value.(string); ok {
+				add(s)
+			}
+		}
+	}
+	return out
+}
+
+// ImportIdentity validates and stores an identity for email (default: the
+// certificate address). PKCS#12 files are kept as-is with the passphrase in
+// the secrets store; PEM input is stored as a 0600 bundle.
+func ImportIdentity(data, keyData []byte, passphrase, email string) (*Identity, error) {
+	id, err := ParseIdentity(data, keyData, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(email) != "" {
+		id.Email = strings.ToLower(strings.TrimSpace(email))
+	}
+	if id.Email == "" {
+		return nil, errNoEmail
+	}
+	dir, err := ensureSubdir(keysDir)
+	if err != nil {
+		return nil, err
+	}
+	base, err := fileBase(id.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	if isPEM(data) {
+		bundle, err := encodePEMIdentity(id)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, base+extPEM), bundle, 0o600); err != nil {
+			return nil, fmt.Errorf("write identity: %w", err)
+		}
+		_ = os.Remove(filepath.Join(dir, base+extP12))
+		if err := secrets.DeleteSecret(passphraseKey(id.Email)); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, base+extP12), data, 0o600); err != nil {
+		return nil, fmt.Errorf("write identity: %w", err)
+	}
+	_ = os.Remove(filepath.Join(dir, base+extPEM))
+	if passphrase != "" {
+		if err := secrets.SetSecret(passphraseKey(id.Email), []byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("store passphrase: %w", err)
+		}
+	} else if err := secrets.DeleteSecret(passphraseKey(id.Email)); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// LoadIdentity returns the stored identity for email or ErrNotFound.
+func LoadIdentity(email string) (*Identity, error) {
+	dir, err := subdir(keysDir)
+	if err != nil {
+		return nil, err
+	}
+	base, err := fileBase(email)
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, base+extP12)); err == nil {
+		passphrase, err := loadPassphrase(email)
+		if err != nil {
+			return nil, err
+		}
+		id, err := ParseIdentity(data, nil, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("load S/MIME identity for %s: %w", email, err)
+		}
+		id.Email = strings.ToLower(strings.TrimSpace(email))
+		return id, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, base+extPEM))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s", ErrNotFound, email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	id, err := ParseIdentity(data, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("load S/MIME identity for %s: %w", email, err)
+	}
+	id.Email = strings.ToLower(strings.TrimSpace(email))
+	return id, nil
+}
+
+// AddCertificates stores recipient certificates under each address they
+// cover and returns the stored entries.
+func AddCertificates(data []byte) ([]Entry, error) {
+	certs, err := ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ensureSubdir(certsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Entry
+	for _, c := range certs {
+		if issuesOther(c, certs) {
+			continue
+		}
+		for _, email := range CertificateEmails(c) {
+			base, err := fileBase(email)
+			if err != nil {
+				return nil, err
+			}
+			path := filepath.Join(dir, base+extPEM)
+			block := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+			if err := os.WriteFile(path, block, 0o600); err != nil {
+				return nil, fmt.Errorf("write certificate: %w", err)
+			}
+			out = append(out, entryFor(email, KindCertificate, c, path))
+		}
+	}
+	if len(out) == 0 {
+		return nil, errNoEmail
+	}
+	return out, nil
+}
+
+// issuesOther reports whether c signed another certificate in the bundle, so
+// chain certificates are skipped while self-signed leaves are still stored.
+func issuesOther(c *x509.Certificate, certs []*x509.Certificate) bool {
+	if !c.IsCA {
+		return false
+	}
+	for _, o := range certs {
+		if o != c && o.CheckSignatureFrom(c) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadCertificate returns the encryption certificate for email: a stored
+// recipient certificate, else the certificate of a local identity.
+func LoadCertificate(email string) (*x509.Certificate, error) {
+	dir, err := subdir(certsDir)
+	if err != nil {
+		return nil, err
+	}
+	base, err := fileBase(email)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, base+extPEM))
+	if err == nil {
+		certs, err := ParseCertificates(data)
+		if err != nil {
+			return nil, err
+		}
+		return certs[0], nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	id, err := LoadIdentity(email)
+	if err != nil {
+		return nil, err
+	}
+	return id.Certificate, nil
+}
+
+// List returns stored identities and certificates sorted by address. Files
+// that cannot be parsed without a passphrase are listed with empty details.
+func List() ([]Entry, error) {
+	var out []Entry
+	for _, kind := range []string{KindIdentity, KindCertificate} {
+		name := certsDir
+		if kind == KindIdentity {
+			name = keysDir
+		}
+		dir, err := subdir(name)
+		if err != nil {
+			return nil, err
+		}
+		files, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			ext := filepath.Ext(f.Name())
+			if f.IsDir() || (ext != extPEM && ext != extP12) {
+				continue
+			}
+			email := strings.TrimSuffix(f.Name(), ext)
+			path := filepath.Join(dir, f.Name())
+			entry := Entry{Email: email, Kind: kind, Path: path}
+			if kind == KindIdentity {
+				if id, err := LoadIdentity(email); err == nil {
+					entry = entryFor(email, kind, id.Certificate, path)
+				}
+			} else if c, err := LoadCertificate(email); err == nil {
+				entry = entryFor(email, kind, c, path)
+			}
+			out = append(out, entry)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Email < out[j].Email })
+	return out, nil
+}
+
+// Remove deletes the identity, passphrase, and recipient certificate stored
+// for email and returns the number of files removed.
+func Remove(email string) (int, error) {
+	base, err := fileBase(email)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, p := range [][2]string{{keysDir, extP12}, {keysDir, extPEM}, {certsDir, extPEM}} {
+		dir, err := subdir(p[0])
+		if err != nil {
+			return removed, err
+		}
+		err = os.Remove(filepath.Join(dir, base+p[1]))
+		if err == nil {
+			removed++
+			continue
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return removed, err
+		}
+	}
+	if removed > 0 {
+		if err := secrets.DeleteSecret(passphraseKey(email)); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func entryFor(email, kind string, c *x509.Certificate, path string) Entry {
+	return Entry{
+		Email:    email,
+		Kind:     kind,
+		Subject:  c.Subject.String(),
+		Issuer:   c.Issuer.String(),
+		NotAfter: c.NotAfter,
+		Path:     path,
+	}
+}
+
+func loadPassphrase(email string) (string, error) {
+	v, err := secrets.GetSecret(passphraseKey(email))
+	if err == nil {
+		return string(v), nil
+	}
+	if errors.Is(err, keyring.ErrKeyNotFound) || errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return "", fmt.Errorf("read S/MIME passphrase: %w", err)
+}
+
+func passphraseKey(email string) string {
+	return fmt.Sprintf("smime/%s/passphrase", strings.ToLower(strings.TrimSpace(email)))
+}
+
+func fileBase(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" || !strings.Contains(email, "@") || strings.ContainsAny(email, `/\`) || strings.HasPrefix(email, ".") {
+		return "", fmt.Errorf("%w: %q", errInvalidEmail, email)
+	}
+	return email, nil
+}
+
+func subdir(name string) (string, error) {
+	dir, err := config.SMIMEDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func ensureSubdir(name string) (string, error) {
+	dir, err := subdir(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("ensure S/MIME dir: %w", err)
+	}
+	return dir, nil
+}
+
+func firstEmail(c *x509.Certificate) string {
+	if emails := CertificateEmails(c); len(emails) > 0 {
+		return emails[0]
+	}
+	return ""
+}
+
+func isPEM(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "))
+}
+
+func parsePEMBundle(data []byte) ([]*x509.Certificate, crypto.Signer, error) {
+	var (
+		certs []*x509.Certificate
+		key   crypto.Signer
+	)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse certificate: %w", err)
+			}
+			certs = append(certs, c)
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, nil, errEncryptedPEM
+		case "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			if _, encrypted := block.Headers["DEK-Info"]; encrypted {
+				return nil, nil, errEncryptedPEM
+			}
+			k, err := parsePrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = k
+		}
+	}
+	return certs, key, nil
+}
+
+func parsePKCS12(data []byte, passphrase string) ([]*x509.Certificate, crypto.Signer, error) {
+	blocks, err := pkcs12.ToPEM(data, passphrase)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read PKCS#12 (legacy 3DES/RC2 encryption is required; re-export with `openssl pkcs12 -export -legacy`): %w", err)
+	}
+	var (
+		certs []*x509.Certificate
+		key   crypto.Signer
+	)
+	for _, b := range blocks {
+		switch b.Type {
+		case "CERTIFICATE":
+			c, err := x509.ParseCertificate(b.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parse certificate: %w", err)
+			}
+			certs = append(certs, c)
+		case "PRIVATE KEY":
+			// pkcs12.ToPEM emits PKCS#1 (RSA) or SEC 1 (EC) bytes here.
+			k, err := parsePrivateKey(b.Bytes)
+			if err != nil {
+				return nil, nil, err
+			}
+			key = k
+		}
+	}
+	return certs, key, nil
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if k, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		signer, ok := k.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", errUnsupportedKeyType, k)
+		}
+		return signer, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return k, nil
+	}
+	return nil, errNoPrivateKey
+}
+
+func encodePEMIdentity(id *Identity) ([]byte, error) {
+	var b bytes.Buffer
+	for _, c := range append([]*x509.Certificate{id.Certificate}, id.Chain...) {
+		if err := pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: c.Raw}); err != nil {
+			return nil, err
+		}
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(id.Key)
+	if err != nil {
+		return nil, fmt.Errorf("encode private key: %w", err)
+	}
+	if err := pem.Encode(&b, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	switch pa := a.(type) {
+	case *rsa.PublicKey:
+		return pa.Equal(b)
+	case *ecdsa.PublicKey:
+		return pa.Equal(b)
+	default:
+		return false
+	}
+}
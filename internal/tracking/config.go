@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -171,6 +172,35 @@ func SaveConfig(account string, cfg *Config) error {
 	return nil
 }
 
+// ListAccounts returns the accounts that have tracking configuration on disk.
+func ListAccounts() ([]string, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok, err := readConfigBytes(path)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var fileCfg fileConfig
+	if err := json.Unmarshal(data, &fileCfg); err != nil {
+		return nil, fmt.Errorf("parse tracking config: %w", err)
+	}
+
+	out := make([]string, 0, len(fileCfg.Accounts))
+	for account := range fileCfg.Accounts {
+		if account = normalizeAccount(account); account != "" {
+			out = append(out, account)
+		}
+	}
+
+	sort.Strings(out)
+
+	return out, nil
+}
+
 // IsConfigured returns true if tracking is set up.
 func (c *Config) IsConfigured() bool {
 	return c.Enabled && c.WorkerURL != "" && c.TrackingKey != ""
@@ -121,3 +121,28 @@ func TestLoadConfigDifferentAccount(t *testing.T) {
 		t.Error("Expected Enabled to be false for missing account config")
 	}
 }
+
+func TestListAccounts(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(tmpDir, "xdg-config"))
+
+	if got, err := ListAccounts(); err != nil || len(got) != 0 {
+		t.Fatalf("expected no accounts, got %v err=%v", got, err)
+	}
+
+	for _, account := range []string{"b@example.com", "A@example.com"} {
+		if err := SaveConfig(account, &Config{Enabled: true, WorkerURL: "https://test.workers.dev"}); err != nil {
+			t.Fatalf("SaveConfig failed: %v", err)
+		}
+	}
+
+	got, err := ListAccounts()
+	if err != nil {
+		t.Fatalf("ListAccounts failed: %v", err)
+	}
+
+	if strings.Join(got, ",") != "a@example.com,b@example.com" {
+		t.Fatalf("unexpected accounts: %v", got)
+	}
+}